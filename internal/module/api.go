@@ -57,6 +57,7 @@ func NewApi(ServerContext *context.ServerContext) (api *Api, err error) {
 		logService:             module_log.NewLogService(ServerContext),
 		apiCache:               make(map[string]*base.ApiWorker),
 	}
+	api.databaseBackupService = module_database.NewBackupService(api.toolboxService, api.nodeService)
 	var apis []*base.ApiWorker
 	apis, err = api.GetApis()
 	if err != nil {
@@ -100,6 +101,10 @@ func NewApi(ServerContext *context.ServerContext) (api *Api, err error) {
 	if err != nil {
 		return
 	}
+	err = api.databaseBackupService.ServerReady()
+	if err != nil {
+		return
+	}
 
 	return
 }
@@ -127,6 +132,7 @@ type Api struct {
 	toolboxService         *module_toolbox.ToolboxService
 	nodeService            *module_node.NodeService
 	terminalCommandService *module_terminal.TerminalCommandService
+	databaseBackupService  *module_database.BackupService
 	userService            *module_user.UserService
 	userSettingService     *module_user.UserSettingService
 	registerService        *module_register.RegisterService
@@ -174,7 +180,7 @@ func (this_ *Api) GetApis() (apis []*base.ApiWorker, err error) {
 	apis = append(apis, module_terminal.NewApi(this_.toolboxService, this_.nodeService, this_.terminalCommandService).GetApis()...)
 	apis = append(apis, module_user.NewApi(this_.userService).GetApis()...)
 	apis = append(apis, module_redis.NewApi(this_.toolboxService).GetApis()...)
-	apis = append(apis, module_database.NewApi(this_.toolboxService).GetApis()...)
+	apis = append(apis, module_database.NewApi(this_.toolboxService, this_.databaseBackupService).GetApis()...)
 	apis = append(apis, module_datamove.NewApi(this_.toolboxService).GetApis()...)
 	apis = append(apis, module_zookeeper.NewApi(this_.toolboxService).GetApis()...)
 	apis = append(apis, module_kafka.NewApi(this_.toolboxService).GetApis()...)
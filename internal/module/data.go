@@ -7,6 +7,7 @@ import (
 	"regexp"
 	"strings"
 	"teamide/internal/context"
+	"teamide/internal/module/module_datamove"
 	"teamide/internal/module/module_toolbox"
 	"teamide/pkg/base"
 )
@@ -59,7 +60,12 @@ func (this_ *Api) apiData(requestBean *base.RequestBean, c *gin.Context) (res in
 
 	response.ToolboxTypes = module_toolbox.GetToolboxTypes()
 	response.SqlConditionalOperations = db.GetSqlConditionalOperations()
-	response.DatabaseTypes = db.DatabaseTypes
+	for _, one := range db.DatabaseTypes {
+		if module_datamove.IsMaskingDatabaseType(one) {
+			continue
+		}
+		response.DatabaseTypes = append(response.DatabaseTypes, one)
+	}
 	response.QuickCommandTypes = module_toolbox.GetQuickCommandTypes()
 
 	response.Setting = this_.Setting
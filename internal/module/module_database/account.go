@@ -0,0 +1,619 @@
+package module_database
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/team-ide/go-dialect/dialect"
+	"github.com/team-ide/go-tool/db"
+	"github.com/team-ide/go-tool/util"
+	"go.uber.org/zap"
+	"regexp"
+	"strings"
+	"teamide/internal/module/module_log"
+	"teamide/pkg/base"
+	"time"
+)
+
+// 账号 操作 类型
+const (
+	AccountActionCreate = "create"
+	AccountActionAlter  = "alter"
+	AccountActionDrop   = "drop"
+	AccountActionGrant  = "grant"
+	AccountActionRevoke = "revoke"
+)
+
+// 日志、预览 中 密码 显示 内容
+const accountPasswordMask = "******"
+
+type AccountRequest struct {
+	ToolboxId int64  `json:"toolboxId,omitempty"`
+	Action    string `json:"action,omitempty"`
+	UserName  string `json:"userName,omitempty"`
+	Host      string `json:"host,omitempty"` // MySQL 账号 主机，默认 %
+	Password  string `json:"password,omitempty"`
+	IsRole    bool   `json:"isRole,omitempty"`
+	Locked    *bool  `json:"locked,omitempty"`  // 修改 时 锁定 或 解锁 账号
+	Cascade   bool   `json:"cascade,omitempty"` // Oracle 删除 用户 时 同时 删除 用户 下 的 对象
+
+	// 授权 对象，库名 和 表名 都 为空 时 为 全局 或 系统 权限
+	OwnerName       string   `json:"ownerName,omitempty"`
+	TableName       string   `json:"tableName,omitempty"`
+	Privileges      []string `json:"privileges,omitempty"`
+	Roles           []string `json:"roles,omitempty"`
+	WithGrantOption bool     `json:"withGrantOption,omitempty"`
+
+	// 生产 保护 确认，需要 输入 工具 名称
+	ConfirmToken string `json:"confirmToken,omitempty"`
+}
+
+// accountSqls 各 数据库 账号 查询 SQL 和 管理 SQL 生成
+type accountSqls struct {
+	users  []string
+	roles  []string
+	grants func(ctx context.Context, service db.IService, request *AccountRequest) (res map[string]interface{}, err error)
+	build  func(dia dialect.Dialect, param *dialect.ParamModel, request *AccountRequest, password string) (sqlList []string, err error)
+}
+
+var privilegeRegexp = regexp.MustCompile(`^[A-Za-z][A-Za-z _]*$`)
+
+var mysqlAccountSqls = &accountSqls{
+	users: []string{
+		`SELECT User AS userName, Host AS host, account_locked AS locked, password_expired AS passwordExpired FROM mysql.user ORDER BY User, Host`,
+		`SELECT User AS userName, Host AS host FROM mysql.user ORDER BY User, Host`,
+	},
+	grants: func(ctx context.Context, service db.IService, request *AccountRequest) (res map[string]interface{}, err error) {
+		showSql := "SHOW GRANTS FOR " + mysqlAccountName(request)
+		list, err := queryDbObject(ctx, service, []string{showSql})
+		if err != nil {
+			return
+		}
+		var grants []string
+		for _, one := range list {
+			for _, v := range one {
+				grants = append(grants, util.GetStringValue(v))
+			}
+		}
+		res = map[string]interface{}{
+			"grants": grants,
+		}
+		return
+	},
+	build: func(dia dialect.Dialect, param *dialect.ParamModel, request *AccountRequest, password string) (sqlList []string, err error) {
+		account := mysqlAccountName(request)
+		switch request.Action {
+		case AccountActionCreate:
+			if request.IsRole {
+				sqlList = append(sqlList, "CREATE ROLE "+account)
+			} else {
+				sqlList = append(sqlList, "CREATE USER "+account+" IDENTIFIED BY "+mysqlQuote(password))
+			}
+		case AccountActionAlter:
+			if password != "" {
+				sqlList = append(sqlList, "ALTER USER "+account+" IDENTIFIED BY "+mysqlQuote(password))
+			}
+			if request.Locked != nil {
+				if *request.Locked {
+					sqlList = append(sqlList, "ALTER USER "+account+" ACCOUNT LOCK")
+				} else {
+					sqlList = append(sqlList, "ALTER USER "+account+" ACCOUNT UNLOCK")
+				}
+			}
+		case AccountActionDrop:
+			if request.IsRole {
+				sqlList = append(sqlList, "DROP ROLE "+account)
+			} else {
+				sqlList = append(sqlList, "DROP USER "+account)
+			}
+		case AccountActionGrant, AccountActionRevoke:
+			if len(request.Privileges) > 0 {
+				on := "*.*"
+				if request.OwnerName != "" && request.TableName != "" {
+					on = dia.OwnerTablePack(param, request.OwnerName, request.TableName)
+				} else if request.OwnerName != "" {
+					on = dia.OwnerNamePack(param, request.OwnerName) + ".*"
+				}
+				privileges := strings.Join(request.Privileges, ", ")
+				if request.Action == AccountActionGrant {
+					grantSql := "GRANT " + privileges + " ON " + on + " TO " + account
+					if request.WithGrantOption {
+						grantSql += " WITH GRANT OPTION"
+					}
+					sqlList = append(sqlList, grantSql)
+				} else {
+					sqlList = append(sqlList, "REVOKE "+privileges+" ON "+on+" FROM "+account)
+				}
+			}
+			for _, role := range request.Roles {
+				if request.Action == AccountActionGrant {
+					sqlList = append(sqlList, "GRANT "+mysqlQuote(role)+" TO "+account)
+				} else {
+					sqlList = append(sqlList, "REVOKE "+mysqlQuote(role)+" FROM "+account)
+				}
+			}
+		}
+		return
+	},
+}
+
+var postgresqlAccountSqls = &accountSqls{
+	users: []string{
+		`SELECT rolname AS "userName", rolcanlogin AS "canLogin", rolsuper AS "isSuper", rolcreatedb AS "createDb", rolcreaterole AS "createRole", rolvaliduntil AS "validUntil" FROM pg_roles WHERE rolname NOT LIKE 'pg\_%' ORDER BY rolname`,
+	},
+	grants: func(ctx context.Context, service db.IService, request *AccountRequest) (res map[string]interface{}, err error) {
+		tableGrants, err := queryDbObject(ctx, service, []string{
+			`SELECT table_schema AS "ownerName", table_name AS "tableName", privilege_type AS "privilege", is_grantable AS "grantable" FROM information_schema.role_table_grants WHERE grantee = $1 ORDER BY table_schema, table_name, privilege_type`,
+		}, request.UserName)
+		if err != nil {
+			return
+		}
+		ownerGrants, err := queryDbObject(ctx, service, []string{
+			`SELECT n.nspname AS "ownerName", a.privilege_type AS "privilege", a.is_grantable AS "grantable" FROM pg_namespace n CROSS JOIN LATERAL aclexplode(n.nspacl) a JOIN pg_roles r ON r.oid = a.grantee WHERE r.rolname = $1 ORDER BY n.nspname, a.privilege_type`,
+		}, request.UserName)
+		if err != nil {
+			return
+		}
+		roles, err := queryDbObject(ctx, service, []string{
+			`SELECT g.rolname AS "role", m.admin_option AS "adminOption" FROM pg_auth_members m JOIN pg_roles g ON g.oid = m.roleid JOIN pg_roles u ON u.oid = m.member WHERE u.rolname = $1 ORDER BY g.rolname`,
+		}, request.UserName)
+		if err != nil {
+			return
+		}
+		res = map[string]interface{}{
+			"tableGrants": tableGrants,
+			"ownerGrants": ownerGrants,
+			"roles":       roles,
+		}
+		return
+	},
+	build: func(dia dialect.Dialect, param *dialect.ParamModel, request *AccountRequest, password string) (sqlList []string, err error) {
+		account := dia.ColumnNamePack(param, request.UserName)
+		switch request.Action {
+		case AccountActionCreate:
+			if request.IsRole {
+				sqlList = append(sqlList, "CREATE ROLE "+account+" NOLOGIN")
+			} else {
+				sqlList = append(sqlList, "CREATE ROLE "+account+" WITH LOGIN PASSWORD "+standardQuote(password))
+			}
+		case AccountActionAlter:
+			if password != "" {
+				sqlList = append(sqlList, "ALTER ROLE "+account+" WITH PASSWORD "+standardQuote(password))
+			}
+			if request.Locked != nil {
+				if *request.Locked {
+					sqlList = append(sqlList, "ALTER ROLE "+account+" NOLOGIN")
+				} else {
+					sqlList = append(sqlList, "ALTER ROLE "+account+" LOGIN")
+				}
+			}
+		case AccountActionDrop:
+			sqlList = append(sqlList, "DROP ROLE "+account)
+		case AccountActionGrant, AccountActionRevoke:
+			// 模式 权限 为 USAGE、CREATE，其它 权限 授予 模式 下 所有 表
+			var schemaPrivileges, tablePrivileges []string
+			for _, one := range request.Privileges {
+				switch strings.ToUpper(one) {
+				case "USAGE", "CREATE":
+					schemaPrivileges = append(schemaPrivileges, one)
+				default:
+					tablePrivileges = append(tablePrivileges, one)
+				}
+			}
+			var targets [][2]string
+			if request.TableName != "" {
+				if request.OwnerName == "" {
+					err = errors.New("表授权需要指定模式")
+					return
+				}
+				targets = append(targets, [2]string{strings.Join(request.Privileges, ", "), dia.OwnerTablePack(param, request.OwnerName, request.TableName)})
+			} else if request.OwnerName != "" {
+				owner := dia.OwnerNamePack(param, request.OwnerName)
+				if len(schemaPrivileges) > 0 {
+					targets = append(targets, [2]string{strings.Join(schemaPrivileges, ", "), "SCHEMA " + owner})
+				}
+				if len(tablePrivileges) > 0 {
+					targets = append(targets, [2]string{strings.Join(tablePrivileges, ", "), "ALL TABLES IN SCHEMA " + owner})
+				}
+			} else if len(request.Privileges) > 0 {
+				err = errors.New("PostgreSQL授权需要指定模式")
+				return
+			}
+			for _, target := range targets {
+				if request.Action == AccountActionGrant {
+					grantSql := "GRANT " + target[0] + " ON " + target[1] + " TO " + account
+					if request.WithGrantOption {
+						grantSql += " WITH GRANT OPTION"
+					}
+					sqlList = append(sqlList, grantSql)
+				} else {
+					sqlList = append(sqlList, "REVOKE "+target[0]+" ON "+target[1]+" FROM "+account)
+				}
+			}
+			for _, role := range request.Roles {
+				if request.Action == AccountActionGrant {
+					sqlList = append(sqlList, "GRANT "+dia.ColumnNamePack(param, role)+" TO "+account)
+				} else {
+					sqlList = append(sqlList, "REVOKE "+dia.ColumnNamePack(param, role)+" FROM "+account)
+				}
+			}
+		}
+		return
+	},
+}
+
+var oracleAccountSqls = &accountSqls{
+	users: []string{
+		`SELECT USERNAME AS "userName", ACCOUNT_STATUS AS "status", DEFAULT_TABLESPACE AS "defaultTablespace", CREATED AS "createTime" FROM DBA_USERS ORDER BY USERNAME`,
+		`SELECT USERNAME AS "userName", CREATED AS "createTime" FROM ALL_USERS ORDER BY USERNAME`,
+	},
+	roles: []string{
+		`SELECT ROLE AS "userName" FROM DBA_ROLES ORDER BY ROLE`,
+	},
+	grants: func(ctx context.Context, service db.IService, request *AccountRequest) (res map[string]interface{}, err error) {
+		tableGrants, err := queryDbObject(ctx, service, []string{
+			`SELECT OWNER AS "ownerName", TABLE_NAME AS "tableName", PRIVILEGE AS "privilege", GRANTABLE AS "grantable" FROM DBA_TAB_PRIVS WHERE GRANTEE = :1 ORDER BY OWNER, TABLE_NAME, PRIVILEGE`,
+		}, request.UserName)
+		if err != nil {
+			return
+		}
+		systemGrants, err := queryDbObject(ctx, service, []string{
+			`SELECT PRIVILEGE AS "privilege", ADMIN_OPTION AS "adminOption" FROM DBA_SYS_PRIVS WHERE GRANTEE = :1 ORDER BY PRIVILEGE`,
+		}, request.UserName)
+		if err != nil {
+			return
+		}
+		roles, err := queryDbObject(ctx, service, []string{
+			`SELECT GRANTED_ROLE AS "role", ADMIN_OPTION AS "adminOption" FROM DBA_ROLE_PRIVS WHERE GRANTEE = :1 ORDER BY GRANTED_ROLE`,
+		}, request.UserName)
+		if err != nil {
+			return
+		}
+		res = map[string]interface{}{
+			"tableGrants":  tableGrants,
+			"systemGrants": systemGrants,
+			"roles":        roles,
+		}
+		return
+	},
+	build: func(dia dialect.Dialect, param *dialect.ParamModel, request *AccountRequest, password string) (sqlList []string, err error) {
+		account := dia.ColumnNamePack(param, request.UserName)
+		switch request.Action {
+		case AccountActionCreate:
+			if request.IsRole {
+				sqlList = append(sqlList, "CREATE ROLE "+account)
+			} else {
+				var quoted string
+				if quoted, err = oraclePasswordQuote(password); err != nil {
+					return
+				}
+				sqlList = append(sqlList, "CREATE USER "+account+" IDENTIFIED BY "+quoted)
+			}
+		case AccountActionAlter:
+			if password != "" {
+				var quoted string
+				if quoted, err = oraclePasswordQuote(password); err != nil {
+					return
+				}
+				sqlList = append(sqlList, "ALTER USER "+account+" IDENTIFIED BY "+quoted)
+			}
+			if request.Locked != nil {
+				if *request.Locked {
+					sqlList = append(sqlList, "ALTER USER "+account+" ACCOUNT LOCK")
+				} else {
+					sqlList = append(sqlList, "ALTER USER "+account+" ACCOUNT UNLOCK")
+				}
+			}
+		case AccountActionDrop:
+			if request.IsRole {
+				sqlList = append(sqlList, "DROP ROLE "+account)
+			} else if request.Cascade {
+				sqlList = append(sqlList, "DROP USER "+account+" CASCADE")
+			} else {
+				sqlList = append(sqlList, "DROP USER "+account)
+			}
+		case AccountActionGrant, AccountActionRevoke:
+			// 没有 按 用户 授权 的 语法，不 指定 表 时 为 系统 权限
+			var on string
+			if request.TableName != "" {
+				if request.OwnerName == "" {
+					err = errors.New("表授权需要指定用户")
+					return
+				}
+				on = " ON " + dia.OwnerTablePack(param, request.OwnerName, request.TableName)
+			} else if request.OwnerName != "" && len(request.Privileges) > 0 {
+				err = errors.New("当前数据库不支持按用户授权，请指定表或授予系统权限")
+				return
+			}
+			var grantOption = " WITH ADMIN OPTION"
+			if on != "" {
+				grantOption = " WITH GRANT OPTION"
+			}
+			if len(request.Privileges) > 0 {
+				privileges := strings.Join(request.Privileges, ", ")
+				if request.Action == AccountActionGrant {
+					grantSql := "GRANT " + privileges + on + " TO " + account
+					if request.WithGrantOption {
+						grantSql += grantOption
+					}
+					sqlList = append(sqlList, grantSql)
+				} else {
+					sqlList = append(sqlList, "REVOKE "+privileges+on+" FROM "+account)
+				}
+			}
+			for _, role := range request.Roles {
+				if request.Action == AccountActionGrant {
+					sqlList = append(sqlList, "GRANT "+dia.ColumnNamePack(param, role)+" TO "+account)
+				} else {
+					sqlList = append(sqlList, "REVOKE "+dia.ColumnNamePack(param, role)+" FROM "+account)
+				}
+			}
+		}
+		return
+	},
+}
+
+// getAccountSqls 获取 数据库 对应 的 账号 SQL，金仓 使用 PostgreSQL 模式，达梦 兼容 Oracle
+func getAccountSqls(dia dialect.Dialect) (res *accountSqls, err error) {
+	switch dia.DialectType() {
+	case dialect.TypeMysql:
+		res = mysqlAccountSqls
+	case dialect.TypePostgresql, dialect.TypeKingBase, dialect.TypeOpenGauss:
+		res = postgresqlAccountSqls
+	case dialect.TypeOracle, dialect.TypeDM:
+		res = oracleAccountSqls
+	default:
+		err = errors.New("数据库类型[" + dia.DialectType().Name + "]暂不支持用户权限管理")
+	}
+	return
+}
+
+func mysqlQuote(value string) string {
+	value = strings.ReplaceAll(value, "\\", "\\\\")
+	return "'" + strings.ReplaceAll(value, "'", "''") + "'"
+}
+
+func standardQuote(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", "''") + "'"
+}
+
+// oraclePasswordQuote Oracle 密码 使用 双引号，不能 包含 双引号
+func oraclePasswordQuote(value string) (res string, err error) {
+	if strings.Contains(value, `"`) {
+		err = errors.New("Oracle密码不能包含双引号")
+		return
+	}
+	res = `"` + value + `"`
+	return
+}
+
+func mysqlAccountName(request *AccountRequest) string {
+	if request.IsRole && request.Host == "" {
+		return mysqlQuote(request.UserName)
+	}
+	host := request.Host
+	if host == "" {
+		host = "%"
+	}
+	return mysqlQuote(request.UserName) + "@" + mysqlQuote(host)
+}
+
+// buildAccountSql 校验 请求 并 生成 SQL，返回 执行 的 SQL 和 隐藏 密码 的 SQL
+func buildAccountSql(sqls *accountSqls, dia dialect.Dialect, param *dialect.ParamModel, request *AccountRequest) (sqlList []string, showSqlList []string, err error) {
+	if strings.TrimSpace(request.UserName) == "" {
+		err = errors.New("用户名不能为空")
+		return
+	}
+	switch request.Action {
+	case AccountActionCreate:
+		if !request.IsRole && request.Password == "" {
+			err = errors.New("密码不能为空")
+			return
+		}
+	case AccountActionAlter, AccountActionDrop:
+	case AccountActionGrant, AccountActionRevoke:
+		if len(request.Privileges) == 0 && len(request.Roles) == 0 {
+			err = errors.New("请选择权限或角色")
+			return
+		}
+		for _, one := range request.Privileges {
+			if !privilegeRegexp.MatchString(one) {
+				err = errors.New("权限[" + one + "]格式错误")
+				return
+			}
+		}
+	default:
+		err = errors.New("不支持的操作[" + request.Action + "]")
+		return
+	}
+	sqlList, err = sqls.build(dia, param, request, request.Password)
+	if err != nil {
+		return
+	}
+	var mask string
+	if request.Password != "" {
+		mask = accountPasswordMask
+	}
+	showSqlList, err = sqls.build(dia, param, request, mask)
+	if err != nil {
+		return
+	}
+	if len(sqlList) == 0 {
+		err = errors.New("没有需要执行的SQL")
+		return
+	}
+	return
+}
+
+func (this_ *api) getAccountService(requestBean *base.RequestBean, c *gin.Context) (service db.IService, sqls *accountSqls, request *AccountRequest, err error) {
+	config, sshConfig, err := this_.getConfig(requestBean, c)
+	if err != nil {
+		return
+	}
+	service, err = getService(config, sshConfig)
+	if err != nil {
+		return
+	}
+	request = &AccountRequest{}
+	if !base.RequestJSON(request, c) {
+		err = errors.New("请求参数错误")
+		return
+	}
+	sqls, err = getAccountSqls(service.GetDialect())
+	return
+}
+
+func (this_ *api) accountList(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	service, sqls, _, err := this_.getAccountService(requestBean, c)
+	if err != nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+	users, err := queryDbObject(ctx, service, sqls.users)
+	if err != nil {
+		return
+	}
+	data := map[string]interface{}{
+		"users": users,
+	}
+	if len(sqls.roles) > 0 {
+		// 没有 DBA 权限 时 只 返回 用户
+		roles, e := queryDbObject(ctx, service, sqls.roles)
+		if e != nil {
+			util.Logger.Warn("database account roles query error", zap.Error(e))
+		}
+		data["roles"] = roles
+	}
+	res = data
+	return
+}
+
+func (this_ *api) accountGrants(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	service, sqls, request, err := this_.getAccountService(requestBean, c)
+	if err != nil {
+		return
+	}
+	if request.UserName == "" {
+		err = errors.New("用户名不能为空")
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+	res, err = sqls.grants(ctx, service, request)
+	return
+}
+
+// accountSql 预览 生成 的 SQL，密码 隐藏
+func (this_ *api) accountSql(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	service, sqls, request, err := this_.getAccountService(requestBean, c)
+	if err != nil {
+		return
+	}
+	param := this_.getParam(requestBean, c)
+	_, showSqlList, err := buildAccountSql(sqls, service.GetDialect(), param.ParamModel, request)
+	if err != nil {
+		return
+	}
+	res = showSqlList
+	return
+}
+
+// accountExecute 按 请求 重新 生成 SQL 并 执行，不 接收 客户端 传入 的 SQL
+func (this_ *api) accountExecute(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	service, sqls, request, err := this_.getAccountService(requestBean, c)
+	if err != nil {
+		return
+	}
+	defer func() {
+		this_.recordAccountLog(requestBean, c, request, err)
+	}()
+	param := this_.getParam(requestBean, c)
+	sqlList, showSqlList, err := buildAccountSql(sqls, service.GetDialect(), param.ParamModel, request)
+	if err != nil {
+		return
+	}
+	var statements []*GuardStatement
+	for _, one := range showSqlList {
+		kind := classifyStatement(service.GetDialect().DialectType(), one)
+		if kind == "" {
+			kind = GuardKindDDL
+		}
+		statements = append(statements, &GuardStatement{Sql: one, Kind: kind})
+	}
+	err = this_.checkProtected(requestBean, c, "account/"+request.Action, &BaseRequest{ToolboxId: request.ToolboxId, ConfirmToken: request.ConfirmToken}, statements)
+	if err != nil {
+		return
+	}
+	timeout, err := this_.getStatementTimeout(requestBean)
+	if err != nil {
+		return
+	}
+	var ctx = context.Background()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	startTime := util.GetNowMilli()
+	var executeIndex int
+	for executeIndex = 0; executeIndex < len(sqlList); executeIndex++ {
+		_, err = service.GetDb().ExecContext(ctx, sqlList[executeIndex])
+		if err != nil {
+			err = errors.New("sql:" + showSqlList[executeIndex] + ",error:" + err.Error())
+			break
+		}
+	}
+	history := &SqlHistoryModel{
+		ToolboxId:   request.ToolboxId,
+		ExecuteType: "account",
+		ExecuteSql:  strings.Join(showSqlList, ";\n"),
+		UseTime:     util.GetNowMilli() - startTime,
+	}
+	if err != nil {
+		history.Error = err.Error()
+	}
+	this_.recordHistory(requestBean, history)
+	if err != nil {
+		return
+	}
+	res = map[string]interface{}{
+		"sqlList": showSqlList,
+		"useTime": history.UseTime,
+	}
+	return
+}
+
+// recordAccountLog 账号 管理 请求 包含 密码，不 使用 通用 日志，记录 隐藏 密码 后 的 请求
+func (this_ *api) recordAccountLog(requestBean *base.RequestBean, c *gin.Context, request *AccountRequest, err error) {
+	if request == nil {
+		return
+	}
+	now := util.GetNow()
+	logRecode := &module_log.LogModel{
+		Action:     "database/account/execute",
+		Method:     c.Request.Method,
+		Ip:         c.ClientIP(),
+		UserAgent:  c.Request.UserAgent(),
+		StartTime:  now,
+		EndTime:    now,
+		CreateTime: now,
+	}
+	data := *request
+	if data.Password != "" {
+		data.Password = accountPasswordMask
+	}
+	bs, _ := json.Marshal(data)
+	logRecode.Data = string(bs)
+	if requestBean.JWT != nil {
+		logRecode.UserId = requestBean.JWT.UserId
+		logRecode.UserName = requestBean.JWT.Name
+		logRecode.UserAccount = requestBean.JWT.Account
+		logRecode.LoginId = requestBean.JWT.LoginId
+	}
+	e := this_.logService.Insert(logRecode, err)
+	if e != nil {
+		util.Logger.Error("database account log insert error", zap.Error(e))
+	}
+}
@@ -0,0 +1,316 @@
+package module_database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/team-ide/go-dialect/dialect"
+	"github.com/team-ide/go-tool/db"
+	"github.com/team-ide/go-tool/util"
+	"go.uber.org/zap"
+	"regexp"
+	"teamide/pkg/base"
+	"time"
+)
+
+// activityQueryTimeout 活动 监控 查询 超时，避免 数据库 繁忙 时 一直 等待
+const activityQueryTimeout = time.Second * 15
+
+type ActivityRequest struct {
+	ToolboxId int64  `json:"toolboxId,omitempty"`
+	SessionId string `json:"sessionId,omitempty"` // 终止 的 会话，Oracle 为 SID,SERIAL#
+	Top       int    `json:"top,omitempty"`       // 慢 语句 数量，默认 20
+}
+
+// LockWait 锁 等待，waiting 会话 被 blocking 会话 阻塞
+type LockWait struct {
+	WaitingSessionId  string `json:"waitingSessionId"`
+	WaitingSql        string `json:"waitingSql,omitempty"`
+	BlockingSessionId string `json:"blockingSessionId"`
+	BlockingSql       string `json:"blockingSql,omitempty"`
+	WaitSeconds       int64  `json:"waitSeconds"`
+}
+
+// activitySqls 各 数据库 的 监控 SQL，可以 有 多个 兼容 不同 版本，依次 尝试
+type activitySqls struct {
+	sessions []string
+	locks    []string
+	slow     []string // 参数 为 数量
+	kill     func(sessionId string) (killSql string, err error)
+}
+
+var numberSessionIdRegexp = regexp.MustCompile(`^\d+$`)
+var oracleSessionIdRegexp = regexp.MustCompile(`^\d+,\d+$`)
+
+var mysqlActivitySqls = &activitySqls{
+	sessions: []string{
+		`SELECT ID AS sessionId, USER AS user, HOST AS host, DB AS db, COMMAND AS command, TIME AS time, STATE AS state, INFO AS info FROM information_schema.PROCESSLIST ORDER BY TIME DESC`,
+	},
+	locks: []string{
+		// MySQL 8.0
+		`SELECT r.trx_mysql_thread_id AS waitingSessionId, r.trx_query AS waitingSql, b.trx_mysql_thread_id AS blockingSessionId, b.trx_query AS blockingSql, TIMESTAMPDIFF(SECOND, r.trx_wait_started, NOW()) AS waitSeconds
+FROM performance_schema.data_lock_waits w
+JOIN information_schema.INNODB_TRX b ON b.trx_id = w.BLOCKING_ENGINE_TRANSACTION_ID
+JOIN information_schema.INNODB_TRX r ON r.trx_id = w.REQUESTING_ENGINE_TRANSACTION_ID`,
+		// MySQL 5.x
+		`SELECT r.trx_mysql_thread_id AS waitingSessionId, r.trx_query AS waitingSql, b.trx_mysql_thread_id AS blockingSessionId, b.trx_query AS blockingSql, TIMESTAMPDIFF(SECOND, r.trx_wait_started, NOW()) AS waitSeconds
+FROM information_schema.INNODB_LOCK_WAITS w
+JOIN information_schema.INNODB_TRX b ON b.trx_id = w.blocking_trx_id
+JOIN information_schema.INNODB_TRX r ON r.trx_id = w.requesting_trx_id`,
+	},
+	slow: []string{
+		`SELECT SCHEMA_NAME AS db, DIGEST_TEXT AS sqlText, COUNT_STAR AS execCount, ROUND(SUM_TIMER_WAIT/1000000000000, 3) AS totalSeconds, ROUND(AVG_TIMER_WAIT/1000000000000, 3) AS avgSeconds, ROUND(MAX_TIMER_WAIT/1000000000000, 3) AS maxSeconds, SUM_ROWS_EXAMINED AS rowsExamined
+FROM performance_schema.events_statements_summary_by_digest ORDER BY AVG_TIMER_WAIT DESC LIMIT ?`,
+	},
+	kill: func(sessionId string) (killSql string, err error) {
+		if !numberSessionIdRegexp.MatchString(sessionId) {
+			err = errors.New("会话[" + sessionId + "]格式错误")
+			return
+		}
+		killSql = "KILL " + sessionId
+		return
+	},
+}
+
+var postgresqlActivitySqls = &activitySqls{
+	sessions: []string{
+		`SELECT pid AS "sessionId", usename AS "user", client_addr::text AS "host", datname AS "db", backend_type AS "command", EXTRACT(EPOCH FROM (now() - query_start))::bigint AS "time", state AS "state", wait_event_type AS "waitEventType", wait_event AS "waitEvent", query AS "info"
+FROM pg_stat_activity WHERE pid <> pg_backend_pid() ORDER BY query_start`,
+		// 9.x 没有 backend_type
+		`SELECT pid AS "sessionId", usename AS "user", client_addr::text AS "host", datname AS "db", EXTRACT(EPOCH FROM (now() - query_start))::bigint AS "time", state AS "state", query AS "info"
+FROM pg_stat_activity WHERE pid <> pg_backend_pid() ORDER BY query_start`,
+	},
+	locks: []string{
+		`SELECT a.pid AS "waitingSessionId", a.query AS "waitingSql", b.pid AS "blockingSessionId", b.query AS "blockingSql", EXTRACT(EPOCH FROM (now() - a.query_start))::bigint AS "waitSeconds"
+FROM pg_stat_activity a
+JOIN LATERAL unnest(pg_blocking_pids(a.pid)) AS bp(pid) ON true
+JOIN pg_stat_activity b ON b.pid = bp.pid`,
+		// 没有 pg_blocking_pids 时 根据 pg_locks 匹配
+		`SELECT w.pid AS "waitingSessionId", wa.query AS "waitingSql", h.pid AS "blockingSessionId", ha.query AS "blockingSql", EXTRACT(EPOCH FROM (now() - wa.query_start))::bigint AS "waitSeconds"
+FROM pg_locks w
+JOIN pg_locks h ON h.granted AND NOT w.granted AND h.pid <> w.pid AND h.locktype = w.locktype
+	AND h.database IS NOT DISTINCT FROM w.database AND h.relation IS NOT DISTINCT FROM w.relation
+	AND h.transactionid IS NOT DISTINCT FROM w.transactionid
+JOIN pg_stat_activity wa ON wa.pid = w.pid
+JOIN pg_stat_activity ha ON ha.pid = h.pid`,
+	},
+	slow: []string{
+		// 13 及 以上
+		`SELECT query AS "sqlText", calls AS "execCount", round((total_exec_time/1000)::numeric, 3) AS "totalSeconds", round((mean_exec_time/1000)::numeric, 3) AS "avgSeconds", round((max_exec_time/1000)::numeric, 3) AS "maxSeconds", rows AS "rowsExamined"
+FROM pg_stat_statements ORDER BY mean_exec_time DESC LIMIT $1`,
+		`SELECT query AS "sqlText", calls AS "execCount", round((total_time/1000)::numeric, 3) AS "totalSeconds", round((mean_time/1000)::numeric, 3) AS "avgSeconds", round((max_time/1000)::numeric, 3) AS "maxSeconds", rows AS "rowsExamined"
+FROM pg_stat_statements ORDER BY mean_time DESC LIMIT $1`,
+	},
+	kill: func(sessionId string) (killSql string, err error) {
+		if !numberSessionIdRegexp.MatchString(sessionId) {
+			err = errors.New("会话[" + sessionId + "]格式错误")
+			return
+		}
+		killSql = "SELECT pg_terminate_backend(" + sessionId + ")"
+		return
+	},
+}
+
+var oracleActivitySqls = &activitySqls{
+	sessions: []string{
+		`SELECT s.SID || ',' || s.SERIAL# AS "sessionId", s.USERNAME AS "user", s.MACHINE AS "host", s.SCHEMANAME AS "db", s.PROGRAM AS "command", s.LAST_CALL_ET AS "time", s.STATUS AS "state", s.WAIT_CLASS AS "waitEventType", s.EVENT AS "waitEvent", q.SQL_TEXT AS "info"
+FROM V$SESSION s LEFT JOIN V$SQL q ON q.SQL_ID = s.SQL_ID AND q.CHILD_NUMBER = s.SQL_CHILD_NUMBER
+WHERE s.TYPE = 'USER' AND s.SID <> SYS_CONTEXT('USERENV', 'SID') ORDER BY s.LAST_CALL_ET DESC`,
+	},
+	locks: []string{
+		`SELECT w.SID || ',' || w.SERIAL# AS "waitingSessionId", (SELECT q.SQL_TEXT FROM V$SQL q WHERE q.SQL_ID = w.SQL_ID AND ROWNUM = 1) AS "waitingSql",
+	b.SID || ',' || b.SERIAL# AS "blockingSessionId", (SELECT q.SQL_TEXT FROM V$SQL q WHERE q.SQL_ID = NVL(b.SQL_ID, b.PREV_SQL_ID) AND ROWNUM = 1) AS "blockingSql",
+	w.SECONDS_IN_WAIT AS "waitSeconds"
+FROM V$SESSION w JOIN V$SESSION b ON b.SID = w.BLOCKING_SESSION
+WHERE w.BLOCKING_SESSION IS NOT NULL`,
+	},
+	slow: []string{
+		`SELECT * FROM (SELECT SQL_TEXT AS "sqlText", EXECUTIONS AS "execCount", ROUND(ELAPSED_TIME/1000000, 3) AS "totalSeconds", ROUND(ELAPSED_TIME/GREATEST(EXECUTIONS, 1)/1000000, 3) AS "avgSeconds", ROWS_PROCESSED AS "rowsExamined"
+FROM V$SQLSTATS ORDER BY ELAPSED_TIME/GREATEST(EXECUTIONS, 1) DESC) WHERE ROWNUM <= :1`,
+	},
+	kill: func(sessionId string) (killSql string, err error) {
+		if !oracleSessionIdRegexp.MatchString(sessionId) {
+			err = errors.New("会话[" + sessionId + "]格式错误，格式为：SID,SERIAL#")
+			return
+		}
+		killSql = "ALTER SYSTEM KILL SESSION '" + sessionId + "' IMMEDIATE"
+		return
+	},
+}
+
+func getActivitySqls(dia dialect.Dialect) (res *activitySqls, err error) {
+	switch dia.DialectType() {
+	case dialect.TypeMysql:
+		res = mysqlActivitySqls
+	case dialect.TypePostgresql, dialect.TypeKingBase, dialect.TypeOpenGauss:
+		res = postgresqlActivitySqls
+	case dialect.TypeOracle:
+		res = oracleActivitySqls
+	default:
+		err = errors.New("数据库类型[" + dia.DialectType().Name + "]暂不支持活动监控")
+	}
+	return
+}
+
+// queryActivity 带 超时 的 兼容 查询
+func queryActivity(service db.IService, sqlList []string, args ...interface{}) (dataList []map[string]interface{}, err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), activityQueryTimeout)
+	defer cancel()
+	dataList, err = queryDbObject(ctx, service, sqlList, args...)
+	return
+}
+
+// lockChains 根据 锁 等待 关系 生成 阻塞 链，从 不被 阻塞 的 源头 会话 开始
+func lockChains(lockWaits []*LockWait) (chains [][]string) {
+	blocked := map[string][]string{}
+	isWaiting := map[string]bool{}
+	var blockers []string
+	for _, one := range lockWaits {
+		if _, find := blocked[one.BlockingSessionId]; !find {
+			blockers = append(blockers, one.BlockingSessionId)
+		}
+		blocked[one.BlockingSessionId] = append(blocked[one.BlockingSessionId], one.WaitingSessionId)
+		isWaiting[one.WaitingSessionId] = true
+	}
+	var walk func(chain []string)
+	walk = func(chain []string) {
+		last := chain[len(chain)-1]
+		next := blocked[last]
+		if len(next) == 0 {
+			chains = append(chains, chain)
+			return
+		}
+		for _, one := range next {
+			// 死锁 时 存在 环，遇到 已 在 链 中 的 会话 结束
+			if util.StringIndexOf(chain, one) >= 0 {
+				chains = append(chains, append(append([]string{}, chain...), one))
+				continue
+			}
+			walk(append(append([]string{}, chain...), one))
+		}
+	}
+	for _, blocker := range blockers {
+		if isWaiting[blocker] {
+			continue
+		}
+		walk([]string{blocker})
+	}
+	// 全部 在 环 中，没有 源头
+	if len(chains) == 0 && len(blockers) > 0 {
+		walk([]string{blockers[0]})
+	}
+	return
+}
+
+func (this_ *api) activitySessions(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	config, sshConfig, err := this_.getConfig(requestBean, c)
+	if err != nil {
+		return
+	}
+	service, err := getService(config, sshConfig)
+	if err != nil {
+		return
+	}
+	sqls, err := getActivitySqls(service.GetDialect())
+	if err != nil {
+		return
+	}
+	res, err = queryActivity(service, sqls.sessions)
+	return
+}
+
+func (this_ *api) activityLocks(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	config, sshConfig, err := this_.getConfig(requestBean, c)
+	if err != nil {
+		return
+	}
+	service, err := getService(config, sshConfig)
+	if err != nil {
+		return
+	}
+	sqls, err := getActivitySqls(service.GetDialect())
+	if err != nil {
+		return
+	}
+	dataList, err := queryActivity(service, sqls.locks)
+	if err != nil {
+		return
+	}
+	var lockWaits []*LockWait
+	for _, data := range dataList {
+		lockWaits = append(lockWaits, &LockWait{
+			WaitingSessionId:  util.GetStringValue(data["waitingSessionId"]),
+			WaitingSql:        util.GetStringValue(data["waitingSql"]),
+			BlockingSessionId: util.GetStringValue(data["blockingSessionId"]),
+			BlockingSql:       util.GetStringValue(data["blockingSql"]),
+			WaitSeconds:       historyInt64(data["waitSeconds"]),
+		})
+	}
+	res = map[string]interface{}{
+		"lockWaits": lockWaits,
+		"chains":    lockChains(lockWaits),
+	}
+	return
+}
+
+func (this_ *api) activitySlow(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	config, sshConfig, err := this_.getConfig(requestBean, c)
+	if err != nil {
+		return
+	}
+	service, err := getService(config, sshConfig)
+	if err != nil {
+		return
+	}
+	var request = &ActivityRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	sqls, err := getActivitySqls(service.GetDialect())
+	if err != nil {
+		return
+	}
+	top := request.Top
+	if top <= 0 {
+		top = 20
+	}
+	res, err = queryActivity(service, sqls.slow, top)
+	if err != nil {
+		err = errors.New("慢语句统计查询失败，请确认已开启 performance_schema 或 安装 pg_stat_statements 扩展：" + err.Error())
+		return
+	}
+	return
+}
+
+func (this_ *api) activityKill(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	config, sshConfig, err := this_.getConfig(requestBean, c)
+	if err != nil {
+		return
+	}
+	service, err := getService(config, sshConfig)
+	if err != nil {
+		return
+	}
+	var request = &ActivityRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	sqls, err := getActivitySqls(service.GetDialect())
+	if err != nil {
+		return
+	}
+	killSql, err := sqls.kill(request.SessionId)
+	if err != nil {
+		return
+	}
+	util.Logger.Info("database activity kill session", zap.Any("toolboxId", request.ToolboxId), zap.Any("killSql", killSql))
+	ctx, cancel := context.WithTimeout(context.Background(), activityQueryTimeout)
+	defer cancel()
+	_, err = service.GetDb().ExecContext(ctx, killSql)
+	if err != nil {
+		err = errors.New(fmt.Sprint("终止会话[", request.SessionId, "]失败：", err.Error()))
+		return
+	}
+	return
+}
@@ -0,0 +1,629 @@
+package module_database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/team-ide/go-dialect/dialect"
+	"github.com/team-ide/go-dialect/worker"
+	"github.com/team-ide/go-tool/db"
+	"github.com/team-ide/go-tool/util"
+	"strings"
+	"teamide/pkg/base"
+	"time"
+)
+
+// analyzeQueryTimeout 分析 时 查询 表 行数、字段 的 超时
+const analyzeQueryTimeout = time.Second * 10
+
+// 默认 大表 行数
+const analyzeDefaultLargeTableRows = 100000
+
+// SQL 分析 规则
+const (
+	AnalyzeRuleNoWhere            = "noWhere"            // UPDATE、DELETE 没有 WHERE
+	AnalyzeRuleSelectStar         = "selectStar"         // 大表 SELECT *
+	AnalyzeRuleImplicitConversion = "implicitConversion" // 条件 中 字段 与 值 类型 不一致
+	AnalyzeRuleNoLimit            = "noLimit"            // 查询 没有 限制 行数
+	AnalyzeRuleLeadingLike        = "leadingLike"        // LIKE 以 通配符 开头
+	AnalyzeRuleCartesianJoin      = "cartesianJoin"      // 没有 关联 条件 的 多表 查询
+)
+
+// SQL 分析 规则 级别
+const (
+	AnalyzeLevelOff   = "off"
+	AnalyzeLevelWarn  = "warn"
+	AnalyzeLevelBlock = "block" // 阻止 执行
+)
+
+var analyzeRuleTexts = map[string]string{
+	AnalyzeRuleNoWhere:            "没有WHERE条件的修改或删除",
+	AnalyzeRuleSelectStar:         "大表查询全部字段",
+	AnalyzeRuleImplicitConversion: "隐式类型转换",
+	AnalyzeRuleNoLimit:            "查询没有限制行数",
+	AnalyzeRuleLeadingLike:        "LIKE以通配符开头",
+	AnalyzeRuleCartesianJoin:      "笛卡尔积关联",
+}
+
+// AnalyzeRule 工具 配置 的 分析 规则 级别，未 配置 的 规则 为 警告
+type AnalyzeRule struct {
+	Rule  string `json:"rule,omitempty"`
+	Level string `json:"level,omitempty"` // off、warn、block
+}
+
+// AnalyzeIssue 分析 发现 的 问题
+type AnalyzeIssue struct {
+	Rule    string `json:"rule"`
+	Level   string `json:"level"`
+	Message string `json:"message"`
+}
+
+type AnalyzeStatement struct {
+	Sql       string          `json:"sql"`
+	Formatted string          `json:"formatted"`
+	Issues    []*AnalyzeIssue `json:"issues"`
+}
+
+type AnalyzeResult struct {
+	StatementList []*AnalyzeStatement `json:"statementList"`
+	Formatted     string              `json:"formatted"`
+	Blocked       bool                `json:"blocked"` // 存在 阻止 执行 的 问题
+}
+
+// analyzeTable 语句 中 引用 的 表
+type analyzeTable struct {
+	ownerName string
+	tableName string
+	alias     string
+}
+
+// sqlAnalyzer 分析 SQL，缓存 查询 过 的 表 行数 和 字段
+type sqlAnalyzer struct {
+	service        db.IService
+	param          *dialect.ParamModel
+	ownerName      string
+	levels         map[string]string
+	largeTableRows int64
+	tableRows      map[string]int64
+	tableColumns   map[string][]*dialect.ColumnModel
+}
+
+func newSqlAnalyzer(service db.IService, param *dialect.ParamModel, ownerName string, databaseOption *DatabaseOption) (res *sqlAnalyzer) {
+	res = &sqlAnalyzer{
+		service:        service,
+		param:          param,
+		ownerName:      ownerName,
+		levels:         map[string]string{},
+		largeTableRows: int64(databaseOption.LargeTableRows),
+		tableRows:      map[string]int64{},
+		tableColumns:   map[string][]*dialect.ColumnModel{},
+	}
+	if res.largeTableRows <= 0 {
+		res.largeTableRows = analyzeDefaultLargeTableRows
+	}
+	for rule := range analyzeRuleTexts {
+		res.levels[rule] = AnalyzeLevelWarn
+	}
+	for _, one := range databaseOption.AnalyzeRules {
+		if one == nil || analyzeRuleTexts[one.Rule] == "" {
+			continue
+		}
+		switch one.Level {
+		case AnalyzeLevelOff, AnalyzeLevelWarn, AnalyzeLevelBlock:
+			res.levels[one.Rule] = one.Level
+		}
+	}
+	return
+}
+
+// hasBlockRule 是否 配置 了 阻止 执行 的 规则
+func (this_ *sqlAnalyzer) hasBlockRule() bool {
+	for _, level := range this_.levels {
+		if level == AnalyzeLevelBlock {
+			return true
+		}
+	}
+	return false
+}
+
+func (this_ *sqlAnalyzer) analyze(sqlContent string) (res *AnalyzeResult) {
+	res = &AnalyzeResult{}
+	var formattedList []string
+	for _, one := range this_.service.GetDialect().SqlSplit(sqlContent) {
+		one = strings.TrimSpace(one)
+		if one == "" {
+			continue
+		}
+		statement := &AnalyzeStatement{
+			Sql:       one,
+			Formatted: formatSql(this_.service.GetDialect().DialectType(), one),
+			Issues:    []*AnalyzeIssue{},
+		}
+		this_.analyzeStatement(statement)
+		for _, issue := range statement.Issues {
+			if issue.Level == AnalyzeLevelBlock {
+				res.Blocked = true
+			}
+		}
+		formattedList = append(formattedList, statement.Formatted)
+		res.StatementList = append(res.StatementList, statement)
+	}
+	if len(formattedList) > 0 {
+		res.Formatted = strings.Join(formattedList, ";\n\n") + ";"
+	}
+	return
+}
+
+func (this_ *sqlAnalyzer) addIssue(statement *AnalyzeStatement, rule string, message string) {
+	level := this_.levels[rule]
+	if level == AnalyzeLevelOff {
+		return
+	}
+	for _, one := range statement.Issues {
+		if one.Rule == rule && one.Message == message {
+			return
+		}
+	}
+	statement.Issues = append(statement.Issues, &AnalyzeIssue{
+		Rule:    rule,
+		Level:   level,
+		Message: message,
+	})
+}
+
+func (this_ *sqlAnalyzer) analyzeStatement(statement *AnalyzeStatement) {
+	dialectType := this_.service.GetDialect().DialectType()
+	allTokens, err := sqlTokenize(dialectType, statement.Sql)
+	if err != nil {
+		// 无法 解析 时 不能 确认 是否 有 WHERE 条件，按 没有 WHERE 的 规则 级别 处理
+		this_.addIssue(statement, AnalyzeRuleNoWhere, "SQL解析失败，无法检查WHERE条件："+err.Error())
+		return
+	}
+	tokens := sqlCodeTokens(allTokens)
+	if len(tokens) == 0 {
+		return
+	}
+	kind := classifyStatement(dialectType, statement.Sql)
+	if kind == GuardKindNoWhere {
+		this_.addIssue(statement, AnalyzeRuleNoWhere, "UPDATE、DELETE语句没有WHERE条件，将修改全表数据")
+	}
+	tables := analyzeTables(tokens)
+
+	isSelect := tokens[0].is("SELECT") || (tokens[0].is("WITH") && kind == "" && hasTopLevelWord(tokens, "SELECT"))
+	if isSelect {
+		this_.checkNoLimit(statement, tokens)
+	}
+	this_.checkSelectStar(statement, tokens, tables)
+	this_.checkLeadingLike(statement, tokens)
+	this_.checkCartesianJoin(statement, tokens)
+	this_.checkImplicitConversion(statement, tokens, tables)
+}
+
+func hasTopLevelWord(tokens []*sqlToken, word string) bool {
+	for _, token := range tokens {
+		if token.depth == 0 && token.is(word) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkNoLimit 查询 没有 LIMIT、TOP、FETCH、ROWNUM 限制，只 查询 聚合 函数 的 除外
+func (this_ *sqlAnalyzer) checkNoLimit(statement *AnalyzeStatement, tokens []*sqlToken) {
+	var hasFrom, hasGroup bool
+	for _, token := range tokens {
+		if token.is("LIMIT", "TOP", "FETCH", "ROWNUM") {
+			return
+		}
+		if token.depth == 0 && token.is("FROM") {
+			hasFrom = true
+		}
+		if token.depth == 0 && token.is("GROUP") {
+			hasGroup = true
+		}
+	}
+	if !hasFrom {
+		return
+	}
+	// SELECT COUNT(*) FROM 等 只 返回 一行
+	for i, token := range tokens {
+		if token.depth == 0 && token.is("SELECT") && i+2 < len(tokens) {
+			if !hasGroup && tokens[i+1].is("COUNT", "SUM", "MAX", "MIN", "AVG") && tokens[i+2].is("(") {
+				return
+			}
+			break
+		}
+	}
+	this_.addIssue(statement, AnalyzeRuleNoLimit, "查询没有限制返回行数，数据量大时可能返回过多数据")
+}
+
+// checkSelectStar 查询 全部 字段 且 表 行数 超过 大表 行数
+func (this_ *sqlAnalyzer) checkSelectStar(statement *AnalyzeStatement, tokens []*sqlToken, tables []*analyzeTable) {
+	var find bool
+	for i, token := range tokens {
+		if !token.is("*") || i == 0 {
+			continue
+		}
+		// SELECT *、SELECT DISTINCT *、a, *、t.*，COUNT(*)、a * b 不是
+		if tokens[i-1].is("SELECT", "DISTINCT", "ALL", ",", ".") {
+			find = true
+			break
+		}
+	}
+	if !find {
+		return
+	}
+	for _, table := range tables {
+		rows, ok := this_.getTableRows(table)
+		if ok && rows >= this_.largeTableRows {
+			this_.addIssue(statement, AnalyzeRuleSelectStar, fmt.Sprint("表[", table.tableName, "]约", rows, "行，不建议使用SELECT *查询全部字段"))
+		}
+	}
+}
+
+// checkLeadingLike LIKE 以 % 或 _ 开头 无法 使用 索引
+func (this_ *sqlAnalyzer) checkLeadingLike(statement *AnalyzeStatement, tokens []*sqlToken) {
+	for i, token := range tokens {
+		if !token.is("LIKE", "ILIKE") || i+1 >= len(tokens) {
+			continue
+		}
+		value := tokens[i+1]
+		// LIKE CONCAT('%', ?)
+		if value.is("CONCAT") && i+3 < len(tokens) && tokens[i+2].is("(") {
+			value = tokens[i+3]
+		}
+		if value.kind != sqlTokenString {
+			continue
+		}
+		str := strings.TrimPrefix(value.text, "'")
+		if strings.HasPrefix(str, "%") || strings.HasPrefix(str, "_") {
+			this_.addIssue(statement, AnalyzeRuleLeadingLike, "条件["+token.upper+" "+value.text+"]以通配符开头，无法使用索引")
+		}
+	}
+}
+
+// checkCartesianJoin 逗号 关联 多表 但 没有 关联 条件、CROSS JOIN、JOIN 没有 ON 或 USING
+func (this_ *sqlAnalyzer) checkCartesianJoin(statement *AnalyzeStatement, tokens []*sqlToken) {
+	for i, token := range tokens {
+		if !token.is("FROM") {
+			continue
+		}
+		depth := token.depth
+		var tableCount = 1
+		var end = len(tokens)
+		var whereStart = -1
+		for j := i + 1; j < len(tokens); j++ {
+			one := tokens[j]
+			if one.depth < depth || (one.depth == depth && one.is("GROUP", "ORDER", "HAVING", "LIMIT", "UNION", "EXCEPT", "INTERSECT", "MINUS", "FETCH", "OFFSET", "FOR", "WINDOW", "RETURNING")) {
+				end = j
+				break
+			}
+			if one.depth != depth {
+				continue
+			}
+			if one.is("WHERE") {
+				whereStart = j
+			}
+			if whereStart >= 0 {
+				continue
+			}
+			switch {
+			case one.is(","):
+				tableCount++
+			case one.is("CROSS") && j+1 < len(tokens) && tokens[j+1].is("JOIN"):
+				this_.addIssue(statement, AnalyzeRuleCartesianJoin, "CROSS JOIN将产生笛卡尔积")
+			case one.is("JOIN") && !tokens[j-1].is("NATURAL", "CROSS"):
+				if !joinHasCondition(tokens, j, depth) {
+					this_.addIssue(statement, AnalyzeRuleCartesianJoin, "JOIN没有ON或USING关联条件，将产生笛卡尔积")
+				}
+			}
+		}
+		if tableCount > 1 && (whereStart < 0 || !hasJoinPredicate(tokens[whereStart:end])) {
+			this_.addIssue(statement, AnalyzeRuleCartesianJoin, fmt.Sprint("FROM中", tableCount, "个表没有关联条件，将产生笛卡尔积"))
+		}
+	}
+}
+
+// joinHasCondition JOIN 后 在 下一个 JOIN 或 子句 前 是否 有 ON、USING
+func joinHasCondition(tokens []*sqlToken, joinIndex int, depth int) bool {
+	for j := joinIndex + 1; j < len(tokens); j++ {
+		one := tokens[j]
+		if one.depth < depth {
+			return false
+		}
+		if one.depth != depth {
+			continue
+		}
+		if one.is("ON", "USING") {
+			return true
+		}
+		if one.is("JOIN", "WHERE", "GROUP", "ORDER", "HAVING", "LIMIT", "UNION", ",") {
+			return false
+		}
+	}
+	return false
+}
+
+// hasJoinPredicate 条件 中 是否 存在 a.x = b.y 形式 的 关联
+func hasJoinPredicate(tokens []*sqlToken) bool {
+	for i := 3; i+3 < len(tokens); i++ {
+		if !tokens[i].is("=") {
+			continue
+		}
+		if tokens[i-2].is(".") && tokens[i+2].is(".") && tokens[i-3].isName() && tokens[i+1].isName() && !strings.EqualFold(tokens[i-3].name(), tokens[i+1].name()) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkImplicitConversion 条件 中 字符 字段 与 数字 比较、数字 字段 与 字符串 比较
+func (this_ *sqlAnalyzer) checkImplicitConversion(statement *AnalyzeStatement, tokens []*sqlToken, tables []*analyzeTable) {
+	if len(tables) == 0 {
+		return
+	}
+	for i, token := range tokens {
+		if !token.is("=", "<>", "!=", "<", ">", "<=", ">=", "IN") || i == 0 || i+1 >= len(tokens) {
+			continue
+		}
+		var column, qualifier, value *sqlToken
+		if token.is("IN") {
+			// a IN ('1', '2') 取 第一个 值
+			if i+2 < len(tokens) && tokens[i+1].is("(") {
+				column, qualifier = analyzeColumnBefore(tokens, i)
+				value = analyzeLiteral(tokens, i+2)
+			}
+		} else if value = analyzeLiteral(tokens, i+1); value != nil {
+			column, qualifier = analyzeColumnBefore(tokens, i)
+		} else if value = analyzeLiteral(tokens, i-1); value != nil {
+			// 值 在 前：1 = a、'1' = t.a
+			column, qualifier = analyzeColumnAfter(tokens, i)
+		}
+		if column == nil || value == nil {
+			continue
+		}
+		var qualifierName string
+		if qualifier != nil {
+			qualifierName = qualifier.name()
+		}
+		dataType := this_.findColumnType(tables, qualifierName, column.name())
+		switch sqlDataTypeKind(dataType) {
+		case "string":
+			if value.kind == sqlTokenNumber {
+				this_.addIssue(statement, AnalyzeRuleImplicitConversion, "字段["+column.name()+"]类型为"+dataType+"，与数字"+value.text+"比较将发生隐式转换，可能导致索引失效")
+			}
+		case "number":
+			if value.kind == sqlTokenString {
+				this_.addIssue(statement, AnalyzeRuleImplicitConversion, "字段["+column.name()+"]类型为"+dataType+"，与字符串"+value.text+"比较将发生隐式转换")
+			}
+		}
+	}
+}
+
+// analyzeLiteral 获取 位置 上 的 字符串、数字，负数 取 数字
+func analyzeLiteral(tokens []*sqlToken, index int) *sqlToken {
+	if index < 0 || index >= len(tokens) {
+		return nil
+	}
+	token := tokens[index]
+	if token.is("-") && index+1 < len(tokens) && tokens[index+1].kind == sqlTokenNumber {
+		return tokens[index+1]
+	}
+	if token.kind == sqlTokenString || token.kind == sqlTokenNumber {
+		return token
+	}
+	return nil
+}
+
+// analyzeColumnBefore 获取 运算符 前 的 字段，如 a =、t.a =
+func analyzeColumnBefore(tokens []*sqlToken, index int) (column *sqlToken, qualifier *sqlToken) {
+	if index < 1 || !tokens[index-1].isName() {
+		return
+	}
+	column = tokens[index-1]
+	if index >= 3 && tokens[index-2].is(".") && tokens[index-3].isName() {
+		qualifier = tokens[index-3]
+	}
+	return
+}
+
+// analyzeColumnAfter 获取 运算符 后 的 字段，如 = a、= t.a，函数 调用 不 作为 字段
+func analyzeColumnAfter(tokens []*sqlToken, index int) (column *sqlToken, qualifier *sqlToken) {
+	next := index + 1
+	if next >= len(tokens) || !tokens[next].isName() {
+		return
+	}
+	if next+2 < len(tokens) && tokens[next+1].is(".") && tokens[next+2].isName() {
+		qualifier = tokens[next]
+		next += 2
+	}
+	if next+1 < len(tokens) && tokens[next+1].is("(", ".") {
+		return nil, nil
+	}
+	column = tokens[next]
+	return
+}
+
+// sqlDataTypeKind 字段 类型 分类，返回 number、string，其它 返回 空
+func sqlDataTypeKind(dataType string) string {
+	t := strings.ToUpper(strings.TrimSpace(dataType))
+	if index := strings.Index(t, "("); index >= 0 {
+		t = strings.TrimSpace(t[:index])
+	}
+	t = strings.TrimSpace(strings.TrimSuffix(strings.TrimSuffix(t, " ZEROFILL"), " UNSIGNED"))
+	switch t {
+	case "TINYINT", "SMALLINT", "MEDIUMINT", "INT", "INTEGER", "BIGINT", "INT2", "INT4", "INT8", "DECIMAL", "NUMERIC", "NUMBER",
+		"FLOAT", "FLOAT4", "FLOAT8", "DOUBLE", "DOUBLE PRECISION", "REAL", "SERIAL", "BIGSERIAL", "SMALLSERIAL", "BINARY_FLOAT", "BINARY_DOUBLE":
+		return "number"
+	case "CHAR", "VARCHAR", "VARCHAR2", "NCHAR", "NVARCHAR", "NVARCHAR2", "CHARACTER", "CHARACTER VARYING", "BPCHAR",
+		"TEXT", "TINYTEXT", "MEDIUMTEXT", "LONGTEXT", "CLOB", "NCLOB":
+		return "string"
+	}
+	return ""
+}
+
+// analyzeTables 获取 语句 中 FROM、JOIN、UPDATE、INTO 后 的 表 及 别名
+func analyzeTables(tokens []*sqlToken) (tables []*analyzeTable) {
+	var inFrom = map[int]bool{} // 每个 括号 层级 是否 在 FROM 子句 中
+	for i := 0; i < len(tokens); i++ {
+		token := tokens[i]
+		switch {
+		case token.is("FROM"):
+			inFrom[token.depth] = true
+		case token.is("WHERE", "GROUP", "ORDER", "HAVING", "LIMIT", "SET", "VALUES", "UNION", "SELECT", "ON", "USING"):
+			inFrom[token.depth] = false
+		}
+		var isTableStart = token.is("FROM", "JOIN", "UPDATE", "INTO") || (token.is(",") && inFrom[token.depth])
+		if !isTableStart || i+1 >= len(tokens) || !tokens[i+1].isName() {
+			continue
+		}
+		table := &analyzeTable{}
+		j := i + 1
+		table.tableName = tokens[j].name()
+		for j+2 < len(tokens) && tokens[j+1].is(".") && tokens[j+2].isName() {
+			table.ownerName = table.tableName
+			table.tableName = tokens[j+2].name()
+			j += 2
+		}
+		if !token.is("INTO") && j+1 < len(tokens) && tokens[j+1].is("(") {
+			// 函数，如 FROM unnest(...)
+			continue
+		}
+		if j+2 < len(tokens) && tokens[j+1].is("AS") && tokens[j+2].isName() {
+			table.alias = tokens[j+2].name()
+		} else if j+1 < len(tokens) && tokens[j+1].isName() {
+			table.alias = tokens[j+1].name()
+		}
+		tables = append(tables, table)
+	}
+	return
+}
+
+// findColumnType 根据 表 名、别名 查找 字段 类型
+func (this_ *sqlAnalyzer) findColumnType(tables []*analyzeTable, qualifier string, columnName string) string {
+	for _, table := range tables {
+		if qualifier != "" && !strings.EqualFold(qualifier, table.alias) && !strings.EqualFold(qualifier, table.tableName) {
+			continue
+		}
+		for _, column := range this_.getTableColumns(table) {
+			if strings.EqualFold(column.ColumnName, columnName) {
+				return column.ColumnDataType
+			}
+		}
+	}
+	return ""
+}
+
+func (this_ *sqlAnalyzer) tableOwner(table *analyzeTable) string {
+	if table.ownerName != "" {
+		return table.ownerName
+	}
+	return this_.ownerName
+}
+
+// tableNameCase 未 使用 引号 的 名称，Oracle、达梦 为 大写，PostgreSQL 为 小写
+func (this_ *sqlAnalyzer) tableNameCase(name string) string {
+	switch this_.service.GetDialect().DialectType() {
+	case dialect.TypeOracle, dialect.TypeDM:
+		return strings.ToUpper(name)
+	case dialect.TypePostgresql, dialect.TypeKingBase, dialect.TypeOpenGauss:
+		return strings.ToLower(name)
+	}
+	return name
+}
+
+func (this_ *sqlAnalyzer) getTableColumns(table *analyzeTable) (columns []*dialect.ColumnModel) {
+	ownerName := this_.tableOwner(table)
+	key := ownerName + "." + table.tableName
+	columns, ok := this_.tableColumns[key]
+	if ok {
+		return
+	}
+	columns, _ = worker.ColumnsSelect(this_.service.GetDb(), this_.service.GetDialect(), this_.param, ownerName, this_.tableNameCase(table.tableName), true)
+	this_.tableColumns[key] = columns
+	return
+}
+
+// getTableRows 查询 表 的 统计 行数，不支持 的 数据库 返回 false
+func (this_ *sqlAnalyzer) getTableRows(table *analyzeTable) (rows int64, ok bool) {
+	ownerName := this_.tableOwner(table)
+	if ownerName == "" {
+		return
+	}
+	key := ownerName + "." + table.tableName
+	if rows, ok = this_.tableRows[key]; ok {
+		return
+	}
+	var sqlList []string
+	switch this_.service.GetDialect().DialectType() {
+	case dialect.TypeMysql:
+		sqlList = []string{`SELECT TABLE_ROWS AS tableRows FROM information_schema.TABLES WHERE TABLE_SCHEMA = ? AND TABLE_NAME = ?`}
+	case dialect.TypePostgresql, dialect.TypeKingBase, dialect.TypeOpenGauss:
+		sqlList = []string{`SELECT c.reltuples AS "tableRows" FROM pg_catalog.pg_class c JOIN pg_catalog.pg_namespace n ON n.oid = c.relnamespace WHERE n.nspname = $1 AND c.relname = $2`}
+	case dialect.TypeOracle, dialect.TypeDM:
+		sqlList = []string{`SELECT NUM_ROWS AS "tableRows" FROM ALL_TABLES WHERE OWNER = :1 AND TABLE_NAME = :2`}
+	default:
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), analyzeQueryTimeout)
+	defer cancel()
+	dataList, err := queryDbObject(ctx, this_.service, sqlList, ownerName, this_.tableNameCase(table.tableName))
+	if err != nil || len(dataList) == 0 {
+		this_.tableRows[key] = 0
+		return
+	}
+	rows = int64(util.StringToFloat64(util.GetStringValue(dataList[0]["tableRows"])))
+	ok = true
+	this_.tableRows[key] = rows
+	return
+}
+
+// checkAnalyzeRules 工具 配置 了 阻止 执行 的 分析 规则 时，执行 前 分析 SQL
+func (this_ *api) checkAnalyzeRules(requestBean *base.RequestBean, service db.IService, param *db.Param, request *BaseRequest) (err error) {
+	databaseOption, err := this_.getDatabaseOption(requestBean)
+	if err != nil {
+		return
+	}
+	analyzer := newSqlAnalyzer(service, param.ParamModel, request.OwnerName, databaseOption)
+	if !analyzer.hasBlockRule() {
+		return
+	}
+	res := analyzer.analyze(request.ExecuteSQL)
+	if !res.Blocked {
+		return
+	}
+	var messages []string
+	for _, statement := range res.StatementList {
+		for _, issue := range statement.Issues {
+			if issue.Level == AnalyzeLevelBlock {
+				messages = append(messages, issue.Message)
+			}
+		}
+	}
+	err = errors.New("SQL分析未通过，当前工具禁止执行：" + strings.Join(messages, "；"))
+	return
+}
+
+func (this_ *api) analyze(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	config, sshConfig, err := this_.getConfig(requestBean, c)
+	if err != nil {
+		return
+	}
+	service, err := getService(config, sshConfig)
+	if err != nil {
+		return
+	}
+
+	var request = &BaseRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	param := this_.getParam(requestBean, c)
+	databaseOption, err := this_.getDatabaseOption(requestBean)
+	if err != nil {
+		return
+	}
+	res = newSqlAnalyzer(service, param.ParamModel, request.OwnerName, databaseOption).analyze(request.ExecuteSQL)
+	return
+}
@@ -23,53 +23,150 @@ import (
 	"net/http"
 	"net/url"
 	"os"
+	"strings"
 	"sync"
+	"teamide/internal/module/module_log"
 	"teamide/internal/module/module_toolbox"
 	"teamide/pkg/base"
 	"teamide/pkg/ssh"
 )
 
 type api struct {
-	toolboxService *module_toolbox.ToolboxService
+	toolboxService    *module_toolbox.ToolboxService
+	sqlHistoryService *SqlHistoryService
+	dataEditService   *DataEditService
+	backupService     *BackupService
+	logService        *module_log.LogService
 }
 
-func NewApi(toolboxService *module_toolbox.ToolboxService) *api {
+func NewApi(toolboxService *module_toolbox.ToolboxService, backupService *BackupService) *api {
 	return &api{
-		toolboxService: toolboxService,
+		toolboxService:    toolboxService,
+		sqlHistoryService: NewSqlHistoryService(toolboxService.ServerContext),
+		dataEditService:   NewDataEditService(toolboxService.ServerContext),
+		backupService:     backupService,
+		logService:        module_log.NewLogService(toolboxService.ServerContext),
 	}
 }
 
 var (
-	Power               = base.AppendPower(&base.PowerAction{Action: "database", Text: "数据库", ShouldLogin: true, StandAlone: true})
-	check               = base.AppendPower(&base.PowerAction{Action: "check", Text: "数据库测试", ShouldLogin: true, StandAlone: true, Parent: Power})
-	infoPower           = base.AppendPower(&base.PowerAction{Action: "info", Text: "数据库信息", ShouldLogin: true, StandAlone: true, Parent: Power})
-	dataPower           = base.AppendPower(&base.PowerAction{Action: "data", Text: "数据库基础数据", ShouldLogin: true, StandAlone: true, Parent: Power})
-	ownersPower         = base.AppendPower(&base.PowerAction{Action: "owners", Text: "数据库查询", ShouldLogin: true, StandAlone: true, Parent: Power})
-	ownerCreatePower    = base.AppendPower(&base.PowerAction{Action: "ownerCreate", Text: "数据库库创建", ShouldLogin: true, StandAlone: true, Parent: Power})
-	ownerDeletePower    = base.AppendPower(&base.PowerAction{Action: "ownerDelete", Text: "数据库库删除", ShouldLogin: true, StandAlone: true, Parent: Power})
-	ownerCreateSqlPower = base.AppendPower(&base.PowerAction{Action: "ownerCreateSql", Text: "数据库库删除SQL", ShouldLogin: true, StandAlone: true, Parent: Power})
-	ddlPower            = base.AppendPower(&base.PowerAction{Action: "ddl", Text: "数据库DDL", ShouldLogin: true, StandAlone: true, Parent: Power})
-	modelPower          = base.AppendPower(&base.PowerAction{Action: "model", Text: "数据库模型", ShouldLogin: true, StandAlone: true, Parent: Power})
-	tablesPower         = base.AppendPower(&base.PowerAction{Action: "tables", Text: "数据库库表查询", ShouldLogin: true, StandAlone: true, Parent: Power})
-	tableDetailPower    = base.AppendPower(&base.PowerAction{Action: "tableDetail", Text: "数据库库表详细信息查询", ShouldLogin: true, StandAlone: true, Parent: Power})
-	tableCreatePower    = base.AppendPower(&base.PowerAction{Action: "tableCreate", Text: "数据库创建表", ShouldLogin: true, StandAlone: true, Parent: Power})
-	tableCreateSqlPower = base.AppendPower(&base.PowerAction{Action: "tableCreateSql", Text: "数据库创建表SQL", ShouldLogin: true, StandAlone: true, Parent: Power})
-	tableUpdatePower    = base.AppendPower(&base.PowerAction{Action: "tableUpdate", Text: "数据库修改表", ShouldLogin: true, StandAlone: true, Parent: Power})
-	tableUpdateSqlPower = base.AppendPower(&base.PowerAction{Action: "tableUpdateSql", Text: "数据库修改表SQL", ShouldLogin: true, StandAlone: true, Parent: Power})
-	tableDeletePower    = base.AppendPower(&base.PowerAction{Action: "tableDelete", Text: "数据库删除表", ShouldLogin: true, StandAlone: true, Parent: Power})
-	tableDataTrimPower  = base.AppendPower(&base.PowerAction{Action: "tableDataTrim", Text: "数据库表数据清空", ShouldLogin: true, StandAlone: true, Parent: Power})
-	tableDataPower      = base.AppendPower(&base.PowerAction{Action: "tableData", Text: "数据库表数据查询", ShouldLogin: true, StandAlone: true, Parent: Power})
-	dataListSqlPower    = base.AppendPower(&base.PowerAction{Action: "dataListSql", Text: "数据库数据转换SQL", ShouldLogin: true, StandAlone: true, Parent: Power})
-	dataListExecPower   = base.AppendPower(&base.PowerAction{Action: "dataListExec", Text: "数据库数据执行", ShouldLogin: true, StandAlone: true, Parent: Power})
-	executeSQLPower     = base.AppendPower(&base.PowerAction{Action: "executeSQL", Text: "数据库SQL执行", ShouldLogin: true, StandAlone: true, Parent: Power})
-	importPower         = base.AppendPower(&base.PowerAction{Action: "import", Text: "数据库导入", ShouldLogin: true, StandAlone: true, Parent: Power})
-	exportPower         = base.AppendPower(&base.PowerAction{Action: "export", Text: "数据库导出", ShouldLogin: true, StandAlone: true, Parent: Power})
-	exportDownloadPower = base.AppendPower(&base.PowerAction{Action: "exportDownload", Text: "数据库导出下载", ShouldLogin: true, StandAlone: true, Parent: Power})
-	syncPower           = base.AppendPower(&base.PowerAction{Action: "sync", Text: "数据库同步", ShouldLogin: true, StandAlone: true, Parent: Power})
-	taskStatusPower     = base.AppendPower(&base.PowerAction{Action: "taskStatus", Text: "数据库任务状态查询", ShouldLogin: true, StandAlone: true, Parent: Power})
-	taskStopPower       = base.AppendPower(&base.PowerAction{Action: "taskStop", Text: "数据库任务停止", ShouldLogin: true, StandAlone: true, Parent: Power})
-	taskCleanPower      = base.AppendPower(&base.PowerAction{Action: "taskClean", Text: "数据库任务清理", ShouldLogin: true, StandAlone: true, Parent: Power})
-	closePower          = base.AppendPower(&base.PowerAction{Action: "close", Text: "数据库关闭", ShouldLogin: true, StandAlone: true, Parent: Power})
+	Power                    = base.AppendPower(&base.PowerAction{Action: "database", Text: "数据库", ShouldLogin: true, StandAlone: true})
+	check                    = base.AppendPower(&base.PowerAction{Action: "check", Text: "数据库测试", ShouldLogin: true, StandAlone: true, Parent: Power})
+	infoPower                = base.AppendPower(&base.PowerAction{Action: "info", Text: "数据库信息", ShouldLogin: true, StandAlone: true, Parent: Power})
+	dataPower                = base.AppendPower(&base.PowerAction{Action: "data", Text: "数据库基础数据", ShouldLogin: true, StandAlone: true, Parent: Power})
+	ownersPower              = base.AppendPower(&base.PowerAction{Action: "owners", Text: "数据库查询", ShouldLogin: true, StandAlone: true, Parent: Power})
+	ownerCreatePower         = base.AppendPower(&base.PowerAction{Action: "ownerCreate", Text: "数据库库创建", ShouldLogin: true, StandAlone: true, Parent: Power})
+	ownerDeletePower         = base.AppendPower(&base.PowerAction{Action: "ownerDelete", Text: "数据库库删除", ShouldLogin: true, StandAlone: true, Parent: Power})
+	ownerCreateSqlPower      = base.AppendPower(&base.PowerAction{Action: "ownerCreateSql", Text: "数据库库删除SQL", ShouldLogin: true, StandAlone: true, Parent: Power})
+	ddlPower                 = base.AppendPower(&base.PowerAction{Action: "ddl", Text: "数据库DDL", ShouldLogin: true, StandAlone: true, Parent: Power})
+	modelPower               = base.AppendPower(&base.PowerAction{Action: "model", Text: "数据库模型", ShouldLogin: true, StandAlone: true, Parent: Power})
+	tablesPower              = base.AppendPower(&base.PowerAction{Action: "tables", Text: "数据库库表查询", ShouldLogin: true, StandAlone: true, Parent: Power})
+	tableDetailPower         = base.AppendPower(&base.PowerAction{Action: "tableDetail", Text: "数据库库表详细信息查询", ShouldLogin: true, StandAlone: true, Parent: Power})
+	tableCreatePower         = base.AppendPower(&base.PowerAction{Action: "tableCreate", Text: "数据库创建表", ShouldLogin: true, StandAlone: true, Parent: Power})
+	tableCreateSqlPower      = base.AppendPower(&base.PowerAction{Action: "tableCreateSql", Text: "数据库创建表SQL", ShouldLogin: true, StandAlone: true, Parent: Power})
+	tableUpdatePower         = base.AppendPower(&base.PowerAction{Action: "tableUpdate", Text: "数据库修改表", ShouldLogin: true, StandAlone: true, Parent: Power})
+	tableUpdateSqlPower      = base.AppendPower(&base.PowerAction{Action: "tableUpdateSql", Text: "数据库修改表SQL", ShouldLogin: true, StandAlone: true, Parent: Power})
+	tableDeletePower         = base.AppendPower(&base.PowerAction{Action: "tableDelete", Text: "数据库删除表", ShouldLogin: true, StandAlone: true, Parent: Power})
+	tableDataTrimPower       = base.AppendPower(&base.PowerAction{Action: "tableDataTrim", Text: "数据库表数据清空", ShouldLogin: true, StandAlone: true, Parent: Power})
+	tableDataPower           = base.AppendPower(&base.PowerAction{Action: "tableData", Text: "数据库表数据查询", ShouldLogin: true, StandAlone: true, Parent: Power})
+	dataListSqlPower         = base.AppendPower(&base.PowerAction{Action: "dataListSql", Text: "数据库数据转换SQL", ShouldLogin: true, StandAlone: true, Parent: Power})
+	dataListExecPower        = base.AppendPower(&base.PowerAction{Action: "dataListExec", Text: "数据库数据执行", ShouldLogin: true, StandAlone: true, Parent: Power})
+	executeSQLPower          = base.AppendPower(&base.PowerAction{Action: "executeSQL", Text: "数据库SQL执行", ShouldLogin: true, StandAlone: true, Parent: Power})
+	queryCancelPower         = base.AppendPower(&base.PowerAction{Action: "queryCancel", Text: "数据库SQL执行取消", ShouldLogin: true, StandAlone: true, Parent: Power})
+	importPower              = base.AppendPower(&base.PowerAction{Action: "import", Text: "数据库导入", ShouldLogin: true, StandAlone: true, Parent: Power})
+	exportPower              = base.AppendPower(&base.PowerAction{Action: "export", Text: "数据库导出", ShouldLogin: true, StandAlone: true, Parent: Power})
+	exportDownloadPower      = base.AppendPower(&base.PowerAction{Action: "exportDownload", Text: "数据库导出下载", ShouldLogin: true, StandAlone: true, Parent: Power})
+	syncPower                = base.AppendPower(&base.PowerAction{Action: "sync", Text: "数据库同步", ShouldLogin: true, StandAlone: true, Parent: Power})
+	taskStatusPower          = base.AppendPower(&base.PowerAction{Action: "taskStatus", Text: "数据库任务状态查询", ShouldLogin: true, StandAlone: true, Parent: Power})
+	taskStopPower            = base.AppendPower(&base.PowerAction{Action: "taskStop", Text: "数据库任务停止", ShouldLogin: true, StandAlone: true, Parent: Power})
+	taskCleanPower           = base.AppendPower(&base.PowerAction{Action: "taskClean", Text: "数据库任务清理", ShouldLogin: true, StandAlone: true, Parent: Power})
+	closePower               = base.AppendPower(&base.PowerAction{Action: "close", Text: "数据库关闭", ShouldLogin: true, StandAlone: true, Parent: Power})
+	schemaDiffPower          = base.AppendPower(&base.PowerAction{Action: "schemaDiff", Text: "数据库结构对比", ShouldLogin: true, StandAlone: true, Parent: Power})
+	erDiagramPower           = base.AppendPower(&base.PowerAction{Action: "erDiagram", Text: "数据库ER图", ShouldLogin: true, StandAlone: true, Parent: Power})
+	dataComparePower         = base.AppendPower(&base.PowerAction{Action: "dataCompare", Text: "数据库数据比对", ShouldLogin: true, StandAlone: true, Parent: Power})
+	dataCompareDownloadPower = base.AppendPower(&base.PowerAction{Action: "dataCompareDownload", Text: "数据库数据比对SQL下载", ShouldLogin: true, StandAlone: true, Parent: Power})
+	downloadPower            = base.AppendPower(&base.PowerAction{Action: "download", Text: "数据库文件下载", ShouldLogin: true, StandAlone: true, Parent: Power})
+
+	transactionBeginPower    = base.AppendPower(&base.PowerAction{Action: "transaction/begin", Text: "数据库事务开启", ShouldLogin: true, StandAlone: true, Parent: Power})
+	transactionCommitPower   = base.AppendPower(&base.PowerAction{Action: "transaction/commit", Text: "数据库事务提交", ShouldLogin: true, StandAlone: true, Parent: Power})
+	transactionRollbackPower = base.AppendPower(&base.PowerAction{Action: "transaction/rollback", Text: "数据库事务回滚", ShouldLogin: true, StandAlone: true, Parent: Power})
+	transactionStatusPower   = base.AppendPower(&base.PowerAction{Action: "transaction/status", Text: "数据库事务状态", ShouldLogin: true, StandAlone: true, Parent: Power})
+
+	historyPower       = base.AppendPower(&base.PowerAction{Action: "history", Text: "数据库SQL历史", ShouldLogin: true, StandAlone: true, Parent: Power})
+	historyQueryPower  = base.AppendPower(&base.PowerAction{Action: "query", Text: "数据库SQL历史查询", ShouldLogin: true, StandAlone: true, Parent: historyPower})
+	historySavePower   = base.AppendPower(&base.PowerAction{Action: "save", Text: "数据库SQL历史保存", ShouldLogin: true, StandAlone: true, Parent: historyPower})
+	historyUnsavePower = base.AppendPower(&base.PowerAction{Action: "unsave", Text: "数据库SQL历史取消保存", ShouldLogin: true, StandAlone: true, Parent: historyPower})
+	historyDeletePower = base.AppendPower(&base.PowerAction{Action: "delete", Text: "数据库SQL历史删除", ShouldLogin: true, StandAlone: true, Parent: historyPower})
+	historyCleanPower  = base.AppendPower(&base.PowerAction{Action: "clean", Text: "数据库SQL历史清理", ShouldLogin: true, StandAlone: true, Parent: historyPower})
+
+	dataEditPower      = base.AppendPower(&base.PowerAction{Action: "dataEdit", Text: "数据库数据编辑记录", ShouldLogin: true, StandAlone: true, Parent: Power})
+	dataEditQueryPower = base.AppendPower(&base.PowerAction{Action: "query", Text: "数据库数据编辑记录查询", ShouldLogin: true, StandAlone: true, Parent: dataEditPower})
+	dataEditUndoPower  = base.AppendPower(&base.PowerAction{Action: "undo", Text: "数据库数据编辑撤销", ShouldLogin: true, StandAlone: true, Parent: dataEditPower})
+
+	activityPower         = base.AppendPower(&base.PowerAction{Action: "activity", Text: "数据库活动监控", ShouldLogin: true, StandAlone: true, Parent: Power})
+	activitySessionsPower = base.AppendPower(&base.PowerAction{Action: "sessions", Text: "数据库会话查询", ShouldLogin: true, StandAlone: true, Parent: activityPower})
+	activityLocksPower    = base.AppendPower(&base.PowerAction{Action: "locks", Text: "数据库锁等待查询", ShouldLogin: true, StandAlone: true, Parent: activityPower})
+	activitySlowPower     = base.AppendPower(&base.PowerAction{Action: "slow", Text: "数据库慢语句查询", ShouldLogin: true, StandAlone: true, Parent: activityPower})
+	activityKillPower     = base.AppendPower(&base.PowerAction{Action: "kill", Text: "数据库会话终止", ShouldLogin: true, StandAlone: true, Parent: activityPower})
+
+	viewPower     = base.AppendPower(&base.PowerAction{Action: "view", Text: "数据库视图管理", ShouldLogin: true, StandAlone: true, Parent: Power})
+	viewListPower = base.AppendPower(&base.PowerAction{Action: "list", Text: "数据库视图查询", ShouldLogin: true, StandAlone: true, Parent: viewPower})
+	viewDdlPower  = base.AppendPower(&base.PowerAction{Action: "ddl", Text: "数据库视图定义查询", ShouldLogin: true, StandAlone: true, Parent: viewPower})
+	viewSavePower = base.AppendPower(&base.PowerAction{Action: "save", Text: "数据库视图保存", ShouldLogin: true, StandAlone: true, Parent: viewPower})
+	viewDropPower = base.AppendPower(&base.PowerAction{Action: "drop", Text: "数据库视图删除", ShouldLogin: true, StandAlone: true, Parent: viewPower})
+
+	procedurePower        = base.AppendPower(&base.PowerAction{Action: "procedure", Text: "数据库存储过程管理", ShouldLogin: true, StandAlone: true, Parent: Power})
+	procedureListPower    = base.AppendPower(&base.PowerAction{Action: "list", Text: "数据库存储过程查询", ShouldLogin: true, StandAlone: true, Parent: procedurePower})
+	procedureDdlPower     = base.AppendPower(&base.PowerAction{Action: "ddl", Text: "数据库存储过程定义查询", ShouldLogin: true, StandAlone: true, Parent: procedurePower})
+	procedureSavePower    = base.AppendPower(&base.PowerAction{Action: "save", Text: "数据库存储过程保存", ShouldLogin: true, StandAlone: true, Parent: procedurePower})
+	procedureDropPower    = base.AppendPower(&base.PowerAction{Action: "drop", Text: "数据库存储过程删除", ShouldLogin: true, StandAlone: true, Parent: procedurePower})
+	procedureExecutePower = base.AppendPower(&base.PowerAction{Action: "execute", Text: "数据库存储过程执行", ShouldLogin: true, StandAlone: true, Parent: procedurePower})
+
+	functionPower        = base.AppendPower(&base.PowerAction{Action: "function", Text: "数据库函数管理", ShouldLogin: true, StandAlone: true, Parent: Power})
+	functionListPower    = base.AppendPower(&base.PowerAction{Action: "list", Text: "数据库函数查询", ShouldLogin: true, StandAlone: true, Parent: functionPower})
+	functionDdlPower     = base.AppendPower(&base.PowerAction{Action: "ddl", Text: "数据库函数定义查询", ShouldLogin: true, StandAlone: true, Parent: functionPower})
+	functionSavePower    = base.AppendPower(&base.PowerAction{Action: "save", Text: "数据库函数保存", ShouldLogin: true, StandAlone: true, Parent: functionPower})
+	functionDropPower    = base.AppendPower(&base.PowerAction{Action: "drop", Text: "数据库函数删除", ShouldLogin: true, StandAlone: true, Parent: functionPower})
+	functionExecutePower = base.AppendPower(&base.PowerAction{Action: "execute", Text: "数据库函数执行", ShouldLogin: true, StandAlone: true, Parent: functionPower})
+
+	triggerPower     = base.AppendPower(&base.PowerAction{Action: "trigger", Text: "数据库触发器管理", ShouldLogin: true, StandAlone: true, Parent: Power})
+	triggerListPower = base.AppendPower(&base.PowerAction{Action: "list", Text: "数据库触发器查询", ShouldLogin: true, StandAlone: true, Parent: triggerPower})
+	triggerDdlPower  = base.AppendPower(&base.PowerAction{Action: "ddl", Text: "数据库触发器定义查询", ShouldLogin: true, StandAlone: true, Parent: triggerPower})
+	triggerSavePower = base.AppendPower(&base.PowerAction{Action: "save", Text: "数据库触发器保存", ShouldLogin: true, StandAlone: true, Parent: triggerPower})
+	triggerDropPower = base.AppendPower(&base.PowerAction{Action: "drop", Text: "数据库触发器删除", ShouldLogin: true, StandAlone: true, Parent: triggerPower})
+
+	sequencePower     = base.AppendPower(&base.PowerAction{Action: "sequence", Text: "数据库序列管理", ShouldLogin: true, StandAlone: true, Parent: Power})
+	sequenceListPower = base.AppendPower(&base.PowerAction{Action: "list", Text: "数据库序列查询", ShouldLogin: true, StandAlone: true, Parent: sequencePower})
+	sequenceDdlPower  = base.AppendPower(&base.PowerAction{Action: "ddl", Text: "数据库序列定义查询", ShouldLogin: true, StandAlone: true, Parent: sequencePower})
+	sequenceSavePower = base.AppendPower(&base.PowerAction{Action: "save", Text: "数据库序列保存", ShouldLogin: true, StandAlone: true, Parent: sequencePower})
+	sequenceDropPower = base.AppendPower(&base.PowerAction{Action: "drop", Text: "数据库序列删除", ShouldLogin: true, StandAlone: true, Parent: sequencePower})
+
+	accountPower        = base.AppendPower(&base.PowerAction{Action: "account", Text: "数据库用户权限管理", ShouldLogin: true, StandAlone: true, Parent: Power})
+	accountListPower    = base.AppendPower(&base.PowerAction{Action: "list", Text: "数据库用户查询", ShouldLogin: true, StandAlone: true, Parent: accountPower})
+	accountGrantsPower  = base.AppendPower(&base.PowerAction{Action: "grants", Text: "数据库用户权限查询", ShouldLogin: true, StandAlone: true, Parent: accountPower})
+	accountSqlPower     = base.AppendPower(&base.PowerAction{Action: "sql", Text: "数据库用户权限SQL", ShouldLogin: true, StandAlone: true, Parent: accountPower})
+	accountExecutePower = base.AppendPower(&base.PowerAction{Action: "execute", Text: "数据库用户权限执行", ShouldLogin: true, StandAlone: true, Parent: accountPower})
+
+	backupPower             = base.AppendPower(&base.PowerAction{Action: "backup", Text: "数据库备份", ShouldLogin: true, StandAlone: true, Parent: Power})
+	backupListPower         = base.AppendPower(&base.PowerAction{Action: "list", Text: "数据库备份任务查询", ShouldLogin: true, StandAlone: true, Parent: backupPower})
+	backupSavePower         = base.AppendPower(&base.PowerAction{Action: "save", Text: "数据库备份任务保存", ShouldLogin: true, StandAlone: true, Parent: backupPower})
+	backupDeletePower       = base.AppendPower(&base.PowerAction{Action: "delete", Text: "数据库备份任务删除", ShouldLogin: true, StandAlone: true, Parent: backupPower})
+	backupRunPower          = base.AppendPower(&base.PowerAction{Action: "run", Text: "数据库备份执行", ShouldLogin: true, StandAlone: true, Parent: backupPower})
+	backupRecordsPower      = base.AppendPower(&base.PowerAction{Action: "records", Text: "数据库备份记录查询", ShouldLogin: true, StandAlone: true, Parent: backupPower})
+	backupRecordDeletePower = base.AppendPower(&base.PowerAction{Action: "recordDelete", Text: "数据库备份记录删除", ShouldLogin: true, StandAlone: true, Parent: backupPower})
+	backupDownloadPower     = base.AppendPower(&base.PowerAction{Action: "download", Text: "数据库备份下载", ShouldLogin: true, StandAlone: true, Parent: backupPower})
+	backupRestorePower      = base.AppendPower(&base.PowerAction{Action: "restore", Text: "数据库备份恢复", ShouldLogin: true, StandAlone: true, Parent: backupPower})
+
+	dataDictionaryPower = base.AppendPower(&base.PowerAction{Action: "dataDictionary", Text: "数据库数据字典", ShouldLogin: true, StandAlone: true, Parent: Power})
+
+	federatedQueryPower = base.AppendPower(&base.PowerAction{Action: "federatedQuery", Text: "数据库联合查询", ShouldLogin: true, StandAlone: true, Parent: Power})
+
+	analyzePower = base.AppendPower(&base.PowerAction{Action: "analyze", Text: "数据库SQL分析", ShouldLogin: true, StandAlone: true, Parent: Power})
+
+	catalogPower        = base.AppendPower(&base.PowerAction{Action: "catalog", Text: "数据库结构缓存", ShouldLogin: true, StandAlone: true, Parent: Power})
+	catalogSearchPower  = base.AppendPower(&base.PowerAction{Action: "search", Text: "数据库结构缓存搜索", ShouldLogin: true, StandAlone: true, Parent: catalogPower})
+	catalogInfoPower    = base.AppendPower(&base.PowerAction{Action: "info", Text: "数据库结构缓存状态", ShouldLogin: true, StandAlone: true, Parent: catalogPower})
+	catalogRefreshPower = base.AppendPower(&base.PowerAction{Action: "refresh", Text: "数据库结构缓存刷新", ShouldLogin: true, StandAlone: true, Parent: catalogPower})
 
 	testStart  = base.AppendPower(&base.PowerAction{Action: "test/start", Text: "测试开始", ShouldLogin: true, StandAlone: true, Parent: Power})
 	testInfo   = base.AppendPower(&base.PowerAction{Action: "test/info", Text: "测试任务信息", ShouldLogin: true, StandAlone: true, Parent: Power})
@@ -100,6 +197,7 @@ func (this_ *api) GetApis() (apis []*base.ApiWorker) {
 	apis = append(apis, &base.ApiWorker{Power: dataListSqlPower, Do: this_.dataListSql})
 	apis = append(apis, &base.ApiWorker{Power: dataListExecPower, Do: this_.dataListExec})
 	apis = append(apis, &base.ApiWorker{Power: executeSQLPower, Do: this_.executeSQL})
+	apis = append(apis, &base.ApiWorker{Power: queryCancelPower, Do: this_.queryCancel})
 	apis = append(apis, &base.ApiWorker{Power: importPower, Do: this_._import})
 	apis = append(apis, &base.ApiWorker{Power: exportPower, Do: this_.export})
 	apis = append(apis, &base.ApiWorker{Power: exportDownloadPower, Do: this_.exportDownload})
@@ -107,6 +205,82 @@ func (this_ *api) GetApis() (apis []*base.ApiWorker) {
 	apis = append(apis, &base.ApiWorker{Power: taskStatusPower, Do: this_.taskStatus, NotRecodeLog: true})
 	apis = append(apis, &base.ApiWorker{Power: taskStopPower, Do: this_.taskStop})
 	apis = append(apis, &base.ApiWorker{Power: taskCleanPower, Do: this_.taskClean})
+	apis = append(apis, &base.ApiWorker{Power: schemaDiffPower, Do: this_.schemaDiff})
+	apis = append(apis, &base.ApiWorker{Power: erDiagramPower, Do: this_.erDiagram})
+	apis = append(apis, &base.ApiWorker{Power: dataComparePower, Do: this_.dataCompare})
+	apis = append(apis, &base.ApiWorker{Power: dataCompareDownloadPower, Do: this_.dataCompareDownload})
+	apis = append(apis, &base.ApiWorker{Power: downloadPower, Do: this_.download})
+
+	apis = append(apis, &base.ApiWorker{Power: transactionBeginPower, Do: this_.transactionBegin})
+	apis = append(apis, &base.ApiWorker{Power: transactionCommitPower, Do: this_.transactionCommit})
+	apis = append(apis, &base.ApiWorker{Power: transactionRollbackPower, Do: this_.transactionRollback})
+	apis = append(apis, &base.ApiWorker{Power: transactionStatusPower, Do: this_.transactionStatus, NotRecodeLog: true})
+
+	apis = append(apis, &base.ApiWorker{Power: historyQueryPower, Do: this_.historyQuery, NotRecodeLog: true})
+	apis = append(apis, &base.ApiWorker{Power: historySavePower, Do: this_.historySave})
+	apis = append(apis, &base.ApiWorker{Power: historyUnsavePower, Do: this_.historyUnsave})
+	apis = append(apis, &base.ApiWorker{Power: historyDeletePower, Do: this_.historyDelete})
+	apis = append(apis, &base.ApiWorker{Power: historyCleanPower, Do: this_.historyClean})
+
+	apis = append(apis, &base.ApiWorker{Power: dataEditQueryPower, Do: this_.dataEditQuery, NotRecodeLog: true})
+	apis = append(apis, &base.ApiWorker{Power: dataEditUndoPower, Do: this_.dataEditUndo})
+
+	apis = append(apis, &base.ApiWorker{Power: activitySessionsPower, Do: this_.activitySessions, NotRecodeLog: true})
+	apis = append(apis, &base.ApiWorker{Power: activityLocksPower, Do: this_.activityLocks, NotRecodeLog: true})
+	apis = append(apis, &base.ApiWorker{Power: activitySlowPower, Do: this_.activitySlow, NotRecodeLog: true})
+	apis = append(apis, &base.ApiWorker{Power: activityKillPower, Do: this_.activityKill})
+
+	apis = append(apis, &base.ApiWorker{Power: viewListPower, Do: this_.dbObjectList(ObjectTypeView), NotRecodeLog: true})
+	apis = append(apis, &base.ApiWorker{Power: viewDdlPower, Do: this_.dbObjectDetail(ObjectTypeView), NotRecodeLog: true})
+	apis = append(apis, &base.ApiWorker{Power: viewSavePower, Do: this_.dbObjectSave(ObjectTypeView)})
+	apis = append(apis, &base.ApiWorker{Power: viewDropPower, Do: this_.dbObjectDrop(ObjectTypeView)})
+
+	apis = append(apis, &base.ApiWorker{Power: procedureListPower, Do: this_.dbObjectList(ObjectTypeProcedure), NotRecodeLog: true})
+	apis = append(apis, &base.ApiWorker{Power: procedureDdlPower, Do: this_.dbObjectDetail(ObjectTypeProcedure), NotRecodeLog: true})
+	apis = append(apis, &base.ApiWorker{Power: procedureSavePower, Do: this_.dbObjectSave(ObjectTypeProcedure)})
+	apis = append(apis, &base.ApiWorker{Power: procedureDropPower, Do: this_.dbObjectDrop(ObjectTypeProcedure)})
+	apis = append(apis, &base.ApiWorker{Power: procedureExecutePower, Do: this_.dbObjectExecute(ObjectTypeProcedure)})
+
+	apis = append(apis, &base.ApiWorker{Power: functionListPower, Do: this_.dbObjectList(ObjectTypeFunction), NotRecodeLog: true})
+	apis = append(apis, &base.ApiWorker{Power: functionDdlPower, Do: this_.dbObjectDetail(ObjectTypeFunction), NotRecodeLog: true})
+	apis = append(apis, &base.ApiWorker{Power: functionSavePower, Do: this_.dbObjectSave(ObjectTypeFunction)})
+	apis = append(apis, &base.ApiWorker{Power: functionDropPower, Do: this_.dbObjectDrop(ObjectTypeFunction)})
+	apis = append(apis, &base.ApiWorker{Power: functionExecutePower, Do: this_.dbObjectExecute(ObjectTypeFunction)})
+
+	apis = append(apis, &base.ApiWorker{Power: triggerListPower, Do: this_.dbObjectList(ObjectTypeTrigger), NotRecodeLog: true})
+	apis = append(apis, &base.ApiWorker{Power: triggerDdlPower, Do: this_.dbObjectDetail(ObjectTypeTrigger), NotRecodeLog: true})
+	apis = append(apis, &base.ApiWorker{Power: triggerSavePower, Do: this_.dbObjectSave(ObjectTypeTrigger)})
+	apis = append(apis, &base.ApiWorker{Power: triggerDropPower, Do: this_.dbObjectDrop(ObjectTypeTrigger)})
+
+	apis = append(apis, &base.ApiWorker{Power: sequenceListPower, Do: this_.dbObjectList(ObjectTypeSequence), NotRecodeLog: true})
+	apis = append(apis, &base.ApiWorker{Power: sequenceDdlPower, Do: this_.dbObjectDetail(ObjectTypeSequence), NotRecodeLog: true})
+	apis = append(apis, &base.ApiWorker{Power: sequenceSavePower, Do: this_.dbObjectSave(ObjectTypeSequence)})
+	apis = append(apis, &base.ApiWorker{Power: sequenceDropPower, Do: this_.dbObjectDrop(ObjectTypeSequence)})
+
+	apis = append(apis, &base.ApiWorker{Power: accountListPower, Do: this_.accountList, NotRecodeLog: true})
+	apis = append(apis, &base.ApiWorker{Power: accountGrantsPower, Do: this_.accountGrants, NotRecodeLog: true})
+	apis = append(apis, &base.ApiWorker{Power: accountSqlPower, Do: this_.accountSql, NotRecodeLog: true})
+	// 请求 包含 密码，由 accountExecute 记录 隐藏 密码 后 的 日志
+	apis = append(apis, &base.ApiWorker{Power: accountExecutePower, Do: this_.accountExecute, NotRecodeLog: true})
+
+	apis = append(apis, &base.ApiWorker{Power: backupListPower, Do: this_.backupList, NotRecodeLog: true})
+	apis = append(apis, &base.ApiWorker{Power: backupSavePower, Do: this_.backupSave})
+	apis = append(apis, &base.ApiWorker{Power: backupDeletePower, Do: this_.backupDelete})
+	apis = append(apis, &base.ApiWorker{Power: backupRunPower, Do: this_.backupRun})
+	apis = append(apis, &base.ApiWorker{Power: backupRecordsPower, Do: this_.backupRecords, NotRecodeLog: true})
+	apis = append(apis, &base.ApiWorker{Power: backupRecordDeletePower, Do: this_.backupRecordDelete})
+	apis = append(apis, &base.ApiWorker{Power: backupDownloadPower, Do: this_.backupDownload})
+	apis = append(apis, &base.ApiWorker{Power: backupRestorePower, Do: this_.backupRestore})
+
+	apis = append(apis, &base.ApiWorker{Power: dataDictionaryPower, Do: this_.dataDictionary, NotRecodeLog: true})
+
+	apis = append(apis, &base.ApiWorker{Power: federatedQueryPower, Do: this_.federatedQuery})
+
+	apis = append(apis, &base.ApiWorker{Power: analyzePower, Do: this_.analyze, NotRecodeLog: true})
+
+	apis = append(apis, &base.ApiWorker{Power: catalogSearchPower, Do: this_.catalogSearch, NotRecodeLog: true})
+	apis = append(apis, &base.ApiWorker{Power: catalogInfoPower, Do: this_.catalogInfo, NotRecodeLog: true})
+	apis = append(apis, &base.ApiWorker{Power: catalogRefreshPower, Do: this_.catalogRefresh})
 
 	apis = append(apis, &base.ApiWorker{Power: testStart, Do: this_.testStart})
 	apis = append(apis, &base.ApiWorker{Power: testInfo, Do: this_.testInfo})
@@ -128,6 +302,39 @@ func (this_ *api) getConfig(requestBean *base.RequestBean, c *gin.Context) (conf
 	return
 }
 
+// getServiceByToolboxId 根据 工具 ID 获取 数据库 服务，用于 跨 工具 操作，需要 校验 工具 权限
+func (this_ *api) getServiceByToolboxId(requestBean *base.RequestBean, toolboxId int64) (res db.IService, err error) {
+	_, res, err = this_.getToolboxService(requestBean, toolboxId)
+	return
+}
+
+// getToolboxService 根据 工具 ID 获取 工具 及 数据库 服务，需要 校验 工具 权限
+func (this_ *api) getToolboxService(requestBean *base.RequestBean, toolboxId int64) (find *module_toolbox.ToolboxModel, res db.IService, err error) {
+	find, err = this_.toolboxService.Get(toolboxId)
+	if err != nil {
+		return
+	}
+	if find == nil {
+		err = errors.New(fmt.Sprint("工具[", toolboxId, "]不存在"))
+		return
+	}
+	if find.ToolboxType != "database" {
+		err = errors.New(fmt.Sprint("工具[", toolboxId, "]不是数据库工具"))
+		return
+	}
+	err = this_.toolboxService.CheckToolboxPower(requestBean, find)
+	if err != nil {
+		return
+	}
+	config := &db.Config{}
+	sshConfig, err := this_.toolboxService.BindConfigByOption(find.Option, config, nil)
+	if err != nil {
+		return
+	}
+	res, err = getService(config, sshConfig)
+	return
+}
+
 func getService(config *db.Config, sshConfig *ssh.Config) (res db.IService, err error) {
 	key := fmt.Sprint("database-", config.Type, "-", config.Host, "-", config.Port)
 	if config.DatabasePath != "" {
@@ -145,6 +352,9 @@ func getService(config *db.Config, sshConfig *ssh.Config) (res db.IService, err
 	if config.OdbcDialectName != "" {
 		key += "-" + config.OdbcDialectName
 	}
+	if config.Schema != "" {
+		key += "-" + config.Schema
+	}
 	if config.Username != "" {
 		key += "-" + base.GetMd5String(key+config.Username)
 	}
@@ -229,8 +439,10 @@ type BaseRequest struct {
 	MaxIdleConn int `json:"maxIdleConn,omitempty"`
 	MaxOpenConn int `json:"maxOpenConn,omitempty"`
 
-	ShowDataMaxSize int  `json:"showDataMaxSize,omitempty"`
-	OpenProfiling   bool `json:"openProfiling,omitempty"`
+	ShowDataMaxSize int    `json:"showDataMaxSize,omitempty"`
+	OpenProfiling   bool   `json:"openProfiling,omitempty"`
+	Explain         bool   `json:"explain,omitempty"`      // 查询 执行计划，不执行 SQL
+	ConfirmToken    string `json:"confirmToken,omitempty"` // 生产 保护 确认，需要 输入 工具 名称
 
 	InsertList      []map[string]interface{} `json:"insertList,omitempty"`
 	UpdateList      []map[string]interface{} `json:"updateList,omitempty"`
@@ -322,6 +534,10 @@ func (this_ *api) ownerCreate(requestBean *base.RequestBean, c *gin.Context) (re
 		return
 	}
 
+	var request = &BaseRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
 	param := this_.getParam(requestBean, c)
 	var owner = &dialect.OwnerModel{}
 	if !base.RequestJSON(owner, c) {
@@ -331,6 +547,7 @@ func (this_ *api) ownerCreate(requestBean *base.RequestBean, c *gin.Context) (re
 	if err != nil {
 		return
 	}
+	refreshCatalog(request.ToolboxId, "")
 	return
 }
 
@@ -350,10 +567,17 @@ func (this_ *api) ownerDelete(requestBean *base.RequestBean, c *gin.Context) (re
 	}
 
 	param := this_.getParam(requestBean, c)
+	err = this_.checkProtected(requestBean, c, "ownerDelete", request, []*GuardStatement{
+		{Sql: "DROP " + service.GetDialect().OwnerNamePack(param.ParamModel, request.OwnerName), Kind: GuardKindDrop},
+	})
+	if err != nil {
+		return
+	}
 	res, err = service.OwnerDelete(param, request.OwnerName)
 	if err != nil {
 		return
 	}
+	refreshCatalog(request.ToolboxId, "")
 	return
 }
 
@@ -497,6 +721,7 @@ func (this_ *api) tableCreate(requestBean *base.RequestBean, c *gin.Context) (re
 	if err != nil {
 		return
 	}
+	refreshCatalog(request.ToolboxId, request.OwnerName)
 	return
 }
 
@@ -553,6 +778,7 @@ func (this_ *api) tableUpdate(requestBean *base.RequestBean, c *gin.Context) (re
 	if err != nil {
 		return
 	}
+	refreshCatalog(request.ToolboxId, request.OwnerName)
 	return
 }
 
@@ -599,11 +825,18 @@ func (this_ *api) tableDelete(requestBean *base.RequestBean, c *gin.Context) (re
 		return
 	}
 	param := this_.getParam(requestBean, c)
+	err = this_.checkProtected(requestBean, c, "tableDelete", request, []*GuardStatement{
+		{Sql: "DROP TABLE " + service.GetDialect().OwnerTablePack(param.ParamModel, request.OwnerName, request.TableName), Kind: GuardKindDrop},
+	})
+	if err != nil {
+		return
+	}
 
 	err = service.TableDelete(param, request.OwnerName, request.TableName)
 	if err != nil {
 		return
 	}
+	refreshCatalog(request.ToolboxId, request.OwnerName)
 	return
 }
 
@@ -622,6 +855,12 @@ func (this_ *api) tableDataTrim(requestBean *base.RequestBean, c *gin.Context) (
 		return
 	}
 	param := this_.getParam(requestBean, c)
+	err = this_.checkProtected(requestBean, c, "tableDataTrim", request, []*GuardStatement{
+		{Sql: "TRUNCATE TABLE " + service.GetDialect().OwnerTablePack(param.ParamModel, request.OwnerName, request.TableName), Kind: GuardKindTruncate},
+	})
+	if err != nil {
+		return
+	}
 
 	err = service.TableDataTrim(param, request.OwnerName, request.TableName)
 	if err != nil {
@@ -646,10 +885,16 @@ func (this_ *api) tableData(requestBean *base.RequestBean, c *gin.Context) (res
 	}
 	param := this_.getParam(requestBean, c)
 
-	res, err = service.TableData(param, request.OwnerName, request.TableName, request.ColumnList, request.Wheres, request.Orders, request.PageSize, request.PageNo)
+	masker, err := this_.getColumnMasker(requestBean)
 	if err != nil {
 		return
 	}
+	dataListResult, err := service.TableData(param, request.OwnerName, request.TableName, request.ColumnList, request.Wheres, request.Orders, request.PageSize, request.PageNo)
+	if err != nil {
+		return
+	}
+	masker.MaskDataList(request.TableName, dataListResult.DataList, nil)
+	res = dataListResult
 	return
 }
 
@@ -709,14 +954,67 @@ func (this_ *api) dataListExec(requestBean *base.RequestBean, c *gin.Context) (r
 	}
 	param := this_.getParam(requestBean, c)
 
+	sqlList, err := service.DataListSql(param, request.OwnerName, request.TableName, request.ColumnList,
+		request.InsertList,
+		request.UpdateList, request.UpdateWhereList,
+		request.DeleteList,
+	)
+	if err != nil {
+		return
+	}
+	err = this_.checkProtected(requestBean, c, "dataListExec", request, classifySql(service.GetDialect(), strings.Join(sqlList, ";\n")))
+	if err != nil {
+		return
+	}
+	undo, err := captureDataEditUndo(service, param, request)
+	if err != nil {
+		return
+	}
+
+	startTime := util.GetNowMilli()
 	err = service.DataListExec(param, request.OwnerName, request.TableName, request.ColumnList,
 		request.InsertList,
 		request.UpdateList, request.UpdateWhereList,
 		request.DeleteList,
 	)
+	history := &SqlHistoryModel{
+		ToolboxId:   request.ToolboxId,
+		OwnerName:   request.OwnerName,
+		ExecuteType: "dataListExec",
+		UseTime:     util.GetNowMilli() - startTime,
+		RowCount:    int64(len(request.InsertList) + len(request.UpdateList) + len(request.DeleteList)),
+	}
 	if err != nil {
+		history.Error = err.Error()
+	}
+	history.ExecuteSql = strings.Join(sqlList, ";\n")
+	this_.recordHistory(requestBean, history)
+	if err != nil {
+		return
+	}
+	this_.recordDataEdit(requestBean, request, sqlList, undo)
+	return
+}
+
+// getExecService 指定 执行 用户 时 使用 该 用户 的 连接，否则 返回 工具 的 连接
+// 数据库 不支持 在 会话 中 切换 库 时，使用 指定 库 的 连接
+func getExecService(service db.IService, config *db.Config, sshConfig *ssh.Config, param *db.Param, ownerName string) (execService db.IService, execConfig *db.Config, err error) {
+	execService = service
+	execConfig = config
+	useOwnerConfig := ownerUseConfig(service.GetDialect(), ownerName)
+	if param.ExecUsername == "" && !useOwnerConfig {
 		return
 	}
+	c := *config
+	if param.ExecUsername != "" {
+		c.Username = param.ExecUsername
+		c.Password = param.ExecPassword
+	}
+	if useOwnerConfig {
+		c = newOwnerConfig(service.GetDialect(), c, "", "", ownerName)
+	}
+	execConfig = &c
+	execService, err = getService(execConfig, sshConfig)
 	return
 }
 
@@ -736,13 +1034,85 @@ func (this_ *api) executeSQL(requestBean *base.RequestBean, c *gin.Context) (res
 	}
 	param := this_.getParam(requestBean, c)
 	data := make(map[string]interface{})
-	data["executeList"], data["error"], err = service.ExecuteSQL(param, request.OwnerName, request.ExecuteSQL, &db.ExecuteOptions{
+	if request.Explain {
+		var explainService db.IService
+		explainService, _, err = getExecService(service, config, sshConfig, param, request.OwnerName)
+		if err != nil {
+			return
+		}
+		data["explainList"], err = explainSQL(explainService, param, request.OwnerName, request.ExecuteSQL)
+		if err != nil {
+			return
+		}
+		res = data
+		return
+	}
+	err = this_.checkAnalyzeRules(requestBean, service, param, request)
+	if err != nil {
+		return
+	}
+	statements := classifySql(service.GetDialect(), request.ExecuteSQL)
+	err = this_.checkProtected(requestBean, c, "executeSQL", request, statements)
+	if err != nil {
+		return
+	}
+	// 执行 的 DDL 可能 部分 成功，不论 结果 都 刷新 结构 缓存
+	defer refreshCatalogBySql(service.GetDialect().DialectType(), request.ToolboxId, request.OwnerName, statements)
+	timeout, err := this_.getStatementTimeout(requestBean)
+	if err != nil {
+		return
+	}
+	masker, err := this_.getColumnMasker(requestBean)
+	if err != nil {
+		return
+	}
+	// 工作区 开启 了 事务，在 事务 中 执行
+	t, err := getUserTransaction(requestBean, request.ToolboxId, request.WorkerId)
+	if err != nil {
+		return
+	}
+	if t != nil {
+		q := newRunningQuery(requestBean, request, service, timeout)
+		addRunningQuery(q)
+		defer removeRunningQuery(q.QueryId)
+		data["queryId"] = q.QueryId
+
+		executeList, errStr, e := t.execute(q, request.OwnerName, request.ExecuteSQL, request.ShowDataMaxSize, param.ErrorContinue)
+		masker.MaskExecuteList(service.GetDialect().DialectType(), executeList)
+		this_.recordExecuteHistory(requestBean, request, executeList)
+		if t.info().IsEnd {
+			removeTransaction(request.WorkerId)
+		}
+		if e != nil {
+			err = e
+			return
+		}
+		data["executeList"] = executeList
+		data["error"] = errStr
+		data["transaction"] = t.info()
+		res = data
+		return
+	}
+	service, _, err = getExecService(service, config, sshConfig, param, request.OwnerName)
+	if err != nil {
+		return
+	}
+	q := newRunningQuery(requestBean, request, service, timeout)
+	addRunningQuery(q)
+	defer removeRunningQuery(q.QueryId)
+	data["queryId"] = q.QueryId
+
+	executeList, errStr, err := executeQuery(q, param, request.OwnerName, request.ExecuteSQL, &db.ExecuteOptions{
 		SelectDataMax: request.ShowDataMaxSize,
 		OpenProfiling: request.OpenProfiling,
 	})
+	masker.MaskExecuteList(service.GetDialect().DialectType(), executeList)
+	this_.recordExecuteHistory(requestBean, request, executeList)
 	if err != nil {
 		return
 	}
+	data["executeList"] = executeList
+	data["error"] = errStr
 	res = data
 	return
 }
@@ -768,8 +1138,14 @@ func (this_ *api) _import(requestBean *base.RequestBean, c *gin.Context) (res in
 		return
 	}
 
-	var task *worker.Task
-	task, err = service.StartImport(param, importParam)
+	var ownerNames = []string{""}
+	for _, owner := range importParam.Owners {
+		ownerNames = append(ownerNames, owner.Name)
+	}
+	// 导入 结束 后 刷新 结构 缓存
+	task, err := startImport(service, config, sshConfig, param, importParam, func() {
+		refreshCatalog(request.ToolboxId, ownerNames...)
+	})
 	if err != nil {
 		return
 	}
@@ -802,8 +1178,16 @@ func (this_ *api) export(requestBean *base.RequestBean, c *gin.Context) (res int
 		return
 	}
 
+	masker, err := this_.getColumnMasker(requestBean)
+	if err != nil {
+		return
+	}
 	var task *worker.Task
-	task, err = service.StartExport(param, exportParam)
+	if masker != nil || getCustomDataSourceType(param.ExportType) != nil {
+		task, err = startExport(service, param, exportParam, masker)
+	} else {
+		task, err = service.StartExport(param, exportParam)
+	}
 	if err != nil {
 		return
 	}
@@ -844,6 +1228,16 @@ func (this_ *api) exportDownload(_ *base.RequestBean, c *gin.Context) (res inter
 	}
 
 	path := tempDir + task.Extend["downloadPath"].(string)
+	err = writeDownloadFile(c, path)
+	if err != nil {
+		return
+	}
+	res = base.HttpNotResponse
+	return
+}
+
+// writeDownloadFile 将 文件 作为 附件 下载，如果 是 目录 则 压缩 后 下载
+func writeDownloadFile(c *gin.Context, path string) (err error) {
 	exists, err := util.PathExists(path)
 	if err != nil {
 		return
@@ -903,7 +1297,6 @@ func (this_ *api) exportDownload(_ *base.RequestBean, c *gin.Context) (res inter
 	}
 
 	c.Status(http.StatusOK)
-	res = base.HttpNotResponse
 	return
 }
 
@@ -948,7 +1341,11 @@ func (this_ *api) taskStatus(requestBean *base.RequestBean, c *gin.Context) (res
 		return
 	}
 
-	res = worker.GetTask(request.TaskId)
+	if task := worker.GetTask(request.TaskId); task != nil {
+		res = task
+	} else if task := getDataCompareTask(request.TaskId); task != nil {
+		res = task.status()
+	}
 	return
 }
 
@@ -960,6 +1357,9 @@ func (this_ *api) taskStop(requestBean *base.RequestBean, c *gin.Context) (res i
 	}
 
 	worker.StopTask(request.TaskId)
+	if task := getDataCompareTask(request.TaskId); task != nil {
+		task.stop()
+	}
 	return
 }
 
@@ -982,6 +1382,7 @@ func (this_ *api) taskClean(requestBean *base.RequestBean, c *gin.Context) (res
 		}
 	}
 	worker.ClearTask(request.TaskId)
+	removeDataCompareTask(request.TaskId)
 	return
 }
 
@@ -993,6 +1394,8 @@ func (this_ *api) close(requestBean *base.RequestBean, c *gin.Context) (res inte
 	}
 
 	removeWorkerTasks(request.WorkerId)
+	cancelWorkerQuery(request.WorkerId)
+	rollbackWorkerTransaction(request.WorkerId)
 	return
 }
 
@@ -1026,6 +1429,7 @@ func removeWorkerTasks(workerId string) {
 			}
 			worker.ClearTask(taskId)
 		}
+		removeDataCompareTask(taskId)
 	}
 	delete(workerTasksCache, workerId)
 	return
@@ -0,0 +1,1085 @@
+package module_database
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/team-ide/cron"
+	"github.com/team-ide/go-dialect/worker"
+	"github.com/team-ide/go-tool/db"
+	"github.com/team-ide/go-tool/util"
+	"go.uber.org/zap"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"teamide/internal/context"
+	"teamide/internal/module/module_file_manager"
+	"teamide/internal/module/module_id"
+	"teamide/internal/module/module_node"
+	"teamide/internal/module/module_toolbox"
+	"teamide/pkg/base"
+	"teamide/pkg/filework"
+	"teamide/pkg/ssh"
+	"time"
+)
+
+// 备份 保存 位置
+const (
+	BackupPlaceServer = "server"
+	BackupPlaceLocal  = "local"
+	BackupPlaceSsh    = "ssh"
+	BackupPlaceNode   = "node"
+)
+
+// 备份 记录 状态
+const (
+	BackupStatusRunning int8 = 1
+	BackupStatusSuccess int8 = 2
+	BackupStatusError   int8 = 3
+)
+
+// fileServiceGetter 文件 管理 获取 文件 服务
+type fileServiceGetter interface {
+	GetService(fileWorkerKey string, param *module_file_manager.BaseParam) (service filework.Service, err error)
+}
+
+// NewBackupService 根据库配置创建BackupService
+func NewBackupService(toolboxService *module_toolbox.ToolboxService, nodeService *module_node.NodeService) (res *BackupService) {
+
+	idService := module_id.NewIDService(toolboxService.ServerContext)
+
+	res = &BackupService{
+		ServerContext:  toolboxService.ServerContext,
+		idService:      idService,
+		toolboxService: toolboxService,
+		nodeService:    nodeService,
+		fileWorker:     module_file_manager.NewWorker(toolboxService, nodeService),
+		entryIds:       map[int64]cron.EntryID{},
+		running:        map[int64]bool{},
+	}
+	return
+}
+
+// BackupService 数据库备份服务
+type BackupService struct {
+	*context.ServerContext
+	idService      *module_id.IDService
+	toolboxService *module_toolbox.ToolboxService
+	nodeService    *module_node.NodeService
+	fileWorker     fileServiceGetter
+	entryIds       map[int64]cron.EntryID
+	running        map[int64]bool
+	lock           sync.Mutex
+}
+
+// ServerReady 服务 启动 后 将 中断 的 备份 记录 置为 失败，并 加载 启用 的 备份 任务
+func (this_ *BackupService) ServerReady() (err error) {
+	sql := `UPDATE ` + TableDatabaseBackupRecord + ` SET status=?, error=?, endTime=? WHERE status=? `
+	_, err = this_.DatabaseWorker.Exec(sql, []interface{}{BackupStatusError, "服务重启，备份中断", util.GetNow(), BackupStatusRunning})
+	if err != nil {
+		this_.Logger.Error("database backup record reset error", zap.Error(err))
+		return
+	}
+
+	var list []*BackupModel
+	sql = `SELECT * FROM ` + TableDatabaseBackup + ` WHERE enabled=1 `
+	err = this_.DatabaseWorker.Query(sql, []interface{}{}, &list)
+	if err != nil {
+		return
+	}
+	for _, one := range list {
+		e := this_.schedule(one)
+		if e != nil {
+			this_.Logger.Error("database backup schedule error", zap.Any("backupId", one.BackupId), zap.Any("spec", one.Spec), zap.Error(e))
+		}
+	}
+	return
+}
+
+// schedule 添加 或 更新 定时，停用 的 任务 只 移除
+func (this_ *BackupService) schedule(backup *BackupModel) (err error) {
+	this_.lock.Lock()
+	defer this_.lock.Unlock()
+
+	if entryId, ok := this_.entryIds[backup.BackupId]; ok {
+		this_.CronHandler.Remove(entryId)
+		delete(this_.entryIds, backup.BackupId)
+	}
+	if backup.Enabled != 1 {
+		return
+	}
+	backupId := backup.BackupId
+	entryId, err := this_.CronHandler.AddFunc(backup.Spec, func() {
+		find, e := this_.Get(backupId)
+		if e != nil || find == nil {
+			this_.Logger.Error("database backup get error", zap.Any("backupId", backupId), zap.Error(e))
+			return
+		}
+		_, e = this_.Run(find, "schedule")
+		if e != nil {
+			this_.Logger.Error("database backup run error", zap.Any("backupId", backupId), zap.Error(e))
+		}
+	})
+	if err != nil {
+		err = errors.New("定时规则[" + backup.Spec + "]错误:" + err.Error())
+		return
+	}
+	this_.entryIds[backupId] = entryId
+	return
+}
+
+func (this_ *BackupService) unschedule(backupId int64) {
+	this_.lock.Lock()
+	defer this_.lock.Unlock()
+
+	if entryId, ok := this_.entryIds[backupId]; ok {
+		this_.CronHandler.Remove(entryId)
+		delete(this_.entryIds, backupId)
+	}
+}
+
+// Save 新增 或 修改，保存 后 更新 定时
+func (this_ *BackupService) Save(backup *BackupModel) (err error) {
+	if backup.Enabled == 0 {
+		backup.Enabled = 1
+	}
+	// 先 校验 定时 规则
+	if _, err = cron.NewParser(cron.Second | cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor).Parse(backup.Spec); err != nil {
+		err = errors.New("定时规则[" + backup.Spec + "]错误:" + err.Error())
+		return
+	}
+	if backup.BackupId == 0 {
+		backup.BackupId, err = this_.idService.GetNextID(module_id.IDTypeDatabaseBackup)
+		if err != nil {
+			return
+		}
+		backup.CreateTime = time.Now()
+
+		sql := `INSERT INTO ` + TableDatabaseBackup + `(backupId, toolboxId, name, spec, owners, place, placeId, placeDir, retainCount, retainDays, enabled, userId, userName, userAccount, createTime) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?) `
+		_, err = this_.DatabaseWorker.Exec(sql, []interface{}{backup.BackupId, backup.ToolboxId, backup.Name, backup.Spec, backup.Owners, backup.Place, backup.PlaceId, backup.PlaceDir, backup.RetainCount, backup.RetainDays, backup.Enabled, backup.UserId, backup.UserName, backup.UserAccount, backup.CreateTime})
+	} else {
+		backup.UpdateTime = time.Now()
+
+		sql := `UPDATE ` + TableDatabaseBackup + ` SET name=?, spec=?, owners=?, place=?, placeId=?, placeDir=?, retainCount=?, retainDays=?, enabled=?, updateTime=? WHERE backupId=? `
+		_, err = this_.DatabaseWorker.Exec(sql, []interface{}{backup.Name, backup.Spec, backup.Owners, backup.Place, backup.PlaceId, backup.PlaceDir, backup.RetainCount, backup.RetainDays, backup.Enabled, backup.UpdateTime, backup.BackupId})
+	}
+	if err != nil {
+		this_.Logger.Error("Save Error", zap.Error(err))
+		return
+	}
+	err = this_.schedule(backup)
+	return
+}
+
+// Get 查询单个
+func (this_ *BackupService) Get(backupId int64) (res *BackupModel, err error) {
+	res = &BackupModel{}
+
+	sql := `SELECT * FROM ` + TableDatabaseBackup + ` WHERE backupId=? `
+	find, err := this_.DatabaseWorker.QueryOne(sql, []interface{}{backupId}, res)
+	if err != nil {
+		this_.Logger.Error("Get Error", zap.Error(err))
+		return
+	}
+	if !find {
+		res = nil
+	}
+	return
+}
+
+// Query 查询 工具 的 备份 任务
+func (this_ *BackupService) Query(toolboxId int64) (res []*BackupModel, err error) {
+
+	sql := `SELECT * FROM ` + TableDatabaseBackup + ` WHERE toolboxId=? ORDER BY createTime DESC `
+	err = this_.DatabaseWorker.Query(sql, []interface{}{toolboxId}, &res)
+	if err != nil {
+		this_.Logger.Error("Query Error", zap.Error(err))
+		return
+	}
+	return
+}
+
+// Delete 删除 备份 任务，已 生成 的 备份 记录 和 文件 保留
+func (this_ *BackupService) Delete(backupId int64) (err error) {
+	this_.unschedule(backupId)
+
+	sql := `DELETE FROM ` + TableDatabaseBackup + ` WHERE backupId=? `
+	_, err = this_.DatabaseWorker.Exec(sql, []interface{}{backupId})
+	if err != nil {
+		this_.Logger.Error("Delete Error", zap.Error(err))
+		return
+	}
+	return
+}
+
+// GetRecord 查询单个 备份 记录
+func (this_ *BackupService) GetRecord(backupRecordId int64) (res *BackupRecordModel, err error) {
+	res = &BackupRecordModel{}
+
+	sql := `SELECT * FROM ` + TableDatabaseBackupRecord + ` WHERE backupRecordId=? `
+	find, err := this_.DatabaseWorker.QueryOne(sql, []interface{}{backupRecordId}, res)
+	if err != nil {
+		this_.Logger.Error("GetRecord Error", zap.Error(err))
+		return
+	}
+	if !find {
+		res = nil
+	}
+	return
+}
+
+type BackupRecordPage struct {
+	*worker.Page
+	DataList []*BackupRecordModel `json:"dataList"`
+}
+
+// QueryRecordPage 分页 查询 工具 的 备份 记录，指定 备份 任务 时 只 查询 该 任务 的
+func (this_ *BackupService) QueryRecordPage(toolboxId int64, backupId int64, page *BackupRecordPage) (err error) {
+	var sql string
+	var values []interface{}
+
+	sql += "SELECT * FROM " + TableDatabaseBackupRecord + " WHERE toolboxId=?"
+	values = append(values, toolboxId)
+	if backupId != 0 {
+		sql += " AND backupId=?"
+		values = append(values, backupId)
+	}
+	sql += " ORDER BY createTime DESC, backupRecordId DESC"
+	page.DataList = []*BackupRecordModel{}
+	err = this_.DatabaseWorker.QueryPage(sql, values, &page.DataList, page.Page)
+	if err != nil {
+		this_.Logger.Error("QueryRecordPage Error", zap.Error(err))
+		return
+	}
+	return
+}
+
+func (this_ *BackupService) insertRecord(record *BackupRecordModel) (err error) {
+	record.BackupRecordId, err = this_.idService.GetNextID(module_id.IDTypeDatabaseBackupRecord)
+	if err != nil {
+		return
+	}
+	record.CreateTime = time.Now()
+
+	sql := `INSERT INTO ` + TableDatabaseBackupRecord + `(backupRecordId, backupId, toolboxId, triggerType, place, placeId, status, startTime, createTime) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?) `
+	_, err = this_.DatabaseWorker.Exec(sql, []interface{}{record.BackupRecordId, record.BackupId, record.ToolboxId, record.TriggerType, record.Place, record.PlaceId, record.Status, record.StartTime, record.CreateTime})
+	if err != nil {
+		this_.Logger.Error("insertRecord Error", zap.Error(err))
+		return
+	}
+	return
+}
+
+func (this_ *BackupService) updateRecord(record *BackupRecordModel) (err error) {
+
+	sql := `UPDATE ` + TableDatabaseBackupRecord + ` SET filePath=?, fileSize=?, status=?, error=?, ownerCount=?, tableCount=?, dataCount=?, useTime=?, endTime=? WHERE backupRecordId=? `
+	_, err = this_.DatabaseWorker.Exec(sql, []interface{}{record.FilePath, record.FileSize, record.Status, record.Error, record.OwnerCount, record.TableCount, record.DataCount, record.UseTime, record.EndTime, record.BackupRecordId})
+	if err != nil {
+		this_.Logger.Error("updateRecord Error", zap.Error(err))
+		return
+	}
+	return
+}
+
+// DeleteRecord 删除 备份 记录 和 备份 文件
+func (this_ *BackupService) DeleteRecord(record *BackupRecordModel) (err error) {
+	if record.FilePath != "" {
+		err = this_.removeFile(record)
+		if err != nil {
+			return
+		}
+	}
+
+	sql := `DELETE FROM ` + TableDatabaseBackupRecord + ` WHERE backupRecordId=? `
+	_, err = this_.DatabaseWorker.Exec(sql, []interface{}{record.BackupRecordId})
+	if err != nil {
+		this_.Logger.Error("DeleteRecord Error", zap.Error(err))
+		return
+	}
+	return
+}
+
+// Run 执行 备份，生成 备份 记录 后 在 后台 导出，同一 任务 同时 只 执行 一个
+func (this_ *BackupService) Run(backup *BackupModel, triggerType string) (record *BackupRecordModel, err error) {
+	this_.lock.Lock()
+	if this_.running[backup.BackupId] {
+		this_.lock.Unlock()
+		err = errors.New("备份任务[" + backup.Name + "]正在执行")
+		return
+	}
+	this_.running[backup.BackupId] = true
+	this_.lock.Unlock()
+
+	record = &BackupRecordModel{
+		BackupId:    backup.BackupId,
+		ToolboxId:   backup.ToolboxId,
+		TriggerType: triggerType,
+		Place:       backup.Place,
+		PlaceId:     backup.PlaceId,
+		Status:      BackupStatusRunning,
+		StartTime:   time.Now(),
+	}
+	err = this_.insertRecord(record)
+	if err != nil {
+		this_.lock.Lock()
+		delete(this_.running, backup.BackupId)
+		this_.lock.Unlock()
+		return
+	}
+	_, _ = this_.DatabaseWorker.Exec(`UPDATE `+TableDatabaseBackup+` SET lastRunTime=? WHERE backupId=? `, []interface{}{record.StartTime, backup.BackupId})
+
+	go func() {
+		defer func() {
+			if e := recover(); e != nil {
+				record.Status = BackupStatusError
+				record.Error = fmt.Sprint(e)
+			}
+			record.EndTime = time.Now()
+			record.UseTime = util.GetMilliByTime(record.EndTime) - util.GetMilliByTime(record.StartTime)
+			_ = this_.updateRecord(record)
+
+			this_.lock.Lock()
+			delete(this_.running, backup.BackupId)
+			this_.lock.Unlock()
+
+			if record.Status == BackupStatusSuccess {
+				this_.clean(backup)
+			}
+		}()
+		e := this_.execute(backup, record)
+		if e != nil {
+			this_.Logger.Error("database backup execute error", zap.Any("backupId", backup.BackupId), zap.Error(e))
+			record.Status = BackupStatusError
+			record.Error = e.Error()
+			return
+		}
+		record.Status = BackupStatusSuccess
+	}()
+	return
+}
+
+// execute 导出 SQL 到 临时 目录，压缩 为 tar.gz 后 保存 到 备份 位置
+func (this_ *BackupService) execute(backup *BackupModel, record *BackupRecordModel) (err error) {
+	find, err := this_.toolboxService.Get(backup.ToolboxId)
+	if err != nil {
+		return
+	}
+	if find == nil {
+		err = errors.New(fmt.Sprint("工具[", backup.ToolboxId, "]不存在"))
+		return
+	}
+	config := &db.Config{}
+	sshConfig, err := this_.toolboxService.BindConfigByOption(find.Option, config, nil)
+	if err != nil {
+		return
+	}
+	service, err := getService(config, sshConfig)
+	if err != nil {
+		return
+	}
+
+	var owners []*worker.TaskExportOwner
+	if backup.Owners != "" {
+		err = json.Unmarshal([]byte(backup.Owners), &owners)
+		if err != nil {
+			return
+		}
+	}
+	tempDir, err := util.GetTempDir()
+	if err != nil {
+		return
+	}
+	exportDir := tempDir + "database-backup/" + util.GetUUID()
+	defer func() {
+		_ = os.RemoveAll(exportDir)
+		_ = os.Remove(exportDir + ".tar.gz")
+	}()
+
+	// 每个 库 导出 一个 SQL 文件，不 拼接 库名，恢复 时 可以 导入 到 其它 库
+	exportParam := &worker.TaskExportParam{
+		Owners:         owners,
+		DataSourceType: worker.DataSourceTypeSql,
+		ExportStruct:   true,
+		ExportData:     true,
+		Dir:            exportDir,
+		OnProgress: func(progress *worker.TaskProgress) {
+			progress.OnError = func(err error) {
+				util.Logger.Error("database backup progress error", zap.Any("progress", progress), zap.Error(err))
+			}
+		},
+	}
+	task := worker.NewTaskExport(service.GetDb(), service.GetDialect(), service.GetDialect(), exportParam)
+	err = task.Start()
+	// 定时 任务 不 需要 查询 进度，执行 完 移除 缓存
+	worker.ClearTask(task.TaskId)
+	record.OwnerCount = task.OwnerCount
+	record.TableCount = task.TableCount
+	record.DataCount = task.DataSuccessCount
+	if err != nil {
+		return
+	}
+	if task.Error != "" {
+		err = errors.New(task.Error)
+		return
+	}
+
+	err = writeTarGz(exportDir, exportDir+".tar.gz")
+	if err != nil {
+		return
+	}
+	stat, err := os.Stat(exportDir + ".tar.gz")
+	if err != nil {
+		return
+	}
+	record.FileSize = stat.Size()
+
+	fileName := formatDownloadFileName(fmt.Sprint(find.Name, "-", backup.Name, "-", time.Now().Format("20060102150405"), ".tar.gz"))
+	if backup.Place == BackupPlaceServer {
+		record.FilePath = fmt.Sprint("database/backup/", backup.BackupId, "/", fileName)
+	} else {
+		record.FilePath = strings.TrimSuffix(backup.PlaceDir, "/") + "/" + fileName
+	}
+	err = this_.saveFile(record, exportDir+".tar.gz")
+	if err != nil {
+		record.FilePath = ""
+		return
+	}
+	return
+}
+
+// clean 按 保留 个数 和 天数 清理 成功 的 备份
+func (this_ *BackupService) clean(backup *BackupModel) {
+	if backup.RetainCount <= 0 && backup.RetainDays <= 0 {
+		return
+	}
+	var list []*BackupRecordModel
+	sql := `SELECT * FROM ` + TableDatabaseBackupRecord + ` WHERE backupId=? AND status=? ORDER BY createTime DESC, backupRecordId DESC `
+	err := this_.DatabaseWorker.Query(sql, []interface{}{backup.BackupId, BackupStatusSuccess}, &list)
+	if err != nil {
+		this_.Logger.Error("database backup clean query error", zap.Error(err))
+		return
+	}
+	deleteBefore := time.Now().AddDate(0, 0, -backup.RetainDays)
+	for index, one := range list {
+		// 至少 保留 最新 的 一个
+		if index == 0 {
+			continue
+		}
+		if (backup.RetainCount > 0 && index >= backup.RetainCount) || (backup.RetainDays > 0 && one.CreateTime.Before(deleteBefore)) {
+			err = this_.DeleteRecord(one)
+			if err != nil {
+				this_.Logger.Error("database backup clean record error", zap.Any("backupRecordId", one.BackupRecordId), zap.Error(err))
+			}
+		}
+	}
+}
+
+// getFileService 获取 备份 位置 的 文件 服务，服务 数据 目录 返回 nil
+func (this_ *BackupService) getFileService(place string, placeId string) (service filework.Service, closeService func(), err error) {
+	closeService = func() {}
+	if place == BackupPlaceServer {
+		return
+	}
+	fileWorkerKey := "database-backup-" + util.GetUUID()
+	service, err = this_.fileWorker.GetService(fileWorkerKey, &module_file_manager.BaseParam{
+		Place:   place,
+		PlaceId: placeId,
+	})
+	if place == BackupPlaceSsh {
+		closeService = func() {
+			ssh.CloseFileService(fileWorkerKey)
+		}
+	}
+	return
+}
+
+func (this_ *BackupService) getServerPath(filePath string) string {
+	return this_.ServerConfig.Server.Data + filePath
+}
+
+func (this_ *BackupService) saveFile(record *BackupRecordModel, localPath string) (err error) {
+	service, closeService, err := this_.getFileService(record.Place, record.PlaceId)
+	defer closeService()
+	if err != nil {
+		return
+	}
+	f, err := os.Open(localPath)
+	if err != nil {
+		return
+	}
+	defer func() { _ = f.Close() }()
+	if service == nil {
+		path := this_.getServerPath(record.FilePath)
+		err = os.MkdirAll(filepath.Dir(path), 0777)
+		if err != nil {
+			return
+		}
+		var target *os.File
+		target, err = os.Create(path)
+		if err != nil {
+			return
+		}
+		defer func() { _ = target.Close() }()
+		_, err = io.Copy(target, f)
+		return
+	}
+	var callStop bool
+	err = service.Write(record.FilePath, f, func(readSize int64, writeSize int64) {}, &callStop)
+	return
+}
+
+func (this_ *BackupService) readFile(record *BackupRecordModel, writer io.Writer) (err error) {
+	service, closeService, err := this_.getFileService(record.Place, record.PlaceId)
+	defer closeService()
+	if err != nil {
+		return
+	}
+	if service == nil {
+		var f *os.File
+		f, err = os.Open(this_.getServerPath(record.FilePath))
+		if err != nil {
+			return
+		}
+		defer func() { _ = f.Close() }()
+		_, err = io.Copy(writer, f)
+		return
+	}
+	var callStop bool
+	err = service.Read(record.FilePath, writer, func(readSize int64, writeSize int64) {}, &callStop)
+	return
+}
+
+func (this_ *BackupService) existFile(record *BackupRecordModel) (exist bool, err error) {
+	service, closeService, err := this_.getFileService(record.Place, record.PlaceId)
+	defer closeService()
+	if err != nil {
+		return
+	}
+	if service == nil {
+		exist, err = util.PathExists(this_.getServerPath(record.FilePath))
+		return
+	}
+	exist, err = service.Exist(record.FilePath)
+	return
+}
+
+func (this_ *BackupService) removeFile(record *BackupRecordModel) (err error) {
+	service, closeService, err := this_.getFileService(record.Place, record.PlaceId)
+	defer closeService()
+	if err != nil {
+		return
+	}
+	if service == nil {
+		err = os.Remove(this_.getServerPath(record.FilePath))
+		if os.IsNotExist(err) {
+			err = nil
+		}
+		return
+	}
+	exist, err := service.Exist(record.FilePath)
+	if err != nil || !exist {
+		return
+	}
+	err = service.Remove(record.FilePath, func(fileCount int, removeCount int) {})
+	return
+}
+
+// writeTarGz 将 目录 下 的 文件 打包 压缩
+func writeTarGz(dir string, path string) (err error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return
+	}
+	defer func() { _ = f.Close() }()
+	gw := gzip.NewWriter(f)
+	tw := tar.NewWriter(gw)
+	fs, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+	for _, one := range fs {
+		if one.IsDir() {
+			continue
+		}
+		err = writeTarFile(tw, dir+"/"+one.Name(), one.Name())
+		if err != nil {
+			return
+		}
+	}
+	err = tw.Close()
+	if err != nil {
+		return
+	}
+	err = gw.Close()
+	return
+}
+
+func writeTarFile(tw *tar.Writer, path string, name string) (err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return
+	}
+	defer func() { _ = f.Close() }()
+	stat, err := f.Stat()
+	if err != nil {
+		return
+	}
+	header, err := tar.FileInfoHeader(stat, "")
+	if err != nil {
+		return
+	}
+	header.Name = name
+	err = tw.WriteHeader(header)
+	if err != nil {
+		return
+	}
+	_, err = io.Copy(tw, f)
+	return
+}
+
+// readTarGz 解压 到 目录，只 保留 文件名，返回 解压 的 文件 路径
+func readTarGz(reader io.Reader, dir string) (paths []string, err error) {
+	gr, err := gzip.NewReader(reader)
+	if err != nil {
+		return
+	}
+	defer func() { _ = gr.Close() }()
+	tr := tar.NewReader(gr)
+	for {
+		var header *tar.Header
+		header, err = tr.Next()
+		if err == io.EOF {
+			err = nil
+			break
+		}
+		if err != nil {
+			return
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		path := dir + "/" + formatDownloadFileName(filepath.Base(header.Name))
+		var f *os.File
+		f, err = os.Create(path)
+		if err != nil {
+			return
+		}
+		_, err = io.Copy(f, tr)
+		_ = f.Close()
+		if err != nil {
+			return
+		}
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return
+}
+
+type BackupRequest struct {
+	*BackupModel
+	OwnerList []*worker.TaskExportOwner `json:"ownerList,omitempty"`
+
+	BackupRecordId        int64             `json:"backupRecordId,omitempty"`
+	OwnerNames            map[string]string `json:"ownerNames,omitempty"` // 恢复 时 备份 库名 对应 的 目标 库名，不 指定 使用 备份 库名
+	OwnerCreateIfNotExist bool              `json:"ownerCreateIfNotExist,omitempty"`
+	WorkerId              string            `json:"workerId,omitempty"`
+	PageNo                int               `json:"pageNo,omitempty"`
+	PageSize              int               `json:"pageSize,omitempty"`
+	// 生产 保护 确认，需要 输入 工具 名称
+	ConfirmToken string `json:"confirmToken,omitempty"`
+}
+
+// getBackupRequest 校验 当前 工具 权限 并 解析 请求，返回 当前 工具
+func (this_ *api) getBackupRequest(requestBean *base.RequestBean, c *gin.Context) (toolbox *module_toolbox.ToolboxModel, request *BackupRequest, err error) {
+	_, _, err = this_.getConfig(requestBean, c)
+	if err != nil {
+		return
+	}
+	if v := requestBean.GetExtend("toolboxModel"); v != nil {
+		toolbox = v.(*module_toolbox.ToolboxModel)
+	}
+	if toolbox == nil {
+		err = errors.New("工具信息获取失败")
+		return
+	}
+	request = &BackupRequest{}
+	if !base.RequestJSON(request, c) {
+		err = errors.New("请求参数错误")
+		return
+	}
+	if request.BackupModel == nil {
+		request.BackupModel = &BackupModel{}
+	}
+	return
+}
+
+// getToolboxBackup 获取 当前 工具 的 备份 任务
+func (this_ *api) getToolboxBackup(toolbox *module_toolbox.ToolboxModel, backupId int64) (backup *BackupModel, err error) {
+	backup, err = this_.backupService.Get(backupId)
+	if err != nil {
+		return
+	}
+	if backup == nil || backup.ToolboxId != toolbox.ToolboxId {
+		err = errors.New("备份任务不存在")
+		backup = nil
+		return
+	}
+	return
+}
+
+// getBackupRecord 获取 备份 记录，备份 所属 工具 需要 有 权限，可以 恢复 到 其它 工具
+func (this_ *api) getBackupRecord(requestBean *base.RequestBean, backupRecordId int64) (record *BackupRecordModel, err error) {
+	record, err = this_.backupService.GetRecord(backupRecordId)
+	if err != nil {
+		return
+	}
+	if record == nil {
+		err = errors.New("备份记录不存在")
+		return
+	}
+	find, err := this_.toolboxService.Get(record.ToolboxId)
+	if err != nil {
+		return
+	}
+	if find == nil {
+		record = nil
+		err = errors.New("备份所属工具不存在")
+		return
+	}
+	err = this_.toolboxService.CheckToolboxPower(requestBean, find)
+	if err != nil {
+		record = nil
+		return
+	}
+	return
+}
+
+// checkBackupPlace 校验 当前 用户 是否 可以 使用 备份 位置，备份 文件 会 写入 该 位置
+func (this_ *api) checkBackupPlace(requestBean *base.RequestBean, backup *BackupModel) (err error) {
+	switch backup.Place {
+	case BackupPlaceLocal:
+		// 服务 模式 下 本地 为 服务器 上 任意 目录，只能 备份 到 服务 数据 目录
+		if this_.toolboxService.ServerContext.IsServer {
+			err = errors.New("服务模式下不能备份到本地目录，请选择服务器")
+			return
+		}
+	case BackupPlaceSsh:
+		var sshToolbox *module_toolbox.ToolboxModel
+		sshToolbox, err = this_.toolboxService.Get(util.StringToInt64(backup.PlaceId))
+		if err != nil {
+			return
+		}
+		if sshToolbox == nil {
+			err = errors.New("SSH[" + backup.PlaceId + "]配置不存在")
+			return
+		}
+		err = this_.toolboxService.CheckToolboxPower(requestBean, sshToolbox)
+		if err != nil {
+			return
+		}
+	case BackupPlaceNode:
+		var nodeList []*module_node.NodeModel
+		nodeList, err = this_.backupService.nodeService.Query(&module_node.NodeModel{})
+		if err != nil {
+			return
+		}
+		var node *module_node.NodeModel
+		for _, one := range nodeList {
+			if one.ServerId == backup.PlaceId {
+				node = one
+				break
+			}
+		}
+		if node == nil {
+			err = errors.New("节点[" + backup.PlaceId + "]不存在")
+			return
+		}
+		if node.UserId != 0 && (requestBean.JWT == nil || node.UserId != requestBean.JWT.UserId) {
+			err = errors.New("节点[" + node.Name + "]不属于当前用户，无法操作")
+			return
+		}
+	}
+	return
+}
+
+func (this_ *api) backupList(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	toolbox, _, err := this_.getBackupRequest(requestBean, c)
+	if err != nil {
+		return
+	}
+	res, err = this_.backupService.Query(toolbox.ToolboxId)
+	return
+}
+
+func (this_ *api) backupSave(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	toolbox, request, err := this_.getBackupRequest(requestBean, c)
+	if err != nil {
+		return
+	}
+	backup := request.BackupModel
+	if strings.TrimSpace(backup.Name) == "" {
+		err = errors.New("备份名称不能为空")
+		return
+	}
+	if len(request.OwnerList) == 0 {
+		err = errors.New("请选择需要备份的库")
+		return
+	}
+	switch backup.Place {
+	case BackupPlaceServer:
+		backup.PlaceId = ""
+		backup.PlaceDir = ""
+	case BackupPlaceLocal, BackupPlaceSsh, BackupPlaceNode:
+		if backup.PlaceDir == "" {
+			err = errors.New("备份目录不能为空")
+			return
+		}
+		if backup.Place != BackupPlaceLocal && backup.PlaceId == "" {
+			err = errors.New("备份位置不能为空")
+			return
+		}
+	default:
+		err = errors.New("备份位置[" + backup.Place + "]不支持")
+		return
+	}
+	err = this_.checkBackupPlace(requestBean, backup)
+	if err != nil {
+		return
+	}
+	bs, err := json.Marshal(request.OwnerList)
+	if err != nil {
+		return
+	}
+	backup.Owners = string(bs)
+
+	if backup.BackupId != 0 {
+		_, err = this_.getToolboxBackup(toolbox, backup.BackupId)
+		if err != nil {
+			return
+		}
+	} else {
+		backup.ToolboxId = toolbox.ToolboxId
+		if requestBean.JWT != nil {
+			backup.UserId = requestBean.JWT.UserId
+			backup.UserName = requestBean.JWT.Name
+			backup.UserAccount = requestBean.JWT.Account
+		}
+	}
+	err = this_.backupService.Save(backup)
+	if err != nil {
+		return
+	}
+	res = backup
+	return
+}
+
+func (this_ *api) backupDelete(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	toolbox, request, err := this_.getBackupRequest(requestBean, c)
+	if err != nil {
+		return
+	}
+	_, err = this_.getToolboxBackup(toolbox, request.BackupId)
+	if err != nil {
+		return
+	}
+	err = this_.backupService.Delete(request.BackupId)
+	return
+}
+
+// backupRun 立即 执行 一次 备份
+func (this_ *api) backupRun(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	toolbox, request, err := this_.getBackupRequest(requestBean, c)
+	if err != nil {
+		return
+	}
+	backup, err := this_.getToolboxBackup(toolbox, request.BackupId)
+	if err != nil {
+		return
+	}
+	res, err = this_.backupService.Run(backup, "manual")
+	return
+}
+
+func (this_ *api) backupRecords(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	toolbox, request, err := this_.getBackupRequest(requestBean, c)
+	if err != nil {
+		return
+	}
+	page := &BackupRecordPage{
+		Page: worker.NewPage(),
+	}
+	page.PageNo = request.PageNo
+	page.PageSize = request.PageSize
+	if page.PageSize <= 0 {
+		page.PageSize = 20
+	}
+	err = this_.backupService.QueryRecordPage(toolbox.ToolboxId, request.BackupId, page)
+	if err != nil {
+		return
+	}
+	res = page
+	return
+}
+
+func (this_ *api) backupRecordDelete(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	toolbox, request, err := this_.getBackupRequest(requestBean, c)
+	if err != nil {
+		return
+	}
+	record, err := this_.backupService.GetRecord(request.BackupRecordId)
+	if err != nil {
+		return
+	}
+	if record == nil || record.ToolboxId != toolbox.ToolboxId {
+		err = errors.New("备份记录不存在")
+		return
+	}
+	if record.Status == BackupStatusRunning {
+		err = errors.New("备份正在执行")
+		return
+	}
+	err = this_.backupService.DeleteRecord(record)
+	return
+}
+
+// backupDownload 下载 备份 文件，备份 未 脱敏，需要 单独 的 权限
+func (this_ *api) backupDownload(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	_, request, err := this_.getBackupRequest(requestBean, c)
+	if err != nil {
+		return
+	}
+	record, err := this_.getBackupRecord(requestBean, request.BackupRecordId)
+	if err != nil {
+		return
+	}
+	if record.Status != BackupStatusSuccess || record.FilePath == "" {
+		err = errors.New("备份文件不存在")
+		return
+	}
+	exist, err := this_.backupService.existFile(record)
+	if err != nil {
+		return
+	}
+	if !exist {
+		err = errors.New("备份文件不存在")
+		return
+	}
+	fileName := filepath.Base(record.FilePath)
+	c.Header("Content-Type", "application/octet-stream")
+	c.Header("Content-Disposition", "attachment; filename="+url.QueryEscape(fileName))
+	c.Header("Content-Transfer-Encoding", "binary")
+	c.Header("Content-Length", fmt.Sprint(record.FileSize))
+	c.Header("download-file-name", fileName)
+	c.Status(http.StatusOK)
+	err = this_.backupService.readFile(record, c.Writer)
+	if err != nil {
+		return
+	}
+	res = base.HttpNotResponse
+	return
+}
+
+// backupRestore 将 备份 导入 到 当前 工具，按 库 导入 备份 中 的 SQL 文件
+func (this_ *api) backupRestore(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	config, sshConfig, err := this_.getConfig(requestBean, c)
+	if err != nil {
+		return
+	}
+	service, err := getService(config, sshConfig)
+	if err != nil {
+		return
+	}
+	toolbox, request, err := this_.getBackupRequest(requestBean, c)
+	if err != nil {
+		return
+	}
+	record, err := this_.getBackupRecord(requestBean, request.BackupRecordId)
+	if err != nil {
+		return
+	}
+	if record.Status != BackupStatusSuccess || record.FilePath == "" {
+		err = errors.New("备份文件不存在")
+		return
+	}
+	tempDir, err := util.GetTempDir()
+	if err != nil {
+		return
+	}
+	restoreDir := tempDir + "database-restore/" + util.GetUUID()
+	var started bool
+	defer func() {
+		if !started {
+			_ = os.RemoveAll(restoreDir)
+		}
+	}()
+	err = os.MkdirAll(restoreDir, 0777)
+	if err != nil {
+		return
+	}
+	reader, writer := io.Pipe()
+	go func() {
+		_ = writer.CloseWithError(this_.backupService.readFile(record, writer))
+	}()
+	paths, err := readTarGz(reader, restoreDir)
+	_ = reader.Close()
+	if err != nil {
+		return
+	}
+
+	importParam := &worker.TaskImportParam{
+		DataSourceType:        worker.DataSourceTypeSql,
+		OwnerCreateIfNotExist: request.OwnerCreateIfNotExist,
+	}
+	var statements []*GuardStatement
+	for _, path := range paths {
+		if !strings.HasSuffix(path, ".sql") {
+			continue
+		}
+		ownerName := strings.TrimSuffix(filepath.Base(path), ".sql")
+		if request.OwnerNames[ownerName] != "" {
+			ownerName = request.OwnerNames[ownerName]
+		}
+		importParam.Owners = append(importParam.Owners, &worker.TaskImportOwner{
+			Name: ownerName,
+			Path: path,
+		})
+		statements = append(statements, &GuardStatement{
+			Sql:  "restore " + filepath.Base(record.FilePath) + " into " + ownerName,
+			Kind: GuardKindRestore,
+		})
+	}
+	if len(importParam.Owners) == 0 {
+		err = errors.New("备份文件中没有可恢复的库")
+		return
+	}
+	err = this_.checkProtected(requestBean, c, "backupRestore", &BaseRequest{ToolboxId: toolbox.ToolboxId, ConfirmToken: request.ConfirmToken}, statements)
+	if err != nil {
+		return
+	}
+	param := this_.getParam(requestBean, c)
+	var ownerNames = []string{""}
+	for _, owner := range importParam.Owners {
+		ownerNames = append(ownerNames, owner.Name)
+	}
+	task, err := runImport(service, config, sshConfig, param, importParam, func() {
+		_ = os.RemoveAll(restoreDir)
+		refreshCatalog(toolbox.ToolboxId, ownerNames...)
+	})
+	if err != nil {
+		return
+	}
+	started = true
+	res = task
+	if task != nil {
+		addWorkerTask(request.WorkerId, task.TaskId)
+	}
+	return
+}
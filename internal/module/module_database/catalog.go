@@ -0,0 +1,635 @@
+package module_database
+
+import (
+	"context"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/team-ide/go-dialect/dialect"
+	"github.com/team-ide/go-dialect/worker"
+	"github.com/team-ide/go-tool/db"
+	"github.com/team-ide/go-tool/util"
+	"go.uber.org/zap"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"teamide/internal/module/module_toolbox"
+	"teamide/pkg/base"
+	"teamide/pkg/ssh"
+	"time"
+)
+
+// 结构 缓存 空闲 超时 毫秒，超时 未 使用 的 缓存 会 被 清理
+const catalogIdleTimeout = 30 * 60 * 1000
+
+// 结构 缓存 查询 超时
+const catalogQueryTimeout = time.Minute * 2
+
+// 搜索 默认 返回 数量
+const catalogSearchDefaultLimit = 50
+
+// 结构 缓存 状态
+const (
+	CatalogStatusBuilding = "building"
+	CatalogStatusReady    = "ready"
+	CatalogStatusError    = "error"
+)
+
+// 结构 缓存 项 类型
+const (
+	CatalogTypeOwner  = "owner"
+	CatalogTypeTable  = "table"
+	CatalogTypeColumn = "column"
+)
+
+type CatalogRequest struct {
+	ToolboxId int64  `json:"toolboxId,omitempty"`
+	OwnerName string `json:"ownerName,omitempty"` // 当前 库，该 库 下 的 结果 优先
+	Keyword   string `json:"keyword,omitempty"`   // 支持 table.column、owner.table、owner.table.column
+	Type      string `json:"type,omitempty"`      // owner、table、column，为空 时 查询 全部
+	Limit     int    `json:"limit,omitempty"`
+}
+
+// CatalogItem 搜索 结果
+type CatalogItem struct {
+	Type       string `json:"type"`
+	OwnerName  string `json:"ownerName"`
+	TableName  string `json:"tableName,omitempty"`
+	ColumnName string `json:"columnName,omitempty"`
+	DataType   string `json:"dataType,omitempty"`
+	Comment    string `json:"comment,omitempty"`
+	Score      int    `json:"score"`
+}
+
+// CatalogInfo 结构 缓存 状态
+type CatalogInfo struct {
+	Status       string `json:"status"`
+	Error        string `json:"error,omitempty"`
+	OwnerCount   int    `json:"ownerCount"`
+	TableCount   int    `json:"tableCount"`
+	ColumnCount  int    `json:"columnCount"`
+	PendingCount int    `json:"pendingCount"` // 等待 刷新 的 库 数量
+	UpdateTime   int64  `json:"updateTime,omitempty"`
+}
+
+type catalogColumn struct {
+	name     string
+	comment  string
+	dataType string
+}
+
+type catalogTable struct {
+	name    string
+	comment string
+	columns []*catalogColumn
+}
+
+type catalogOwner struct {
+	name    string
+	comment string
+	tables  []*catalogTable
+}
+
+// schemaCatalog 工具 的 库、表、字段 结构 缓存，在 后台 按 库 加载，DDL 执行 后 只 刷新 涉及 的 库
+type schemaCatalog struct {
+	toolboxId   int64
+	optionKey   string
+	config      *db.Config
+	sshConfig   *ssh.Config
+	owners      []*catalogOwner
+	error       string
+	updateTime  int64
+	lastUseTime int64
+	pending     []string // 等待 刷新 的 库，空 字符串 表示 刷新 库 列表
+	running     bool
+	locker      sync.RWMutex
+}
+
+// catalogCache 按 工具 ID 和 配置 摘要 缓存，避免 测试 请求 使用 已有 工具 ID 时 替换 工具 的 缓存
+var catalogCache = map[string]*schemaCatalog{}
+var catalogCacheLocker sync.Mutex
+
+// getCatalog 获取 工具 的 结构 缓存，不存在 或 配置 已 修改 时 重新 构建
+func getCatalog(toolbox *module_toolbox.ToolboxModel, config *db.Config, sshConfig *ssh.Config) (res *schemaCatalog) {
+	catalogCacheLocker.Lock()
+	defer catalogCacheLocker.Unlock()
+
+	nowTime := util.GetNowMilli()
+	for key, one := range catalogCache {
+		if nowTime-one.getLastUseTime() > catalogIdleTimeout {
+			delete(catalogCache, key)
+		}
+	}
+	optionKey := base.GetMd5String(toolbox.Option)
+	key := util.GetStringValue(toolbox.ToolboxId) + "-" + optionKey
+	res = catalogCache[key]
+	if res == nil {
+		res = &schemaCatalog{
+			toolboxId: toolbox.ToolboxId,
+			optionKey: optionKey,
+			config:    config,
+			sshConfig: sshConfig,
+		}
+		catalogCache[key] = res
+		res.refresh("")
+	}
+	res.locker.Lock()
+	res.lastUseTime = nowTime
+	res.locker.Unlock()
+	return
+}
+
+// refreshCatalog 刷新 已 存在 的 结构 缓存，ownerName 为 空 时 刷新 库 列表
+func refreshCatalog(toolboxId int64, ownerNames ...string) {
+	var findList []*schemaCatalog
+	catalogCacheLocker.Lock()
+	for _, one := range catalogCache {
+		if one.toolboxId == toolboxId {
+			findList = append(findList, one)
+		}
+	}
+	catalogCacheLocker.Unlock()
+	for _, one := range findList {
+		one.refresh(ownerNames...)
+	}
+}
+
+// catalogDdlOwnerRegexp 匹配 DDL 中 带 库 名 的 对象，如 CREATE TABLE db.table
+var catalogDdlOwnerRegexp = regexp.MustCompile("(?i)\\b(?:TABLE|VIEW)\\s+(?:IF\\s+(?:NOT\\s+)?EXISTS\\s+)?[`\"\\[]?([^\\s.`\"\\[\\]]+)[`\"\\]]?\\s*\\.")
+
+// refreshCatalogBySql 根据 执行 的 DDL 刷新 结构 缓存
+func refreshCatalogBySql(dialectType *dialect.Type, toolboxId int64, ownerName string, statements []*GuardStatement) {
+	var ownerNames []string
+	for _, one := range statements {
+		if one.Kind != GuardKindDDL && one.Kind != GuardKindDrop {
+			continue
+		}
+		words, _ := sqlTopLevelWords(dialectType, one.Sql)
+		if len(words) > 0 && (words[0] == "GRANT" || words[0] == "REVOKE") {
+			continue
+		}
+		if len(words) > 1 && (words[1] == "DATABASE" || words[1] == "SCHEMA" || words[1] == "USER") {
+			ownerNames = append(ownerNames, "")
+			continue
+		}
+		if find := catalogDdlOwnerRegexp.FindStringSubmatch(one.Sql); len(find) > 1 {
+			ownerNames = append(ownerNames, find[1])
+		} else if ownerName != "" {
+			ownerNames = append(ownerNames, ownerName)
+		} else {
+			ownerNames = append(ownerNames, "")
+		}
+	}
+	if len(ownerNames) > 0 {
+		refreshCatalog(toolboxId, ownerNames...)
+	}
+}
+
+func (this_ *schemaCatalog) getLastUseTime() int64 {
+	this_.locker.RLock()
+	defer this_.locker.RUnlock()
+	return this_.lastUseTime
+}
+
+// refresh 添加 到 刷新 队列，由 单独 的 协程 依次 加载
+func (this_ *schemaCatalog) refresh(ownerNames ...string) {
+	this_.locker.Lock()
+	defer this_.locker.Unlock()
+	for _, ownerName := range ownerNames {
+		if util.StringIndexOf(this_.pending, ownerName) < 0 {
+			this_.pending = append(this_.pending, ownerName)
+		}
+	}
+	if this_.running {
+		return
+	}
+	this_.running = true
+	go this_.run()
+}
+
+func (this_ *schemaCatalog) run() {
+	defer func() {
+		if e := recover(); e != nil {
+			util.Logger.Error("catalog run panic", zap.Any("toolboxId", this_.toolboxId), zap.Any("error", e))
+			this_.locker.Lock()
+			this_.running = false
+			this_.pending = nil
+			this_.locker.Unlock()
+		}
+	}()
+	for {
+		this_.locker.Lock()
+		if len(this_.pending) == 0 {
+			this_.running = false
+			this_.locker.Unlock()
+			return
+		}
+		ownerName := this_.pending[0]
+		this_.pending = this_.pending[1:]
+		this_.locker.Unlock()
+
+		if ownerName == "" {
+			this_.loadOwners()
+		} else {
+			this_.loadOwner(ownerName)
+		}
+	}
+}
+
+// loadOwners 加载 库 列表，保留 已 加载 的 库，新 的 库 添加 到 刷新 队列
+func (this_ *schemaCatalog) loadOwners() {
+	service, err := getService(this_.config, this_.sshConfig)
+	if err != nil {
+		this_.setError(err)
+		return
+	}
+	owners, err := service.OwnersSelect(&db.Param{ParamModel: &dialect.ParamModel{}})
+	if err != nil {
+		util.Logger.Error("catalog load owners error", zap.Any("toolboxId", this_.toolboxId), zap.Error(err))
+		this_.setError(err)
+		return
+	}
+
+	this_.locker.Lock()
+	defer this_.locker.Unlock()
+	oldOwners := map[string]*catalogOwner{}
+	for _, one := range this_.owners {
+		oldOwners[one.name] = one
+	}
+	var list []*catalogOwner
+	for _, owner := range owners {
+		one := oldOwners[owner.OwnerName]
+		if one == nil {
+			one = &catalogOwner{name: owner.OwnerName}
+			if util.StringIndexOf(this_.pending, owner.OwnerName) < 0 {
+				this_.pending = append(this_.pending, owner.OwnerName)
+			}
+		} else {
+			one = &catalogOwner{name: one.name, tables: one.tables}
+		}
+		one.comment = owner.OwnerComment
+		list = append(list, one)
+	}
+	this_.owners = list
+	this_.error = ""
+	this_.updateTime = util.GetNowMilli()
+}
+
+// loadOwner 加载 库 下 的 表 和 字段，加载 完成 后 整体 替换
+func (this_ *schemaCatalog) loadOwner(ownerName string) {
+	service, err := getService(this_.config, this_.sshConfig)
+	if err != nil {
+		this_.setError(err)
+		return
+	}
+	tables, err := loadCatalogTables(service, ownerName)
+	if err != nil {
+		// 加载 失败 时 保留 原有 的 缓存
+		util.Logger.Error("catalog load owner error", zap.Any("toolboxId", this_.toolboxId), zap.Any("ownerName", ownerName), zap.Error(err))
+		this_.setError(err)
+		return
+	}
+	owner := &catalogOwner{name: ownerName, tables: tables}
+
+	this_.locker.Lock()
+	defer this_.locker.Unlock()
+	var find bool
+	for i, one := range this_.owners {
+		if one.name == ownerName {
+			owner.comment = one.comment
+			this_.owners[i] = owner
+			find = true
+			break
+		}
+	}
+	// 库 不在 列表 中 说明 是 新建 的 库，刷新 库 列表
+	if !find && util.StringIndexOf(this_.pending, "") < 0 {
+		this_.pending = append(this_.pending, "")
+	}
+	this_.updateTime = util.GetNowMilli()
+}
+
+func (this_ *schemaCatalog) setError(err error) {
+	this_.locker.Lock()
+	defer this_.locker.Unlock()
+	this_.error = err.Error()
+}
+
+func (this_ *schemaCatalog) info() (res *CatalogInfo) {
+	this_.locker.RLock()
+	defer this_.locker.RUnlock()
+	res = &CatalogInfo{
+		OwnerCount:   len(this_.owners),
+		PendingCount: len(this_.pending),
+		UpdateTime:   this_.updateTime,
+		Error:        this_.error,
+	}
+	for _, owner := range this_.owners {
+		res.TableCount += len(owner.tables)
+		for _, table := range owner.tables {
+			res.ColumnCount += len(table.columns)
+		}
+	}
+	switch {
+	case this_.running:
+		res.Status = CatalogStatusBuilding
+	case this_.error != "":
+		res.Status = CatalogStatusError
+	default:
+		res.Status = CatalogStatusReady
+	}
+	return
+}
+
+// catalogColumnSqls 各 数据库 按 库 查询 所有 字段 的 SQL，参数 为 库 名
+var catalogColumnSqls = map[string][]string{
+	dialect.TypeMysql.Name: {
+		`SELECT TABLE_NAME AS tableName, COLUMN_NAME AS columnName, COLUMN_COMMENT AS columnComment, DATA_TYPE AS dataType FROM information_schema.COLUMNS WHERE TABLE_SCHEMA = ? ORDER BY TABLE_NAME, ORDINAL_POSITION`,
+	},
+	dialect.TypePostgresql.Name: {
+		`SELECT c.relname AS "tableName", a.attname AS "columnName", d.description AS "columnComment", format_type(a.atttypid, a.atttypmod) AS "dataType"
+FROM pg_catalog.pg_attribute a
+JOIN pg_catalog.pg_class c ON c.oid = a.attrelid
+JOIN pg_catalog.pg_namespace n ON n.oid = c.relnamespace
+LEFT JOIN pg_catalog.pg_description d ON d.objoid = c.oid AND d.objsubid = a.attnum
+WHERE n.nspname = $1 AND c.relkind IN ('r', 'p', 'v', 'm', 'f') AND a.attnum > 0 AND NOT a.attisdropped
+ORDER BY c.relname, a.attnum`,
+	},
+	dialect.TypeOracle.Name: {
+		`SELECT c.TABLE_NAME AS "tableName", c.COLUMN_NAME AS "columnName", m.COMMENTS AS "columnComment", c.DATA_TYPE AS "dataType"
+FROM ALL_TAB_COLUMNS c
+LEFT JOIN ALL_COL_COMMENTS m ON m.OWNER = c.OWNER AND m.TABLE_NAME = c.TABLE_NAME AND m.COLUMN_NAME = c.COLUMN_NAME
+WHERE c.OWNER = :1
+ORDER BY c.TABLE_NAME, c.COLUMN_ID`,
+	},
+}
+
+func getCatalogColumnSqls(dia dialect.Dialect) []string {
+	switch dia.DialectType() {
+	case dialect.TypeMysql:
+		return catalogColumnSqls[dialect.TypeMysql.Name]
+	case dialect.TypePostgresql, dialect.TypeKingBase, dialect.TypeOpenGauss:
+		return catalogColumnSqls[dialect.TypePostgresql.Name]
+	case dialect.TypeOracle, dialect.TypeDM:
+		return catalogColumnSqls[dialect.TypeOracle.Name]
+	}
+	return nil
+}
+
+// loadCatalogTables 加载 库 下 的 表，字段 优先 按 库 一次 查询，不支持 的 数据库 逐 表 查询
+func loadCatalogTables(service db.IService, ownerName string) (tables []*catalogTable, err error) {
+	param := &db.Param{ParamModel: &dialect.ParamModel{}}
+	tableList, err := service.TablesSelect(param, ownerName)
+	if err != nil {
+		return
+	}
+	tableCache := map[string]*catalogTable{}
+	for _, one := range tableList {
+		table := &catalogTable{name: one.TableName, comment: one.TableComment}
+		tableCache[one.TableName] = table
+		tables = append(tables, table)
+	}
+	if len(tables) == 0 {
+		return
+	}
+
+	if sqlList := getCatalogColumnSqls(service.GetDialect()); len(sqlList) > 0 {
+		ctx, cancel := context.WithTimeout(context.Background(), catalogQueryTimeout)
+		dataList, e := queryDbObject(ctx, service, sqlList, ownerName)
+		cancel()
+		if e == nil {
+			for _, data := range dataList {
+				table := tableCache[util.GetStringValue(data["tableName"])]
+				if table == nil {
+					continue
+				}
+				table.columns = append(table.columns, &catalogColumn{
+					name:     util.GetStringValue(data["columnName"]),
+					comment:  util.GetStringValue(data["columnComment"]),
+					dataType: util.GetStringValue(data["dataType"]),
+				})
+			}
+			return
+		}
+		util.Logger.Warn("catalog columns query error, select by table", zap.Any("ownerName", ownerName), zap.Error(e))
+	}
+	for _, table := range tables {
+		columns, _ := worker.ColumnsSelect(service.GetDb(), service.GetDialect(), param.ParamModel, ownerName, table.name, true)
+		for _, column := range columns {
+			table.columns = append(table.columns, &catalogColumn{
+				name:     column.ColumnName,
+				comment:  column.ColumnComment,
+				dataType: column.ColumnDataType,
+			})
+		}
+	}
+	return
+}
+
+// search 在 缓存 中 模糊 搜索，按 匹配 程度 排序
+func (this_ *schemaCatalog) search(request *CatalogRequest) (items []*CatalogItem) {
+	keyword := strings.ToLower(strings.TrimSpace(request.Keyword))
+	// 按 “.” 拆分 出 范围，最后 一段 为 搜索 关键字
+	var scopes []string
+	if strings.Contains(keyword, ".") {
+		scopes = strings.Split(keyword, ".")
+		keyword = scopes[len(scopes)-1]
+		scopes = scopes[:len(scopes)-1]
+	}
+	currentOwner := strings.ToLower(request.OwnerName)
+	var matchType = func(type_ string) bool {
+		return request.Type == "" || request.Type == type_
+	}
+	var add = func(item *CatalogItem, name string, comment string, boost int) {
+		score := catalogMatchScore(strings.ToLower(name), keyword)
+		if score == 0 && keyword != "" && strings.Contains(strings.ToLower(comment), keyword) {
+			score = 300
+		}
+		if score == 0 {
+			return
+		}
+		item.Comment = comment
+		item.Score = score + boost
+		items = append(items, item)
+	}
+
+	this_.locker.RLock()
+	for _, owner := range this_.owners {
+		ownerLower := strings.ToLower(owner.name)
+		var ownerBoost int
+		if ownerLower == currentOwner {
+			ownerBoost = 100
+		}
+		switch len(scopes) {
+		case 0:
+			if matchType(CatalogTypeOwner) {
+				add(&CatalogItem{Type: CatalogTypeOwner, OwnerName: owner.name}, owner.name, owner.comment, 0)
+			}
+			for _, table := range owner.tables {
+				if matchType(CatalogTypeTable) {
+					add(&CatalogItem{Type: CatalogTypeTable, OwnerName: owner.name, TableName: table.name}, table.name, table.comment, ownerBoost+20)
+				}
+				// 字段 只 在 当前 库 中 搜索，避免 结果 过多
+				if matchType(CatalogTypeColumn) && (currentOwner == "" || ownerBoost > 0) {
+					for _, column := range table.columns {
+						add(&CatalogItem{Type: CatalogTypeColumn, OwnerName: owner.name, TableName: table.name, ColumnName: column.name, DataType: column.dataType}, column.name, column.comment, ownerBoost)
+					}
+				}
+			}
+		case 1:
+			// owner.table 或 table.column
+			for _, table := range owner.tables {
+				if ownerLower == scopes[0] && matchType(CatalogTypeTable) {
+					add(&CatalogItem{Type: CatalogTypeTable, OwnerName: owner.name, TableName: table.name}, table.name, table.comment, 20)
+				}
+				if strings.ToLower(table.name) == scopes[0] && matchType(CatalogTypeColumn) {
+					for _, column := range table.columns {
+						add(&CatalogItem{Type: CatalogTypeColumn, OwnerName: owner.name, TableName: table.name, ColumnName: column.name, DataType: column.dataType}, column.name, column.comment, ownerBoost)
+					}
+				}
+			}
+		default:
+			// owner.table.column
+			if ownerLower != scopes[len(scopes)-2] || !matchType(CatalogTypeColumn) {
+				continue
+			}
+			for _, table := range owner.tables {
+				if strings.ToLower(table.name) != scopes[len(scopes)-1] {
+					continue
+				}
+				for _, column := range table.columns {
+					add(&CatalogItem{Type: CatalogTypeColumn, OwnerName: owner.name, TableName: table.name, ColumnName: column.name, DataType: column.dataType}, column.name, column.comment, 0)
+				}
+			}
+		}
+	}
+	this_.locker.RUnlock()
+
+	// 没有 关键字 时 保持 原有 顺序，如 字段 顺序
+	sort.SliceStable(items, func(i, j int) bool {
+		if items[i].Score != items[j].Score || keyword == "" {
+			return items[i].Score > items[j].Score
+		}
+		return catalogItemName(items[i]) < catalogItemName(items[j])
+	})
+	limit := request.Limit
+	if limit <= 0 {
+		limit = catalogSearchDefaultLimit
+	}
+	if len(items) > limit {
+		items = items[:limit]
+	}
+	return
+}
+
+func catalogItemName(item *CatalogItem) string {
+	switch item.Type {
+	case CatalogTypeColumn:
+		return item.ColumnName
+	case CatalogTypeTable:
+		return item.TableName
+	}
+	return item.OwnerName
+}
+
+// catalogMatchScore 计算 名称 匹配 分数，全 匹配 > 前缀 > 下划线 分段 前缀 > 包含 > 按 顺序 包含 所有 字符，不 匹配 返回 0
+func catalogMatchScore(name string, keyword string) int {
+	if keyword == "" {
+		return 1
+	}
+	if name == keyword {
+		return 1000
+	}
+	// 名称 越 短 越 靠前
+	var lengthBonus = func() int {
+		diff := len(name) - len(keyword)
+		if diff > 99 {
+			diff = 99
+		}
+		return 99 - diff
+	}
+	if strings.HasPrefix(name, keyword) {
+		return 800 + lengthBonus()
+	}
+	for _, segment := range strings.Split(name, "_")[1:] {
+		if strings.HasPrefix(segment, keyword) {
+			return 600 + lengthBonus()
+		}
+	}
+	if strings.Contains(name, keyword) {
+		return 400 + lengthBonus()
+	}
+	var index int
+	keywordRunes := []rune(keyword)
+	for _, r := range name {
+		if index < len(keywordRunes) && r == keywordRunes[index] {
+			index++
+		}
+	}
+	if index == len(keywordRunes) {
+		return 200 + lengthBonus()
+	}
+	return 0
+}
+
+// getCatalogContext 获取 当前 工具 的 结构 缓存
+func (this_ *api) getCatalogContext(requestBean *base.RequestBean, c *gin.Context) (catalog *schemaCatalog, request *CatalogRequest, err error) {
+	config, sshConfig, err := this_.getConfig(requestBean, c)
+	if err != nil {
+		return
+	}
+	request = &CatalogRequest{}
+	if !base.RequestJSON(request, c) {
+		err = errors.New("请求参数错误")
+		return
+	}
+	v := requestBean.GetExtend("toolboxModel")
+	if v == nil {
+		err = errors.New("工具[" + util.GetStringValue(request.ToolboxId) + "]不存在")
+		return
+	}
+	catalog = getCatalog(v.(*module_toolbox.ToolboxModel), config, sshConfig)
+	return
+}
+
+func (this_ *api) catalogSearch(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	catalog, request, err := this_.getCatalogContext(requestBean, c)
+	if err != nil {
+		return
+	}
+	data := make(map[string]interface{})
+	data["info"] = catalog.info()
+	data["items"] = catalog.search(request)
+	res = data
+	return
+}
+
+func (this_ *api) catalogInfo(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	catalog, _, err := this_.getCatalogContext(requestBean, c)
+	if err != nil {
+		return
+	}
+	res = catalog.info()
+	return
+}
+
+// catalogRefresh 刷新 结构 缓存，指定 库 时 只 刷新 该 库，否则 刷新 库 列表 和 所有 库
+func (this_ *api) catalogRefresh(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	catalog, request, err := this_.getCatalogContext(requestBean, c)
+	if err != nil {
+		return
+	}
+	if request.OwnerName != "" {
+		catalog.refresh(request.OwnerName)
+	} else {
+		catalog.locker.RLock()
+		ownerNames := []string{""}
+		for _, owner := range catalog.owners {
+			ownerNames = append(ownerNames, owner.name)
+		}
+		catalog.locker.RUnlock()
+		catalog.refresh(ownerNames...)
+	}
+	res = catalog.info()
+	return
+}
@@ -0,0 +1,141 @@
+package module_database
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"github.com/team-ide/go-dialect/dialect"
+	"github.com/team-ide/go-tool/db"
+	"github.com/team-ide/go-tool/util"
+	"strings"
+)
+
+// newOwnerConn 从 连接池 中 获取 独立 连接，并 切换 到 指定 库
+// 会话 状态 已被 修改，使用 完 后 需要 调用 discardConn 丢弃 连接，不放回 连接池
+func newOwnerConn(ctx context.Context, service db.IService, param *dialect.ParamModel, ownerName string) (conn *sql.Conn, err error) {
+	conn, err = service.GetDb().Conn(ctx)
+	if err != nil {
+		return
+	}
+	useSql := ownerUseSql(service.GetDialect(), param, ownerName)
+	if useSql == "" {
+		return
+	}
+	_, err = conn.ExecContext(ctx, useSql)
+	if err != nil {
+		discardConn(conn)
+		conn = nil
+		return
+	}
+	return
+}
+
+// ownerUseSql 切换 库 的 SQL，不需要 切换 返回 空
+func ownerUseSql(dia dialect.Dialect, param *dialect.ParamModel, ownerName string) string {
+	if ownerName == "" {
+		return ""
+	}
+	ownerPack := dia.OwnerNamePack(param, ownerName)
+	switch dia.DialectType() {
+	case dialect.TypeMysql:
+		return "USE " + ownerPack
+	case dialect.TypePostgresql, dialect.TypeKingBase, dialect.TypeOpenGauss:
+		return "SET search_path TO " + ownerPack
+	case dialect.TypeOracle, dialect.TypeDM, dialect.TypeShenTong:
+		return "ALTER SESSION SET CURRENT_SCHEMA = " + ownerPack
+	}
+	return ""
+}
+
+// ownerUseConfig 没有 切换 库 的 SQL 时，是否 需要 使用 指定 库 的 连接 配置，如 GBase、ODBC
+func ownerUseConfig(dia dialect.Dialect, ownerName string) bool {
+	if ownerName == "" {
+		return false
+	}
+	switch dia.DialectType() {
+	case dialect.TypeMysql, dialect.TypePostgresql, dialect.TypeKingBase, dialect.TypeOpenGauss,
+		dialect.TypeOracle, dialect.TypeDM, dialect.TypeShenTong:
+		return false
+	case dialect.TypeSqlite:
+		// SQLite 单库，库名 为 附加 数据库，无需 切换
+		return false
+	}
+	return true
+}
+
+// discardConn 关闭 并 丢弃 连接
+func discardConn(conn *sql.Conn) {
+	if conn == nil {
+		return
+	}
+	// 返回 ErrBadConn 后 连接池 将 关闭 该 连接
+	_ = conn.Raw(func(driverConn interface{}) error {
+		return driver.ErrBadConn
+	})
+	_ = conn.Close()
+}
+
+// sqlExecutor 执行 SQL，*sql.Conn、*sql.Tx 都 实现 了 该接口
+type sqlExecutor interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+// executeStatement 执行 单条 SQL，返回 结构 与 ExecuteSQL 的 执行结果 一致
+func executeStatement(ctx context.Context, executor sqlExecutor, executeSql string, selectDataMax int) (executeData map[string]interface{}, isChange bool, err error) {
+	executeData = map[string]interface{}{}
+	var startTime = util.GetNow()
+	executeData["sql"] = executeSql
+	executeData["startTime"] = util.GetFormatByTime(startTime)
+	defer func() {
+		var endTime = util.GetNow()
+		executeData["endTime"] = util.GetFormatByTime(endTime)
+		executeData["isEnd"] = true
+		executeData["useTime"] = util.GetMilliByTime(endTime) - util.GetMilliByTime(startTime)
+		if err != nil {
+			executeData["error"] = err.Error()
+		}
+	}()
+
+	str := strings.ToLower(strings.TrimSpace(executeSql))
+	if strings.HasPrefix(str, "select") ||
+		strings.HasPrefix(str, "show") ||
+		strings.HasPrefix(str, "desc") ||
+		strings.HasPrefix(str, "explain") {
+		executeData["isSelect"] = true
+		var rows *sql.Rows
+		rows, err = executor.QueryContext(ctx, executeSql)
+		if err != nil {
+			return
+		}
+		defer func() { _ = rows.Close() }()
+		var columnList []map[string]interface{}
+		var dataList []map[string]interface{}
+		var dataSize int
+		dataSize, columnList, dataList, err = db.RowsToListMap(rows, selectDataMax)
+		if err != nil {
+			return
+		}
+		executeData["columnList"] = columnList
+		executeData["dataSize"] = dataSize
+		executeData["dataList"] = dataList
+		return
+	}
+	if strings.HasPrefix(str, "insert") {
+		executeData["isInsert"] = true
+	} else if strings.HasPrefix(str, "update") {
+		executeData["isUpdate"] = true
+	} else if strings.HasPrefix(str, "delete") {
+		executeData["isDelete"] = true
+	} else {
+		executeData["isExec"] = true
+	}
+	var result sql.Result
+	result, err = executor.ExecContext(ctx, executeSql)
+	if err != nil {
+		return
+	}
+	isChange = true
+	executeData["rowsAffected"], _ = result.RowsAffected()
+	return
+}
@@ -0,0 +1,942 @@
+package module_database
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/team-ide/go-dialect/dialect"
+	"github.com/team-ide/go-tool/db"
+	"github.com/team-ide/go-tool/util"
+	"go.uber.org/zap"
+	"math/big"
+	"os"
+	"strings"
+	"sync"
+	"teamide/pkg/base"
+)
+
+// 比对 结果 明细 默认 最多 保留 条数，超出 只 计数
+const dataCompareDefaultMaxDetail = 1000
+
+type DataCompareRequest struct {
+	WorkerId        string `json:"workerId,omitempty"`
+	SourceToolboxId int64  `json:"sourceToolboxId,omitempty"`
+	SourceOwnerName string `json:"sourceOwnerName,omitempty"`
+	SourceTableName string `json:"sourceTableName,omitempty"`
+	SourceSql       string `json:"sourceSql,omitempty"` // 源 查询 SQL，设置 后 不 使用 源表
+	TargetToolboxId int64  `json:"targetToolboxId,omitempty"`
+	TargetOwnerName string `json:"targetOwnerName,omitempty"`
+	TargetTableName string `json:"targetTableName,omitempty"`
+	TargetSql       string `json:"targetSql,omitempty"` // 目标 查询 SQL，设置 后 不 使用 目标表
+
+	KeyColumns     []string `json:"keyColumns,omitempty"`     // 比对 主键，不 设置 使用 源表 主键
+	CompareColumns []string `json:"compareColumns,omitempty"` // 比对 字段，不 设置 比对 两边 同名 字段
+	GenerateSql    bool     `json:"generateSql,omitempty"`    // 是否 生成 目标 同步 为 源 数据 的 SQL
+	MaxDetail      int      `json:"maxDetail,omitempty"`
+}
+
+type DataCompareTask struct {
+	TaskId    string `json:"taskId"`
+	StartTime int64  `json:"startTime"`
+	EndTime   int64  `json:"endTime"`
+	UseTime   int64  `json:"useTime"`
+	Error     string `json:"error"`
+	IsEnd     bool   `json:"isEnd"`
+	IsStop    bool   `json:"isStop"`
+
+	KeyColumns     []string `json:"keyColumns"`
+	CompareColumns []string `json:"compareColumns"`
+
+	SourceCount       int64 `json:"sourceCount"`
+	TargetCount       int64 `json:"targetCount"`
+	SameCount         int64 `json:"sameCount"`
+	OnlyInSourceCount int64 `json:"onlyInSourceCount"`
+	OnlyInTargetCount int64 `json:"onlyInTargetCount"`
+	ChangedCount      int64 `json:"changedCount"`
+	SqlCount          int64 `json:"sqlCount"`
+
+	OnlyInSource []map[string]interface{} `json:"onlyInSource"`
+	OnlyInTarget []map[string]interface{} `json:"onlyInTarget"`
+	Changed      []*DataCompareChange     `json:"changed"`
+	// 明细 超出 最大 条数 后 不再 记录
+	DetailTruncated bool `json:"detailTruncated"`
+
+	Extend map[string]interface{} `json:"extend"`
+
+	locker sync.Mutex
+	cancel context.CancelFunc
+	// 创建 任务 的 用户 和 工具，下载 时 校验
+	userId          int64
+	sourceToolboxId int64
+	targetToolboxId int64
+}
+
+type DataCompareChange struct {
+	Key     map[string]interface{} `json:"key"`
+	Columns []*DataCompareColumn   `json:"columns"`
+}
+
+type DataCompareColumn struct {
+	ColumnName  string      `json:"columnName"`
+	SourceValue interface{} `json:"sourceValue"`
+	TargetValue interface{} `json:"targetValue"`
+}
+
+// compareColumn 比对 字段 在 两边 结果 中 的 位置
+type compareColumn struct {
+	sourceName  string
+	targetName  string
+	sourceIndex int
+	targetIndex int
+}
+
+func (this_ *api) dataCompare(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	var request = &DataCompareRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	if request.SourceSql == "" && request.SourceTableName == "" {
+		err = errors.New("源表或源查询SQL不能为空")
+		return
+	}
+	if request.TargetSql == "" && request.TargetTableName == "" {
+		request.TargetTableName = request.SourceTableName
+	}
+	if request.TargetOwnerName == "" && request.TargetSql == "" {
+		request.TargetOwnerName = request.SourceOwnerName
+	}
+	if request.GenerateSql && request.TargetTableName == "" {
+		err = errors.New("生成同步SQL需要设置目标表")
+		return
+	}
+	sourceToolbox, sourceService, err := this_.getToolboxService(requestBean, request.SourceToolboxId)
+	if err != nil {
+		return
+	}
+	targetToolbox, targetService, err := this_.getToolboxService(requestBean, request.TargetToolboxId)
+	if err != nil {
+		return
+	}
+	sourceMasker, err := NewColumnMaskerByToolbox(this_.toolboxService, sourceToolbox)
+	if err != nil {
+		return
+	}
+	targetMasker, err := NewColumnMaskerByToolbox(this_.toolboxService, targetToolbox)
+	if err != nil {
+		return
+	}
+	// 同步 SQL 包含 原始 数据，有 脱敏 规则 时 不能 生成
+	if request.GenerateSql && (sourceMasker.HasRule(request.SourceTableName, nil) || targetMasker.HasRule(request.TargetTableName, nil)) {
+		err = errors.New("工具配置了脱敏规则，不能生成同步SQL")
+		return
+	}
+	param := this_.getParam(requestBean, c)
+
+	if len(request.KeyColumns) == 0 {
+		if request.SourceSql != "" {
+			err = errors.New("使用查询SQL比对时需要设置主键字段")
+			return
+		}
+		var tableDetail *dialect.TableModel
+		tableDetail, err = sourceService.TableDetail(param, request.SourceOwnerName, request.SourceTableName)
+		if err != nil {
+			return
+		}
+		if tableDetail == nil || len(tableDetail.PrimaryKeys) == 0 {
+			err = errors.New("源表[" + request.SourceTableName + "]没有主键，请设置主键字段")
+			return
+		}
+		request.KeyColumns = tableDetail.PrimaryKeys
+	}
+	var targetTable *dialect.TableModel
+	if request.GenerateSql {
+		targetTable, err = targetService.TableDetail(param, request.TargetOwnerName, request.TargetTableName)
+		if err != nil {
+			return
+		}
+		if targetTable == nil || len(targetTable.ColumnList) == 0 {
+			err = errors.New("目标表[" + request.TargetTableName + "]不存在")
+			return
+		}
+	}
+	if request.MaxDetail <= 0 {
+		request.MaxDetail = dataCompareDefaultMaxDetail
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	task := &DataCompareTask{
+		TaskId:     util.GetUUID(),
+		StartTime:  util.GetNowMilli(),
+		KeyColumns: request.KeyColumns,
+		Extend: map[string]interface{}{
+			"downloadPath": "",
+		},
+		cancel:          cancel,
+		sourceToolboxId: request.SourceToolboxId,
+		targetToolboxId: request.TargetToolboxId,
+	}
+	if requestBean.JWT != nil {
+		task.userId = requestBean.JWT.UserId
+	}
+	addDataCompareTask(task)
+	addWorkerTask(request.WorkerId, task.TaskId)
+
+	comparer := &dataComparer{
+		DataCompareRequest: request,
+		task:               task,
+		ctx:                ctx,
+		param:              param.ParamModel,
+		sourceService:      sourceService,
+		targetService:      targetService,
+		targetTable:        targetTable,
+		sourceMasker:       sourceMasker,
+		targetMasker:       targetMasker,
+	}
+	go func() {
+		var e error
+		defer func() {
+			if x := recover(); x != nil {
+				e = errors.New(fmt.Sprint(x))
+			}
+			cancel()
+			task.locker.Lock()
+			defer task.locker.Unlock()
+			if e != nil && !task.IsStop {
+				task.Error = e.Error()
+				util.Logger.Error("data compare task error", zap.Error(e))
+			}
+			task.EndTime = util.GetNowMilli()
+			task.UseTime = task.EndTime - task.StartTime
+			task.IsEnd = true
+		}()
+		e = comparer.do()
+	}()
+
+	res = task.status()
+	return
+}
+
+func (this_ *api) dataCompareDownload(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	data := map[string]string{}
+	err = c.Bind(&data)
+	if err != nil {
+		return
+	}
+	task := getDataCompareTask(data["taskId"])
+	if task == nil {
+		err = errors.New("任务不存在")
+		return
+	}
+	var userId int64
+	if requestBean.JWT != nil {
+		userId = requestBean.JWT.UserId
+	}
+	toolboxId := data["toolboxId"]
+	if task.userId != userId || (toolboxId != fmt.Sprint(task.sourceToolboxId) && toolboxId != fmt.Sprint(task.targetToolboxId)) {
+		err = errors.New("任务不属于当前工具或用户，无法下载")
+		return
+	}
+	downloadPath := task.status().Extend["downloadPath"]
+	if downloadPath == nil || downloadPath == "" {
+		err = errors.New("任务未生成同步SQL")
+		return
+	}
+	tempDir, err := util.GetTempDir()
+	if err != nil {
+		return
+	}
+	err = writeDownloadFile(c, tempDir+downloadPath.(string))
+	if err != nil {
+		return
+	}
+	res = base.HttpNotResponse
+	return
+}
+
+// status 获取 任务 当前 状态 的 副本，任务 执行 中 也 可以 安全 序列化
+func (this_ *DataCompareTask) status() *DataCompareTask {
+	this_.locker.Lock()
+	defer this_.locker.Unlock()
+	res := &DataCompareTask{
+		TaskId:            this_.TaskId,
+		StartTime:         this_.StartTime,
+		EndTime:           this_.EndTime,
+		UseTime:           this_.UseTime,
+		Error:             this_.Error,
+		IsEnd:             this_.IsEnd,
+		IsStop:            this_.IsStop,
+		KeyColumns:        this_.KeyColumns,
+		CompareColumns:    this_.CompareColumns,
+		SourceCount:       this_.SourceCount,
+		TargetCount:       this_.TargetCount,
+		SameCount:         this_.SameCount,
+		OnlyInSourceCount: this_.OnlyInSourceCount,
+		OnlyInTargetCount: this_.OnlyInTargetCount,
+		ChangedCount:      this_.ChangedCount,
+		SqlCount:          this_.SqlCount,
+		OnlyInSource:      this_.OnlyInSource,
+		OnlyInTarget:      this_.OnlyInTarget,
+		Changed:           this_.Changed,
+		DetailTruncated:   this_.DetailTruncated,
+		Extend:            map[string]interface{}{},
+	}
+	if !this_.IsEnd {
+		res.UseTime = util.GetNowMilli() - this_.StartTime
+	}
+	for k, v := range this_.Extend {
+		res.Extend[k] = v
+	}
+	return res
+}
+
+func (this_ *DataCompareTask) stop() {
+	this_.locker.Lock()
+	defer this_.locker.Unlock()
+	this_.IsStop = true
+	if this_.cancel != nil {
+		this_.cancel()
+	}
+}
+
+func (this_ *DataCompareTask) isStop() bool {
+	this_.locker.Lock()
+	defer this_.locker.Unlock()
+	return this_.IsStop
+}
+
+// detailCount 已 记录 的 明细 条数，需要 在 锁 内 调用
+func (this_ *DataCompareTask) detailCount() int {
+	return len(this_.OnlyInSource) + len(this_.OnlyInTarget) + len(this_.Changed)
+}
+
+type dataComparer struct {
+	*DataCompareRequest
+	task          *DataCompareTask
+	ctx           context.Context
+	param         *dialect.ParamModel
+	sourceService db.IService
+	targetService db.IService
+	targetTable   *dialect.TableModel
+	sourceMasker  *ColumnMasker
+	targetMasker  *ColumnMasker
+
+	keyColumns     []*compareColumn
+	compareColumns []*compareColumn
+	keyNumbers     []bool // 主键 字段 是否 数字，数字 按 数值 比较，其它 按 字节 比较
+	sqlWriter      *bufio.Writer
+}
+
+func (this_ *dataComparer) do() (err error) {
+	source, err := this_.openCursor("源", this_.sourceService, this_.SourceSql, this_.SourceOwnerName, this_.SourceTableName)
+	if err != nil {
+		return
+	}
+	defer source.close()
+	target, err := this_.openCursor("目标", this_.targetService, this_.TargetSql, this_.TargetOwnerName, this_.TargetTableName)
+	if err != nil {
+		return
+	}
+	defer target.close()
+
+	err = this_.initColumns(source, target)
+	if err != nil {
+		return
+	}
+	if this_.GenerateSql {
+		var f *os.File
+		f, err = this_.createSqlFile()
+		if err != nil {
+			return
+		}
+		defer func() { _ = f.Close() }()
+		this_.sqlWriter = bufio.NewWriter(f)
+		defer func() {
+			if e := this_.sqlWriter.Flush(); e != nil && err == nil {
+				err = e
+			}
+		}()
+	}
+
+	hasSource, err := source.next()
+	if err != nil {
+		return
+	}
+	hasTarget, err := target.next()
+	if err != nil {
+		return
+	}
+	for hasSource || hasTarget {
+		if this_.task.isStop() {
+			return
+		}
+		var cmp int
+		switch {
+		case !hasTarget:
+			cmp = -1
+		case !hasSource:
+			cmp = 1
+		default:
+			cmp = compareKeys(source.key(), target.key(), this_.keyNumbers)
+		}
+		switch {
+		case cmp < 0:
+			err = this_.onlyInSource(source.row)
+			if err != nil {
+				return
+			}
+			hasSource, err = source.next()
+		case cmp > 0:
+			err = this_.onlyInTarget(target.row)
+			if err != nil {
+				return
+			}
+			hasTarget, err = target.next()
+		default:
+			err = this_.compareRow(source.row, target.row)
+			if err != nil {
+				return
+			}
+			hasSource, err = source.next()
+			if err != nil {
+				return
+			}
+			hasTarget, err = target.next()
+		}
+		if err != nil {
+			return
+		}
+		this_.task.locker.Lock()
+		this_.task.SourceCount = source.count
+		this_.task.TargetCount = target.count
+		this_.task.locker.Unlock()
+	}
+	return
+}
+
+// openCursor 按 主键 排序 查询 数据，字符串 主键 按 二进制 排序，与 compareKeys 的 字节 比较 一致
+func (this_ *dataComparer) openCursor(side string, service db.IService, querySql string, ownerName string, tableName string) (res *compareCursor, err error) {
+	dia := service.GetDialect()
+	var keys []string
+	if querySql != "" {
+		// 查询 SQL 的 字段 别名 由 用户 指定，不 加 引号
+		querySql = "SELECT * FROM (" + strings.TrimRight(strings.TrimSpace(querySql), ";") + ") compare_t"
+		keys = this_.KeyColumns
+	} else {
+		querySql = "SELECT * FROM " + dia.OwnerTablePack(this_.param, ownerName, tableName)
+		for _, name := range this_.KeyColumns {
+			keys = append(keys, dia.ColumnNamePack(this_.param, name))
+		}
+	}
+	columnNames, typeNames, err := queryColumnTypes(this_.ctx, service, querySql)
+	if err != nil {
+		err = errors.New(side + "数据查询异常，sql:" + querySql + "，error:" + err.Error())
+		return
+	}
+	var orders []string
+	for i, name := range this_.KeyColumns {
+		index := findCompareColumn(columnNames, name)
+		if index < 0 {
+			err = errors.New(side + "数据中没有字段[" + name + "]")
+			return
+		}
+		order := keys[i]
+		if isStringTypeName(typeNames[index]) {
+			order = binaryOrderColumn(dia.DialectType(), order)
+		}
+		orders = append(orders, order)
+	}
+	querySql += " ORDER BY " + strings.Join(orders, ", ")
+
+	rows, err := service.GetDb().QueryContext(this_.ctx, querySql)
+	if err != nil {
+		err = errors.New(side + "数据查询异常，sql:" + querySql + "，error:" + err.Error())
+		return
+	}
+	res = &compareCursor{
+		side: side,
+		rows: rows,
+	}
+	columnTypes, err := rows.ColumnTypes()
+	if err != nil {
+		_ = rows.Close()
+		return
+	}
+	for _, columnType := range columnTypes {
+		res.columns = append(res.columns, columnType.Name())
+		res.binary = append(res.binary, isBinaryTypeName(columnType.DatabaseTypeName()))
+		res.number = append(res.number, isNumberTypeName(columnType.DatabaseTypeName()))
+	}
+	return
+}
+
+// queryColumnTypes 查询 结果 的 字段 名称 和 类型，不 返回 数据
+func queryColumnTypes(ctx context.Context, service db.IService, querySql string) (columnNames []string, typeNames []string, err error) {
+	rows, err := service.GetDb().QueryContext(ctx, "SELECT * FROM ("+querySql+") compare_c WHERE 1 = 0")
+	if err != nil {
+		return
+	}
+	defer func() { _ = rows.Close() }()
+	columnTypes, err := rows.ColumnTypes()
+	if err != nil {
+		return
+	}
+	for _, columnType := range columnTypes {
+		columnNames = append(columnNames, columnType.Name())
+		typeNames = append(typeNames, columnType.DatabaseTypeName())
+	}
+	return
+}
+
+// binaryOrderColumn 字符串 字段 按 UTF-8 字节 排序，不 支持 的 库 使用 默认 排序，由 游标 校验 顺序
+func binaryOrderColumn(dialectType *dialect.Type, column string) string {
+	switch dialectType {
+	case dialect.TypeMysql:
+		return "CONVERT(" + column + " USING utf8mb4) COLLATE utf8mb4_bin"
+	case dialect.TypePostgresql, dialect.TypeKingBase, dialect.TypeOpenGauss:
+		return column + ` COLLATE "C"`
+	case dialect.TypeSqlite:
+		return column + " COLLATE BINARY"
+	case dialect.TypeOracle:
+		return "NLSSORT(" + column + ", 'NLS_SORT=BINARY')"
+	}
+	return column
+}
+
+// findCompareColumn 查找 字段 位置，优先 完全 匹配，其次 忽略 大小写
+func findCompareColumn(columns []string, name string) int {
+	for i, one := range columns {
+		if one == name {
+			return i
+		}
+	}
+	for i, one := range columns {
+		if strings.EqualFold(one, name) {
+			return i
+		}
+	}
+	return -1
+}
+
+// initColumns 匹配 两边 的 主键 和 比对 字段，字段名 忽略 大小写
+func (this_ *dataComparer) initColumns(source *compareCursor, target *compareCursor) (err error) {
+	var newColumn = func(name string) (column *compareColumn, err error) {
+		sourceIndex := findCompareColumn(source.columns, name)
+		if sourceIndex < 0 {
+			err = errors.New("源数据中没有字段[" + name + "]")
+			return
+		}
+		targetIndex := findCompareColumn(target.columns, name)
+		if targetIndex < 0 {
+			err = errors.New("目标数据中没有字段[" + name + "]")
+			return
+		}
+		column = &compareColumn{
+			sourceName:  source.columns[sourceIndex],
+			targetName:  target.columns[targetIndex],
+			sourceIndex: sourceIndex,
+			targetIndex: targetIndex,
+		}
+		return
+	}
+	var keyIndexes = map[int]bool{}
+	for _, name := range this_.KeyColumns {
+		var column *compareColumn
+		column, err = newColumn(name)
+		if err != nil {
+			return
+		}
+		// 两边 排序 方式 需要 一致
+		if source.number[column.sourceIndex] != target.number[column.targetIndex] {
+			err = errors.New("主键字段[" + name + "]在源和目标中的类型不一致，无法比对")
+			return
+		}
+		this_.keyColumns = append(this_.keyColumns, column)
+		this_.keyNumbers = append(this_.keyNumbers, source.number[column.sourceIndex])
+		keyIndexes[column.sourceIndex] = true
+	}
+	for _, column := range this_.keyColumns {
+		source.keyList = append(source.keyList, column.sourceIndex)
+		target.keyList = append(target.keyList, column.targetIndex)
+	}
+	source.keyNumbers = this_.keyNumbers
+	target.keyNumbers = this_.keyNumbers
+
+	var compareNames = this_.CompareColumns
+	if len(compareNames) == 0 {
+		for i, name := range source.columns {
+			if !keyIndexes[i] && findCompareColumn(target.columns, name) >= 0 {
+				compareNames = append(compareNames, name)
+			}
+		}
+	}
+	var names []string
+	for _, name := range compareNames {
+		var column *compareColumn
+		column, err = newColumn(name)
+		if err != nil {
+			return
+		}
+		if keyIndexes[column.sourceIndex] {
+			continue
+		}
+		this_.compareColumns = append(this_.compareColumns, column)
+		names = append(names, column.sourceName)
+	}
+	this_.task.locker.Lock()
+	this_.task.CompareColumns = names
+	this_.task.locker.Unlock()
+	return
+}
+
+func (this_ *dataComparer) createSqlFile() (f *os.File, err error) {
+	tempDir, err := util.GetTempDir()
+	if err != nil {
+		return
+	}
+	downloadPath := "compare/" + this_.task.TaskId + "/" + formatDownloadFileName(this_.TargetTableName) + ".sql"
+	err = os.MkdirAll(tempDir+"compare/"+this_.task.TaskId, os.ModePerm)
+	if err != nil {
+		return
+	}
+	f, err = os.Create(tempDir + downloadPath)
+	if err != nil {
+		return
+	}
+	this_.task.locker.Lock()
+	this_.task.Extend["downloadPath"] = downloadPath
+	this_.task.Extend["dirPath"] = tempDir + "compare/" + this_.task.TaskId
+	this_.task.locker.Unlock()
+	return
+}
+
+func (this_ *dataComparer) keyData(row []interface{}, isSource bool) (res map[string]interface{}) {
+	res = map[string]interface{}{}
+	for _, column := range this_.keyColumns {
+		if isSource {
+			res[column.sourceName] = this_.maskValue(column.sourceName, row[column.sourceIndex])
+		} else {
+			res[column.sourceName] = this_.maskValue(column.sourceName, row[column.targetIndex])
+		}
+	}
+	return
+}
+
+// maskValue 比对 明细 脱敏，优先 使用 源 工具 的 规则，其次 目标 工具 的 规则
+func (this_ *dataComparer) maskValue(columnName string, value interface{}) interface{} {
+	if this_.sourceMasker.getRule(this_.SourceTableName, columnName) != nil {
+		return this_.sourceMasker.MaskValue(this_.SourceTableName, columnName, value)
+	}
+	return this_.targetMasker.MaskValue(this_.TargetTableName, columnName, value)
+}
+
+func (this_ *dataComparer) onlyInSource(row []interface{}) (err error) {
+	this_.task.locker.Lock()
+	this_.task.OnlyInSourceCount++
+	if this_.task.detailCount() < this_.MaxDetail {
+		data := this_.keyData(row, true)
+		for _, column := range this_.compareColumns {
+			data[column.sourceName] = this_.maskValue(column.sourceName, row[column.sourceIndex])
+		}
+		this_.task.OnlyInSource = append(this_.task.OnlyInSource, data)
+	} else {
+		this_.task.DetailTruncated = true
+	}
+	this_.task.locker.Unlock()
+
+	if this_.sqlWriter == nil {
+		return
+	}
+	data := map[string]interface{}{}
+	for _, column := range this_.keyColumns {
+		data[this_.targetColumnName(column)] = row[column.sourceIndex]
+	}
+	for _, column := range this_.compareColumns {
+		data[this_.targetColumnName(column)] = row[column.sourceIndex]
+	}
+	sqlList, _, _, _, err := this_.targetService.GetDialect().DataListInsertSql(this_.sqlParam(), this_.TargetOwnerName, this_.TargetTableName, this_.targetTable.ColumnList, []map[string]interface{}{data})
+	if err != nil {
+		return
+	}
+	err = this_.writeSql(sqlList)
+	return
+}
+
+func (this_ *dataComparer) onlyInTarget(row []interface{}) (err error) {
+	this_.task.locker.Lock()
+	this_.task.OnlyInTargetCount++
+	if this_.task.detailCount() < this_.MaxDetail {
+		data := this_.keyData(row, false)
+		for _, column := range this_.compareColumns {
+			data[column.sourceName] = this_.maskValue(column.sourceName, row[column.targetIndex])
+		}
+		this_.task.OnlyInTarget = append(this_.task.OnlyInTarget, data)
+	} else {
+		this_.task.DetailTruncated = true
+	}
+	this_.task.locker.Unlock()
+
+	if this_.sqlWriter == nil {
+		return
+	}
+	sqlList, _, err := this_.targetService.GetDialect().DataListDeleteSql(this_.sqlParam(), this_.TargetOwnerName, this_.TargetTableName, this_.targetTable.ColumnList, []map[string]interface{}{this_.targetKeyData(row)})
+	if err != nil {
+		return
+	}
+	err = this_.writeSql(sqlList)
+	return
+}
+
+func (this_ *dataComparer) compareRow(sourceRow []interface{}, targetRow []interface{}) (err error) {
+	var columns []*DataCompareColumn
+	var updateData = map[string]interface{}{}
+	for _, column := range this_.compareColumns {
+		sourceValue := sourceRow[column.sourceIndex]
+		targetValue := targetRow[column.targetIndex]
+		if compareValueEqual(sourceValue, targetValue) {
+			continue
+		}
+		columns = append(columns, &DataCompareColumn{
+			ColumnName:  column.sourceName,
+			SourceValue: this_.maskValue(column.sourceName, sourceValue),
+			TargetValue: this_.maskValue(column.sourceName, targetValue),
+		})
+		updateData[this_.targetColumnName(column)] = sourceValue
+	}
+
+	this_.task.locker.Lock()
+	if len(columns) == 0 {
+		this_.task.SameCount++
+		this_.task.locker.Unlock()
+		return
+	}
+	this_.task.ChangedCount++
+	if this_.task.detailCount() < this_.MaxDetail {
+		this_.task.Changed = append(this_.task.Changed, &DataCompareChange{
+			Key:     this_.keyData(sourceRow, true),
+			Columns: columns,
+		})
+	} else {
+		this_.task.DetailTruncated = true
+	}
+	this_.task.locker.Unlock()
+
+	if this_.sqlWriter == nil {
+		return
+	}
+	sqlList, _, err := this_.targetService.GetDialect().DataListUpdateSql(this_.sqlParam(), this_.TargetOwnerName, this_.TargetTableName, this_.targetTable.ColumnList, []map[string]interface{}{updateData}, []map[string]interface{}{this_.targetKeyData(targetRow)})
+	if err != nil {
+		return
+	}
+	err = this_.writeSql(sqlList)
+	return
+}
+
+// targetColumnName 获取 目标表 中 的 字段名，用于 生成 SQL
+func (this_ *dataComparer) targetColumnName(column *compareColumn) string {
+	for _, one := range this_.targetTable.ColumnList {
+		if one.ColumnName == column.targetName {
+			return one.ColumnName
+		}
+	}
+	for _, one := range this_.targetTable.ColumnList {
+		if strings.EqualFold(one.ColumnName, column.targetName) {
+			return one.ColumnName
+		}
+	}
+	return column.targetName
+}
+
+func (this_ *dataComparer) targetKeyData(row []interface{}) (res map[string]interface{}) {
+	res = map[string]interface{}{}
+	for _, column := range this_.keyColumns {
+		res[this_.targetColumnName(column)] = row[column.targetIndex]
+	}
+	return
+}
+
+func (this_ *dataComparer) sqlParam() *dialect.ParamModel {
+	appendSqlValue := true
+	return &dialect.ParamModel{
+		AppendSqlValue: &appendSqlValue,
+	}
+}
+
+func (this_ *dataComparer) writeSql(sqlList []string) (err error) {
+	for _, one := range sqlList {
+		if _, err = this_.sqlWriter.WriteString(one + ";\n"); err != nil {
+			return
+		}
+	}
+	this_.task.locker.Lock()
+	this_.task.SqlCount += int64(len(sqlList))
+	this_.task.locker.Unlock()
+	return
+}
+
+// compareCursor 逐行 读取 一边 的 数据，并 校验 主键 顺序
+type compareCursor struct {
+	side    string
+	rows    *sql.Rows
+	columns []string
+	binary  []bool
+	number  []bool
+	keyList []int
+	// 主键 字段 是否 数字
+	keyNumbers []bool
+	row        []interface{}
+	lastKey    []interface{}
+	count      int64
+}
+
+func (this_ *compareCursor) next() (ok bool, err error) {
+	if !this_.rows.Next() {
+		err = this_.rows.Err()
+		return
+	}
+	var values = make([]interface{}, len(this_.columns))
+	var pointers = make([]interface{}, len(this_.columns))
+	for i := range values {
+		pointers[i] = &values[i]
+	}
+	err = this_.rows.Scan(pointers...)
+	if err != nil {
+		return
+	}
+	for i, value := range values {
+		// 非 二进制 字段 驱动 返回 的 字节 转换 为 字符串
+		if bs, isBytes := value.([]byte); isBytes && !this_.binary[i] {
+			values[i] = string(bs)
+		}
+	}
+	this_.row = values
+	key := this_.key()
+	for i, value := range key {
+		if value == nil {
+			err = errors.New(this_.side + "数据主键字段[" + this_.columns[this_.keyList[i]] + "]存在空值，无法比对")
+			return
+		}
+	}
+	if this_.lastKey != nil && compareKeys(this_.lastKey, key, this_.keyNumbers) > 0 {
+		err = errors.New(fmt.Sprint(this_.side, "数据主键排序与比对规则不一致，上一行", this_.lastKey, "，当前行", key, "，请检查主键字段的排序规则（如大小写、字符集）"))
+		return
+	}
+	this_.lastKey = key
+	this_.count++
+	ok = true
+	return
+}
+
+func (this_ *compareCursor) key() (res []interface{}) {
+	for _, index := range this_.keyList {
+		res = append(res, this_.row[index])
+	}
+	return
+}
+
+func (this_ *compareCursor) close() {
+	_ = this_.rows.Close()
+}
+
+// isBinaryTypeName 根据 驱动 返回 的 字段 类型 判断 是否 二进制
+func isBinaryTypeName(typeName string) bool {
+	typeName = strings.ToUpper(typeName)
+	return strings.Contains(typeName, "BLOB") || strings.Contains(typeName, "BINARY") ||
+		typeName == "BYTEA" || typeName == "RAW" || typeName == "LONG RAW" || typeName == "IMAGE"
+}
+
+// compareKeys 比较 两个 主键，数字 字段 按 数值 比较，其它 按 字节 比较，与 查询 的 排序 一致
+func compareKeys(key []interface{}, otherKey []interface{}, numbers []bool) int {
+	for i := range key {
+		var cmp int
+		if numbers[i] {
+			cmp = compareValue(key[i], otherKey[i])
+		} else {
+			cmp = strings.Compare(valueToString(key[i]), valueToString(otherKey[i]))
+		}
+		if cmp != 0 {
+			return cmp
+		}
+	}
+	return 0
+}
+
+func compareValue(value interface{}, otherValue interface{}) int {
+	str, otherStr := valueToString(value), valueToString(otherValue)
+	if num, ok := parseCompareNumber(str); ok {
+		if otherNum, ok := parseCompareNumber(otherStr); ok {
+			return num.Cmp(otherNum)
+		}
+	}
+	return strings.Compare(str, otherStr)
+}
+
+// isNumberTypeName 根据 驱动 返回 的 字段 类型 判断 是否 数字
+func isNumberTypeName(typeName string) bool {
+	typeName = strings.ToUpper(typeName)
+	if strings.Contains(typeName, "INTERVAL") || strings.Contains(typeName, "POINT") {
+		return false
+	}
+	return strings.Contains(typeName, "INT") || strings.Contains(typeName, "DECIMAL") || strings.Contains(typeName, "NUMERIC") ||
+		strings.Contains(typeName, "NUMBER") || strings.Contains(typeName, "FLOAT") || strings.Contains(typeName, "DOUBLE") ||
+		strings.Contains(typeName, "REAL") || strings.Contains(typeName, "SERIAL")
+}
+
+// isStringTypeName 根据 驱动 返回 的 字段 类型 判断 是否 字符串
+func isStringTypeName(typeName string) bool {
+	typeName = strings.ToUpper(typeName)
+	return strings.Contains(typeName, "CHAR") || strings.Contains(typeName, "TEXT") ||
+		strings.Contains(typeName, "CLOB") || strings.Contains(typeName, "STRING")
+}
+
+// compareValueEqual 比较 两边 的 值 是否 相同，数字 按 数值 比较，避免 精度 格式 不同 误报
+func compareValueEqual(value interface{}, otherValue interface{}) bool {
+	if value == nil || otherValue == nil {
+		return value == nil && otherValue == nil
+	}
+	return compareValue(value, otherValue) == 0
+}
+
+func parseCompareNumber(str string) (res *big.Rat, ok bool) {
+	str = strings.TrimSpace(str)
+	if str == "" || strings.ContainsAny(str, "/xXpP_") {
+		return
+	}
+	res, ok = new(big.Rat).SetString(str)
+	return
+}
+
+var dataCompareTaskCache = map[string]*DataCompareTask{}
+var dataCompareTaskLocker = &sync.Mutex{}
+
+func getDataCompareTask(taskId string) *DataCompareTask {
+	dataCompareTaskLocker.Lock()
+	defer dataCompareTaskLocker.Unlock()
+
+	return dataCompareTaskCache[taskId]
+}
+
+func addDataCompareTask(task *DataCompareTask) {
+	dataCompareTaskLocker.Lock()
+	defer dataCompareTaskLocker.Unlock()
+
+	dataCompareTaskCache[task.TaskId] = task
+}
+
+// removeDataCompareTask 停止 并 删除 任务，同时 删除 生成 的 SQL 文件
+func removeDataCompareTask(taskId string) {
+	dataCompareTaskLocker.Lock()
+	defer dataCompareTaskLocker.Unlock()
+
+	task := dataCompareTaskCache[taskId]
+	if task == nil {
+		return
+	}
+	task.stop()
+	if dirPath, _ := task.status().Extend["dirPath"].(string); dirPath != "" {
+		_ = os.RemoveAll(dirPath)
+	}
+	delete(dataCompareTaskCache, taskId)
+}
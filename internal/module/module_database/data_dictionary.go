@@ -0,0 +1,532 @@
+package module_database
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/tealeg/xlsx"
+	"github.com/team-ide/go-dialect/dialect"
+	"html"
+	"strings"
+	"teamide/pkg/base"
+	"time"
+)
+
+type DataDictionaryRequest struct {
+	OwnerName  string   `json:"ownerName,omitempty"`
+	TableNames []string `json:"tableNames,omitempty"`
+	Format     string   `json:"format,omitempty"` // markdown、html、excel
+	Title      string   `json:"title,omitempty"`
+
+	// 对比 旧 版本 生成 变更 记录，PreviousSnapshot 为 之前 生成 的 结构 快照，为空 时 使用 PreviousOwnerName 对比
+	PreviousSnapshot  string `json:"previousSnapshot,omitempty"`
+	PreviousToolboxId int64  `json:"previousToolboxId,omitempty"` // 为空 使用 当前 工具
+	PreviousOwnerName string `json:"previousOwnerName,omitempty"`
+}
+
+type DataDictionaryResult struct {
+	Format               string `json:"format"`
+	FileName             string `json:"fileName"`
+	Content              string `json:"content,omitempty"`
+	DownloadPath         string `json:"downloadPath"`
+	SnapshotFileName     string `json:"snapshotFileName"`
+	SnapshotDownloadPath string `json:"snapshotDownloadPath"` // 结构 快照，用于 下次 对比
+	TableCount           int    `json:"tableCount"`
+	ChangeCount          int    `json:"changeCount"`
+}
+
+func (this_ *api) dataDictionary(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	config, sshConfig, err := this_.getConfig(requestBean, c)
+	if err != nil {
+		return
+	}
+	service, err := getService(config, sshConfig)
+	if err != nil {
+		return
+	}
+
+	var request = &DataDictionaryRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	param := this_.getParam(requestBean, c)
+
+	result := &DataDictionaryResult{
+		Format: strings.ToLower(request.Format),
+	}
+	var fileSuffix string
+	switch result.Format {
+	case "", "markdown", "md":
+		result.Format = "markdown"
+		fileSuffix = ".md"
+	case "html":
+		fileSuffix = ".html"
+	case "excel", "xlsx":
+		result.Format = "excel"
+		fileSuffix = ".xlsx"
+	default:
+		err = errors.New("不支持的数据字典格式[" + request.Format + "]")
+		return
+	}
+
+	tables, err := loadSchemaTables(service, param, request.OwnerName, request.TableNames)
+	if err != nil {
+		return
+	}
+
+	builder := &dataDictionaryBuilder{
+		DataDictionaryRequest: request,
+		dia:                   service.GetDialect(),
+		tables:                tables,
+	}
+	if builder.Title == "" {
+		builder.Title = request.OwnerName + " 数据字典"
+	}
+
+	var previousTables []*dialect.TableModel
+	var hasPrevious bool
+	if request.PreviousSnapshot != "" {
+		hasPrevious = true
+		err = json.Unmarshal([]byte(request.PreviousSnapshot), &previousTables)
+		if err != nil {
+			err = errors.New("结构快照解析失败:" + err.Error())
+			return
+		}
+	} else if request.PreviousOwnerName != "" {
+		hasPrevious = true
+		previousService := service
+		if request.PreviousToolboxId != 0 {
+			previousService, err = this_.getServiceByToolboxId(requestBean, request.PreviousToolboxId)
+			if err != nil {
+				return
+			}
+		}
+		previousTables, err = loadSchemaTables(previousService, param, request.PreviousOwnerName, request.TableNames)
+		if err != nil {
+			return
+		}
+	}
+	if hasPrevious {
+		// 当前 结构 作为 源，旧 版本 作为 目标，对比 出 旧 版本 到 当前 的 变更
+		differ := &schemaDiffer{
+			SchemaDiffRequest: &SchemaDiffRequest{
+				TargetOwnerName: request.OwnerName,
+			},
+			param:         param.ParamModel,
+			sourceDialect: service.GetDialect(),
+			targetDialect: service.GetDialect(),
+		}
+		var diffResult *SchemaDiffResult
+		diffResult, err = differ.diff(tables, previousTables)
+		if err != nil {
+			return
+		}
+		builder.changes = builder.changeRows(diffResult.TableList)
+		if len(builder.changes) == 0 {
+			builder.changes = [][]string{}
+		}
+	}
+
+	var content []byte
+	switch result.Format {
+	case "markdown":
+		result.Content = builder.markdown()
+		content = []byte(result.Content)
+	case "html":
+		result.Content = builder.html()
+		content = []byte(result.Content)
+	case "excel":
+		content, err = builder.excel()
+		if err != nil {
+			return
+		}
+	}
+
+	fileName := "dictionary"
+	if request.OwnerName != "" {
+		fileName = request.OwnerName + "-dictionary"
+	}
+	result.FileName = fileName + fileSuffix
+	result.DownloadPath, err = saveDownloadFile(result.FileName, content)
+	if err != nil {
+		return
+	}
+
+	snapshot, err := json.Marshal(tables)
+	if err != nil {
+		return
+	}
+	result.SnapshotFileName = fileName + "-snapshot-" + time.Now().Format("20060102150405") + ".json"
+	result.SnapshotDownloadPath, err = saveDownloadFile(result.SnapshotFileName, snapshot)
+	if err != nil {
+		return
+	}
+	result.TableCount = len(tables)
+	result.ChangeCount = len(builder.changes)
+	res = result
+	return
+}
+
+var (
+	dataDictionaryColumnHeaders = []string{"序号", "字段名", "类型", "主键", "非空", "默认值", "注释"}
+	dataDictionaryIndexHeaders  = []string{"索引名", "类型", "字段", "注释"}
+	dataDictionaryTableHeaders  = []string{"序号", "表名", "注释", "字段数"}
+	dataDictionaryChangeHeaders = []string{"表名", "变更", "说明"}
+)
+
+type dataDictionaryBuilder struct {
+	*DataDictionaryRequest
+	dia     dialect.Dialect
+	tables  []*dialect.TableModel
+	changes [][]string // 变更 记录，nil 表示 未 对比
+}
+
+func (this_ *dataDictionaryBuilder) columnType(column *dialect.ColumnModel) string {
+	columnType, err := this_.dia.ColumnTypePack(column)
+	if err != nil || columnType == "" {
+		columnType = column.ColumnDataType
+	}
+	return columnType
+}
+
+func (this_ *dataDictionaryBuilder) tableRows() (rows [][]string) {
+	for i, table := range this_.tables {
+		rows = append(rows, []string{fmt.Sprint(i + 1), table.TableName, table.TableComment, fmt.Sprint(len(table.ColumnList))})
+	}
+	return
+}
+
+func (this_ *dataDictionaryBuilder) columnRows(table *dialect.TableModel) (rows [][]string) {
+	for i, column := range table.ColumnList {
+		rows = append(rows, []string{
+			fmt.Sprint(i + 1),
+			column.ColumnName,
+			this_.columnType(column),
+			yesOrEmpty(column.PrimaryKey),
+			yesOrEmpty(column.ColumnNotNull),
+			column.ColumnDefault,
+			column.ColumnComment,
+		})
+	}
+	return
+}
+
+func (this_ *dataDictionaryBuilder) indexRows(table *dialect.TableModel) (rows [][]string) {
+	if len(table.PrimaryKeys) > 0 {
+		rows = append(rows, []string{"PRIMARY", "PRIMARY KEY", strings.Join(table.PrimaryKeys, ", "), ""})
+	}
+	for _, index := range table.IndexList {
+		columnNames := index.ColumnNames
+		if len(columnNames) == 0 && index.ColumnName != "" {
+			columnNames = []string{index.ColumnName}
+		}
+		indexType := index.IndexType
+		if indexType == "" {
+			indexType = "INDEX"
+		}
+		rows = append(rows, []string{index.IndexName, indexType, strings.Join(columnNames, ", "), index.IndexComment})
+	}
+	return
+}
+
+// changeRows 将 结构 对比 结果 转为 变更 记录
+func (this_ *dataDictionaryBuilder) changeRows(tableList []*TableDiff) (rows [][]string) {
+	for _, tableDiff := range tableList {
+		switch tableDiff.DiffType {
+		case diffTypeCreate:
+			rows = append(rows, []string{tableDiff.TableName, "新增表", tableDiff.TableComment})
+			continue
+		case diffTypeDrop:
+			rows = append(rows, []string{tableDiff.TableName, "删除表", tableDiff.OldTableComment})
+			continue
+		}
+		if tableDiff.TableComment != tableDiff.OldTableComment {
+			rows = append(rows, []string{tableDiff.TableName, "修改表注释", tableDiff.OldTableComment + " -> " + tableDiff.TableComment})
+		}
+		for _, columnDiff := range tableDiff.ColumnList {
+			switch columnDiff.DiffType {
+			case diffTypeCreate:
+				rows = append(rows, []string{tableDiff.TableName, "新增字段", columnDiff.ColumnName + " " + this_.columnDesc(columnDiff.Column)})
+			case diffTypeDrop:
+				rows = append(rows, []string{tableDiff.TableName, "删除字段", columnDiff.ColumnName + " " + this_.columnDesc(columnDiff.OldColumn)})
+			default:
+				rows = append(rows, []string{tableDiff.TableName, "修改字段", columnDiff.ColumnName + " " + this_.columnChangeDesc(columnDiff)})
+			}
+		}
+		if tableDiff.PrimaryKeyChanged {
+			rows = append(rows, []string{tableDiff.TableName, "修改主键", "(" + strings.Join(tableDiff.OldPrimaryKeys, ", ") + ") -> (" + strings.Join(tableDiff.PrimaryKeys, ", ") + ")"})
+		}
+		for _, indexDiff := range tableDiff.IndexList {
+			switch indexDiff.DiffType {
+			case diffTypeCreate:
+				rows = append(rows, []string{tableDiff.TableName, "新增索引", indexDiff.IndexName + " " + indexDesc(indexDiff.Index)})
+			case diffTypeDrop:
+				rows = append(rows, []string{tableDiff.TableName, "删除索引", indexDiff.IndexName + " " + indexDesc(indexDiff.OldIndex)})
+			default:
+				rows = append(rows, []string{tableDiff.TableName, "修改索引", indexDiff.IndexName + " " + indexDesc(indexDiff.OldIndex) + " -> " + indexDesc(indexDiff.Index)})
+			}
+		}
+	}
+	return
+}
+
+func (this_ *dataDictionaryBuilder) columnDesc(column *dialect.ColumnModel) string {
+	if column == nil {
+		return ""
+	}
+	desc := this_.columnType(column)
+	if column.ColumnNotNull {
+		desc += " NOT NULL"
+	}
+	if column.ColumnComment != "" {
+		desc += " " + column.ColumnComment
+	}
+	return desc
+}
+
+func (this_ *dataDictionaryBuilder) columnChangeDesc(columnDiff *ColumnDiff) string {
+	column, oldColumn := columnDiff.Column, columnDiff.OldColumn
+	if column == nil || oldColumn == nil {
+		return strings.Join(columnDiff.Changes, ", ")
+	}
+	var list []string
+	for _, change := range columnDiff.Changes {
+		switch change {
+		case "type":
+			list = append(list, "类型 "+this_.columnType(oldColumn)+" -> "+this_.columnType(column))
+		case "notNull":
+			list = append(list, fmt.Sprintf("非空 %v -> %v", oldColumn.ColumnNotNull, column.ColumnNotNull))
+		case "default":
+			list = append(list, "默认值 "+oldColumn.ColumnDefault+" -> "+column.ColumnDefault)
+		case "comment":
+			list = append(list, "注释 "+oldColumn.ColumnComment+" -> "+column.ColumnComment)
+		default:
+			list = append(list, change)
+		}
+	}
+	return strings.Join(list, "; ")
+}
+
+func indexDesc(index *dialect.IndexModel) string {
+	if index == nil {
+		return ""
+	}
+	columnNames := index.ColumnNames
+	if len(columnNames) == 0 && index.ColumnName != "" {
+		columnNames = []string{index.ColumnName}
+	}
+	return strings.TrimSpace(index.IndexType + " (" + strings.Join(columnNames, ", ") + ")")
+}
+
+func yesOrEmpty(v bool) string {
+	if v {
+		return "Y"
+	}
+	return ""
+}
+
+func (this_ *dataDictionaryBuilder) markdown() string {
+	var buf strings.Builder
+	var writeTable = func(headers []string, rows [][]string) {
+		buf.WriteString("| " + strings.Join(headers, " | ") + " |\n")
+		buf.WriteString("|" + strings.Repeat(" --- |", len(headers)) + "\n")
+		for _, row := range rows {
+			var cells []string
+			for _, cell := range row {
+				cells = append(cells, markdownCell(cell))
+			}
+			buf.WriteString("| " + strings.Join(cells, " | ") + " |\n")
+		}
+		buf.WriteString("\n")
+	}
+
+	buf.WriteString("# " + this_.Title + "\n\n")
+	buf.WriteString("> 生成时间：" + time.Now().Format("2006-01-02 15:04:05") + "\n\n")
+	if this_.changes != nil {
+		buf.WriteString("## 变更记录\n\n")
+		if len(this_.changes) == 0 {
+			buf.WriteString("无变更\n\n")
+		} else {
+			writeTable(dataDictionaryChangeHeaders, this_.changes)
+		}
+	}
+	buf.WriteString("## 表目录\n\n")
+	writeTable(dataDictionaryTableHeaders, this_.tableRows())
+	for _, table := range this_.tables {
+		title := table.TableName
+		if table.TableComment != "" {
+			title += "（" + markdownCell(table.TableComment) + "）"
+		}
+		buf.WriteString("## " + title + "\n\n")
+		writeTable(dataDictionaryColumnHeaders, this_.columnRows(table))
+		if indexRows := this_.indexRows(table); len(indexRows) > 0 {
+			buf.WriteString("**索引**\n\n")
+			writeTable(dataDictionaryIndexHeaders, indexRows)
+		}
+	}
+	return buf.String()
+}
+
+func markdownCell(v string) string {
+	v = strings.ReplaceAll(v, "|", "\\|")
+	v = strings.ReplaceAll(v, "\r\n", "<br>")
+	v = strings.ReplaceAll(v, "\n", "<br>")
+	return v
+}
+
+func (this_ *dataDictionaryBuilder) html() string {
+	var buf strings.Builder
+	var writeTable = func(headers []string, rows [][]string) {
+		buf.WriteString("<table>\n<tr>")
+		for _, header := range headers {
+			buf.WriteString("<th>" + html.EscapeString(header) + "</th>")
+		}
+		buf.WriteString("</tr>\n")
+		for _, row := range rows {
+			buf.WriteString("<tr>")
+			for _, cell := range row {
+				buf.WriteString("<td>" + strings.ReplaceAll(html.EscapeString(cell), "\n", "<br>") + "</td>")
+			}
+			buf.WriteString("</tr>\n")
+		}
+		buf.WriteString("</table>\n")
+	}
+
+	title := html.EscapeString(this_.Title)
+	buf.WriteString(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>` + title + `</title>
+<style>
+body { font-family: Arial, "Microsoft YaHei", sans-serif; font-size: 13px; margin: 20px; }
+table { border-collapse: collapse; margin-bottom: 16px; }
+th, td { border: 1px solid #ccc; padding: 4px 8px; text-align: left; vertical-align: top; }
+th { background: #f0f0f0; }
+</style>
+</head>
+<body>
+`)
+	buf.WriteString("<h1>" + title + "</h1>\n")
+	buf.WriteString("<p>生成时间：" + time.Now().Format("2006-01-02 15:04:05") + "</p>\n")
+	if this_.changes != nil {
+		buf.WriteString("<h2>变更记录</h2>\n")
+		if len(this_.changes) == 0 {
+			buf.WriteString("<p>无变更</p>\n")
+		} else {
+			writeTable(dataDictionaryChangeHeaders, this_.changes)
+		}
+	}
+	buf.WriteString("<h2>表目录</h2>\n<table>\n<tr>")
+	for _, header := range dataDictionaryTableHeaders {
+		buf.WriteString("<th>" + html.EscapeString(header) + "</th>")
+	}
+	buf.WriteString("</tr>\n")
+	for i, row := range this_.tableRows() {
+		buf.WriteString(fmt.Sprintf(`<tr><td>%s</td><td><a href="#table-%d">%s</a></td><td>%s</td><td>%s</td></tr>`, row[0], i+1, html.EscapeString(row[1]), html.EscapeString(row[2]), row[3]) + "\n")
+	}
+	buf.WriteString("</table>\n")
+	for i, table := range this_.tables {
+		title := html.EscapeString(table.TableName)
+		if table.TableComment != "" {
+			title += "（" + html.EscapeString(table.TableComment) + "）"
+		}
+		buf.WriteString(fmt.Sprintf(`<h2 id="table-%d">%s</h2>`, i+1, title) + "\n")
+		writeTable(dataDictionaryColumnHeaders, this_.columnRows(table))
+		if indexRows := this_.indexRows(table); len(indexRows) > 0 {
+			buf.WriteString("<h4>索引</h4>\n")
+			writeTable(dataDictionaryIndexHeaders, indexRows)
+		}
+	}
+	buf.WriteString("</body>\n</html>\n")
+	return buf.String()
+}
+
+// excel 生成 Excel，目录、数据字典、变更记录 分别 一个 Sheet
+func (this_ *dataDictionaryBuilder) excel() (content []byte, err error) {
+	file := xlsx.NewFile()
+
+	headerStyle := xlsx.NewStyle()
+	headerStyle.Font.Bold = true
+	headerStyle.Fill = *xlsx.NewFill("solid", "FFDDEBF7", "FFDDEBF7")
+	headerStyle.ApplyFont = true
+	headerStyle.ApplyFill = true
+	titleStyle := xlsx.NewStyle()
+	titleStyle.Font.Bold = true
+	titleStyle.Font.Size = 12
+	titleStyle.ApplyFont = true
+
+	var addRow = func(sheet *xlsx.Sheet, values []string, style *xlsx.Style) {
+		row := sheet.AddRow()
+		for _, value := range values {
+			cell := row.AddCell()
+			cell.SetString(value)
+			if style != nil {
+				cell.SetStyle(style)
+			}
+		}
+	}
+
+	if this_.changes != nil {
+		var sheet *xlsx.Sheet
+		sheet, err = file.AddSheet("变更记录")
+		if err != nil {
+			return
+		}
+		addRow(sheet, dataDictionaryChangeHeaders, headerStyle)
+		for _, row := range this_.changes {
+			addRow(sheet, row, nil)
+		}
+		_ = sheet.SetColWidth(0, 1, 24)
+		_ = sheet.SetColWidth(2, 2, 80)
+	}
+
+	sheet, err := file.AddSheet("目录")
+	if err != nil {
+		return
+	}
+	addRow(sheet, []string{this_.Title}, titleStyle)
+	addRow(sheet, dataDictionaryTableHeaders, headerStyle)
+	for _, row := range this_.tableRows() {
+		addRow(sheet, row, nil)
+	}
+	_ = sheet.SetColWidth(0, 0, 8)
+	_ = sheet.SetColWidth(1, 2, 36)
+
+	sheet, err = file.AddSheet("数据字典")
+	if err != nil {
+		return
+	}
+	for _, table := range this_.tables {
+		title := table.TableName
+		if table.TableComment != "" {
+			title += "（" + table.TableComment + "）"
+		}
+		addRow(sheet, []string{title}, titleStyle)
+		addRow(sheet, dataDictionaryColumnHeaders, headerStyle)
+		for _, row := range this_.columnRows(table) {
+			addRow(sheet, row, nil)
+		}
+		if indexRows := this_.indexRows(table); len(indexRows) > 0 {
+			addRow(sheet, append([]string{""}, dataDictionaryIndexHeaders...), headerStyle)
+			for _, row := range indexRows {
+				addRow(sheet, append([]string{""}, row...), nil)
+			}
+		}
+		sheet.AddRow()
+	}
+	_ = sheet.SetColWidth(0, 0, 8)
+	_ = sheet.SetColWidth(1, 2, 24)
+	_ = sheet.SetColWidth(3, 5, 12)
+	_ = sheet.SetColWidth(6, 6, 48)
+
+	var buf bytes.Buffer
+	err = file.Write(&buf)
+	if err != nil {
+		return
+	}
+	content = buf.Bytes()
+	return
+}
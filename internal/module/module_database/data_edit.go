@@ -0,0 +1,519 @@
+package module_database
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/team-ide/go-dialect/dialect"
+	"github.com/team-ide/go-dialect/worker"
+	"github.com/team-ide/go-tool/db"
+	"github.com/team-ide/go-tool/util"
+	"go.uber.org/zap"
+	"strings"
+	"teamide/internal/context"
+	"teamide/internal/module/module_id"
+	"teamide/pkg/base"
+	"time"
+)
+
+// NewDataEditService 根据库配置创建DataEditService
+func NewDataEditService(ServerContext *context.ServerContext) (res *DataEditService) {
+
+	idService := module_id.NewIDService(ServerContext)
+
+	res = &DataEditService{
+		ServerContext: ServerContext,
+		idService:     idService,
+	}
+	return
+}
+
+// DataEditService 数据库数据编辑记录服务
+type DataEditService struct {
+	*context.ServerContext
+	idService *module_id.IDService
+}
+
+// Insert 新增
+func (this_ *DataEditService) Insert(dataEdit *DataEditModel) (err error) {
+
+	if dataEdit.DataEditId == 0 {
+		dataEdit.DataEditId, err = this_.idService.GetNextID(module_id.IDTypeDatabaseDataEdit)
+		if err != nil {
+			return
+		}
+	}
+	if dataEdit.Status == 0 {
+		dataEdit.Status = 1
+	}
+	if dataEdit.CreateTime.IsZero() {
+		dataEdit.CreateTime = time.Now()
+	}
+
+	sql := `INSERT INTO ` + TableDatabaseDataEdit + `(dataEditId, toolboxId, userId, userName, userAccount, ownerName, tableName, insertCount, updateCount, deleteCount, executeSql, undoData, status, createTime) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?) `
+
+	_, err = this_.DatabaseWorker.Exec(sql, []interface{}{dataEdit.DataEditId, dataEdit.ToolboxId, dataEdit.UserId, dataEdit.UserName, dataEdit.UserAccount, dataEdit.OwnerName, dataEdit.TableName, dataEdit.InsertCount, dataEdit.UpdateCount, dataEdit.DeleteCount, dataEdit.ExecuteSql, dataEdit.UndoData, dataEdit.Status, dataEdit.CreateTime})
+	if err != nil {
+		this_.Logger.Error("Insert Error", zap.Error(err))
+		return
+	}
+	return
+}
+
+// Get 查询单个
+func (this_ *DataEditService) Get(dataEditId int64) (res *DataEditModel, err error) {
+	res = &DataEditModel{}
+
+	sql := `SELECT * FROM ` + TableDatabaseDataEdit + ` WHERE dataEditId=? `
+	find, err := this_.DatabaseWorker.QueryOne(sql, []interface{}{dataEditId}, res)
+	if err != nil {
+		this_.Logger.Error("Get Error", zap.Error(err))
+		return
+	}
+	if !find {
+		res = nil
+	}
+	return
+}
+
+// GetLast 查询 用户 在 工具 中 最后 一次 未撤销 的 编辑
+func (this_ *DataEditService) GetLast(toolboxId int64, userId int64) (res *DataEditModel, err error) {
+	var list []*DataEditModel
+
+	sql := `SELECT * FROM ` + TableDatabaseDataEdit + ` WHERE toolboxId=? AND userId=? AND status=1 ORDER BY createTime DESC, dataEditId DESC `
+	page := worker.NewPage()
+	page.PageSize = 1
+	err = this_.DatabaseWorker.QueryPage(sql, []interface{}{toolboxId, userId}, &list, page)
+	if err != nil {
+		this_.Logger.Error("GetLast Error", zap.Error(err))
+		return
+	}
+	if len(list) > 0 {
+		res = list[0]
+	}
+	return
+}
+
+type DataEditPage struct {
+	*worker.Page
+	DataList []*DataEditModel `json:"dataList"`
+}
+
+// DataEditQuery 数据编辑记录 查询条件
+type DataEditQuery struct {
+	ToolboxId int64  `json:"toolboxId,omitempty"`
+	UserId    int64  `json:"-"`
+	OwnerName string `json:"ownerName,omitempty"`
+	TableName string `json:"tableName,omitempty"`
+	Status    int8   `json:"status,omitempty"`
+}
+
+// QueryPage 分页查询 用户 自己 的 编辑 记录，不 返回 撤销 数据
+func (this_ *DataEditService) QueryPage(query *DataEditQuery, page *DataEditPage) (err error) {
+	var sql string
+	var values []interface{}
+
+	sql += "SELECT * FROM " + TableDatabaseDataEdit + " WHERE toolboxId=? AND userId=?"
+	values = append(values, query.ToolboxId, query.UserId)
+
+	if query.OwnerName != "" {
+		sql += " AND ownerName=?"
+		values = append(values, query.OwnerName)
+	}
+	if query.TableName != "" {
+		sql += " AND tableName=?"
+		values = append(values, query.TableName)
+	}
+	if query.Status != 0 {
+		sql += " AND status=?"
+		values = append(values, query.Status)
+	}
+	sql += " ORDER BY createTime DESC, dataEditId DESC"
+	page.DataList = []*DataEditModel{}
+	err = this_.DatabaseWorker.QueryPage(sql, values, &page.DataList, page.Page)
+	if err != nil {
+		return
+	}
+	for _, one := range page.DataList {
+		one.UndoData = ""
+	}
+	return
+}
+
+// MarkUndo 标记 为 已撤销，只有 未撤销 的 可以 标记，返回 是否 标记 成功
+func (this_ *DataEditService) MarkUndo(dataEditId int64) (ok bool, err error) {
+
+	sql := `UPDATE ` + TableDatabaseDataEdit + ` SET status=2,undoTime=? WHERE dataEditId=? AND status=1 `
+
+	rowsAffected, err := this_.DatabaseWorker.Exec(sql, []interface{}{time.Now(), dataEditId})
+	if err != nil {
+		this_.Logger.Error("MarkUndo Error", zap.Error(err))
+		return
+	}
+	ok = rowsAffected > 0
+	return
+}
+
+// UndoEnd 撤销 执行 完成，成功 保存 撤销 SQL，失败 恢复 为 未撤销
+func (this_ *DataEditService) UndoEnd(dataEditId int64, undoSql string, undoErr error) (err error) {
+
+	var sql string
+	var values []interface{}
+	if undoErr != nil {
+		sql = `UPDATE ` + TableDatabaseDataEdit + ` SET status=1,undoTime=NULL WHERE dataEditId=? `
+		values = []interface{}{dataEditId}
+	} else {
+		sql = `UPDATE ` + TableDatabaseDataEdit + ` SET undoSql=? WHERE dataEditId=? `
+		values = []interface{}{undoSql, dataEditId}
+	}
+	_, err = this_.DatabaseWorker.Exec(sql, values)
+	if err != nil {
+		this_.Logger.Error("UndoEnd Error", zap.Error(err))
+		return
+	}
+	return
+}
+
+// DataEditUndo 撤销 编辑 需要 执行 的 数据，按 新增、修改、删除 的 顺序 执行 即 为 编辑 的 逆序
+type DataEditUndo struct {
+	ColumnList      []*dialect.ColumnModel   `json:"columnList"`
+	InsertList      []map[string]interface{} `json:"insertList"`      // 恢复 删除 的 行
+	UpdateList      []map[string]interface{} `json:"updateList"`      // 恢复 修改 前 的 值
+	UpdateWhereList []map[string]interface{} `json:"updateWhereList"` // 修改 后 的 行 条件
+	DeleteList      []map[string]interface{} `json:"deleteList"`      // 删除 新增 的 行
+}
+
+// captureDataEditUndo 执行 编辑 前 查询 修改、删除 的 行，生成 撤销 数据
+// 查询 与 执行 不在 同一 事务 中，期间 其它 会话 的 修改 不会 被 记录
+func captureDataEditUndo(service db.IService, param *db.Param, request *BaseRequest) (undo *DataEditUndo, err error) {
+	undo = &DataEditUndo{
+		ColumnList: request.ColumnList,
+	}
+	var primaryKeys []string
+	for _, column := range request.ColumnList {
+		if column.PrimaryKey {
+			primaryKeys = append(primaryKeys, column.ColumnName)
+		}
+	}
+
+	for _, data := range request.InsertList {
+		undo.DeleteList = append(undo.DeleteList, dataEditRowWhere(primaryKeys, data))
+	}
+
+	for index, data := range request.UpdateList {
+		if index >= len(request.UpdateWhereList) {
+			break
+		}
+		var beforeList []map[string]interface{}
+		beforeList, err = queryDataEditRows(service, param, request, request.UpdateWhereList[index])
+		if err != nil {
+			return
+		}
+		for _, before := range beforeList {
+			var updateData = map[string]interface{}{}
+			for name := range data {
+				updateData[name] = before[name]
+			}
+			// 修改 后 的 行 使用 修改 后 的 值 作为 条件
+			var afterData = map[string]interface{}{}
+			for name, value := range before {
+				afterData[name] = value
+			}
+			for name, value := range data {
+				afterData[name] = value
+			}
+			var where map[string]interface{}
+			if len(primaryKeys) > 0 {
+				where = dataEditRowWhere(primaryKeys, afterData)
+			} else {
+				where = map[string]interface{}{}
+				for name := range request.UpdateWhereList[index] {
+					where[name] = afterData[name]
+				}
+			}
+			undo.UpdateList = append(undo.UpdateList, updateData)
+			undo.UpdateWhereList = append(undo.UpdateWhereList, where)
+		}
+	}
+
+	for _, dataWhere := range request.DeleteList {
+		var beforeList []map[string]interface{}
+		beforeList, err = queryDataEditRows(service, param, request, dataWhere)
+		if err != nil {
+			return
+		}
+		undo.InsertList = append(undo.InsertList, beforeList...)
+	}
+	return
+}
+
+// dataEditRowWhere 有 主键 且 数据 中 包含 主键 时 使用 主键 作为 条件，否则 使用 所有 非空 字段
+func dataEditRowWhere(primaryKeys []string, data map[string]interface{}) (where map[string]interface{}) {
+	where = map[string]interface{}{}
+	for _, name := range primaryKeys {
+		value, ok := data[name]
+		if !ok || value == nil {
+			where = map[string]interface{}{}
+			break
+		}
+		where[name] = value
+	}
+	if len(where) > 0 {
+		return
+	}
+	for name, value := range data {
+		if value != nil {
+			where[name] = value
+		}
+	}
+	return
+}
+
+// queryDataEditRows 按 编辑 条件 查询 当前 行，条件 与 执行 时 生成 的 一致
+func queryDataEditRows(service db.IService, param *db.Param, request *BaseRequest, dataWhere map[string]interface{}) (list []map[string]interface{}, err error) {
+	if len(dataWhere) == 0 {
+		err = errors.New("更新数据条件丢失")
+		return
+	}
+	dia := service.GetDialect()
+	var values []interface{}
+	selectSql := "SELECT * FROM " + dia.OwnerTablePack(param.ParamModel, request.OwnerName, request.TableName) + " WHERE "
+	var i int
+	for name, value := range dataWhere {
+		if i > 0 {
+			selectSql += " AND "
+		}
+		selectSql += dia.ColumnNamePack(param.ParamModel, name) + "=?"
+		values = append(values, value)
+		i++
+	}
+	selectSql = dia.ReplaceSqlVariable(selectSql, values)
+	list, err = service.QueryMap(selectSql, values)
+	if err != nil {
+		err = errors.New("查询修改前数据失败：" + err.Error())
+		return
+	}
+	// 只 保留 编辑 的 字段
+	if len(request.ColumnList) > 0 {
+		for _, one := range list {
+			for name := range one {
+				if dataEditColumn(request.ColumnList, name) == nil {
+					delete(one, name)
+				}
+			}
+		}
+	}
+	return
+}
+
+func dataEditColumn(columnList []*dialect.ColumnModel, name string) *dialect.ColumnModel {
+	for _, column := range columnList {
+		if column.ColumnName == name {
+			return column
+		}
+	}
+	return nil
+}
+
+// restoreDataEditValues 将 JSON 保存 的 值 转换 为 字段 类型 对应 的 值，二进制 保存 为 base64
+func restoreDataEditValues(columnList []*dialect.ColumnModel, list []map[string]interface{}) (err error) {
+	for _, data := range list {
+		for name, value := range data {
+			if value == nil {
+				continue
+			}
+			column := dataEditColumn(columnList, name)
+			if column == nil {
+				continue
+			}
+			kind := columnValueKind(column)
+			if str, ok := value.(string); ok && kind == valueKindBinary {
+				if bs, e := base64.StdEncoding.DecodeString(str); e == nil {
+					data[name] = bs
+					continue
+				}
+			}
+			data[name], err = coerceImportValue(kind, value)
+			if err != nil {
+				err = errors.New("字段[" + name + "]" + err.Error())
+				return
+			}
+		}
+	}
+	return
+}
+
+// recordDataEdit 记录 编辑，记录 失败 不影响 执行 结果
+func (this_ *api) recordDataEdit(requestBean *base.RequestBean, request *BaseRequest, sqlList []string, undo *DataEditUndo) {
+	bs, err := json.Marshal(undo)
+	if err != nil {
+		util.Logger.Error("database data edit undo to json error", zap.Error(err))
+		return
+	}
+	dataEdit := &DataEditModel{
+		ToolboxId:   request.ToolboxId,
+		OwnerName:   request.OwnerName,
+		TableName:   request.TableName,
+		InsertCount: len(request.InsertList),
+		UpdateCount: len(request.UpdateList),
+		DeleteCount: len(request.DeleteList),
+		ExecuteSql:  strings.Join(sqlList, ";\n"),
+		UndoData:    string(bs),
+	}
+	if requestBean.JWT != nil {
+		dataEdit.UserId = requestBean.JWT.UserId
+		dataEdit.UserName = requestBean.JWT.Name
+		dataEdit.UserAccount = requestBean.JWT.Account
+	}
+	err = this_.dataEditService.Insert(dataEdit)
+	if err != nil {
+		util.Logger.Error("database data edit insert error", zap.Error(err))
+	}
+}
+
+type DataEditRequest struct {
+	*DataEditPage
+	*DataEditQuery
+	DataEditId int64 `json:"dataEditId,omitempty"`
+}
+
+func (this_ *api) dataEditQuery(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	_, _, err = this_.getConfig(requestBean, c)
+	if err != nil {
+		return
+	}
+
+	request := &DataEditRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	if request.DataEditQuery == nil {
+		request.DataEditQuery = &DataEditQuery{}
+	}
+	if request.DataEditPage == nil {
+		request.DataEditPage = &DataEditPage{}
+	}
+	if request.DataEditPage.Page == nil {
+		request.DataEditPage.Page = worker.NewPage()
+		request.DataEditPage.Page.PageSize = 20
+	}
+	request.DataEditQuery.UserId = requestBean.JWT.UserId
+
+	err = this_.dataEditService.QueryPage(request.DataEditQuery, request.DataEditPage)
+	if err != nil {
+		return
+	}
+	res = request.DataEditPage
+	return
+}
+
+// dataEditUndo 撤销 指定 的 编辑，不 指定 撤销 最后 一次 编辑
+func (this_ *api) dataEditUndo(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	config, sshConfig, err := this_.getConfig(requestBean, c)
+	if err != nil {
+		return
+	}
+	service, err := getService(config, sshConfig)
+	if err != nil {
+		return
+	}
+
+	var request = &BaseRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	editRequest := &DataEditRequest{}
+	if !base.RequestJSON(editRequest, c) {
+		return
+	}
+	param := this_.getParam(requestBean, c)
+
+	var dataEdit *DataEditModel
+	if editRequest.DataEditId != 0 {
+		dataEdit, err = this_.dataEditService.Get(editRequest.DataEditId)
+	} else {
+		dataEdit, err = this_.dataEditService.GetLast(request.ToolboxId, requestBean.JWT.UserId)
+	}
+	if err != nil {
+		return
+	}
+	if dataEdit == nil || dataEdit.ToolboxId != request.ToolboxId {
+		err = errors.New("没有可以撤销的编辑")
+		return
+	}
+	if dataEdit.UserId != requestBean.JWT.UserId {
+		err = errors.New("编辑记录不属于当前用户，无法撤销")
+		return
+	}
+	if dataEdit.Status != 1 {
+		err = errors.New("编辑已撤销")
+		return
+	}
+
+	undo := &DataEditUndo{}
+	err = util.JSONDecodeUseNumber([]byte(dataEdit.UndoData), undo)
+	if err != nil {
+		return
+	}
+	for _, list := range [][]map[string]interface{}{undo.InsertList, undo.UpdateList, undo.UpdateWhereList, undo.DeleteList} {
+		err = restoreDataEditValues(undo.ColumnList, list)
+		if err != nil {
+			return
+		}
+	}
+
+	sqlList, err := service.DataListSql(this_.getParam(requestBean, c), dataEdit.OwnerName, dataEdit.TableName, undo.ColumnList,
+		undo.InsertList,
+		undo.UpdateList, undo.UpdateWhereList,
+		undo.DeleteList,
+	)
+	if err != nil {
+		return
+	}
+	request.OwnerName = dataEdit.OwnerName
+	request.TableName = dataEdit.TableName
+	err = this_.checkProtected(requestBean, c, "dataEditUndo", request, classifySql(service.GetDialect(), strings.Join(sqlList, ";\n")))
+	if err != nil {
+		return
+	}
+
+	ok, err := this_.dataEditService.MarkUndo(dataEdit.DataEditId)
+	if err != nil {
+		return
+	}
+	if !ok {
+		err = errors.New("编辑已撤销")
+		return
+	}
+	startTime := util.GetNowMilli()
+	err = service.DataListExec(param, dataEdit.OwnerName, dataEdit.TableName, undo.ColumnList,
+		undo.InsertList,
+		undo.UpdateList, undo.UpdateWhereList,
+		undo.DeleteList,
+	)
+	undoSql := strings.Join(sqlList, ";\n")
+	if e := this_.dataEditService.UndoEnd(dataEdit.DataEditId, undoSql, err); e != nil {
+		util.Logger.Error("database data edit undo end error", zap.Error(e))
+	}
+	history := &SqlHistoryModel{
+		ToolboxId:   request.ToolboxId,
+		OwnerName:   dataEdit.OwnerName,
+		ExecuteType: "dataEditUndo",
+		ExecuteSql:  undoSql,
+		UseTime:     util.GetNowMilli() - startTime,
+		RowCount:    int64(len(undo.InsertList) + len(undo.UpdateList) + len(undo.DeleteList)),
+	}
+	if err != nil {
+		history.Error = err.Error()
+	}
+	this_.recordHistory(requestBean, history)
+	if err != nil {
+		return
+	}
+	dataEdit.Status = 2
+	dataEdit.UndoSql = undoSql
+	dataEdit.UndoData = ""
+	res = dataEdit
+	return
+}
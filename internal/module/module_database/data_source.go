@@ -0,0 +1,324 @@
+package module_database
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/team-ide/go-dialect/dialect"
+	"github.com/team-ide/go-dialect/worker"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	// DataSourceTypeParquet Apache Parquet 文件，字段 类型 根据 ColumnModel 推导
+	DataSourceTypeParquet = &worker.DataSourceType{
+		Name:       "parquet",
+		FileSuffix: "parquet",
+		New:        newDataSourceParquet,
+	}
+	// DataSourceTypeJsonLines JSON Lines 文件，每行 一条 JSON 数据
+	DataSourceTypeJsonLines = &worker.DataSourceType{
+		Name:       "jsonl",
+		FileSuffix: "jsonl",
+		New:        newDataSourceJsonLines,
+	}
+)
+
+// getCustomDataSourceType 获取 本 模块 扩展 的 导入 导出 文件 类型，不是 扩展 类型 返回 nil
+func getCustomDataSourceType(dataSourceType string) *worker.DataSourceType {
+	switch strings.ToLower(dataSourceType) {
+	case "parquet":
+		return DataSourceTypeParquet
+	case "jsonl", "jsonlines", "ndjson":
+		return DataSourceTypeJsonLines
+	}
+	return nil
+}
+
+// getDataSourceType 获取 导入 导出 文件 类型，先 查找 扩展 类型
+func getDataSourceType(dataSourceType string) *worker.DataSourceType {
+	if res := getCustomDataSourceType(dataSourceType); res != nil {
+		return res
+	}
+	return worker.GetDataSource(dataSourceType)
+}
+
+// 字段 值 类型，用于 文件 类型 推导 和 导入 时 类型 转换
+const (
+	valueKindString   = "string"
+	valueKindInt      = "int"
+	valueKindFloat    = "float"
+	valueKindDecimal  = "decimal"
+	valueKindBool     = "bool"
+	valueKindDate     = "date"
+	valueKindDatetime = "datetime"
+	valueKindBinary   = "binary"
+)
+
+// columnValueKind 根据 字段 类型 推导 值 类型
+func columnValueKind(column *dialect.ColumnModel) string {
+	dataType := strings.ToLower(strings.TrimSpace(column.ColumnDataType))
+	if index := strings.Index(dataType, "("); index >= 0 {
+		dataType = strings.TrimSpace(dataType[:index])
+	}
+	dataType = strings.TrimSuffix(dataType, " unsigned")
+	switch dataType {
+	case "":
+		return valueKindString
+	case "tinyint", "smallint", "mediumint", "int", "integer", "bigint", "int2", "int4", "int8",
+		"serial", "smallserial", "bigserial", "long", "year":
+		return valueKindInt
+	case "float", "double", "real", "double precision", "float4", "float8", "binary_float", "binary_double":
+		return valueKindFloat
+	case "number", "numeric", "decimal", "dec", "money":
+		// 没有 小数 位 且 长度 不超过 int64 的 按 整数 处理
+		if column.ColumnScale == 0 && column.ColumnPrecision > 0 && column.ColumnPrecision <= 18 {
+			return valueKindInt
+		}
+		return valueKindDecimal
+	case "bit", "bool", "boolean":
+		if column.ColumnLength > 1 {
+			return valueKindBinary
+		}
+		return valueKindBool
+	case "date":
+		return valueKindDate
+	case "datetime", "datetime2", "smalldatetime", "timestamp", "timestamptz", "timestamp with time zone",
+		"timestamp without time zone", "timestamp with local time zone":
+		return valueKindDatetime
+	case "binary", "varbinary", "blob", "tinyblob", "mediumblob", "longblob", "bytea", "raw", "long raw", "image":
+		return valueKindBinary
+	}
+	if strings.HasPrefix(dataType, "timestamp") {
+		return valueKindDatetime
+	}
+	return valueKindString
+}
+
+var timeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02 15:04:05.999999999 -0700 MST",
+	"2006-01-02 15:04:05.999999999Z07:00",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+	"2006/01/02 15:04:05",
+	"2006/01/02",
+	"20060102150405",
+	"20060102",
+}
+
+func valueToString(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case []byte:
+		return string(v)
+	case time.Time:
+		return v.Format("2006-01-02 15:04:05.999999999")
+	case json.Number:
+		return v.String()
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case float32:
+		return strconv.FormatFloat(float64(v), 'f', -1, 32)
+	}
+	return dialect.GetStringValue(value)
+}
+
+func valueToInt64(value interface{}) (res int64, err error) {
+	switch v := value.(type) {
+	case int64:
+		return v, nil
+	case int:
+		return int64(v), nil
+	case int32:
+		return int64(v), nil
+	case int16:
+		return int64(v), nil
+	case int8:
+		return int64(v), nil
+	case uint8:
+		return int64(v), nil
+	case uint16:
+		return int64(v), nil
+	case uint32:
+		return int64(v), nil
+	case uint64:
+		return int64(v), nil
+	case float64:
+		return int64(v), nil
+	case float32:
+		return int64(v), nil
+	case bool:
+		if v {
+			return 1, nil
+		}
+		return 0, nil
+	}
+	str := strings.TrimSpace(valueToString(value))
+	res, err = strconv.ParseInt(str, 10, 64)
+	if err != nil {
+		var f float64
+		f, err = strconv.ParseFloat(str, 64)
+		if err != nil {
+			err = errors.New("值[" + str + "]不能转换为整数")
+			return
+		}
+		res = int64(f)
+	}
+	return
+}
+
+func valueToFloat64(value interface{}) (res float64, err error) {
+	switch v := value.(type) {
+	case float64:
+		return v, nil
+	case float32:
+		return float64(v), nil
+	case int64:
+		return float64(v), nil
+	case int:
+		return float64(v), nil
+	case int32:
+		return float64(v), nil
+	}
+	str := strings.TrimSpace(valueToString(value))
+	res, err = strconv.ParseFloat(str, 64)
+	if err != nil {
+		err = errors.New("值[" + str + "]不能转换为小数")
+		return
+	}
+	return
+}
+
+func valueToBool(value interface{}) (res bool, err error) {
+	switch v := value.(type) {
+	case bool:
+		return v, nil
+	case []byte:
+		// mysql bit(1) 读取 为 字节
+		if len(v) == 1 && v[0] <= 1 {
+			return v[0] == 1, nil
+		}
+	}
+	str := strings.ToLower(strings.TrimSpace(valueToString(value)))
+	switch str {
+	case "1", "true", "t", "yes", "y", "on":
+		return true, nil
+	case "0", "false", "f", "no", "n", "off":
+		return false, nil
+	}
+	err = errors.New("值[" + str + "]不能转换为布尔")
+	return
+}
+
+func valueToTime(value interface{}) (res time.Time, err error) {
+	switch v := value.(type) {
+	case time.Time:
+		return v, nil
+	case int64:
+		return time.UnixMilli(v), nil
+	case json.Number:
+		var n int64
+		if n, err = v.Int64(); err == nil {
+			return time.UnixMilli(n), nil
+		}
+	}
+	str := strings.TrimSpace(valueToString(value))
+	for _, layout := range timeLayouts {
+		res, err = time.ParseInLocation(layout, str, time.Local)
+		if err == nil {
+			return
+		}
+	}
+	err = errors.New("值[" + str + "]不能转换为时间")
+	return
+}
+
+// coerceImportValue 导入 时 将 文件 中 读取 的 值 转换 为 字段 类型 对应 的 值，由 目标 数据库 驱动 参数化 写入
+func coerceImportValue(kind string, value interface{}) (res interface{}, err error) {
+	if value == nil {
+		return
+	}
+	switch v := value.(type) {
+	case map[string]interface{}, []interface{}:
+		// 嵌套 JSON 作为 字符串 写入
+		var bs []byte
+		bs, err = json.Marshal(v)
+		if err != nil {
+			return
+		}
+		value = string(bs)
+	}
+	switch kind {
+	case valueKindInt:
+		res, err = valueToInt64(value)
+	case valueKindFloat:
+		res, err = valueToFloat64(value)
+	case valueKindDecimal:
+		// 保留 原始 精度，由 数据库 转换
+		res = valueToString(value)
+	case valueKindBool:
+		res, err = valueToBool(value)
+	case valueKindDate, valueKindDatetime:
+		if str, ok := value.(string); ok && strings.TrimSpace(str) == "" {
+			return
+		}
+		res, err = valueToTime(value)
+	case valueKindBinary:
+		switch v := value.(type) {
+		case []byte:
+			res = v
+		default:
+			res = []byte(valueToString(v))
+		}
+	default:
+		res = valueToString(value)
+	}
+	return
+}
+
+// matchFileColumns 文件 中 的 字段名 对应 目标 字段，先 精确 匹配，再 忽略 大小写 匹配
+func matchFileColumns(fileColumnNames []string, columnList []*dialect.ColumnModel) (res map[string]*dialect.ColumnModel) {
+	res = map[string]*dialect.ColumnModel{}
+	var columnCache = map[string]*dialect.ColumnModel{}
+	for _, column := range columnList {
+		columnCache[column.ColumnName] = column
+	}
+	for _, name := range fileColumnNames {
+		if column := columnCache[name]; column != nil {
+			res[name] = column
+			continue
+		}
+		for _, column := range columnList {
+			if strings.EqualFold(column.ColumnName, name) {
+				res[name] = column
+				break
+			}
+		}
+	}
+	return
+}
+
+// readDataRow 根据 目标 字段 转换 一行 数据，文件 中 没有 的 字段 不设置
+func readDataRow(row map[string]interface{}, fileColumns map[string]*dialect.ColumnModel, columnKinds map[string]string) (data map[string]interface{}, err error) {
+	data = map[string]interface{}{}
+	for name, value := range row {
+		column := fileColumns[name]
+		if column == nil {
+			continue
+		}
+		var v interface{}
+		v, err = coerceImportValue(columnKinds[column.ColumnName], value)
+		if err != nil {
+			err = errors.New(fmt.Sprint("字段[", column.ColumnName, "]", err.Error()))
+			return
+		}
+		data[column.ColumnName] = v
+	}
+	return
+}
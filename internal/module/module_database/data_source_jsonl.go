@@ -0,0 +1,253 @@
+package module_database
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/team-ide/go-dialect/dialect"
+	"github.com/team-ide/go-dialect/worker"
+	"io"
+	"os"
+	"strings"
+)
+
+func newDataSourceJsonLines(param *worker.DataSourceParam) (res worker.DataSource) {
+	res = &dataSourceJsonLines{
+		DataSourceParam: param,
+	}
+	return
+}
+
+// dataSourceJsonLines JSON Lines 文件，每行 一个 JSON 对象，二进制 使用 base64
+type dataSourceJsonLines struct {
+	*worker.DataSourceParam
+	isStop   bool
+	saveFile *os.File
+	writer   *bufio.Writer
+}
+
+func (this_ *dataSourceJsonLines) Stop() {
+	this_.isStop = true
+}
+
+func (this_ *dataSourceJsonLines) ReadStart() (err error) {
+	return
+}
+
+func (this_ *dataSourceJsonLines) ReadEnd() (err error) {
+	return
+}
+
+func (this_ *dataSourceJsonLines) Read(columnList []*dialect.ColumnModel, onRead func(data *worker.DataSourceData) (err error)) (err error) {
+	defer func() {
+		if e := recover(); e != nil {
+			err = errors.New(fmt.Sprint(e))
+		}
+	}()
+	if this_.Path == "" {
+		err = errors.New("文件地址不能为空")
+		return
+	}
+	f, err := os.Open(this_.Path)
+	if err != nil {
+		return
+	}
+	defer func() { _ = f.Close() }()
+
+	columnKinds := map[string]string{}
+	for _, column := range columnList {
+		columnKinds[column.ColumnName] = columnValueKind(column)
+	}
+	// 每行 字段 可能 不同，按 字段名 缓存 匹配 结果
+	var fileColumns = map[string]*dialect.ColumnModel{}
+	var matched = map[string]bool{}
+
+	buf := bufio.NewReader(f)
+	var lineNo int
+	for {
+		if this_.isStop {
+			return
+		}
+		var line []byte
+		line, err = buf.ReadBytes('\n')
+		if err != nil && err != io.EOF {
+			return
+		}
+		isEnd := err == io.EOF
+		err = nil
+		lineNo++
+		line = bytes.TrimSpace(line)
+		if len(line) > 0 {
+			var row = map[string]interface{}{}
+			decoder := json.NewDecoder(bytes.NewReader(line))
+			decoder.UseNumber()
+			if err = decoder.Decode(&row); err != nil {
+				err = errors.New(fmt.Sprint("第", lineNo, "行不是有效的JSON对象：", err.Error()))
+				return
+			}
+			var names []string
+			for name := range row {
+				if !matched[name] {
+					names = append(names, name)
+					matched[name] = true
+				}
+			}
+			if len(names) > 0 {
+				for name, column := range matchFileColumns(names, columnList) {
+					fileColumns[name] = column
+				}
+			}
+			for name, value := range row {
+				column := fileColumns[name]
+				if column == nil {
+					continue
+				}
+				if str, ok := value.(string); ok && columnKinds[column.ColumnName] == valueKindBinary {
+					if bs, e := base64.StdEncoding.DecodeString(str); e == nil {
+						row[name] = bs
+					}
+				}
+			}
+			var data map[string]interface{}
+			data, err = readDataRow(row, fileColumns, columnKinds)
+			if err != nil {
+				err = errors.New(fmt.Sprint("第", lineNo, "行", err.Error()))
+				return
+			}
+			err = onRead(&worker.DataSourceData{
+				HasData:    true,
+				Data:       data,
+				ColumnList: columnList,
+			})
+			if err != nil {
+				return
+			}
+		}
+		if isEnd {
+			break
+		}
+	}
+	return
+}
+
+func (this_ *dataSourceJsonLines) WriteStart() (err error) {
+	if this_.Path == "" {
+		err = errors.New("文件地址不能为空")
+		return
+	}
+	this_.saveFile, err = os.Create(this_.Path)
+	if err != nil {
+		return
+	}
+	this_.writer = bufio.NewWriterSize(this_.saveFile, 64*1024)
+	return
+}
+
+func (this_ *dataSourceJsonLines) WriteEnd() (err error) {
+	if this_.saveFile == nil {
+		return
+	}
+	err = this_.writer.Flush()
+	if e := this_.saveFile.Close(); err == nil {
+		err = e
+	}
+	this_.saveFile = nil
+	this_.writer = nil
+	return
+}
+
+func (this_ *dataSourceJsonLines) WriteHeader(columnList []*dialect.ColumnModel) (err error) {
+	return
+}
+
+func (this_ *dataSourceJsonLines) Write(data *worker.DataSourceData) (err error) {
+	defer func() {
+		if e := recover(); e != nil {
+			err = errors.New(fmt.Sprint(e))
+		}
+	}()
+	// 表 结构 SQL 不 写入
+	if this_.isStop || !data.HasData || data.Data == nil || data.ColumnList == nil {
+		return
+	}
+	if this_.saveFile == nil {
+		err = this_.WriteStart()
+		if err != nil {
+			return
+		}
+	}
+	// 按 字段 顺序 输出
+	var line bytes.Buffer
+	line.WriteByte('{')
+	for i, column := range data.ColumnList {
+		if i > 0 {
+			line.WriteByte(',')
+		}
+		var bs []byte
+		bs, err = json.Marshal(column.ColumnName)
+		if err != nil {
+			return
+		}
+		line.Write(bs)
+		line.WriteByte(':')
+		bs, err = json.Marshal(jsonLinesValue(columnValueKind(column), data.Data[column.ColumnName]))
+		if err != nil {
+			err = errors.New(fmt.Sprint("字段[", column.ColumnName, "]", err.Error()))
+			return
+		}
+		line.Write(bs)
+	}
+	line.WriteString("}\n")
+	_, err = this_.writer.Write(line.Bytes())
+	if err != nil {
+		return
+	}
+	return
+}
+
+// jsonLinesValue 将 数据库 值 转换 为 JSON 值，不能 转换 的 使用 字符串
+func jsonLinesValue(kind string, value interface{}) interface{} {
+	if value == nil {
+		return nil
+	}
+	switch kind {
+	case valueKindInt:
+		if v, err := valueToInt64(value); err == nil {
+			return v
+		}
+	case valueKindFloat:
+		if v, err := valueToFloat64(value); err == nil {
+			return v
+		}
+	case valueKindDecimal:
+		// 保留 精度，直接 输出 数字 文本
+		str := strings.TrimSpace(valueToString(value))
+		if str != "" && (str[0] == '-' || (str[0] >= '0' && str[0] <= '9')) && json.Valid([]byte(str)) {
+			return json.Number(str)
+		}
+		return str
+	case valueKindBool:
+		if v, err := valueToBool(value); err == nil {
+			return v
+		}
+	case valueKindDate:
+		if v, err := valueToTime(value); err == nil {
+			return v.Format("2006-01-02")
+		}
+	case valueKindDatetime:
+		if v, err := valueToTime(value); err == nil {
+			return v.Format("2006-01-02 15:04:05.999999999")
+		}
+	case valueKindBinary:
+		switch v := value.(type) {
+		case []byte:
+			return base64.StdEncoding.EncodeToString(v)
+		case string:
+			return base64.StdEncoding.EncodeToString([]byte(v))
+		}
+	}
+	return valueToString(value)
+}
@@ -0,0 +1,370 @@
+package module_database
+
+import (
+	"errors"
+	"fmt"
+	"github.com/team-ide/go-dialect/dialect"
+	"github.com/team-ide/go-dialect/worker"
+	"github.com/xitongsys/parquet-go-source/local"
+	"github.com/xitongsys/parquet-go/common"
+	"github.com/xitongsys/parquet-go/parquet"
+	"github.com/xitongsys/parquet-go/reader"
+	"github.com/xitongsys/parquet-go/source"
+	"github.com/xitongsys/parquet-go/types"
+	"github.com/xitongsys/parquet-go/writer"
+	"os"
+	"strings"
+	"time"
+)
+
+// parquet 读取 每批 行数，写入 行组 大小，避免 大表 全部 加载 到 内存
+const (
+	parquetReadBatchSize = 1000
+	parquetRowGroupSize  = 16 * 1024 * 1024
+)
+
+func newDataSourceParquet(param *worker.DataSourceParam) (res worker.DataSource) {
+	res = &dataSourceParquet{
+		DataSourceParam: param,
+	}
+	return
+}
+
+// dataSourceParquet Parquet 文件，只 读写 数据，不 包含 表 结构 SQL
+type dataSourceParquet struct {
+	*worker.DataSourceParam
+	isStop      bool
+	saveFile    source.ParquetFile
+	writer      *writer.CSVWriter
+	columnKinds []string
+}
+
+func (this_ *dataSourceParquet) Stop() {
+	this_.isStop = true
+}
+
+func (this_ *dataSourceParquet) ReadStart() (err error) {
+	return
+}
+
+func (this_ *dataSourceParquet) ReadEnd() (err error) {
+	return
+}
+
+func (this_ *dataSourceParquet) Read(columnList []*dialect.ColumnModel, onRead func(data *worker.DataSourceData) (err error)) (err error) {
+	defer func() {
+		if e := recover(); e != nil {
+			err = errors.New(fmt.Sprint(e))
+		}
+	}()
+	if this_.Path == "" {
+		err = errors.New("文件地址不能为空")
+		return
+	}
+	stat, err := os.Stat(this_.Path)
+	if err != nil {
+		return
+	}
+	// 空表 导出 的 文件 没有 内容
+	if stat.Size() == 0 {
+		return
+	}
+	file, err := local.NewLocalFileReader(this_.Path)
+	if err != nil {
+		return
+	}
+	defer func() { _ = file.Close() }()
+
+	pr, err := reader.NewParquetColumnReader(file, 1)
+	if err != nil {
+		return
+	}
+	defer pr.ReadStop()
+
+	var fileColumnNames []string
+	var fileColumnSchemas []*parquet.SchemaElement
+	for _, pathStr := range pr.SchemaHandler.ValueColumns {
+		index := pr.SchemaHandler.MapIndex[pathStr]
+		// 只 支持 平铺 的 字段，嵌套 字段 忽略
+		if len(common.StrToPath(pathStr)) != 2 {
+			fileColumnNames = append(fileColumnNames, "")
+			fileColumnSchemas = append(fileColumnSchemas, nil)
+			continue
+		}
+		fileColumnNames = append(fileColumnNames, pr.SchemaHandler.GetExName(int(index)))
+		fileColumnSchemas = append(fileColumnSchemas, pr.SchemaHandler.SchemaElements[index])
+	}
+	fileColumns := matchFileColumns(fileColumnNames, columnList)
+	if len(fileColumns) == 0 {
+		err = errors.New("文件[" + this_.Path + "]中没有字段与表字段[" + strings.Join(worker.GetColumnNames(columnList), ",") + "]匹配")
+		return
+	}
+	columnKinds := map[string]string{}
+	for _, column := range columnList {
+		columnKinds[column.ColumnName] = columnValueKind(column)
+	}
+
+	numRows := pr.GetNumRows()
+	for readRows := int64(0); readRows < numRows; {
+		if this_.isStop {
+			return
+		}
+		batchSize := numRows - readRows
+		if batchSize > parquetReadBatchSize {
+			batchSize = parquetReadBatchSize
+		}
+		var rows = make([]map[string]interface{}, batchSize)
+		for i := range rows {
+			rows[i] = map[string]interface{}{}
+		}
+		for columnIndex, name := range fileColumnNames {
+			if fileColumns[name] == nil {
+				pr.SkipRowsByIndex(int64(columnIndex), batchSize)
+				continue
+			}
+			var values []interface{}
+			values, _, _, err = pr.ReadColumnByIndex(int64(columnIndex), batchSize)
+			if err != nil {
+				return
+			}
+			if int64(len(values)) != batchSize {
+				err = errors.New(fmt.Sprint("字段[", name, "]读取数据行数[", len(values), "]与预期[", batchSize, "]不一致"))
+				return
+			}
+			for i, value := range values {
+				rows[i][name] = parquetValueToGo(fileColumnSchemas[columnIndex], value)
+			}
+		}
+		readRows += batchSize
+		for _, row := range rows {
+			var data map[string]interface{}
+			data, err = readDataRow(row, fileColumns, columnKinds)
+			if err != nil {
+				return
+			}
+			err = onRead(&worker.DataSourceData{
+				HasData:    true,
+				Data:       data,
+				ColumnList: columnList,
+			})
+			if err != nil {
+				return
+			}
+		}
+	}
+	return
+}
+
+// parquetValueToGo 将 parquet 原始 值 根据 逻辑 类型 转换 为 Go 值
+func parquetValueToGo(schema *parquet.SchemaElement, value interface{}) interface{} {
+	if value == nil || schema == nil {
+		return value
+	}
+	if schema.IsSetLogicalType() && schema.LogicalType.IsSetTIMESTAMP() {
+		if v, ok := value.(int64); ok {
+			t := schema.LogicalType.TIMESTAMP
+			adjustedToUTC := t.IsAdjustedToUTC
+			switch {
+			case t.Unit.IsSetMICROS():
+				return types.TIMESTAMP_MICROSToTime(v, adjustedToUTC).Local()
+			case t.Unit.IsSetNANOS():
+				return types.TIMESTAMP_NANOSToTime(v, adjustedToUTC).Local()
+			default:
+				return types.TIMESTAMP_MILLISToTime(v, adjustedToUTC).Local()
+			}
+		}
+	}
+	if schema.IsSetConvertedType() {
+		switch schema.GetConvertedType() {
+		case parquet.ConvertedType_DATE:
+			if v, ok := value.(int32); ok {
+				t := time.Unix(int64(v)*24*3600, 0).UTC()
+				return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.Local)
+			}
+		case parquet.ConvertedType_TIMESTAMP_MILLIS:
+			if v, ok := value.(int64); ok {
+				return time.UnixMilli(v)
+			}
+		case parquet.ConvertedType_TIMESTAMP_MICROS:
+			if v, ok := value.(int64); ok {
+				return time.UnixMicro(v)
+			}
+		case parquet.ConvertedType_DECIMAL:
+			precision, scale := int(schema.GetPrecision()), int(schema.GetScale())
+			switch v := value.(type) {
+			case int32:
+				return types.DECIMAL_INT_ToString(int64(v), precision, scale)
+			case int64:
+				return types.DECIMAL_INT_ToString(v, precision, scale)
+			case string:
+				return types.DECIMAL_BYTE_ARRAY_ToString([]byte(v), precision, scale)
+			}
+		}
+	}
+	switch schema.GetType() {
+	case parquet.Type_INT96:
+		if v, ok := value.(string); ok {
+			return types.INT96ToTime(v).Local()
+		}
+	case parquet.Type_BYTE_ARRAY, parquet.Type_FIXED_LEN_BYTE_ARRAY:
+		// 没有 UTF8 标记 的 为 二进制
+		if v, ok := value.(string); ok && (!schema.IsSetConvertedType() || schema.GetConvertedType() != parquet.ConvertedType_UTF8) {
+			return []byte(v)
+		}
+	}
+	return value
+}
+
+func (this_ *dataSourceParquet) WriteStart() (err error) {
+	if this_.Path == "" {
+		err = errors.New("文件地址不能为空")
+		return
+	}
+	// 字段 在 写入 表头 时 确定，这里 只 创建 文件
+	this_.saveFile, err = local.NewLocalFileWriter(this_.Path)
+	if err != nil {
+		return
+	}
+	return
+}
+
+func (this_ *dataSourceParquet) WriteEnd() (err error) {
+	if this_.writer != nil {
+		err = this_.writer.WriteStop()
+		this_.writer = nil
+		if err != nil {
+			_ = this_.saveFile.Close()
+			return
+		}
+	}
+	if this_.saveFile != nil {
+		err = this_.saveFile.Close()
+		this_.saveFile = nil
+	}
+	return
+}
+
+// WriteHeader 根据 字段 类型 创建 parquet 结构，每批 数据 都会 调用，只 处理 第一次
+func (this_ *dataSourceParquet) WriteHeader(columnList []*dialect.ColumnModel) (err error) {
+	if this_.writer != nil {
+		return
+	}
+	if this_.saveFile == nil {
+		err = this_.WriteStart()
+		if err != nil {
+			return
+		}
+	}
+	var mds []string
+	this_.columnKinds = []string{}
+	for _, column := range columnList {
+		if strings.ContainsAny(column.ColumnName, ",=") {
+			err = errors.New("字段名[" + column.ColumnName + "]包含逗号或等号，不支持导出为Parquet")
+			return
+		}
+		kind := columnValueKind(column)
+		md := "name=" + column.ColumnName + ", repetitiontype=OPTIONAL, "
+		switch kind {
+		case valueKindInt:
+			md += "type=INT64"
+		case valueKindFloat:
+			md += "type=DOUBLE"
+		case valueKindDecimal:
+			// 精度 在 double 范围 内 的 使用 double，否则 使用 字符串 保留 精度
+			if column.ColumnPrecision > 0 && column.ColumnPrecision <= 15 {
+				md += "type=DOUBLE"
+				kind = valueKindFloat
+			} else {
+				md += "type=BYTE_ARRAY, convertedtype=UTF8"
+				kind = valueKindString
+			}
+		case valueKindBool:
+			md += "type=BOOLEAN"
+		case valueKindDate:
+			md += "type=INT32, convertedtype=DATE"
+		case valueKindDatetime:
+			md += "type=INT64, convertedtype=TIMESTAMP_MILLIS"
+		case valueKindBinary:
+			md += "type=BYTE_ARRAY"
+		default:
+			md += "type=BYTE_ARRAY, convertedtype=UTF8"
+		}
+		mds = append(mds, md)
+		this_.columnKinds = append(this_.columnKinds, kind)
+	}
+	this_.writer, err = writer.NewCSVWriter(mds, this_.saveFile, 1)
+	if err != nil {
+		return
+	}
+	this_.writer.RowGroupSize = parquetRowGroupSize
+	this_.writer.CompressionType = parquet.CompressionCodec_SNAPPY
+	return
+}
+
+func (this_ *dataSourceParquet) Write(data *worker.DataSourceData) (err error) {
+	defer func() {
+		if e := recover(); e != nil {
+			err = errors.New(fmt.Sprint(e))
+		}
+	}()
+	// 表 结构 SQL 不 写入
+	if this_.isStop || !data.HasData || data.Data == nil || data.ColumnList == nil {
+		return
+	}
+	if this_.writer == nil {
+		err = this_.WriteHeader(data.ColumnList)
+		if err != nil {
+			return
+		}
+	}
+	var rec = make([]interface{}, len(this_.columnKinds))
+	for i, column := range data.ColumnList {
+		if i >= len(rec) {
+			break
+		}
+		value := data.Data[column.ColumnName]
+		if value == nil {
+			continue
+		}
+		rec[i], err = parquetValue(this_.columnKinds[i], value)
+		if err != nil {
+			err = errors.New(fmt.Sprint("字段[", column.ColumnName, "]", err.Error()))
+			return
+		}
+	}
+	err = this_.writer.Write(rec)
+	if err != nil {
+		return
+	}
+	return
+}
+
+// parquetValue 将 数据库 值 转换 为 parquet 写入 的 值
+func parquetValue(kind string, value interface{}) (res interface{}, err error) {
+	switch kind {
+	case valueKindInt:
+		res, err = valueToInt64(value)
+	case valueKindFloat:
+		res, err = valueToFloat64(value)
+	case valueKindBool:
+		res, err = valueToBool(value)
+	case valueKindDate:
+		var t time.Time
+		t, err = valueToTime(value)
+		if err != nil {
+			return
+		}
+		// 日期 按 本地 年月日 计算 天数
+		res = int32(time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC).Unix() / (24 * 3600))
+	case valueKindDatetime:
+		var t time.Time
+		t, err = valueToTime(value)
+		if err != nil {
+			return
+		}
+		res = t.UnixMilli()
+	default:
+		res = valueToString(value)
+	}
+	return
+}
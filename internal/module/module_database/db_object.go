@@ -0,0 +1,836 @@
+package module_database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/team-ide/go-dialect/dialect"
+	"github.com/team-ide/go-tool/db"
+	"github.com/team-ide/go-tool/util"
+	"go.uber.org/zap"
+	"regexp"
+	"strings"
+	"teamide/pkg/base"
+)
+
+// 数据库 对象 类型
+const (
+	ObjectTypeView      = "view"
+	ObjectTypeProcedure = "procedure"
+	ObjectTypeFunction  = "function"
+	ObjectTypeTrigger   = "trigger"
+	ObjectTypeSequence  = "sequence"
+)
+
+var objectTypeTexts = map[string]string{
+	ObjectTypeView:      "视图",
+	ObjectTypeProcedure: "存储过程",
+	ObjectTypeFunction:  "函数",
+	ObjectTypeTrigger:   "触发器",
+	ObjectTypeSequence:  "序列",
+}
+
+type DbObjectRequest struct {
+	ToolboxId int64  `json:"toolboxId,omitempty"`
+	OwnerName string `json:"ownerName,omitempty"`
+	Name      string `json:"name,omitempty"`
+	TableName string `json:"tableName,omitempty"` // 触发器 所在 表，PostgreSQL 删除 触发器 需要
+	Signature string `json:"signature,omitempty"` // PostgreSQL 函数 参数 签名，区分 重载 的 函数
+	Ddl       string `json:"ddl,omitempty"`
+	Replace   bool   `json:"replace,omitempty"` // MySQL 修改 存储过程、函数、触发器 时 先 删除 再 创建
+
+	Params []*DbObjectParam `json:"params,omitempty"`
+	// 生产 保护 确认，需要 输入 工具 名称
+	ConfirmToken string `json:"confirmToken,omitempty"`
+}
+
+// DbObjectParam 存储过程、函数 参数
+type DbObjectParam struct {
+	Name     string      `json:"name"`
+	Mode     string      `json:"mode"` // IN、OUT、INOUT
+	DataType string      `json:"dataType,omitempty"`
+	Value    interface{} `json:"value,omitempty"`
+}
+
+// dbObjectSqls 各 数据库 对象 的 查询 SQL
+type dbObjectSqls struct {
+	// 对象 列表，参数 为 库名，返回 字段 name 为 对象 名称
+	list map[string][]string
+	// 存储过程、函数 参数，参数 为 库名、对象 名称、对象 类型
+	params []string
+	ddl    func(ctx context.Context, service db.IService, request *DbObjectRequest, objectType string) (ddl string, err error)
+	drop   func(ctx context.Context, service db.IService, param *dialect.ParamModel, request *DbObjectRequest, objectType string) (dropSql string, err error)
+	call   func(ctx context.Context, service db.IService, param *dialect.ParamModel, request *DbObjectRequest, objectType string) (res map[string]interface{}, err error)
+}
+
+var mysqlDbObjectSqls = &dbObjectSqls{
+	list: map[string][]string{
+		ObjectTypeView: {
+			`SELECT TABLE_NAME AS name, DEFINER AS definer, CHECK_OPTION AS checkOption, IS_UPDATABLE AS isUpdatable FROM information_schema.VIEWS WHERE TABLE_SCHEMA = ? ORDER BY TABLE_NAME`,
+		},
+		ObjectTypeProcedure: {
+			`SELECT ROUTINE_NAME AS name, DEFINER AS definer, CREATED AS createTime, LAST_ALTERED AS updateTime, ROUTINE_COMMENT AS comment FROM information_schema.ROUTINES WHERE ROUTINE_SCHEMA = ? AND ROUTINE_TYPE = 'PROCEDURE' ORDER BY ROUTINE_NAME`,
+		},
+		ObjectTypeFunction: {
+			`SELECT ROUTINE_NAME AS name, DTD_IDENTIFIER AS returnType, DEFINER AS definer, CREATED AS createTime, LAST_ALTERED AS updateTime, ROUTINE_COMMENT AS comment FROM information_schema.ROUTINES WHERE ROUTINE_SCHEMA = ? AND ROUTINE_TYPE = 'FUNCTION' ORDER BY ROUTINE_NAME`,
+		},
+		ObjectTypeTrigger: {
+			`SELECT TRIGGER_NAME AS name, EVENT_OBJECT_TABLE AS tableName, ACTION_TIMING AS timing, EVENT_MANIPULATION AS event, CREATED AS createTime FROM information_schema.TRIGGERS WHERE TRIGGER_SCHEMA = ? ORDER BY TRIGGER_NAME`,
+		},
+	},
+	params: []string{
+		`SELECT PARAMETER_NAME AS name, PARAMETER_MODE AS mode, DTD_IDENTIFIER AS dataType FROM information_schema.PARAMETERS WHERE SPECIFIC_SCHEMA = ? AND SPECIFIC_NAME = ? AND ROUTINE_TYPE = UPPER(?) AND ORDINAL_POSITION > 0 ORDER BY ORDINAL_POSITION`,
+	},
+	ddl: func(ctx context.Context, service db.IService, request *DbObjectRequest, objectType string) (ddl string, err error) {
+		var column string
+		switch objectType {
+		case ObjectTypeView:
+			column = "Create View"
+		case ObjectTypeProcedure:
+			column = "Create Procedure"
+		case ObjectTypeFunction:
+			column = "Create Function"
+		case ObjectTypeTrigger:
+			column = "SQL Original Statement"
+		}
+		dia := service.GetDialect()
+		showSql := "SHOW CREATE " + strings.ToUpper(objectType) + " " + dia.OwnerTablePack(nil, request.OwnerName, request.Name)
+		list, err := queryDbObject(ctx, service, []string{showSql})
+		if err != nil {
+			return
+		}
+		if len(list) > 0 {
+			ddl = util.GetStringValue(list[0][column])
+		}
+		return
+	},
+	drop: func(ctx context.Context, service db.IService, param *dialect.ParamModel, request *DbObjectRequest, objectType string) (dropSql string, err error) {
+		dia := service.GetDialect()
+		dropSql = "DROP " + strings.ToUpper(objectType) + " IF EXISTS " + dia.OwnerTablePack(param, request.OwnerName, request.Name)
+		return
+	},
+	call: mysqlDbObjectCall,
+}
+
+var postgresqlDbObjectSqls = &dbObjectSqls{
+	list: map[string][]string{
+		ObjectTypeView: {
+			`SELECT c.relname AS name, pg_get_userbyid(c.relowner) AS definer, obj_description(c.oid, 'pg_class') AS comment FROM pg_class c JOIN pg_namespace n ON n.oid = c.relnamespace WHERE n.nspname = $1 AND c.relkind IN ('v', 'm') ORDER BY c.relname`,
+		},
+		ObjectTypeProcedure: {
+			// PostgreSQL 11 及 以上 使用 prokind 区分 存储过程
+			`SELECT p.proname AS name, pg_get_function_identity_arguments(p.oid) AS signature, obj_description(p.oid, 'pg_proc') AS comment FROM pg_proc p JOIN pg_namespace n ON n.oid = p.pronamespace WHERE n.nspname = $1 AND p.prokind = 'p' ORDER BY p.proname`,
+		},
+		ObjectTypeFunction: {
+			`SELECT p.proname AS name, pg_get_function_identity_arguments(p.oid) AS signature, pg_get_function_result(p.oid) AS returnType, obj_description(p.oid, 'pg_proc') AS comment FROM pg_proc p JOIN pg_namespace n ON n.oid = p.pronamespace WHERE n.nspname = $1 AND p.prokind = 'f' ORDER BY p.proname`,
+			`SELECT p.proname AS name, pg_get_function_identity_arguments(p.oid) AS signature, pg_get_function_result(p.oid) AS returnType, obj_description(p.oid, 'pg_proc') AS comment FROM pg_proc p JOIN pg_namespace n ON n.oid = p.pronamespace WHERE n.nspname = $1 AND NOT p.proisagg ORDER BY p.proname`,
+		},
+		ObjectTypeTrigger: {
+			`SELECT t.tgname AS name, c.relname AS tableName, CASE t.tgenabled WHEN 'D' THEN 'DISABLED' ELSE 'ENABLED' END AS status FROM pg_trigger t JOIN pg_class c ON c.oid = t.tgrelid JOIN pg_namespace n ON n.oid = c.relnamespace WHERE n.nspname = $1 AND NOT t.tgisinternal ORDER BY t.tgname`,
+		},
+		ObjectTypeSequence: {
+			`SELECT sequence_name AS name, data_type AS dataType, start_value AS startValue, minimum_value AS minValue, maximum_value AS maxValue, increment AS increment, cycle_option AS cycle FROM information_schema.sequences WHERE sequence_schema = $1 ORDER BY sequence_name`,
+		},
+	},
+	params: []string{
+		`SELECT p.parameter_name AS name, p.parameter_mode AS mode, p.data_type AS dataType, p.specific_name AS specificName FROM information_schema.parameters p JOIN information_schema.routines r ON r.specific_schema = p.specific_schema AND r.specific_name = p.specific_name WHERE r.routine_schema = $1 AND r.routine_name = $2 AND LOWER(r.routine_type) = $3 ORDER BY p.specific_name, p.ordinal_position`,
+	},
+	ddl: func(ctx context.Context, service db.IService, request *DbObjectRequest, objectType string) (ddl string, err error) {
+		dia := service.GetDialect()
+		name := dia.OwnerTablePack(nil, request.OwnerName, request.Name)
+		var list []map[string]interface{}
+		switch objectType {
+		case ObjectTypeView:
+			list, err = queryDbObject(ctx, service, []string{
+				`SELECT c.relkind AS kind, pg_get_viewdef(c.oid, true) AS ddl FROM pg_class c JOIN pg_namespace n ON n.oid = c.relnamespace WHERE n.nspname = $1 AND c.relname = $2`,
+			}, request.OwnerName, request.Name)
+			if err != nil || len(list) == 0 {
+				return
+			}
+			if util.GetStringValue(list[0]["kind"]) == "m" {
+				ddl = "CREATE MATERIALIZED VIEW " + name + " AS\n" + util.GetStringValue(list[0]["ddl"])
+			} else {
+				ddl = "CREATE OR REPLACE VIEW " + name + " AS\n" + util.GetStringValue(list[0]["ddl"])
+			}
+		case ObjectTypeProcedure, ObjectTypeFunction:
+			list, err = queryDbObject(ctx, service, []string{
+				`SELECT pg_get_function_identity_arguments(p.oid) AS signature, pg_get_functiondef(p.oid) AS ddl FROM pg_proc p JOIN pg_namespace n ON n.oid = p.pronamespace WHERE n.nspname = $1 AND p.proname = $2`,
+			}, request.OwnerName, request.Name)
+			if err != nil {
+				return
+			}
+			// 重载 的 函数 没有 指定 签名 时 返回 全部
+			var ddlList []string
+			for _, one := range list {
+				if request.Signature != "" && util.GetStringValue(one["signature"]) != request.Signature {
+					continue
+				}
+				ddlList = append(ddlList, strings.TrimSpace(util.GetStringValue(one["ddl"])))
+			}
+			ddl = strings.Join(ddlList, ";\n\n")
+		case ObjectTypeTrigger:
+			list, err = queryDbObject(ctx, service, []string{
+				`SELECT pg_get_triggerdef(t.oid, true) AS ddl FROM pg_trigger t JOIN pg_class c ON c.oid = t.tgrelid JOIN pg_namespace n ON n.oid = c.relnamespace WHERE n.nspname = $1 AND t.tgname = $2 AND NOT t.tgisinternal`,
+			}, request.OwnerName, request.Name)
+			if err != nil || len(list) == 0 {
+				return
+			}
+			ddl = util.GetStringValue(list[0]["ddl"])
+		case ObjectTypeSequence:
+			list, err = queryDbObject(ctx, service, []string{
+				`SELECT start_value AS startValue, minimum_value AS minValue, maximum_value AS maxValue, increment AS increment, cycle_option AS cycle FROM information_schema.sequences WHERE sequence_schema = $1 AND sequence_name = $2`,
+			}, request.OwnerName, request.Name)
+			if err != nil || len(list) == 0 {
+				return
+			}
+			ddl = sequenceDdl(name, list[0], "NO CYCLE")
+		}
+		return
+	},
+	drop: func(ctx context.Context, service db.IService, param *dialect.ParamModel, request *DbObjectRequest, objectType string) (dropSql string, err error) {
+		dia := service.GetDialect()
+		name := dia.OwnerTablePack(param, request.OwnerName, request.Name)
+		switch objectType {
+		case ObjectTypeProcedure, ObjectTypeFunction:
+			dropSql = "DROP " + strings.ToUpper(objectType) + " " + name
+			if request.Signature != "" {
+				// 签名 不 直接 拼接，根据 签名 查询 函数 的 参数 类型
+				var list []map[string]interface{}
+				list, err = queryDbObject(ctx, service, []string{
+					`SELECT oidvectortypes(p.proargtypes) AS argTypes FROM pg_proc p JOIN pg_namespace n ON n.oid = p.pronamespace WHERE n.nspname = $1 AND p.proname = $2 AND pg_get_function_identity_arguments(p.oid) = $3`,
+				}, request.OwnerName, request.Name, request.Signature)
+				if err != nil {
+					return
+				}
+				if len(list) == 0 {
+					err = errors.New(objectTypeTexts[objectType] + "[" + request.Name + "(" + request.Signature + ")]不存在")
+					return
+				}
+				dropSql += "(" + util.GetStringValue(list[0]["argTypes"]) + ")"
+			}
+		case ObjectTypeTrigger:
+			if request.TableName == "" {
+				err = errors.New("删除触发器需要指定表名")
+				return
+			}
+			dropSql = "DROP TRIGGER " + dia.ColumnNamePack(param, request.Name) + " ON " + dia.OwnerTablePack(param, request.OwnerName, request.TableName)
+		default:
+			dropSql = "DROP " + strings.ToUpper(objectType) + " " + name
+		}
+		return
+	},
+	call: postgresqlDbObjectCall,
+}
+
+var oracleDbObjectSqls = &dbObjectSqls{
+	list: map[string][]string{
+		ObjectTypeView: {
+			`SELECT VIEW_NAME AS "name" FROM ALL_VIEWS WHERE OWNER = :1 ORDER BY VIEW_NAME`,
+		},
+		ObjectTypeProcedure: {
+			`SELECT OBJECT_NAME AS "name", STATUS AS "status", CREATED AS "createTime", LAST_DDL_TIME AS "updateTime" FROM ALL_OBJECTS WHERE OWNER = :1 AND OBJECT_TYPE = 'PROCEDURE' ORDER BY OBJECT_NAME`,
+		},
+		ObjectTypeFunction: {
+			`SELECT OBJECT_NAME AS "name", STATUS AS "status", CREATED AS "createTime", LAST_DDL_TIME AS "updateTime" FROM ALL_OBJECTS WHERE OWNER = :1 AND OBJECT_TYPE = 'FUNCTION' ORDER BY OBJECT_NAME`,
+		},
+		ObjectTypeTrigger: {
+			`SELECT TRIGGER_NAME AS "name", TABLE_NAME AS "tableName", TRIGGER_TYPE AS "timing", TRIGGERING_EVENT AS "event", STATUS AS "status" FROM ALL_TRIGGERS WHERE OWNER = :1 ORDER BY TRIGGER_NAME`,
+		},
+		ObjectTypeSequence: {
+			`SELECT SEQUENCE_NAME AS "name", MIN_VALUE AS "minValue", MAX_VALUE AS "maxValue", INCREMENT_BY AS "increment", CYCLE_FLAG AS "cycle", LAST_NUMBER AS "lastNumber" FROM ALL_SEQUENCES WHERE SEQUENCE_OWNER = :1 ORDER BY SEQUENCE_NAME`,
+		},
+	},
+	params: []string{
+		`SELECT ARGUMENT_NAME AS "name", IN_OUT AS "mode", DATA_TYPE AS "dataType" FROM ALL_ARGUMENTS WHERE OWNER = :1 AND OBJECT_NAME = :2 AND PACKAGE_NAME IS NULL AND DATA_LEVEL = 0 AND ARGUMENT_NAME IS NOT NULL AND OBJECT_ID IN (SELECT OBJECT_ID FROM ALL_OBJECTS WHERE OBJECT_TYPE = UPPER(:3)) ORDER BY POSITION`,
+	},
+	ddl: func(ctx context.Context, service db.IService, request *DbObjectRequest, objectType string) (ddl string, err error) {
+		list, err := queryDbObject(ctx, service, []string{
+			`SELECT DBMS_METADATA.GET_DDL(:1, :2, :3) AS "ddl" FROM DUAL`,
+		}, strings.ToUpper(objectType), request.Name, request.OwnerName)
+		if err == nil && len(list) > 0 {
+			ddl = strings.TrimSpace(util.GetStringValue(list[0]["ddl"]))
+			return
+		}
+		// 没有 DBMS_METADATA 权限 时 从 数据 字典 拼接
+		util.Logger.Warn("database object get ddl by dbms_metadata error", zap.Error(err))
+		name := service.GetDialect().OwnerTablePack(nil, request.OwnerName, request.Name)
+		switch objectType {
+		case ObjectTypeView:
+			list, err = queryDbObject(ctx, service, []string{
+				`SELECT TEXT AS "text" FROM ALL_VIEWS WHERE OWNER = :1 AND VIEW_NAME = :2`,
+			}, request.OwnerName, request.Name)
+			if err != nil || len(list) == 0 {
+				return
+			}
+			ddl = "CREATE OR REPLACE VIEW " + name + " AS\n" + util.GetStringValue(list[0]["text"])
+		case ObjectTypeSequence:
+			list, err = queryDbObject(ctx, service, []string{
+				`SELECT LAST_NUMBER AS "startValue", MIN_VALUE AS "minValue", MAX_VALUE AS "maxValue", INCREMENT_BY AS "increment", CYCLE_FLAG AS "cycle" FROM ALL_SEQUENCES WHERE SEQUENCE_OWNER = :1 AND SEQUENCE_NAME = :2`,
+			}, request.OwnerName, request.Name)
+			if err != nil || len(list) == 0 {
+				return
+			}
+			ddl = sequenceDdl(name, list[0], "NOCYCLE")
+		default:
+			list, err = queryDbObject(ctx, service, []string{
+				`SELECT TEXT AS "text" FROM ALL_SOURCE WHERE OWNER = :1 AND NAME = :2 AND TYPE = :3 ORDER BY LINE`,
+			}, request.OwnerName, request.Name, strings.ToUpper(objectType))
+			if err != nil || len(list) == 0 {
+				return
+			}
+			var source string
+			for _, one := range list {
+				source += util.GetStringValue(one["text"])
+			}
+			ddl = "CREATE OR REPLACE " + strings.TrimSpace(source)
+		}
+		return
+	},
+	drop: func(ctx context.Context, service db.IService, param *dialect.ParamModel, request *DbObjectRequest, objectType string) (dropSql string, err error) {
+		dia := service.GetDialect()
+		dropSql = "DROP " + strings.ToUpper(objectType) + " " + dia.OwnerTablePack(param, request.OwnerName, request.Name)
+		return
+	},
+	call: oracleDbObjectCall,
+}
+
+// getDbObjectSqls 获取 数据库 对应 的 对象 SQL，金仓 使用 PostgreSQL 模式，达梦 兼容 Oracle
+func getDbObjectSqls(dia dialect.Dialect) (res *dbObjectSqls, err error) {
+	switch dia.DialectType() {
+	case dialect.TypeMysql:
+		res = mysqlDbObjectSqls
+	case dialect.TypePostgresql, dialect.TypeKingBase, dialect.TypeOpenGauss:
+		res = postgresqlDbObjectSqls
+	case dialect.TypeOracle, dialect.TypeDM:
+		res = oracleDbObjectSqls
+	default:
+		err = errors.New("数据库类型[" + dia.DialectType().Name + "]暂不支持视图、存储过程、函数、触发器、序列管理")
+	}
+	return
+}
+
+// sequenceDdl 根据 序列 信息 拼接 创建 语句
+func sequenceDdl(name string, data map[string]interface{}, noCycle string) string {
+	ddl := "CREATE SEQUENCE " + name
+	ddl += "\n  INCREMENT BY " + util.GetStringValue(data["increment"])
+	ddl += "\n  MINVALUE " + util.GetStringValue(data["minValue"])
+	ddl += "\n  MAXVALUE " + util.GetStringValue(data["maxValue"])
+	ddl += "\n  START WITH " + util.GetStringValue(data["startValue"])
+	switch strings.ToUpper(util.GetStringValue(data["cycle"])) {
+	case "YES", "Y":
+		ddl += "\n  CYCLE"
+	default:
+		ddl += "\n  " + noCycle
+	}
+	return ddl
+}
+
+// queryDbObject 依次 尝试 兼容 不同 版本 的 SQL，返回 第一个 执行 成功 的 结果
+func queryDbObject(ctx context.Context, service db.IService, sqlList []string, args ...interface{}) (dataList []map[string]interface{}, err error) {
+	for _, querySql := range sqlList {
+		rows, e := service.GetDb().QueryContext(ctx, querySql, args...)
+		if e != nil {
+			util.Logger.Warn("database object query error", zap.Any("querySql", querySql), zap.Error(e))
+			err = e
+			continue
+		}
+		_, _, dataList, err = db.RowsToListMap(rows, 0)
+		_ = rows.Close()
+		if err == nil {
+			return
+		}
+	}
+	return
+}
+
+// readResultSets 读取 所有 结果集
+func readResultSets(rows *sql.Rows) (resultSets []map[string]interface{}, err error) {
+	for {
+		var columnList, dataList []map[string]interface{}
+		_, columnList, dataList, err = db.RowsToListMap(rows, 0)
+		if err != nil {
+			return
+		}
+		if len(columnList) > 0 {
+			resultSets = append(resultSets, map[string]interface{}{
+				"columnList": columnList,
+				"dataList":   dataList,
+			})
+		}
+		if !rows.NextResultSet() {
+			break
+		}
+	}
+	err = rows.Err()
+	return
+}
+
+func isOutParam(param *DbObjectParam) bool {
+	mode := strings.ToUpper(strings.ReplaceAll(strings.TrimSpace(param.Mode), " ", ""))
+	return mode == "OUT" || mode == "INOUT" || mode == "IN/OUT"
+}
+
+func isInParam(param *DbObjectParam) bool {
+	mode := strings.ToUpper(strings.ReplaceAll(strings.TrimSpace(param.Mode), " ", ""))
+	return mode == "" || mode == "IN" || mode == "INOUT" || mode == "IN/OUT"
+}
+
+// mysqlDbObjectCall MySQL 输出 参数 使用 会话 变量 接收，需要 在 同一个 连接 中 执行
+func mysqlDbObjectCall(ctx context.Context, service db.IService, param *dialect.ParamModel, request *DbObjectRequest, objectType string) (res map[string]interface{}, err error) {
+	res = map[string]interface{}{}
+	dia := service.GetDialect()
+	name := dia.OwnerTablePack(param, request.OwnerName, request.Name)
+	conn, err := service.GetDb().Conn(ctx)
+	if err != nil {
+		return
+	}
+	defer func() { _ = conn.Close() }()
+
+	if objectType == ObjectTypeFunction {
+		var holders []string
+		var args []interface{}
+		for _, one := range request.Params {
+			holders = append(holders, "?")
+			args = append(args, one.Value)
+		}
+		callSql := "SELECT " + name + "(" + strings.Join(holders, ", ") + ") AS result"
+		res["sql"] = callSql
+		var result interface{}
+		err = conn.QueryRowContext(ctx, callSql, args...).Scan(&result)
+		if err != nil {
+			return
+		}
+		if bs, ok := result.([]byte); ok {
+			result = string(bs)
+		}
+		res["result"] = result
+		return
+	}
+
+	var holders []string
+	var args []interface{}
+	var outVars []string
+	var outNames []string
+	for index, one := range request.Params {
+		if !isOutParam(one) {
+			holders = append(holders, "?")
+			args = append(args, one.Value)
+			continue
+		}
+		variable := fmt.Sprint("@teamide_p", index)
+		holders = append(holders, variable)
+		outVars = append(outVars, variable+" AS "+dia.ColumnNamePack(param, one.Name))
+		outNames = append(outNames, one.Name)
+		var value interface{}
+		if isInParam(one) {
+			value = one.Value
+		}
+		_, err = conn.ExecContext(ctx, "SET "+variable+" = ?", value)
+		if err != nil {
+			return
+		}
+	}
+	callSql := "CALL " + name + "(" + strings.Join(holders, ", ") + ")"
+	res["sql"] = callSql
+	rows, err := conn.QueryContext(ctx, callSql, args...)
+	if err != nil {
+		return
+	}
+	res["resultSets"], err = readResultSets(rows)
+	_ = rows.Close()
+	if err != nil {
+		return
+	}
+	if len(outVars) > 0 {
+		rows, err = conn.QueryContext(ctx, "SELECT "+strings.Join(outVars, ", "))
+		if err != nil {
+			return
+		}
+		var dataList []map[string]interface{}
+		_, _, dataList, err = db.RowsToListMap(rows, 0)
+		_ = rows.Close()
+		if err != nil {
+			return
+		}
+		if len(dataList) > 0 {
+			res["outParams"] = dataList[0]
+		}
+	}
+	return
+}
+
+// postgresqlDataTypeRegexp 参数 类型 转换 拼接 到 SQL 中，只 允许 类型名，如 int、character varying(20)、numeric(10,2)、text[]
+var postgresqlDataTypeRegexp = regexp.MustCompile(`^([A-Za-z_][A-Za-z0-9_]*\.)?[A-Za-z_][A-Za-z0-9_]*( [A-Za-z_][A-Za-z0-9_]*)*(\(\d+(, ?\d+)?\))?(\[\])*$`)
+
+// postgresqlDbObjectCall 存储过程 输出 参数 传 NULL，通过 返回 行 获取；函数 不 传 输出 参数
+func postgresqlDbObjectCall(ctx context.Context, service db.IService, param *dialect.ParamModel, request *DbObjectRequest, objectType string) (res map[string]interface{}, err error) {
+	res = map[string]interface{}{}
+	dia := service.GetDialect()
+	name := dia.OwnerTablePack(param, request.OwnerName, request.Name)
+	var holders []string
+	var args []interface{}
+	for _, one := range request.Params {
+		if !isInParam(one) {
+			if objectType == ObjectTypeProcedure {
+				holders = append(holders, "NULL")
+			}
+			continue
+		}
+		args = append(args, one.Value)
+		holder := fmt.Sprint("$", len(args))
+		if one.DataType != "" {
+			if !postgresqlDataTypeRegexp.MatchString(one.DataType) {
+				err = errors.New("参数[" + one.Name + "]类型[" + one.DataType + "]格式错误")
+				return
+			}
+			holder += "::" + one.DataType
+		}
+		holders = append(holders, holder)
+	}
+	var callSql string
+	if objectType == ObjectTypeProcedure {
+		callSql = "CALL " + name + "(" + strings.Join(holders, ", ") + ")"
+	} else {
+		callSql = "SELECT * FROM " + name + "(" + strings.Join(holders, ", ") + ")"
+	}
+	res["sql"] = callSql
+	rows, err := service.GetDb().QueryContext(ctx, callSql, args...)
+	if err != nil {
+		return
+	}
+	resultSets, err := readResultSets(rows)
+	_ = rows.Close()
+	if err != nil {
+		return
+	}
+	res["resultSets"] = resultSets
+	if objectType == ObjectTypeProcedure && len(resultSets) > 0 {
+		if dataList, _ := resultSets[0]["dataList"].([]map[string]interface{}); len(dataList) > 0 {
+			res["outParams"] = dataList[0]
+		}
+	}
+	return
+}
+
+// oracleDbObjectCall 使用 匿名 块 调用，输出 参数 使用 sql.Out 绑定
+func oracleDbObjectCall(ctx context.Context, service db.IService, param *dialect.ParamModel, request *DbObjectRequest, objectType string) (res map[string]interface{}, err error) {
+	res = map[string]interface{}{}
+	dia := service.GetDialect()
+	name := dia.OwnerTablePack(param, request.OwnerName, request.Name)
+	var holders []string
+	var args []interface{}
+	var outValues = map[string]*string{}
+
+	var result string
+	if objectType == ObjectTypeFunction {
+		args = append(args, sql.Out{Dest: &result})
+	}
+	for _, one := range request.Params {
+		if isOutParam(one) {
+			value := new(string)
+			if isInParam(one) && one.Value != nil {
+				*value = util.GetStringValue(one.Value)
+			}
+			outValues[one.Name] = value
+			args = append(args, sql.Out{Dest: value, In: isInParam(one)})
+		} else {
+			args = append(args, one.Value)
+		}
+		holders = append(holders, fmt.Sprint(":", len(args)))
+	}
+	var callSql string
+	if objectType == ObjectTypeFunction {
+		callSql = "BEGIN :1 := " + name + "(" + strings.Join(holders, ", ") + "); END;"
+	} else {
+		callSql = "BEGIN " + name + "(" + strings.Join(holders, ", ") + "); END;"
+	}
+	res["sql"] = callSql
+	_, err = service.GetDb().ExecContext(ctx, callSql, args...)
+	if err != nil {
+		return
+	}
+	if objectType == ObjectTypeFunction {
+		res["result"] = result
+	}
+	if len(outValues) > 0 {
+		outParams := map[string]interface{}{}
+		for paramName, value := range outValues {
+			outParams[paramName] = *value
+		}
+		res["outParams"] = outParams
+	}
+	return
+}
+
+var mysqlDelimiterRegexp = regexp.MustCompile(`(?im)^\s*DELIMITER\s+(\S+)\s*$`)
+
+// trimMysqlDelimiter 去掉 客户端 使用 的 DELIMITER 语句 和 结尾 的 分隔符，驱动 按 单条 语句 执行
+func trimMysqlDelimiter(ddl string) string {
+	match := mysqlDelimiterRegexp.FindStringSubmatch(ddl)
+	if match == nil {
+		return strings.TrimSpace(ddl)
+	}
+	delimiter := match[1]
+	ddl = mysqlDelimiterRegexp.ReplaceAllString(ddl, "")
+	ddl = strings.TrimSpace(ddl)
+	if delimiter != ";" {
+		ddl = strings.TrimSpace(strings.TrimSuffix(ddl, delimiter))
+	}
+	return ddl
+}
+
+// getDbObjectContext 获取 对象 服务，并 根据 工具 配置 的 语句 超时 创建 上下文
+func (this_ *api) getDbObjectContext(requestBean *base.RequestBean, c *gin.Context) (service db.IService, sqls *dbObjectSqls, request *DbObjectRequest, ctx context.Context, cancel context.CancelFunc, err error) {
+	config, sshConfig, err := this_.getConfig(requestBean, c)
+	if err != nil {
+		return
+	}
+	service, err = getService(config, sshConfig)
+	if err != nil {
+		return
+	}
+	request = &DbObjectRequest{}
+	if !base.RequestJSON(request, c) {
+		err = errors.New("请求参数错误")
+		return
+	}
+	sqls, err = getDbObjectSqls(service.GetDialect())
+	if err != nil {
+		return
+	}
+	timeout, err := this_.getStatementTimeout(requestBean)
+	if err != nil {
+		return
+	}
+	if timeout > 0 {
+		ctx, cancel = context.WithTimeout(context.Background(), timeout)
+	} else {
+		ctx, cancel = context.WithCancel(context.Background())
+	}
+	return
+}
+
+func (this_ *api) dbObjectList(objectType string) func(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	return func(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+		service, sqls, request, ctx, cancel, err := this_.getDbObjectContext(requestBean, c)
+		if err != nil {
+			return
+		}
+		defer cancel()
+		sqlList := sqls.list[objectType]
+		if len(sqlList) == 0 {
+			err = errors.New("当前数据库不支持" + objectTypeTexts[objectType])
+			return
+		}
+		list, err := queryDbObject(ctx, service, sqlList, request.OwnerName)
+		if err != nil {
+			return
+		}
+		res = list
+		return
+	}
+}
+
+// dbObjectDetail 查询 对象 DDL，存储过程、函数 同时 返回 参数
+func (this_ *api) dbObjectDetail(objectType string) func(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	return func(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+		service, sqls, request, ctx, cancel, err := this_.getDbObjectContext(requestBean, c)
+		if err != nil {
+			return
+		}
+		defer cancel()
+		if len(sqls.list[objectType]) == 0 {
+			err = errors.New("当前数据库不支持" + objectTypeTexts[objectType])
+			return
+		}
+		ddl, err := sqls.ddl(ctx, service, request, objectType)
+		if err != nil {
+			return
+		}
+		data := map[string]interface{}{
+			"ddl": ddl,
+		}
+		if objectType == ObjectTypeProcedure || objectType == ObjectTypeFunction {
+			var params []map[string]interface{}
+			params, err = queryDbObject(ctx, service, sqls.params, request.OwnerName, request.Name, objectType)
+			if err != nil {
+				return
+			}
+			// 重载 的 函数 只 返回 第一个 的 参数
+			var specificName interface{}
+			var list []map[string]interface{}
+			for _, one := range params {
+				if specificName != nil && one["specificName"] != specificName {
+					continue
+				}
+				specificName = one["specificName"]
+				list = append(list, one)
+			}
+			data["params"] = list
+		}
+		res = data
+		return
+	}
+}
+
+// dbObjectSave 执行 创建 或 修改 语句
+func (this_ *api) dbObjectSave(objectType string) func(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	return func(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+		service, sqls, request, ctx, cancel, err := this_.getDbObjectContext(requestBean, c)
+		if err != nil {
+			return
+		}
+		defer cancel()
+		if len(sqls.list[objectType]) == 0 {
+			err = errors.New("当前数据库不支持" + objectTypeTexts[objectType])
+			return
+		}
+		ddl := strings.TrimSpace(request.Ddl)
+		if ddl == "" {
+			err = errors.New(objectTypeTexts[objectType] + "定义不能为空")
+			return
+		}
+		// 对象 定义 中 包含 分号，整体 作为 一条 语句 执行
+		var sqlList []string
+		// MySQL 删除 和 创建 分别 自动 提交，创建 失败 时 使用 原 定义 恢复
+		var oldDdl string
+		isMysql := service.GetDialect().DialectType() == dialect.TypeMysql
+		if isMysql {
+			ddl = trimMysqlDelimiter(ddl)
+			if request.Replace && objectType != ObjectTypeView {
+				oldDdl, err = sqls.ddl(ctx, service, request, objectType)
+				if err != nil {
+					if !strings.Contains(err.Error(), "does not exist") {
+						err = errors.New("获取" + objectTypeTexts[objectType] + "原定义失败:" + err.Error())
+						return
+					}
+					oldDdl, err = "", nil
+				}
+				var dropSql string
+				dropSql, err = sqls.drop(ctx, service, nil, request, objectType)
+				if err != nil {
+					return
+				}
+				sqlList = append(sqlList, dropSql)
+			}
+		} else {
+			ddl = strings.TrimSuffix(ddl, "/")
+		}
+		sqlList = append(sqlList, ddl)
+
+		var statements []*GuardStatement
+		for _, one := range sqlList {
+			statements = append(statements, &GuardStatement{Sql: one, Kind: GuardKindDDL})
+		}
+		err = this_.checkProtected(requestBean, c, objectType+"Save", &BaseRequest{ToolboxId: request.ToolboxId, OwnerName: request.OwnerName, ConfirmToken: request.ConfirmToken}, statements)
+		if err != nil {
+			return
+		}
+		for i, one := range sqlList {
+			_, err = service.GetDb().ExecContext(ctx, one)
+			if err != nil {
+				err = errors.New("sql:" + one + ",error:" + err.Error())
+				if i > 0 && oldDdl != "" {
+					if _, e := service.GetDb().ExecContext(ctx, oldDdl); e != nil {
+						err = errors.New(err.Error() + "，恢复原定义失败:" + e.Error() + "，原定义:" + oldDdl)
+					} else {
+						err = errors.New(err.Error() + "，已恢复原定义")
+					}
+				}
+				return
+			}
+		}
+		if objectType == ObjectTypeView {
+			refreshCatalog(request.ToolboxId, request.OwnerName)
+		}
+		res = map[string]interface{}{
+			"sqlList": sqlList,
+		}
+		return
+	}
+}
+
+func (this_ *api) dbObjectDrop(objectType string) func(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	return func(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+		service, sqls, request, ctx, cancel, err := this_.getDbObjectContext(requestBean, c)
+		if err != nil {
+			return
+		}
+		defer cancel()
+		if len(sqls.list[objectType]) == 0 {
+			err = errors.New("当前数据库不支持" + objectTypeTexts[objectType])
+			return
+		}
+		if request.Name == "" {
+			err = errors.New(objectTypeTexts[objectType] + "名称不能为空")
+			return
+		}
+		param := this_.getParam(requestBean, c)
+		dropSql, err := sqls.drop(ctx, service, param.ParamModel, request, objectType)
+		if err != nil {
+			return
+		}
+		err = this_.checkProtected(requestBean, c, objectType+"Drop", &BaseRequest{ToolboxId: request.ToolboxId, OwnerName: request.OwnerName, ConfirmToken: request.ConfirmToken}, []*GuardStatement{
+			{Sql: dropSql, Kind: GuardKindDrop},
+		})
+		if err != nil {
+			return
+		}
+		_, err = service.GetDb().ExecContext(ctx, dropSql)
+		if err != nil {
+			err = errors.New("sql:" + dropSql + ",error:" + err.Error())
+			return
+		}
+		if objectType == ObjectTypeView {
+			refreshCatalog(request.ToolboxId, request.OwnerName)
+		}
+		res = map[string]interface{}{
+			"sql": dropSql,
+		}
+		return
+	}
+}
+
+// dbObjectExecute 执行 存储过程、函数，支持 输入 输出 参数
+func (this_ *api) dbObjectExecute(objectType string) func(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	return func(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+		service, sqls, request, ctx, cancel, err := this_.getDbObjectContext(requestBean, c)
+		if err != nil {
+			return
+		}
+		defer cancel()
+		if request.Name == "" {
+			err = errors.New(objectTypeTexts[objectType] + "名称不能为空")
+			return
+		}
+		param := this_.getParam(requestBean, c)
+		name := service.GetDialect().OwnerTablePack(param.ParamModel, request.OwnerName, request.Name)
+		err = this_.checkProtected(requestBean, c, objectType+"Execute", &BaseRequest{ToolboxId: request.ToolboxId, OwnerName: request.OwnerName, ConfirmToken: request.ConfirmToken}, []*GuardStatement{
+			{Sql: name, Kind: GuardKindCall},
+		})
+		if err != nil {
+			return
+		}
+		startTime := util.GetNowMilli()
+		data, err := sqls.call(ctx, service, param.ParamModel, request, objectType)
+		history := &SqlHistoryModel{
+			ToolboxId:   request.ToolboxId,
+			OwnerName:   request.OwnerName,
+			ExecuteType: objectType + "Execute",
+			ExecuteSql:  util.GetStringValue(data["sql"]),
+			UseTime:     util.GetNowMilli() - startTime,
+		}
+		if err != nil {
+			history.Error = err.Error()
+		}
+		this_.recordHistory(requestBean, history)
+		if err != nil {
+			return
+		}
+		data["useTime"] = history.UseTime
+		res = data
+		return
+	}
+}
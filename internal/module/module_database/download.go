@@ -0,0 +1,114 @@
+package module_database
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/team-ide/go-tool/util"
+	"go.uber.org/zap"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"teamide/pkg/base"
+	"time"
+)
+
+// 生成 的 下载文件 存放 在 临时目录 下 该目录 中
+const downloadDirName = "database-download/"
+
+// 下载文件 保留 时间，超过 后 定时 删除
+const downloadFileKeepTime = 24 * time.Hour
+
+var downloadCleanOnce = &sync.Once{}
+
+// startDownloadClean 定时 删除 过期 的 下载文件
+func startDownloadClean() {
+	downloadCleanOnce.Do(func() {
+		go func() {
+			for {
+				cleanDownloadFiles()
+				time.Sleep(time.Hour)
+			}
+		}()
+	})
+}
+
+func cleanDownloadFiles() {
+	tempDir, err := util.GetTempDir()
+	if err != nil {
+		return
+	}
+	entries, err := os.ReadDir(tempDir + downloadDirName)
+	if err != nil {
+		return
+	}
+	for _, entry := range entries {
+		info, e := entry.Info()
+		if e != nil || time.Since(info.ModTime()) < downloadFileKeepTime {
+			continue
+		}
+		e = os.RemoveAll(tempDir + downloadDirName + entry.Name())
+		if e != nil {
+			util.Logger.Error("clean download file error", zap.Any("name", entry.Name()), zap.Error(e))
+		}
+	}
+}
+
+// saveDownloadFile 保存 下载文件 到 临时目录，返回 下载路径
+func saveDownloadFile(fileName string, content []byte) (downloadPath string, err error) {
+	startDownloadClean()
+	tempDir, err := util.GetTempDir()
+	if err != nil {
+		return
+	}
+	dir := downloadDirName + util.GetUUID() + "/"
+	err = os.MkdirAll(tempDir+dir, 0777)
+	if err != nil {
+		return
+	}
+	downloadPath = dir + formatDownloadFileName(fileName)
+	err = os.WriteFile(tempDir+downloadPath, content, 0666)
+	if err != nil {
+		return
+	}
+	return
+}
+
+// formatDownloadFileName 去除 文件名 中 的 路径 分隔符
+func formatDownloadFileName(fileName string) string {
+	fileName = strings.ReplaceAll(fileName, "/", "_")
+	fileName = strings.ReplaceAll(fileName, "\\", "_")
+	fileName = strings.ReplaceAll(fileName, "..", "_")
+	return fileName
+}
+
+func (this_ *api) download(_ *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+
+	data := map[string]string{}
+	err = c.Bind(&data)
+	if err != nil {
+		return
+	}
+
+	downloadPath := data["downloadPath"]
+	if downloadPath == "" {
+		err = errors.New("downloadPath获取失败")
+		return
+	}
+	// 只允许 下载 下载目录 中 的 文件
+	downloadPath = filepath.ToSlash(filepath.Clean(downloadPath))
+	if !strings.HasPrefix(downloadPath, downloadDirName) || strings.Contains(downloadPath, "..") {
+		err = errors.New("下载路径不合法")
+		return
+	}
+	tempDir, err := util.GetTempDir()
+	if err != nil {
+		return
+	}
+	err = writeDownloadFile(c, tempDir+downloadPath)
+	if err != nil {
+		return
+	}
+	res = base.HttpNotResponse
+	return
+}
@@ -0,0 +1,497 @@
+package module_database
+
+import (
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/team-ide/go-dialect/dialect"
+	"github.com/team-ide/go-tool/db"
+	"github.com/team-ide/go-tool/util"
+	"go.uber.org/zap"
+	"html"
+	"regexp"
+	"sort"
+	"strings"
+	"teamide/pkg/base"
+)
+
+type ErDiagramRequest struct {
+	OwnerName      string   `json:"ownerName,omitempty"`
+	TableNames     []string `json:"tableNames,omitempty"`
+	Format         string   `json:"format,omitempty"`         // mermaid、plantuml、dot
+	DisableInfer   bool     `json:"disableInfer,omitempty"`   // 不根据 xxx_id 命名 推断 关系
+	OnlyKeyColumns bool     `json:"onlyKeyColumns,omitempty"` // 只显示 主键、外键 字段
+}
+
+// ErRelation 表 关系，Inferred 为 根据 字段 命名 推断 的 关系
+type ErRelation struct {
+	TableName      string `json:"tableName"`
+	ColumnName     string `json:"columnName"`
+	RefTableName   string `json:"refTableName"`
+	RefColumnName  string `json:"refColumnName"`
+	ConstraintName string `json:"constraintName,omitempty"`
+	Inferred       bool   `json:"inferred,omitempty"`
+}
+
+type ErDiagramResult struct {
+	Format       string        `json:"format"`
+	FileName     string        `json:"fileName"`
+	Content      string        `json:"content"`
+	DownloadPath string        `json:"downloadPath"`
+	RelationList []*ErRelation `json:"relationList"`
+}
+
+func (this_ *api) erDiagram(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	config, sshConfig, err := this_.getConfig(requestBean, c)
+	if err != nil {
+		return
+	}
+	service, err := getService(config, sshConfig)
+	if err != nil {
+		return
+	}
+
+	var request = &ErDiagramRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	param := this_.getParam(requestBean, c)
+
+	tables, err := loadSchemaTables(service, param, request.OwnerName, request.TableNames)
+	if err != nil {
+		return
+	}
+	relations, e := queryForeignKeys(service, request.OwnerName, tables)
+	if e != nil {
+		// 部分 数据库 没有 权限 查询 约束，忽略 外键
+		util.Logger.Warn("er diagram query foreign keys error", zap.Any("ownerName", request.OwnerName), zap.Error(e))
+	}
+	relations = filterErRelations(tables, relations)
+	if !request.DisableInfer {
+		relations = append(relations, inferErRelations(tables, relations)...)
+	}
+
+	result := &ErDiagramResult{
+		Format:       strings.ToLower(request.Format),
+		RelationList: relations,
+	}
+	builder := &erDiagramBuilder{
+		ErDiagramRequest: request,
+		dia:              service.GetDialect(),
+		tables:           tables,
+		relations:        relations,
+	}
+	var fileSuffix string
+	switch result.Format {
+	case "", "mermaid":
+		result.Format = "mermaid"
+		fileSuffix = ".mmd"
+		result.Content = builder.mermaid()
+	case "plantuml":
+		fileSuffix = ".puml"
+		result.Content = builder.plantUML()
+	case "dot":
+		fileSuffix = ".dot"
+		result.Content = builder.dot()
+	default:
+		err = errors.New("不支持的ER图格式[" + request.Format + "]")
+		return
+	}
+	result.FileName = request.OwnerName + "-er" + fileSuffix
+	if request.OwnerName == "" {
+		result.FileName = "er" + fileSuffix
+	}
+	result.DownloadPath, err = saveDownloadFile(result.FileName, []byte(result.Content))
+	if err != nil {
+		return
+	}
+	res = result
+	return
+}
+
+// queryForeignKeys 查询 声明 的 外键
+func queryForeignKeys(service db.IService, ownerName string, tables []*dialect.TableModel) (relations []*ErRelation, err error) {
+	owner := strings.ReplaceAll(ownerName, "'", "''")
+	var sqlList []string
+	switch service.GetDialect().DialectType() {
+	case dialect.TypeMysql:
+		sqlList = append(sqlList, `SELECT TABLE_NAME, COLUMN_NAME, REFERENCED_TABLE_NAME, REFERENCED_COLUMN_NAME, CONSTRAINT_NAME
+FROM information_schema.KEY_COLUMN_USAGE
+WHERE TABLE_SCHEMA = '`+owner+`' AND REFERENCED_TABLE_NAME IS NOT NULL`)
+	case dialect.TypePostgresql, dialect.TypeKingBase, dialect.TypeOpenGauss:
+		// 复合 外键 按 引用 字段 的 位置 对应，避免 字段 两两 组合
+		sqlList = append(sqlList, `SELECT kcu.table_name, kcu.column_name, rcu.table_name AS referenced_table_name, rcu.column_name AS referenced_column_name, kcu.constraint_name
+FROM information_schema.referential_constraints rc
+JOIN information_schema.key_column_usage kcu ON kcu.constraint_schema = rc.constraint_schema AND kcu.constraint_name = rc.constraint_name
+JOIN information_schema.key_column_usage rcu ON rcu.constraint_schema = rc.unique_constraint_schema AND rcu.constraint_name = rc.unique_constraint_name AND rcu.ordinal_position = kcu.position_in_unique_constraint
+WHERE kcu.table_schema = '`+owner+`'`)
+	case dialect.TypeOracle, dialect.TypeDM, dialect.TypeShenTong:
+		sqlList = append(sqlList, `SELECT a.TABLE_NAME, a.COLUMN_NAME, c_pk.TABLE_NAME REFERENCED_TABLE_NAME, b.COLUMN_NAME REFERENCED_COLUMN_NAME, c.CONSTRAINT_NAME
+FROM ALL_CONS_COLUMNS a
+JOIN ALL_CONSTRAINTS c ON a.OWNER = c.OWNER AND a.CONSTRAINT_NAME = c.CONSTRAINT_NAME
+JOIN ALL_CONSTRAINTS c_pk ON c.R_OWNER = c_pk.OWNER AND c.R_CONSTRAINT_NAME = c_pk.CONSTRAINT_NAME
+JOIN ALL_CONS_COLUMNS b ON b.OWNER = c_pk.OWNER AND b.CONSTRAINT_NAME = c_pk.CONSTRAINT_NAME AND b.POSITION = a.POSITION
+WHERE c.CONSTRAINT_TYPE = 'R' AND a.OWNER = '`+owner+`'`)
+	case dialect.TypeSqlite:
+		for _, table := range tables {
+			sqlList = append(sqlList, `SELECT '`+strings.ReplaceAll(table.TableName, "'", "''")+`' AS TABLE_NAME, "from" AS COLUMN_NAME, "table" AS REFERENCED_TABLE_NAME, "to" AS REFERENCED_COLUMN_NAME, '' AS CONSTRAINT_NAME
+FROM pragma_foreign_key_list('`+strings.ReplaceAll(table.TableName, "'", "''")+`')`)
+		}
+	default:
+		return
+	}
+	for _, sqlInfo := range sqlList {
+		var list []map[string]interface{}
+		list, err = service.QueryMap(sqlInfo, nil)
+		if err != nil {
+			return
+		}
+		for _, data := range list {
+			data = upperKeyMap(data)
+			relations = append(relations, &ErRelation{
+				TableName:      util.GetStringValue(data["TABLE_NAME"]),
+				ColumnName:     util.GetStringValue(data["COLUMN_NAME"]),
+				RefTableName:   util.GetStringValue(data["REFERENCED_TABLE_NAME"]),
+				RefColumnName:  util.GetStringValue(data["REFERENCED_COLUMN_NAME"]),
+				ConstraintName: util.GetStringValue(data["CONSTRAINT_NAME"]),
+			})
+		}
+	}
+	return
+}
+
+// filterErRelations 只保留 两端 表 都在 图 中 的 关系，表名 统一 使用 表 详情 中 的 名称
+func filterErRelations(tables []*dialect.TableModel, relations []*ErRelation) (res []*ErRelation) {
+	tableCache := map[string]*dialect.TableModel{}
+	for _, table := range tables {
+		tableCache[strings.ToLower(table.TableName)] = table
+	}
+	for _, one := range relations {
+		table := tableCache[strings.ToLower(one.TableName)]
+		refTable := tableCache[strings.ToLower(one.RefTableName)]
+		if table == nil || refTable == nil {
+			continue
+		}
+		one.TableName = table.TableName
+		one.RefTableName = refTable.TableName
+		if one.RefColumnName == "" && len(refTable.PrimaryKeys) == 1 {
+			one.RefColumnName = refTable.PrimaryKeys[0]
+		}
+		res = append(res, one)
+	}
+	return
+}
+
+// inferErRelations 根据 字段 命名 推断 关系，如 user_id 关联 user 表 主键
+func inferErRelations(tables []*dialect.TableModel, relations []*ErRelation) (res []*ErRelation) {
+	exists := map[string]bool{}
+	for _, one := range relations {
+		exists[strings.ToLower(one.TableName+"."+one.ColumnName)] = true
+	}
+	tableCache := map[string]*dialect.TableModel{}
+	for _, table := range tables {
+		tableCache[strings.ToLower(table.TableName)] = table
+	}
+	findRefTable := func(name string) *dialect.TableModel {
+		for _, one := range []string{name, name + "s", name + "es"} {
+			if find := tableCache[one]; find != nil {
+				return find
+			}
+		}
+		// 带 前缀 的 表，如 tm_user，多个 匹配 时 不推断
+		var find *dialect.TableModel
+		for key, table := range tableCache {
+			if strings.HasSuffix(key, "_"+name) {
+				if find != nil {
+					return nil
+				}
+				find = table
+			}
+		}
+		return find
+	}
+	for _, table := range tables {
+		for _, column := range table.ColumnList {
+			columnName := strings.ToLower(column.ColumnName)
+			if !strings.HasSuffix(columnName, "_id") || len(columnName) <= 3 {
+				continue
+			}
+			if exists[strings.ToLower(table.TableName+"."+column.ColumnName)] {
+				continue
+			}
+			refTable := findRefTable(strings.TrimSuffix(columnName, "_id"))
+			if refTable == nil || refTable == table {
+				continue
+			}
+			var refColumnName string
+			if len(refTable.PrimaryKeys) == 1 {
+				refColumnName = refTable.PrimaryKeys[0]
+			} else if refColumn := refTable.FindColumnByName("id"); refColumn != nil {
+				refColumnName = refColumn.ColumnName
+			} else {
+				continue
+			}
+			res = append(res, &ErRelation{
+				TableName:     table.TableName,
+				ColumnName:    column.ColumnName,
+				RefTableName:  refTable.TableName,
+				RefColumnName: refColumnName,
+				Inferred:      true,
+			})
+		}
+	}
+	return
+}
+
+type erDiagramBuilder struct {
+	*ErDiagramRequest
+	dia       dialect.Dialect
+	tables    []*dialect.TableModel
+	relations []*ErRelation
+}
+
+func (this_ *erDiagramBuilder) isPrimaryKey(table *dialect.TableModel, column *dialect.ColumnModel) bool {
+	if column.PrimaryKey {
+		return true
+	}
+	for _, one := range table.PrimaryKeys {
+		if strings.EqualFold(one, column.ColumnName) {
+			return true
+		}
+	}
+	return false
+}
+
+func (this_ *erDiagramBuilder) isForeignKey(table *dialect.TableModel, column *dialect.ColumnModel) bool {
+	for _, one := range this_.relations {
+		if one.TableName == table.TableName && strings.EqualFold(one.ColumnName, column.ColumnName) {
+			return true
+		}
+	}
+	return false
+}
+
+// columns 需要 显示 的 字段
+func (this_ *erDiagramBuilder) columns(table *dialect.TableModel) (res []*dialect.ColumnModel) {
+	for _, column := range table.ColumnList {
+		if this_.OnlyKeyColumns && !this_.isPrimaryKey(table, column) && !this_.isForeignKey(table, column) {
+			continue
+		}
+		res = append(res, column)
+	}
+	return
+}
+
+func (this_ *erDiagramBuilder) columnType(column *dialect.ColumnModel) string {
+	columnType, err := this_.dia.ColumnTypePack(column)
+	if err != nil || columnType == "" {
+		columnType = column.ColumnDataType
+	}
+	return columnType
+}
+
+func (this_ *erDiagramBuilder) sortedRelations() []*ErRelation {
+	list := append([]*ErRelation{}, this_.relations...)
+	sort.SliceStable(list, func(i, j int) bool {
+		if list[i].TableName != list[j].TableName {
+			return list[i].TableName < list[j].TableName
+		}
+		return list[i].ColumnName < list[j].ColumnName
+	})
+	return list
+}
+
+// erIds 图 中 使用 的 标识，只 保留 字母、数字、下划线，其它 字符 转为 u+编码，转换 后 重复 时 追加 序号
+type erIds struct {
+	ids  map[string]string
+	used map[string]bool
+}
+
+func newErIds() *erIds {
+	return &erIds{
+		ids:  map[string]string{},
+		used: map[string]bool{},
+	}
+}
+
+func (this_ *erIds) get(name string) string {
+	if id, ok := this_.ids[name]; ok {
+		return id
+	}
+	var b strings.Builder
+	for _, r := range name {
+		if r == '_' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			b.WriteRune(r)
+		} else {
+			b.WriteString(fmt.Sprintf("u%04x", r))
+		}
+	}
+	id := b.String()
+	if id == "" {
+		id = "_"
+	}
+	for i := 1; this_.used[id]; i++ {
+		id = fmt.Sprintf("%s_%d", b.String(), i)
+	}
+	this_.ids[name] = id
+	this_.used[id] = true
+	return id
+}
+
+// escaped 标识 是否 与 名称 不同
+func (this_ *erIds) escaped(name string) bool {
+	return this_.get(name) != name
+}
+
+var erTypeRegexp = regexp.MustCompile(`[^A-Za-z0-9_\-()\[\]]`)
+
+func erQuote(str string) string {
+	str = strings.ReplaceAll(str, "\"", "'")
+	str = strings.ReplaceAll(str, "\r", " ")
+	str = strings.ReplaceAll(str, "\n", " ")
+	return str
+}
+
+// mermaid erDiagram 格式，推断 的 关系 使用 虚线
+func (this_ *erDiagramBuilder) mermaid() string {
+	var b strings.Builder
+	b.WriteString("erDiagram\n")
+	tableIds := newErIds()
+	for _, table := range this_.tables {
+		b.WriteString("    " + tableIds.get(table.TableName) + " {\n")
+		columnIds := newErIds()
+		for _, column := range this_.columns(table) {
+			columnType := erTypeRegexp.ReplaceAllString(this_.columnType(column), "_")
+			b.WriteString("        " + columnType + " " + columnIds.get(column.ColumnName))
+			var keys []string
+			if this_.isPrimaryKey(table, column) {
+				keys = append(keys, "PK")
+			}
+			if this_.isForeignKey(table, column) {
+				keys = append(keys, "FK")
+			}
+			if len(keys) > 0 {
+				b.WriteString(" " + strings.Join(keys, ","))
+			}
+			// 名称 转换 后 在 注释 中 显示 原 名称
+			comment := column.ColumnComment
+			if columnIds.escaped(column.ColumnName) {
+				comment = strings.TrimSpace(column.ColumnName + " " + comment)
+			}
+			if comment != "" {
+				b.WriteString(" \"" + erQuote(comment) + "\"")
+			}
+			b.WriteString("\n")
+		}
+		b.WriteString("    }\n")
+	}
+	for _, one := range this_.sortedRelations() {
+		line := "}o--||"
+		if one.Inferred {
+			line = "}o..||"
+		}
+		b.WriteString("    " + tableIds.get(one.TableName) + " " + line + " " + tableIds.get(one.RefTableName) + " : \"" + erQuote(one.ColumnName) + "\"\n")
+	}
+	return b.String()
+}
+
+// plantUML 实体 格式，主键 在 分隔线 上方
+func (this_ *erDiagramBuilder) plantUML() string {
+	var b strings.Builder
+	b.WriteString("@startuml\n")
+	b.WriteString("hide circle\n")
+	b.WriteString("skinparam linetype ortho\n\n")
+	tableIds := newErIds()
+	alias := func(name string) string {
+		return "e_" + tableIds.get(name)
+	}
+	for _, table := range this_.tables {
+		b.WriteString("entity \"" + erQuote(table.TableName) + "\" as " + alias(table.TableName) + " {\n")
+		columns := this_.columns(table)
+		var hasPrimaryKey bool
+		for _, column := range columns {
+			if this_.isPrimaryKey(table, column) {
+				hasPrimaryKey = true
+				b.WriteString("  * " + this_.plantUMLColumn(table, column) + "\n")
+			}
+		}
+		if hasPrimaryKey {
+			b.WriteString("  --\n")
+		}
+		for _, column := range columns {
+			if this_.isPrimaryKey(table, column) {
+				continue
+			}
+			prefix := "  "
+			if column.ColumnNotNull {
+				prefix = "  * "
+			}
+			b.WriteString(prefix + this_.plantUMLColumn(table, column) + "\n")
+		}
+		b.WriteString("}\n\n")
+	}
+	for _, one := range this_.sortedRelations() {
+		line := "}o--||"
+		if one.Inferred {
+			line = "}o..||"
+		}
+		b.WriteString(alias(one.TableName) + " " + line + " " + alias(one.RefTableName) + " : " + erQuote(one.ColumnName) + "\n")
+	}
+	b.WriteString("@enduml\n")
+	return b.String()
+}
+
+func (this_ *erDiagramBuilder) plantUMLColumn(table *dialect.TableModel, column *dialect.ColumnModel) string {
+	str := erQuote(column.ColumnName) + " : " + erQuote(this_.columnType(column))
+	if this_.isPrimaryKey(table, column) {
+		str += " <<PK>>"
+	}
+	if this_.isForeignKey(table, column) {
+		str += " <<FK>>"
+	}
+	if column.ColumnComment != "" {
+		str += " // " + erQuote(column.ColumnComment)
+	}
+	return str
+}
+
+// dot Graphviz 格式，表 使用 HTML 标签 显示 字段，关系 连接 到 字段
+func (this_ *erDiagramBuilder) dot() string {
+	var b strings.Builder
+	b.WriteString("digraph ER {\n")
+	b.WriteString("  rankdir=LR;\n")
+	b.WriteString("  node [shape=plaintext, fontname=\"Helvetica\"];\n")
+	b.WriteString("  edge [arrowhead=none, arrowtail=crow, dir=both];\n\n")
+	dotId := func(name string) string {
+		return "\"" + strings.ReplaceAll(name, "\"", "\\\"") + "\""
+	}
+	for _, table := range this_.tables {
+		b.WriteString("  " + dotId(table.TableName) + " [label=<<TABLE BORDER=\"0\" CELLBORDER=\"1\" CELLSPACING=\"0\">")
+		title := "<B>" + html.EscapeString(table.TableName) + "</B>"
+		if table.TableComment != "" {
+			title += "<BR/>" + html.EscapeString(table.TableComment)
+		}
+		b.WriteString("<TR><TD BGCOLOR=\"lightgrey\">" + title + "</TD></TR>")
+		for _, column := range this_.columns(table) {
+			text := html.EscapeString(column.ColumnName) + " : " + html.EscapeString(this_.columnType(column))
+			if this_.isPrimaryKey(table, column) {
+				text = "<U>" + text + "</U> PK"
+			}
+			if this_.isForeignKey(table, column) {
+				text += " FK"
+			}
+			b.WriteString(fmt.Sprintf("<TR><TD PORT=\"%s\" ALIGN=\"LEFT\">%s</TD></TR>", html.EscapeString(column.ColumnName), text))
+		}
+		b.WriteString("</TABLE>>];\n")
+	}
+	b.WriteString("\n")
+	for _, one := range this_.sortedRelations() {
+		attr := "label=" + dotId(one.ColumnName)
+		if one.Inferred {
+			attr += ", style=dashed"
+		}
+		b.WriteString("  " + dotId(one.TableName) + ":" + dotId(one.ColumnName) + " -> " + dotId(one.RefTableName) + ":" + dotId(one.RefColumnName) + " [" + attr + "];\n")
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
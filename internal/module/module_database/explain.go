@@ -0,0 +1,397 @@
+package module_database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/team-ide/go-dialect/dialect"
+	"github.com/team-ide/go-tool/db"
+	"github.com/team-ide/go-tool/util"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ExplainNode 执行计划 节点，统一 不同 数据库 的 执行计划 结构
+type ExplainNode struct {
+	Operation  string         `json:"operation,omitempty"`
+	TableName  string         `json:"tableName,omitempty"`
+	AccessType string         `json:"accessType,omitempty"`
+	IndexName  string         `json:"indexName,omitempty"`
+	Cost       float64        `json:"cost,omitempty"`
+	Rows       float64        `json:"rows,omitempty"`
+	FullScan   bool           `json:"fullScan,omitempty"` // 全表扫描
+	Detail     string         `json:"detail,omitempty"`
+	Children   []*ExplainNode `json:"children,omitempty"`
+}
+
+type ExplainResult struct {
+	Sql      string       `json:"sql"`
+	Root     *ExplainNode `json:"root"`
+	FullScan bool         `json:"fullScan"` // 是否 存在 全表扫描
+	Raw      interface{}  `json:"raw"`      // 数据库 返回 的 原始 执行计划
+}
+
+// explainSQL 使用 对应 数据库 的 EXPLAIN 语法 查询 执行计划，每条 SQL 返回 一个 执行计划
+func explainSQL(service db.IService, param *db.Param, ownerName string, sqlContent string) (resList []*ExplainResult, err error) {
+	dia := service.GetDialect()
+	ctx := context.Background()
+	conn, err := newOwnerConn(ctx, service, param.ParamModel, ownerName)
+	if err != nil {
+		return
+	}
+	defer discardConn(conn)
+
+	sqlList := dia.SqlSplit(sqlContent)
+	for _, one := range sqlList {
+		one = strings.TrimSpace(one)
+		if one == "" {
+			continue
+		}
+		res := &ExplainResult{
+			Sql: one,
+		}
+		switch dia.DialectType() {
+		case dialect.TypeMysql:
+			err = explainMysql(ctx, conn, res)
+		case dialect.TypePostgresql, dialect.TypeKingBase, dialect.TypeOpenGauss:
+			err = explainPostgresql(ctx, conn, res)
+		case dialect.TypeOracle, dialect.TypeDM, dialect.TypeShenTong:
+			err = explainPlanTable(ctx, conn, res)
+		case dialect.TypeSqlite:
+			err = explainSqlite(ctx, conn, res)
+		default:
+			err = errors.New("数据库类型[" + dia.DialectType().Name + "]暂不支持执行计划")
+		}
+		if err != nil {
+			err = errors.New("sql [" + one + "] explain error:" + err.Error())
+			return
+		}
+		res.FullScan = hasFullScan(res.Root)
+		resList = append(resList, res)
+	}
+	return
+}
+
+func explainQuery(ctx context.Context, conn *sql.Conn, query string) (dataList []map[string]interface{}, err error) {
+	rows, err := conn.QueryContext(ctx, query)
+	if err != nil {
+		return
+	}
+	defer func() { _ = rows.Close() }()
+	_, _, dataList, err = db.RowsToListMap(rows, 0)
+	return
+}
+
+func hasFullScan(node *ExplainNode) bool {
+	if node == nil {
+		return false
+	}
+	if node.FullScan {
+		return true
+	}
+	for _, one := range node.Children {
+		if hasFullScan(one) {
+			return true
+		}
+	}
+	return false
+}
+
+// explainMysql 使用 EXPLAIN FORMAT=JSON
+func explainMysql(ctx context.Context, conn *sql.Conn, res *ExplainResult) (err error) {
+	dataList, err := explainQuery(ctx, conn, "EXPLAIN FORMAT=JSON "+res.Sql)
+	if err != nil {
+		return
+	}
+	if len(dataList) == 0 {
+		err = errors.New("explain result is empty")
+		return
+	}
+	var text string
+	for _, v := range dataList[0] {
+		text = util.GetStringValue(v)
+	}
+	data := map[string]interface{}{}
+	err = util.JSONDecodeUseNumber([]byte(text), &data)
+	if err != nil {
+		return
+	}
+	res.Raw = data
+	res.Root = mysqlExplainNode("query", data)
+	return
+}
+
+var mysqlExplainIgnoreKeys = map[string]bool{
+	"cost_info":              true,
+	"used_columns":           true,
+	"possible_keys":          true,
+	"used_key_parts":         true,
+	"ref":                    true,
+	"key_length":             true,
+	"filtered":               true,
+	"rows_produced_per_join": true,
+}
+
+func mysqlExplainNode(operation string, data map[string]interface{}) (node *ExplainNode) {
+	node = &ExplainNode{
+		Operation: operation,
+	}
+	if costInfo, ok := data["cost_info"].(map[string]interface{}); ok {
+		if v, find := costInfo["query_cost"]; find {
+			node.Cost = explainFloat(v)
+		} else if v, find = costInfo["prefix_cost"]; find {
+			node.Cost = explainFloat(v)
+		}
+	}
+	if v, ok := data["table_name"]; ok {
+		node.TableName = util.GetStringValue(v)
+		node.AccessType = util.GetStringValue(data["access_type"])
+		node.IndexName = util.GetStringValue(data["key"])
+		node.Rows = explainFloat(data["rows_examined_per_scan"])
+		node.FullScan = strings.EqualFold(node.AccessType, "ALL")
+	}
+	if v, ok := data["message"]; ok {
+		node.Detail = util.GetStringValue(v)
+	} else if v, ok = data["attached_condition"]; ok {
+		node.Detail = util.GetStringValue(v)
+	}
+
+	var keys []string
+	for key := range data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if mysqlExplainIgnoreKeys[key] {
+			continue
+		}
+		switch v := data[key].(type) {
+		case map[string]interface{}:
+			node.Children = append(node.Children, mysqlExplainNode(key, v))
+		case []interface{}:
+			for _, one := range v {
+				if m, ok := one.(map[string]interface{}); ok {
+					node.Children = append(node.Children, mysqlExplainNode(key, m))
+				}
+			}
+		}
+	}
+	// table 节点 合并 到 父 节点，减少 层级
+	if node.TableName == "" && len(node.Children) == 1 && node.Children[0].TableName != "" && node.Children[0].Operation == "table" {
+		child := node.Children[0]
+		child.Operation = operation
+		if child.Cost == 0 {
+			child.Cost = node.Cost
+		}
+		node = child
+	}
+	return
+}
+
+// explainPostgresql 使用 EXPLAIN (FORMAT JSON)
+func explainPostgresql(ctx context.Context, conn *sql.Conn, res *ExplainResult) (err error) {
+	dataList, err := explainQuery(ctx, conn, "EXPLAIN (FORMAT JSON) "+res.Sql)
+	if err != nil {
+		return
+	}
+	var text string
+	for _, data := range dataList {
+		for _, v := range data {
+			text += util.GetStringValue(v)
+		}
+	}
+	var list []map[string]interface{}
+	err = util.JSONDecodeUseNumber([]byte(text), &list)
+	if err != nil {
+		return
+	}
+	res.Raw = list
+	for _, one := range list {
+		plan, ok := one["Plan"].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		res.Root = postgresqlExplainNode(plan)
+		break
+	}
+	if res.Root == nil {
+		err = errors.New("explain result is empty")
+		return
+	}
+	return
+}
+
+func postgresqlExplainNode(plan map[string]interface{}) (node *ExplainNode) {
+	node = &ExplainNode{
+		Operation:  util.GetStringValue(plan["Node Type"]),
+		TableName:  util.GetStringValue(plan["Relation Name"]),
+		AccessType: util.GetStringValue(plan["Node Type"]),
+		IndexName:  util.GetStringValue(plan["Index Name"]),
+		Cost:       explainFloat(plan["Total Cost"]),
+		Rows:       explainFloat(plan["Plan Rows"]),
+	}
+	node.FullScan = node.Operation == "Seq Scan"
+	for _, key := range []string{"Filter", "Index Cond", "Hash Cond", "Join Filter", "Merge Cond"} {
+		if v, ok := plan[key]; ok {
+			node.Detail = key + ": " + util.GetStringValue(v)
+			break
+		}
+	}
+	if plans, ok := plan["Plans"].([]interface{}); ok {
+		for _, one := range plans {
+			if m, ok := one.(map[string]interface{}); ok {
+				node.Children = append(node.Children, postgresqlExplainNode(m))
+			}
+		}
+	}
+	return
+}
+
+// explainPlanTable 使用 EXPLAIN PLAN 写入 PLAN_TABLE 后 查询，Oracle、达梦、神通 使用
+func explainPlanTable(ctx context.Context, conn *sql.Conn, res *ExplainResult) (err error) {
+	statementId := "TEAMIDE_" + strings.ToUpper(util.GetUUID())
+	if len(statementId) > 30 {
+		statementId = statementId[0:30]
+	}
+	_, err = conn.ExecContext(ctx, "EXPLAIN PLAN SET STATEMENT_ID = '"+statementId+"' FOR "+res.Sql)
+	if err != nil {
+		return
+	}
+	defer func() {
+		_, _ = conn.ExecContext(ctx, "DELETE FROM PLAN_TABLE WHERE STATEMENT_ID = '"+statementId+"'")
+	}()
+	dataList, err := explainQuery(ctx, conn, `SELECT ID, PARENT_ID, OPERATION, OPTIONS, OBJECT_NAME, COST, CARDINALITY, ACCESS_PREDICATES, FILTER_PREDICATES
+FROM PLAN_TABLE WHERE STATEMENT_ID = '`+statementId+`' ORDER BY ID`)
+	if err != nil {
+		return
+	}
+	res.Raw = dataList
+	nodeCache := map[string]*ExplainNode{}
+	for _, data := range dataList {
+		data = upperKeyMap(data)
+		operation := util.GetStringValue(data["OPERATION"])
+		options := util.GetStringValue(data["OPTIONS"])
+		node := &ExplainNode{
+			Operation:  strings.TrimSpace(operation + " " + options),
+			AccessType: options,
+			Cost:       explainFloat(data["COST"]),
+			Rows:       explainFloat(data["CARDINALITY"]),
+		}
+		objectName := util.GetStringValue(data["OBJECT_NAME"])
+		if strings.HasPrefix(strings.ToUpper(operation), "INDEX") {
+			node.IndexName = objectName
+		} else {
+			node.TableName = objectName
+		}
+		node.FullScan = strings.EqualFold(operation, "TABLE ACCESS") && strings.EqualFold(options, "FULL")
+		if v := util.GetStringValue(data["ACCESS_PREDICATES"]); v != "" {
+			node.Detail = "access: " + v
+		} else if v = util.GetStringValue(data["FILTER_PREDICATES"]); v != "" {
+			node.Detail = "filter: " + v
+		}
+		id := util.GetStringValue(data["ID"])
+		nodeCache[id] = node
+		parent := nodeCache[util.GetStringValue(data["PARENT_ID"])]
+		if parent == nil {
+			if res.Root == nil {
+				res.Root = node
+			}
+			continue
+		}
+		parent.Children = append(parent.Children, node)
+	}
+	if res.Root == nil {
+		err = errors.New("explain result is empty")
+		return
+	}
+	return
+}
+
+// explainSqlite 使用 EXPLAIN QUERY PLAN
+func explainSqlite(ctx context.Context, conn *sql.Conn, res *ExplainResult) (err error) {
+	dataList, err := explainQuery(ctx, conn, "EXPLAIN QUERY PLAN "+res.Sql)
+	if err != nil {
+		return
+	}
+	res.Raw = dataList
+	res.Root = &ExplainNode{
+		Operation: "QUERY PLAN",
+	}
+	nodeCache := map[string]*ExplainNode{}
+	for _, data := range dataList {
+		detail := util.GetStringValue(data["detail"])
+		node := sqliteExplainNode(detail)
+		id := util.GetStringValue(data["id"])
+		nodeCache[id] = node
+		// 低版本 没有 parent 字段
+		parent := nodeCache[util.GetStringValue(data["parent"])]
+		if data["parent"] == nil || parent == nil {
+			parent = res.Root
+		}
+		parent.Children = append(parent.Children, node)
+	}
+	return
+}
+
+func sqliteExplainNode(detail string) (node *ExplainNode) {
+	node = &ExplainNode{
+		Operation: detail,
+		Detail:    detail,
+	}
+	words := strings.Fields(detail)
+	if len(words) == 0 {
+		return
+	}
+	node.AccessType = words[0]
+	if words[0] != "SCAN" && words[0] != "SEARCH" {
+		return
+	}
+	if len(words) > 1 {
+		node.TableName = words[1]
+		// 低版本 格式 SCAN TABLE t
+		if words[1] == "TABLE" && len(words) > 2 {
+			node.TableName = words[2]
+		}
+	}
+	for i, word := range words {
+		if word == "INDEX" && i+1 < len(words) {
+			node.IndexName = words[i+1]
+			break
+		}
+	}
+	if strings.Contains(detail, "PRIMARY KEY") && node.IndexName == "" {
+		node.IndexName = "PRIMARY KEY"
+	}
+	node.FullScan = words[0] == "SCAN" && node.IndexName == ""
+	return
+}
+
+func upperKeyMap(data map[string]interface{}) (res map[string]interface{}) {
+	res = map[string]interface{}{}
+	for k, v := range data {
+		res[strings.ToUpper(k)] = v
+	}
+	return
+}
+
+func explainFloat(v interface{}) (res float64) {
+	if v == nil {
+		return
+	}
+	switch tV := v.(type) {
+	case float64:
+		return tV
+	case int64:
+		return float64(tV)
+	case int:
+		return float64(tV)
+	case json.Number:
+		res, _ = tV.Float64()
+		return
+	}
+	res, _ = strconv.ParseFloat(strings.TrimSpace(fmt.Sprint(v)), 64)
+	return
+}
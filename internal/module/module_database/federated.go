@@ -0,0 +1,472 @@
+package module_database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/team-ide/go-dialect/dialect"
+	"github.com/team-ide/go-tool/db"
+	"github.com/team-ide/go-tool/util"
+	"go.uber.org/zap"
+	"os"
+	"regexp"
+	"strings"
+	"teamide/pkg/base"
+)
+
+// 联合 查询 每个 表 默认 最多 拉取 行数，超出 需要 添加 过滤 条件
+const federatedDefaultMaxRows = 100000
+
+// 联合 查询 写入 临时 库 的 批量 大小
+const federatedInsertBatch = 500
+
+// FederatedSource 联合 查询 的 数据源，SQL 中 使用 别名.表名 引用 该 工具 的 表，别名 不要 与 SQL 中 的 表 别名 相同
+type FederatedSource struct {
+	Alias     string `json:"alias,omitempty"`
+	ToolboxId int64  `json:"toolboxId,omitempty"`
+	OwnerName string `json:"ownerName,omitempty"`
+}
+
+// FederatedTable 拉取 源 表 时 的 过滤 条件 和 字段，在 源 库 中 执行，条件 值 使用 参数 绑定
+type FederatedTable struct {
+	Alias     string           `json:"alias,omitempty"`
+	TableName string           `json:"tableName,omitempty"`
+	WhereList []*dialect.Where `json:"whereList,omitempty"`
+	Columns   []string         `json:"columns,omitempty"`
+}
+
+type FederatedRequest struct {
+	Sources    []*FederatedSource `json:"sources,omitempty"`
+	Tables     []*FederatedTable  `json:"tables,omitempty"`
+	ExecuteSQL string             `json:"executeSQL,omitempty"`
+	MaxRows    int                `json:"maxRows,omitempty"`
+	PageNo     int                `json:"pageNo,omitempty"`
+	PageSize   int                `json:"pageSize,omitempty"`
+}
+
+// FederatedTableInfo 拉取 的 源 表 信息
+type FederatedTableInfo struct {
+	Alias     string `json:"alias"`
+	TableName string `json:"tableName"`
+	TempName  string `json:"tempName"` // 临时 库 中 的 表名
+	SelectSql string `json:"selectSql"`
+	RowCount  int    `json:"rowCount"`
+	UseTime   int64  `json:"useTime"`
+}
+
+type FederatedResult struct {
+	Sql        string                   `json:"sql"` // 在 临时 库 中 执行 的 SQL
+	Tables     []*FederatedTableInfo    `json:"tables"`
+	Total      int64                    `json:"total"`
+	PageNo     int                      `json:"pageNo"`
+	PageSize   int                      `json:"pageSize"`
+	ColumnList []map[string]interface{} `json:"columnList"`
+	DataList   []map[string]interface{} `json:"dataList"`
+	UseTime    int64                    `json:"useTime"`
+}
+
+// federatedSource 校验 权限 后 的 数据源
+type federatedSource struct {
+	*FederatedSource
+	service db.IService
+	masker  *ColumnMasker
+}
+
+// federatedRef SQL 中 的 表 引用，别名.表名
+type federatedRef struct {
+	source    *federatedSource
+	tableName string
+	tempName  string
+}
+
+func (this_ *api) federatedQuery(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	var request = &FederatedRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	if strings.TrimSpace(request.ExecuteSQL) == "" {
+		err = errors.New("查询SQL不能为空")
+		return
+	}
+	if len(request.Sources) == 0 {
+		err = errors.New("数据源不能为空")
+		return
+	}
+	if request.MaxRows <= 0 {
+		request.MaxRows = federatedDefaultMaxRows
+	}
+	if request.PageNo <= 0 {
+		request.PageNo = 1
+	}
+	if request.PageSize <= 0 {
+		request.PageSize = 50
+	}
+
+	sources := map[string]*federatedSource{}
+	for _, one := range request.Sources {
+		alias := strings.ToLower(strings.TrimSpace(one.Alias))
+		if alias == "" {
+			err = errors.New(fmt.Sprint("工具[", one.ToolboxId, "]别名不能为空"))
+			return
+		}
+		if sources[alias] != nil {
+			err = errors.New("数据源别名[" + one.Alias + "]重复")
+			return
+		}
+		source := &federatedSource{FederatedSource: one}
+		toolbox, service, e := this_.getToolboxService(requestBean, one.ToolboxId)
+		if e != nil {
+			err = e
+			return
+		}
+		source.service = service
+		// 拉取 的 数据 按 源 工具 的 脱敏 规则 脱敏
+		source.masker, err = NewColumnMaskerByToolbox(this_.toolboxService, toolbox)
+		if err != nil {
+			return
+		}
+		sources[alias] = source
+	}
+
+	executeSql, refs, err := federatedRewrite(request.ExecuteSQL, sources)
+	if err != nil {
+		return
+	}
+	if len(refs) == 0 {
+		err = errors.New("SQL中没有引用数据源的表，请使用 别名.表名 引用")
+		return
+	}
+
+	startTime := util.GetNowMilli()
+	ctx := c.Request.Context()
+	tempDir, err := util.GetTempDir()
+	if err != nil {
+		return
+	}
+	tempDir += "federated/"
+	if err = os.MkdirAll(tempDir, 0777); err != nil {
+		return
+	}
+	tempPath := tempDir + util.GetUUID() + ".db"
+	defer func() {
+		_ = os.Remove(tempPath)
+	}()
+	tempService, err := db.New(&db.Config{
+		Type:         "sqlite",
+		DatabasePath: tempPath,
+	})
+	if err != nil {
+		return
+	}
+	defer tempService.Close()
+
+	result := &FederatedResult{
+		Sql:      executeSql,
+		PageNo:   request.PageNo,
+		PageSize: request.PageSize,
+	}
+	for _, ref := range refs {
+		var info *FederatedTableInfo
+		info, err = federatedPull(ctx, tempService.GetDb(), ref, federatedFindTable(request.Tables, ref), request.MaxRows)
+		if err != nil {
+			return
+		}
+		result.Tables = append(result.Tables, info)
+	}
+
+	countRow := tempService.GetDb().QueryRowContext(ctx, "SELECT COUNT(*) FROM ("+executeSql+")")
+	if err = countRow.Scan(&result.Total); err != nil {
+		err = errors.New("sql:" + executeSql + ",error:" + err.Error())
+		return
+	}
+	rows, err := tempService.GetDb().QueryContext(ctx, "SELECT * FROM ("+executeSql+") LIMIT ? OFFSET ?", request.PageSize, (request.PageNo-1)*request.PageSize)
+	if err != nil {
+		err = errors.New("sql:" + executeSql + ",error:" + err.Error())
+		return
+	}
+	_, result.ColumnList, result.DataList, err = db.RowsToListMap(rows, 0)
+	_ = rows.Close()
+	if err != nil {
+		return
+	}
+	result.UseTime = util.GetNowMilli() - startTime
+	res = result
+	return
+}
+
+// federatedNameRegexp 临时 表名 只 保留 字母、数字、下划线
+var federatedNameRegexp = regexp.MustCompile(`[^a-z0-9_]`)
+
+// federatedRewrite 校验 SQL 只 包含 一条 查询 语句，将 别名.表名 替换 为 临时 库 的 表名
+func federatedRewrite(sqlContent string, sources map[string]*federatedSource) (executeSql string, refs []*federatedRef, err error) {
+	sqlContent = strings.TrimSpace(sqlContent)
+	// 在 临时 SQLite 库 中 执行，按 SQLite 规则 解析
+	tokens, err := sqlTokenize(dialect.TypeSqlite, sqlContent)
+	if err != nil {
+		return
+	}
+	code := sqlCodeTokens(tokens)
+	for len(code) > 0 && code[len(code)-1].is(";") {
+		code = code[:len(code)-1]
+	}
+	if len(code) == 0 || !code[0].is("SELECT", "WITH") {
+		err = errors.New("联合查询只支持SELECT语句")
+		return
+	}
+	for _, token := range code {
+		if token.is(";") {
+			err = errors.New("联合查询只支持一条SELECT语句")
+			return
+		}
+	}
+
+	rs := []rune(sqlContent)
+	var buf strings.Builder
+	var last int
+	var refCache = map[string]*federatedRef{}
+	for i := 0; i+2 < len(code); i++ {
+		aliasToken, tableToken := code[i], code[i+2]
+		if !aliasToken.isName() || !code[i+1].is(".") || !tableToken.isName() {
+			continue
+		}
+		// 前面 是 “.” 说明 是 表.字段 中 的 一部分
+		if i > 0 && code[i-1].is(".") {
+			continue
+		}
+		source := sources[strings.ToLower(aliasToken.name())]
+		if source == nil {
+			continue
+		}
+		key := strings.ToLower(source.Alias + "." + tableToken.name())
+		ref := refCache[key]
+		if ref == nil {
+			ref = &federatedRef{
+				source:    source,
+				tableName: tableToken.name(),
+				tempName:  fmt.Sprint("t", len(refs)+1, "_", federatedNameRegexp.ReplaceAllString(strings.ToLower(source.Alias+"_"+tableToken.name()), "_")),
+			}
+			refCache[key] = ref
+			refs = append(refs, ref)
+		}
+		buf.WriteString(string(rs[last:aliasToken.start]))
+		buf.WriteString(`"` + ref.tempName + `"`)
+		last = tableToken.end
+		i += 2
+	}
+	buf.WriteString(string(rs[last:]))
+	executeSql = strings.TrimSuffix(strings.TrimSpace(buf.String()), ";")
+	return
+}
+
+func federatedFindTable(tables []*FederatedTable, ref *federatedRef) *FederatedTable {
+	for _, one := range tables {
+		if strings.EqualFold(one.Alias, ref.source.Alias) && strings.EqualFold(one.TableName, ref.tableName) {
+			return one
+		}
+	}
+	return nil
+}
+
+// federatedPull 在 源 库 中 查询 表 数据，写入 临时 库
+func federatedPull(ctx context.Context, tempDb *sql.DB, ref *federatedRef, table *FederatedTable, maxRows int) (info *FederatedTableInfo, err error) {
+	startTime := util.GetNowMilli()
+	service := ref.source.service
+	dia := service.GetDialect()
+	param := &dialect.ParamModel{}
+
+	if err = checkFederatedName(ref.tableName); err != nil {
+		return
+	}
+	if ref.source.OwnerName != "" {
+		if err = checkFederatedName(ref.source.OwnerName); err != nil {
+			return
+		}
+	}
+	var columnList []*dialect.ColumnModel
+	var whereList []*dialect.Where
+	if table != nil {
+		for _, columnName := range table.Columns {
+			if err = checkFederatedName(columnName); err != nil {
+				err = errors.New("表[" + ref.tableName + "]" + err.Error())
+				return
+			}
+			columnList = append(columnList, &dialect.ColumnModel{ColumnName: columnName})
+		}
+		whereList, err = checkFederatedWhere(table.WhereList)
+		if err != nil {
+			err = errors.New("表[" + ref.tableName + "]" + err.Error())
+			return
+		}
+	}
+	selectSql, selectArgs, err := dia.DataListSelectSql(param, ref.source.OwnerName, ref.tableName, columnList, whereList, nil)
+	if err != nil {
+		return
+	}
+	info = &FederatedTableInfo{
+		Alias:     ref.source.Alias,
+		TableName: ref.tableName,
+		TempName:  ref.tempName,
+		SelectSql: selectSql,
+	}
+
+	rows, err := service.GetDb().QueryContext(ctx, selectSql, selectArgs...)
+	if err != nil {
+		err = errors.New("数据源[" + ref.source.Alias + "]sql:" + selectSql + ",error:" + err.Error())
+		return
+	}
+	defer func() { _ = rows.Close() }()
+	columnTypes, err := rows.ColumnTypes()
+	if err != nil {
+		return
+	}
+	var columnNames, columnDefines, placeholders []string
+	var blobColumns []bool
+	for _, columnType := range columnTypes {
+		affinity := federatedAffinity(columnType.DatabaseTypeName())
+		columnNames = append(columnNames, columnType.Name())
+		columnDefines = append(columnDefines, `"`+strings.ReplaceAll(columnType.Name(), `"`, `""`)+`" `+affinity)
+		placeholders = append(placeholders, "?")
+		blobColumns = append(blobColumns, affinity == "BLOB")
+	}
+	_, err = tempDb.ExecContext(ctx, `CREATE TABLE "`+ref.tempName+`" (`+strings.Join(columnDefines, ", ")+`)`)
+	if err != nil {
+		return
+	}
+	insertSql := `INSERT INTO "` + ref.tempName + `" VALUES (` + strings.Join(placeholders, ", ") + `)`
+
+	var batch [][]interface{}
+	var flush = func() (e error) {
+		if len(batch) == 0 {
+			return
+		}
+		tx, e := tempDb.BeginTx(ctx, nil)
+		if e != nil {
+			return
+		}
+		stmt, e := tx.PrepareContext(ctx, insertSql)
+		if e != nil {
+			_ = tx.Rollback()
+			return
+		}
+		for _, values := range batch {
+			if _, e = stmt.ExecContext(ctx, values...); e != nil {
+				_ = stmt.Close()
+				_ = tx.Rollback()
+				return
+			}
+		}
+		_ = stmt.Close()
+		e = tx.Commit()
+		batch = batch[:0]
+		return
+	}
+	for rows.Next() {
+		if info.RowCount >= maxRows {
+			err = errors.New(fmt.Sprint("数据源[", ref.source.Alias, "]表[", ref.tableName, "]超过", maxRows, "行，请设置过滤条件"))
+			return
+		}
+		values := make([]interface{}, len(columnTypes))
+		scans := make([]interface{}, len(columnTypes))
+		for i := range values {
+			scans[i] = &values[i]
+		}
+		if err = rows.Scan(scans...); err != nil {
+			return
+		}
+		for i, value := range values {
+			if bs, ok := value.([]byte); ok && !blobColumns[i] {
+				values[i] = string(bs)
+			}
+			if rule := ref.source.masker.getRule(ref.tableName, columnNames[i]); rule != nil && values[i] != nil {
+				values[i] = rule.mask(values[i])
+			}
+		}
+		batch = append(batch, values)
+		info.RowCount++
+		if len(batch) >= federatedInsertBatch {
+			if err = flush(); err != nil {
+				return
+			}
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return
+	}
+	if err = flush(); err != nil {
+		return
+	}
+	info.UseTime = util.GetNowMilli() - startTime
+	util.Logger.Info("federated pull table", zap.Any("alias", info.Alias), zap.Any("tableName", info.TableName), zap.Any("rowCount", info.RowCount), zap.Any("useTime", info.UseTime))
+	return
+}
+
+// federatedAffinity 根据 源 字段 类型 确定 SQLite 字段 类型，保证 不同 数据库 的 数字 可以 关联 比较
+func federatedAffinity(databaseTypeName string) string {
+	t := strings.ToUpper(databaseTypeName)
+	switch {
+	case t == "":
+		return ""
+	case strings.Contains(t, "BLOB") || strings.Contains(t, "BINARY") || t == "BYTEA" || t == "RAW" || t == "LONG RAW" || t == "IMAGE":
+		return "BLOB"
+	case strings.Contains(t, "INT") && !strings.Contains(t, "INTERVAL") && !strings.Contains(t, "POINT"), t == "SERIAL", t == "BIGSERIAL":
+		return "INTEGER"
+	case strings.Contains(t, "DEC"), strings.Contains(t, "NUMERIC"), strings.Contains(t, "NUMBER"), strings.Contains(t, "FLOAT"), strings.Contains(t, "DOUBLE"), strings.Contains(t, "REAL"):
+		return "NUMERIC"
+	}
+	return "TEXT"
+}
+
+// federatedWhereOperations 过滤条件 允许 的 比较 方式，与 数据 列表 的 条件 一致
+var federatedWhereOperations = map[string]bool{
+	"=": true, "<>": true, "!=": true, ">": true, ">=": true, "<": true, "<=": true,
+	"like": true, "not like": true, "like start": true, "not like start": true, "like end": true, "not like end": true,
+	"is null": true, "is not null": true, "is empty": true, "is not empty": true,
+	"between": true, "not between": true, "in": true, "not in": true,
+}
+
+// checkFederatedWhere 校验 过滤 条件 的 字段、比较 方式 和 连接 方式，值 由 参数 绑定，不 拼接 到 SQL
+func checkFederatedWhere(whereList []*dialect.Where) (res []*dialect.Where, err error) {
+	for _, one := range whereList {
+		if one == nil {
+			continue
+		}
+		if err = checkFederatedName(one.Name); err != nil {
+			return
+		}
+		where := *one
+		where.SqlConditionalOperation = strings.ToLower(strings.TrimSpace(where.SqlConditionalOperation))
+		if where.SqlConditionalOperation == "" {
+			where.SqlConditionalOperation = "="
+		}
+		if !federatedWhereOperations[where.SqlConditionalOperation] {
+			err = errors.New("过滤条件不支持[" + one.SqlConditionalOperation + "]")
+			return
+		}
+		where.AndOr = strings.ToUpper(strings.TrimSpace(where.AndOr))
+		if where.AndOr == "" {
+			where.AndOr = "AND"
+		}
+		if where.AndOr != "AND" && where.AndOr != "OR" {
+			err = errors.New("过滤条件连接方式不支持[" + one.AndOr + "]")
+			return
+		}
+		where.CustomSql = ""
+		res = append(res, &where)
+	}
+	return
+}
+
+// checkFederatedName 库、表、字段 名称 会 拼接 到 SQL 中，不能 包含 引号、分号 等 字符
+func checkFederatedName(name string) (err error) {
+	if strings.TrimSpace(name) == "" {
+		err = errors.New("名称不能为空")
+		return
+	}
+	if strings.ContainsAny(name, "\"`'[];\\\r\n\t\x00") {
+		err = errors.New("名称[" + name + "]包含不支持的字符")
+		return
+	}
+	return
+}
@@ -0,0 +1,271 @@
+package module_database
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/team-ide/go-dialect/dialect"
+	"github.com/team-ide/go-tool/util"
+	"go.uber.org/zap"
+	"strings"
+	"teamide/internal/module/module_log"
+	"teamide/pkg/base"
+)
+
+// 危险 语句 分类
+const (
+	GuardKindDDL      = "ddl"      // 结构 变更
+	GuardKindDrop     = "drop"     // 删除 库、表 等
+	GuardKindTruncate = "truncate" // 清空 表
+	GuardKindNoWhere  = "noWhere"  // 没有 WHERE 条件 的 UPDATE、DELETE
+	GuardKindCall     = "call"     // 执行 存储过程、函数
+	GuardKindMerge    = "merge"    // 合并、替换 数据
+	GuardKindRestore  = "restore"  // 恢复 备份
+	GuardKindUnknown  = "unknown"  // 无法 解析 的 语句
+)
+
+var guardKindTexts = map[string]string{
+	GuardKindDDL:      "结构变更",
+	GuardKindDrop:     "删除",
+	GuardKindTruncate: "清空",
+	GuardKindNoWhere:  "无WHERE条件的修改或删除",
+	GuardKindCall:     "执行存储过程或函数",
+	GuardKindMerge:    "合并或替换数据",
+	GuardKindRestore:  "恢复备份",
+	GuardKindUnknown:  "无法解析的语句",
+}
+
+// GuardStatement 生产 保护 检测 到 的 危险 语句
+type GuardStatement struct {
+	Sql  string `json:"sql"`
+	Kind string `json:"kind"`
+}
+
+// GuardLog 生产 保护 判定 记录，保存 在 日志 数据 中
+type GuardLog struct {
+	ToolboxId   int64             `json:"toolboxId"`
+	ToolboxName string            `json:"toolboxName"`
+	Operation   string            `json:"operation"`
+	OwnerName   string            `json:"ownerName,omitempty"`
+	TableName   string            `json:"tableName,omitempty"`
+	Decision    string            `json:"decision"` // confirm：已确认 执行，reject：拒绝
+	Statements  []*GuardStatement `json:"statements"`
+}
+
+// classifySql 分割 并 分类 SQL，只 返回 危险 语句
+func classifySql(dia dialect.Dialect, sqlContent string) (res []*GuardStatement) {
+	for _, one := range dia.SqlSplit(sqlContent) {
+		if strings.TrimSpace(one) == "" {
+			continue
+		}
+		kind := classifyStatement(dia.DialectType(), one)
+		if kind == "" {
+			continue
+		}
+		res = append(res, &GuardStatement{
+			Sql:  strings.TrimSpace(one),
+			Kind: kind,
+		})
+	}
+	return
+}
+
+// classifyStatement 根据 关键字 分类 单条 语句，安全 的 语句 返回 空，无法 解析 的 语句 按 危险 语句 处理
+func classifyStatement(dialectType *dialect.Type, sqlInfo string) string {
+	tokens, err := sqlTokenize(dialectType, sqlInfo)
+	if err != nil {
+		return GuardKindUnknown
+	}
+	var words []string
+	for _, token := range tokens {
+		if token.kind == sqlTokenWord && token.depth == 0 {
+			words = append(words, token.upper)
+		}
+	}
+	if len(words) == 0 {
+		return ""
+	}
+	first := words[0]
+	switch first {
+	case "WITH":
+		// CTE 中 的 修改 语句 同样 会 执行
+		if kind := classifyNestedDml(tokens); kind != "" {
+			return kind
+		}
+		first = firstDmlWord(words[1:])
+	case "EXPLAIN":
+		// EXPLAIN ANALYZE 会 实际 执行 语句
+		if util.StringIndexOf(words, "ANALYZE") < 0 && util.StringIndexOf(words, "ANALYSE") < 0 {
+			return ""
+		}
+		if kind := classifyNestedDml(tokens); kind != "" {
+			return kind
+		}
+		first = firstDmlWord(words[1:])
+	}
+	switch first {
+	case "DROP":
+		return GuardKindDrop
+	case "TRUNCATE":
+		return GuardKindTruncate
+	case "CREATE", "ALTER", "RENAME", "COMMENT", "GRANT", "REVOKE":
+		return GuardKindDDL
+	case "CALL", "EXEC", "EXECUTE", "DO", "DECLARE":
+		return GuardKindCall
+	case "BEGIN":
+		// BEGIN 后 不是 事务 关键字 的 为 匿名 块
+		if len(words) > 1 && !guardTransactionWords[words[1]] {
+			return GuardKindCall
+		}
+	case "MERGE", "REPLACE":
+		return GuardKindMerge
+	case "UPDATE", "DELETE":
+		if util.StringIndexOf(words, "WHERE") < 0 {
+			return GuardKindNoWhere
+		}
+	}
+	return ""
+}
+
+// guardTransactionWords BEGIN 开启 事务 时 可能 跟随 的 关键字
+var guardTransactionWords = map[string]bool{
+	"TRANSACTION": true,
+	"WORK":        true,
+	"TRAN":        true,
+	"ISOLATION":   true,
+	"READ":        true,
+	"DEFERRED":    true,
+	"IMMEDIATE":   true,
+	"EXCLUSIVE":   true,
+}
+
+// firstDmlWord 获取 第一个 DML 关键字
+func firstDmlWord(words []string) string {
+	for _, word := range words {
+		switch word {
+		case "UPDATE", "DELETE", "INSERT", "SELECT", "MERGE", "REPLACE":
+			return word
+		}
+	}
+	return ""
+}
+
+// classifyNestedDml 分类 括号 内 的 修改 语句，如 CTE 中 的 UPDATE、DELETE、MERGE
+func classifyNestedDml(tokens []*sqlToken) string {
+	var prev *sqlToken
+	for i, token := range tokens {
+		if token.kind == sqlTokenComment {
+			continue
+		}
+		if token.depth > 0 && token.kind == sqlTokenWord && prev.is("(") {
+			switch token.upper {
+			case "MERGE":
+				return GuardKindMerge
+			case "UPDATE", "DELETE":
+				if !hasWhereAtDepth(tokens[i+1:], token.depth) {
+					return GuardKindNoWhere
+				}
+			}
+		}
+		prev = token
+	}
+	return ""
+}
+
+// hasWhereAtDepth 在 括号 结束 前 是否 有 同 层级 的 WHERE
+func hasWhereAtDepth(tokens []*sqlToken, depth int) bool {
+	for _, token := range tokens {
+		if token.depth < depth {
+			return false
+		}
+		if token.depth == depth && token.kind == sqlTokenWord && token.upper == "WHERE" {
+			return true
+		}
+	}
+	return false
+}
+
+// sqlTopLevelWords 获取 语句 中 不在 括号、字符串、注释 内 的 关键字，统一 大写
+func sqlTopLevelWords(dialectType *dialect.Type, sqlInfo string) (words []string, err error) {
+	tokens, err := sqlTokenize(dialectType, sqlInfo)
+	if err != nil {
+		return
+	}
+	for _, token := range tokens {
+		if token.kind == sqlTokenWord && token.depth == 0 {
+			words = append(words, token.upper)
+		}
+	}
+	return
+}
+
+// checkProtected 工具 开启 生产 保护 时 校验 危险 语句，需要 输入 工具 名称 确认 才能 执行，并 记录 日志
+func (this_ *api) checkProtected(requestBean *base.RequestBean, c *gin.Context, operation string, request *BaseRequest, statements []*GuardStatement) (err error) {
+	if len(statements) == 0 {
+		return
+	}
+	databaseOption, err := this_.getDatabaseOption(requestBean)
+	if err != nil {
+		return
+	}
+	if !databaseOption.Protected {
+		return
+	}
+	var toolboxName string
+	toolbox, err := this_.getToolboxModel(requestBean)
+	if err != nil {
+		return
+	}
+	if toolbox != nil {
+		toolboxName = toolbox.Name
+	}
+	guardLog := &GuardLog{
+		ToolboxId:   request.ToolboxId,
+		ToolboxName: toolboxName,
+		Operation:   operation,
+		OwnerName:   request.OwnerName,
+		TableName:   request.TableName,
+		Decision:    "confirm",
+		Statements:  statements,
+	}
+	if request.ConfirmToken == "" || request.ConfirmToken != toolboxName {
+		guardLog.Decision = "reject"
+		var kindTexts []string
+		for _, one := range statements {
+			text := guardKindTexts[one.Kind]
+			if util.StringIndexOf(kindTexts, text) < 0 {
+				kindTexts = append(kindTexts, text)
+			}
+		}
+		err = errors.New("当前工具已开启生产保护，包含[" + strings.Join(kindTexts, "、") + "]操作，请输入工具名称[" + toolboxName + "]确认后执行")
+	}
+	this_.recordGuardLog(requestBean, c, guardLog, err)
+	return
+}
+
+// recordGuardLog 将 生产 保护 判定 记录 到 日志
+func (this_ *api) recordGuardLog(requestBean *base.RequestBean, c *gin.Context, guardLog *GuardLog, guardErr error) {
+	util.Logger.Info("database guard", zap.Any("guard", guardLog))
+	now := util.GetNow()
+	logRecode := &module_log.LogModel{
+		Action:     "database/guard/" + guardLog.Operation,
+		Method:     c.Request.Method,
+		Ip:         c.ClientIP(),
+		UserAgent:  c.Request.UserAgent(),
+		StartTime:  now,
+		EndTime:    now,
+		CreateTime: now,
+	}
+	bs, _ := json.Marshal(guardLog)
+	logRecode.Data = string(bs)
+	if requestBean.JWT != nil {
+		logRecode.UserId = requestBean.JWT.UserId
+		logRecode.UserName = requestBean.JWT.Name
+		logRecode.UserAccount = requestBean.JWT.Account
+		logRecode.LoginId = requestBean.JWT.LoginId
+	}
+	e := this_.logService.Insert(logRecode, guardErr)
+	if e != nil {
+		util.Logger.Error("database guard log insert error", zap.Error(e))
+	}
+}
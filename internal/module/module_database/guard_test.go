@@ -0,0 +1,50 @@
+package module_database
+
+import (
+	"github.com/team-ide/go-dialect/dialect"
+	"testing"
+)
+
+func TestClassifyStatement(t *testing.T) {
+	tests := []struct {
+		name        string
+		dialectType *dialect.Type
+		sql         string
+		kind        string
+	}{
+		{"select", dialect.TypeMysql, "SELECT * FROM t", ""},
+		{"drop", dialect.TypeMysql, "DROP TABLE t", GuardKindDrop},
+		{"truncate", dialect.TypeMysql, "TRUNCATE TABLE t", GuardKindTruncate},
+		{"create or replace", dialect.TypeOracle, "CREATE OR REPLACE VIEW v AS SELECT 1 FROM dual", GuardKindDDL},
+		{"update with where", dialect.TypeMysql, "UPDATE t SET a = 1 WHERE id = 1", ""},
+		{"update without where", dialect.TypeMysql, "UPDATE t SET a = 1", GuardKindNoWhere},
+		{"delete where in subquery", dialect.TypeMysql, "DELETE FROM t WHERE id IN (SELECT id FROM b)", ""},
+		{"delete where only in subquery", dialect.TypeMysql, "DELETE FROM t LIMIT (SELECT 1 WHERE 1 = 1)", GuardKindNoWhere},
+		{"where in comment", dialect.TypeMysql, "DELETE FROM t # WHERE id = 1", GuardKindNoWhere},
+		{"call", dialect.TypeMysql, "CALL p(1)", GuardKindCall},
+		{"exec", dialect.TypeDM, "EXEC p", GuardKindCall},
+		{"execute", dialect.TypePostgresql, "EXECUTE s(1)", GuardKindCall},
+		{"do block", dialect.TypePostgresql, "DO $$ BEGIN DELETE FROM t; END $$", GuardKindCall},
+		{"anonymous block", dialect.TypeOracle, "BEGIN p(1); END;", GuardKindCall},
+		{"begin transaction", dialect.TypeSqlite, "BEGIN TRANSACTION", ""},
+		{"begin", dialect.TypePostgresql, "BEGIN", ""},
+		{"merge", dialect.TypeOracle, "MERGE INTO t USING s ON (t.id = s.id) WHEN MATCHED THEN UPDATE SET t.a = s.a", GuardKindMerge},
+		{"replace", dialect.TypeMysql, "REPLACE INTO t (id) VALUES (1)", GuardKindMerge},
+		{"with select", dialect.TypePostgresql, "WITH a AS (SELECT 1) SELECT * FROM a", ""},
+		{"with select for update", dialect.TypePostgresql, "WITH a AS (SELECT * FROM t FOR UPDATE) SELECT * FROM a", ""},
+		{"with update", dialect.TypePostgresql, "WITH a AS (SELECT 1) UPDATE t SET a = 1", GuardKindNoWhere},
+		{"with merge", dialect.TypeKingBase, "WITH a AS (SELECT 1) MERGE INTO t USING a ON (t.id = a.id) WHEN MATCHED THEN DELETE", GuardKindMerge},
+		{"with nested delete", dialect.TypePostgresql, "WITH d AS (DELETE FROM t RETURNING *) SELECT * FROM d", GuardKindNoWhere},
+		{"with nested delete where", dialect.TypePostgresql, "WITH d AS (DELETE FROM t WHERE id = 1 RETURNING *) SELECT * FROM d", ""},
+		{"explain", dialect.TypePostgresql, "EXPLAIN DELETE FROM t", ""},
+		{"explain analyze", dialect.TypePostgresql, "EXPLAIN ANALYZE DELETE FROM t", GuardKindNoWhere},
+		{"unterminated string", dialect.TypeMysql, "DELETE FROM t WHERE a = '", GuardKindUnknown},
+	}
+	for _, one := range tests {
+		t.Run(one.name, func(t *testing.T) {
+			if kind := classifyStatement(one.dialectType, one.sql); kind != one.kind {
+				t.Fatalf("sql [%s] kind [%s], want [%s]", one.sql, kind, one.kind)
+			}
+		})
+	}
+}
@@ -0,0 +1,376 @@
+package module_database
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/team-ide/go-dialect/worker"
+	"github.com/team-ide/go-tool/util"
+	"go.uber.org/zap"
+	"strings"
+	"teamide/internal/context"
+	"teamide/internal/module/module_id"
+	"teamide/pkg/base"
+	"time"
+)
+
+// NewSqlHistoryService 根据库配置创建SqlHistoryService
+func NewSqlHistoryService(ServerContext *context.ServerContext) (res *SqlHistoryService) {
+
+	idService := module_id.NewIDService(ServerContext)
+
+	res = &SqlHistoryService{
+		ServerContext: ServerContext,
+		idService:     idService,
+	}
+	return
+}
+
+// SqlHistoryService 数据库SQL历史服务
+type SqlHistoryService struct {
+	*context.ServerContext
+	idService *module_id.IDService
+}
+
+// Insert 新增
+func (this_ *SqlHistoryService) Insert(history *SqlHistoryModel) (err error) {
+
+	if history.SqlHistoryId == 0 {
+		history.SqlHistoryId, err = this_.idService.GetNextID(module_id.IDTypeDatabaseSqlHistory)
+		if err != nil {
+			return
+		}
+	}
+	if history.Saved == 0 {
+		history.Saved = 2
+	}
+	if history.Shared == 0 {
+		history.Shared = 2
+	}
+	if history.CreateTime.IsZero() {
+		history.CreateTime = time.Now()
+	}
+
+	sql := `INSERT INTO ` + TableDatabaseSqlHistory + `(sqlHistoryId, toolboxId, userId, userName, userAccount, ownerName, executeType, executeSql, useTime, rowCount, error, saved, name, tags, shared, createTime) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?) `
+
+	_, err = this_.DatabaseWorker.Exec(sql, []interface{}{history.SqlHistoryId, history.ToolboxId, history.UserId, history.UserName, history.UserAccount, history.OwnerName, history.ExecuteType, history.ExecuteSql, history.UseTime, history.RowCount, history.Error, history.Saved, history.Name, formatHistoryTags(history.Tags), history.Shared, history.CreateTime})
+	if err != nil {
+		this_.Logger.Error("Insert Error", zap.Error(err))
+		return
+	}
+	return
+}
+
+// Get 查询单个
+func (this_ *SqlHistoryService) Get(sqlHistoryId int64) (res *SqlHistoryModel, err error) {
+	res = &SqlHistoryModel{}
+
+	sql := `SELECT * FROM ` + TableDatabaseSqlHistory + ` WHERE sqlHistoryId=? `
+	find, err := this_.DatabaseWorker.QueryOne(sql, []interface{}{sqlHistoryId}, res)
+	if err != nil {
+		this_.Logger.Error("Get Error", zap.Error(err))
+		return
+	}
+	if !find {
+		res = nil
+	} else {
+		res.Tags = strings.Trim(res.Tags, ",")
+	}
+	return
+}
+
+type SqlHistoryPage struct {
+	*worker.Page
+	DataList []*SqlHistoryModel `json:"dataList"`
+}
+
+// SqlHistoryQuery SQL历史 查询条件
+type SqlHistoryQuery struct {
+	ToolboxId int64  `json:"toolboxId,omitempty"`
+	UserId    int64  `json:"-"`
+	OwnerName string `json:"ownerName,omitempty"`
+	Keyword   string `json:"keyword,omitempty"` // 匹配 SQL、名称
+	Tag       string `json:"tag,omitempty"`
+	Saved     int8   `json:"saved,omitempty"`
+	HasError  int8   `json:"hasError,omitempty"` // 1：只查询 异常 2：只查询 成功
+}
+
+// QueryPage 分页查询，包含 自己 的 历史 和 其它用户 共享 的 保存的查询
+func (this_ *SqlHistoryService) QueryPage(query *SqlHistoryQuery, page *SqlHistoryPage) (err error) {
+	var sql string
+	var values []interface{}
+
+	sql += "SELECT * FROM " + TableDatabaseSqlHistory + " WHERE toolboxId=?"
+	values = append(values, query.ToolboxId)
+
+	sql += " AND (userId=? OR (saved=1 AND shared=1))"
+	values = append(values, query.UserId)
+
+	if query.OwnerName != "" {
+		sql += " AND ownerName=?"
+		values = append(values, query.OwnerName)
+	}
+	if query.Keyword != "" {
+		keyword := escapeHistoryLike(query.Keyword)
+		sql += " AND (executeSql LIKE ? ESCAPE '/' OR name LIKE ? ESCAPE '/')"
+		values = append(values, "%"+keyword+"%", "%"+keyword+"%")
+	}
+	if query.Tag != "" {
+		sql += " AND tags LIKE ? ESCAPE '/'"
+		values = append(values, "%,"+escapeHistoryLike(strings.TrimSpace(query.Tag))+",%")
+	}
+	if query.Saved != 0 {
+		sql += " AND saved=?"
+		values = append(values, query.Saved)
+	}
+	if query.HasError == 1 {
+		sql += " AND error IS NOT NULL AND error != ''"
+	} else if query.HasError == 2 {
+		sql += " AND (error IS NULL OR error = '')"
+	}
+	sql += " ORDER BY createTime DESC"
+	page.DataList = []*SqlHistoryModel{}
+	err = this_.DatabaseWorker.QueryPage(sql, values, &page.DataList, page.Page)
+	if err != nil {
+		return
+	}
+	for _, one := range page.DataList {
+		one.Tags = strings.Trim(one.Tags, ",")
+	}
+	return
+}
+
+// Save 保存为 保存的查询，设置 名称、标签、共享
+func (this_ *SqlHistoryService) Save(history *SqlHistoryModel) (err error) {
+
+	sql := `UPDATE ` + TableDatabaseSqlHistory + ` SET saved=?,name=?,tags=?,shared=?,updateTime=? WHERE sqlHistoryId=? AND userId=? `
+
+	_, err = this_.DatabaseWorker.Exec(sql, []interface{}{history.Saved, history.Name, formatHistoryTags(history.Tags), history.Shared, time.Now(), history.SqlHistoryId, history.UserId})
+	if err != nil {
+		this_.Logger.Error("Save Error", zap.Error(err))
+		return
+	}
+	return
+}
+
+// Delete 删除
+func (this_ *SqlHistoryService) Delete(sqlHistoryId int64, userId int64) (err error) {
+
+	sql := `DELETE FROM ` + TableDatabaseSqlHistory + ` WHERE sqlHistoryId=? AND userId=? `
+
+	_, err = this_.DatabaseWorker.Exec(sql, []interface{}{sqlHistoryId, userId})
+	if err != nil {
+		this_.Logger.Error("Delete Error", zap.Error(err))
+		return
+	}
+	return
+}
+
+// Clean 清理 未保存 的 历史
+func (this_ *SqlHistoryService) Clean(toolboxId int64, userId int64) (err error) {
+
+	sql := `DELETE FROM ` + TableDatabaseSqlHistory + ` WHERE toolboxId=? AND userId=? AND saved!=1 `
+
+	_, err = this_.DatabaseWorker.Exec(sql, []interface{}{toolboxId, userId})
+	if err != nil {
+		this_.Logger.Error("Clean Error", zap.Error(err))
+		return
+	}
+	return
+}
+
+// escapeHistoryLike 转义 LIKE 的 通配符，使用 / 作为 转义符，MySQL 和 SQLite 都 支持
+func escapeHistoryLike(value string) string {
+	value = strings.ReplaceAll(value, "/", "//")
+	value = strings.ReplaceAll(value, "%", "/%")
+	value = strings.ReplaceAll(value, "_", "/_")
+	return value
+}
+
+// formatHistoryTags 标签 前后 加 逗号 存储，便于 LIKE 匹配 单个 标签
+func formatHistoryTags(tags string) string {
+	var list []string
+	for _, one := range strings.Split(tags, ",") {
+		one = strings.TrimSpace(one)
+		if one == "" {
+			continue
+		}
+		list = append(list, one)
+	}
+	if len(list) == 0 {
+		return ""
+	}
+	return "," + strings.Join(list, ",") + ","
+}
+
+// recordHistory 记录 执行 的 SQL，记录 失败 不影响 执行 结果
+func (this_ *api) recordHistory(requestBean *base.RequestBean, history *SqlHistoryModel) {
+	if history.ToolboxId == 0 || strings.TrimSpace(history.ExecuteSql) == "" {
+		return
+	}
+	if requestBean.JWT != nil {
+		history.UserId = requestBean.JWT.UserId
+		history.UserName = requestBean.JWT.Name
+		history.UserAccount = requestBean.JWT.Account
+	}
+	go func() {
+		e := this_.sqlHistoryService.Insert(history)
+		if e != nil {
+			util.Logger.Error("database sql history insert error", zap.Error(e))
+		}
+	}()
+}
+
+// recordExecuteHistory 按 每条 执行 的 SQL 记录 历史
+func (this_ *api) recordExecuteHistory(requestBean *base.RequestBean, request *BaseRequest, executeList []map[string]interface{}) {
+	for _, executeData := range executeList {
+		history := &SqlHistoryModel{
+			ToolboxId:   request.ToolboxId,
+			OwnerName:   request.OwnerName,
+			ExecuteType: "executeSQL",
+			ExecuteSql:  util.GetStringValue(executeData["sql"]),
+			UseTime:     historyInt64(executeData["useTime"]),
+			Error:       util.GetStringValue(executeData["error"]),
+		}
+		if executeData["dataSize"] != nil {
+			history.RowCount = historyInt64(executeData["dataSize"])
+		} else {
+			history.RowCount = historyInt64(executeData["rowsAffected"])
+		}
+		this_.recordHistory(requestBean, history)
+	}
+}
+
+func historyInt64(v interface{}) int64 {
+	switch tV := v.(type) {
+	case int64:
+		return tV
+	case int:
+		return int64(tV)
+	}
+	return 0
+}
+
+type SqlHistoryRequest struct {
+	*SqlHistoryPage
+	*SqlHistoryQuery
+	SqlHistoryId int64  `json:"sqlHistoryId,omitempty"`
+	Name         string `json:"name,omitempty"`
+	Tags         string `json:"tags,omitempty"`
+	Shared       int8   `json:"shared,omitempty"`
+}
+
+func (this_ *api) historyQuery(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	_, _, err = this_.getConfig(requestBean, c)
+	if err != nil {
+		return
+	}
+
+	request := &SqlHistoryRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	if request.SqlHistoryQuery == nil {
+		request.SqlHistoryQuery = &SqlHistoryQuery{}
+	}
+	if request.SqlHistoryPage == nil {
+		request.SqlHistoryPage = &SqlHistoryPage{}
+	}
+	if request.SqlHistoryPage.Page == nil {
+		request.SqlHistoryPage.Page = worker.NewPage()
+		request.SqlHistoryPage.Page.PageSize = 20
+	}
+	request.SqlHistoryQuery.UserId = requestBean.JWT.UserId
+
+	err = this_.sqlHistoryService.QueryPage(request.SqlHistoryQuery, request.SqlHistoryPage)
+	if err != nil {
+		return
+	}
+	res = request.SqlHistoryPage
+	return
+}
+
+func (this_ *api) historySave(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	request := &SqlHistoryRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	history, err := this_.getUserHistory(requestBean, request.SqlHistoryId)
+	if err != nil {
+		return
+	}
+	history.Saved = 1
+	history.Name = request.Name
+	history.Tags = request.Tags
+	history.Shared = 2
+	if request.Shared == 1 {
+		history.Shared = 1
+	}
+	err = this_.sqlHistoryService.Save(history)
+	if err != nil {
+		return
+	}
+	res = history
+	return
+}
+
+func (this_ *api) historyUnsave(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	request := &SqlHistoryRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	history, err := this_.getUserHistory(requestBean, request.SqlHistoryId)
+	if err != nil {
+		return
+	}
+	history.Saved = 2
+	history.Shared = 2
+	err = this_.sqlHistoryService.Save(history)
+	if err != nil {
+		return
+	}
+	return
+}
+
+func (this_ *api) historyDelete(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	request := &SqlHistoryRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	_, err = this_.getUserHistory(requestBean, request.SqlHistoryId)
+	if err != nil {
+		return
+	}
+	err = this_.sqlHistoryService.Delete(request.SqlHistoryId, requestBean.JWT.UserId)
+	return
+}
+
+func (this_ *api) historyClean(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	_, _, err = this_.getConfig(requestBean, c)
+	if err != nil {
+		return
+	}
+
+	request := &BaseRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	err = this_.sqlHistoryService.Clean(request.ToolboxId, requestBean.JWT.UserId)
+	return
+}
+
+// getUserHistory 查询 当前用户 的 SQL历史，只有 创建者 可以 修改、删除
+func (this_ *api) getUserHistory(requestBean *base.RequestBean, sqlHistoryId int64) (history *SqlHistoryModel, err error) {
+	history, err = this_.sqlHistoryService.Get(sqlHistoryId)
+	if err != nil {
+		return
+	}
+	if history == nil {
+		err = errors.New("SQL历史不存在")
+		return
+	}
+	if requestBean.JWT == nil || history.UserId != requestBean.JWT.UserId {
+		err = errors.New("SQL历史不属于当前用户，无法操作")
+		return
+	}
+	return
+}
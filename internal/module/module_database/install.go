@@ -0,0 +1,253 @@
+package module_database
+
+import (
+	"teamide/internal/install"
+)
+
+func GetInstallStages() []*install.StageModel {
+
+	return []*install.StageModel{
+
+		// 创建 数据库SQL历史 表 开始
+		{
+			Version: "1.0",
+			Module:  ModuleDatabaseSqlHistory,
+			Stage:   `创建表[` + TableDatabaseSqlHistory + `]`,
+			Sql: &install.StageSqlModel{
+				Mysql: []string{`
+CREATE TABLE ` + TableDatabaseSqlHistory + ` (
+	sqlHistoryId bigint(20) NOT NULL COMMENT 'SQL历史ID',
+	toolboxId bigint(20) NOT NULL COMMENT '工具ID',
+	userId bigint(20) DEFAULT NULL COMMENT '用户ID',
+	userName varchar(50) DEFAULT NULL COMMENT '用户名称',
+	userAccount varchar(50) DEFAULT NULL COMMENT '用户账号',
+	ownerName varchar(200) DEFAULT NULL COMMENT '库名',
+	executeType varchar(50) DEFAULT NULL COMMENT '执行类型',
+	executeSql longtext DEFAULT NULL COMMENT '执行SQL',
+	useTime bigint(20) DEFAULT NULL COMMENT '耗时（毫秒）',
+	rowCount bigint(20) DEFAULT NULL COMMENT '行数',
+	error text DEFAULT NULL COMMENT '异常',
+	saved int(10) DEFAULT 2 COMMENT '是否保存',
+	name varchar(200) DEFAULT NULL COMMENT '名称',
+	tags varchar(500) DEFAULT NULL COMMENT '标签',
+	shared int(10) DEFAULT 2 COMMENT '是否共享',
+	createTime datetime NOT NULL COMMENT '创建时间',
+	updateTime datetime DEFAULT NULL COMMENT '修改时间',
+	PRIMARY KEY (sqlHistoryId),
+	KEY index_toolboxId (toolboxId),
+	KEY index_userId (userId),
+	KEY index_saved (saved),
+	KEY index_shared (shared),
+	KEY index_createTime (createTime)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='` + TableDatabaseSqlHistoryComment + `';
+`},
+				Sqlite: []string{`
+CREATE TABLE ` + TableDatabaseSqlHistory + ` (
+	sqlHistoryId bigint(20) NOT NULL,
+	toolboxId bigint(20) NOT NULL,
+	userId bigint(20) DEFAULT NULL,
+	userName varchar(50) DEFAULT NULL,
+	userAccount varchar(50) DEFAULT NULL,
+	ownerName varchar(200) DEFAULT NULL,
+	executeType varchar(50) DEFAULT NULL,
+	executeSql text DEFAULT NULL,
+	useTime bigint(20) DEFAULT NULL,
+	rowCount bigint(20) DEFAULT NULL,
+	error text DEFAULT NULL,
+	saved int(10) DEFAULT 2,
+	name varchar(200) DEFAULT NULL,
+	tags varchar(500) DEFAULT NULL,
+	shared int(10) DEFAULT 2,
+	createTime datetime NOT NULL,
+	updateTime datetime DEFAULT NULL,
+	PRIMARY KEY (sqlHistoryId)
+);
+`,
+					`CREATE INDEX ` + TableDatabaseSqlHistory + `_index_toolboxId on ` + TableDatabaseSqlHistory + ` (toolboxId);`,
+					`CREATE INDEX ` + TableDatabaseSqlHistory + `_index_userId on ` + TableDatabaseSqlHistory + ` (userId);`,
+					`CREATE INDEX ` + TableDatabaseSqlHistory + `_index_saved on ` + TableDatabaseSqlHistory + ` (saved);`,
+					`CREATE INDEX ` + TableDatabaseSqlHistory + `_index_shared on ` + TableDatabaseSqlHistory + ` (shared);`,
+					`CREATE INDEX ` + TableDatabaseSqlHistory + `_index_createTime on ` + TableDatabaseSqlHistory + ` (createTime);`,
+				},
+			},
+		},
+		// 创建 数据库SQL历史 表 结束
+
+		// 创建 数据库数据编辑记录 表 开始
+		{
+			Version: "1.0",
+			Module:  ModuleDatabaseDataEdit,
+			Stage:   `创建表[` + TableDatabaseDataEdit + `]`,
+			Sql: &install.StageSqlModel{
+				Mysql: []string{`
+CREATE TABLE ` + TableDatabaseDataEdit + ` (
+	dataEditId bigint(20) NOT NULL COMMENT '编辑记录ID',
+	toolboxId bigint(20) NOT NULL COMMENT '工具ID',
+	userId bigint(20) DEFAULT NULL COMMENT '用户ID',
+	userName varchar(50) DEFAULT NULL COMMENT '用户名称',
+	userAccount varchar(50) DEFAULT NULL COMMENT '用户账号',
+	ownerName varchar(200) DEFAULT NULL COMMENT '库名',
+	tableName varchar(200) DEFAULT NULL COMMENT '表名',
+	insertCount int(10) DEFAULT 0 COMMENT '新增行数',
+	updateCount int(10) DEFAULT 0 COMMENT '修改行数',
+	deleteCount int(10) DEFAULT 0 COMMENT '删除行数',
+	executeSql longtext DEFAULT NULL COMMENT '执行SQL',
+	undoData longtext DEFAULT NULL COMMENT '撤销数据',
+	status int(10) DEFAULT 1 COMMENT '状态',
+	undoSql longtext DEFAULT NULL COMMENT '撤销SQL',
+	undoTime datetime DEFAULT NULL COMMENT '撤销时间',
+	createTime datetime NOT NULL COMMENT '创建时间',
+	PRIMARY KEY (dataEditId),
+	KEY index_toolboxId (toolboxId),
+	KEY index_userId (userId),
+	KEY index_createTime (createTime)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='` + TableDatabaseDataEditComment + `';
+`},
+				Sqlite: []string{`
+CREATE TABLE ` + TableDatabaseDataEdit + ` (
+	dataEditId bigint(20) NOT NULL,
+	toolboxId bigint(20) NOT NULL,
+	userId bigint(20) DEFAULT NULL,
+	userName varchar(50) DEFAULT NULL,
+	userAccount varchar(50) DEFAULT NULL,
+	ownerName varchar(200) DEFAULT NULL,
+	tableName varchar(200) DEFAULT NULL,
+	insertCount int(10) DEFAULT 0,
+	updateCount int(10) DEFAULT 0,
+	deleteCount int(10) DEFAULT 0,
+	executeSql text DEFAULT NULL,
+	undoData text DEFAULT NULL,
+	status int(10) DEFAULT 1,
+	undoSql text DEFAULT NULL,
+	undoTime datetime DEFAULT NULL,
+	createTime datetime NOT NULL,
+	PRIMARY KEY (dataEditId)
+);
+`,
+					`CREATE INDEX ` + TableDatabaseDataEdit + `_index_toolboxId on ` + TableDatabaseDataEdit + ` (toolboxId);`,
+					`CREATE INDEX ` + TableDatabaseDataEdit + `_index_userId on ` + TableDatabaseDataEdit + ` (userId);`,
+					`CREATE INDEX ` + TableDatabaseDataEdit + `_index_createTime on ` + TableDatabaseDataEdit + ` (createTime);`,
+				},
+			},
+		},
+		// 创建 数据库数据编辑记录 表 结束
+
+		// 创建 数据库备份任务 表 开始
+		{
+			Version: "1.0",
+			Module:  ModuleDatabaseBackup,
+			Stage:   `创建表[` + TableDatabaseBackup + `]`,
+			Sql: &install.StageSqlModel{
+				Mysql: []string{`
+CREATE TABLE ` + TableDatabaseBackup + ` (
+	backupId bigint(20) NOT NULL COMMENT '备份任务ID',
+	toolboxId bigint(20) NOT NULL COMMENT '工具ID',
+	name varchar(200) NOT NULL COMMENT '名称',
+	spec varchar(100) NOT NULL COMMENT '定时规则',
+	owners longtext DEFAULT NULL COMMENT '备份库表',
+	place varchar(20) NOT NULL COMMENT '保存位置',
+	placeId varchar(100) DEFAULT NULL COMMENT '保存位置ID',
+	placeDir varchar(500) DEFAULT NULL COMMENT '保存目录',
+	retainCount int(10) DEFAULT 0 COMMENT '保留个数',
+	retainDays int(10) DEFAULT 0 COMMENT '保留天数',
+	enabled int(10) DEFAULT 1 COMMENT '启用',
+	userId bigint(20) DEFAULT NULL COMMENT '用户ID',
+	userName varchar(50) DEFAULT NULL COMMENT '用户名称',
+	userAccount varchar(50) DEFAULT NULL COMMENT '用户账号',
+	lastRunTime datetime DEFAULT NULL COMMENT '最后执行时间',
+	createTime datetime NOT NULL COMMENT '创建时间',
+	updateTime datetime DEFAULT NULL COMMENT '修改时间',
+	PRIMARY KEY (backupId),
+	KEY index_toolboxId (toolboxId)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='` + TableDatabaseBackupComment + `';
+`},
+				Sqlite: []string{`
+CREATE TABLE ` + TableDatabaseBackup + ` (
+	backupId bigint(20) NOT NULL,
+	toolboxId bigint(20) NOT NULL,
+	name varchar(200) NOT NULL,
+	spec varchar(100) NOT NULL,
+	owners text DEFAULT NULL,
+	place varchar(20) NOT NULL,
+	placeId varchar(100) DEFAULT NULL,
+	placeDir varchar(500) DEFAULT NULL,
+	retainCount int(10) DEFAULT 0,
+	retainDays int(10) DEFAULT 0,
+	enabled int(10) DEFAULT 1,
+	userId bigint(20) DEFAULT NULL,
+	userName varchar(50) DEFAULT NULL,
+	userAccount varchar(50) DEFAULT NULL,
+	lastRunTime datetime DEFAULT NULL,
+	createTime datetime NOT NULL,
+	updateTime datetime DEFAULT NULL,
+	PRIMARY KEY (backupId)
+);
+`,
+					`CREATE INDEX ` + TableDatabaseBackup + `_index_toolboxId on ` + TableDatabaseBackup + ` (toolboxId);`,
+				},
+			},
+		},
+		// 创建 数据库备份任务 表 结束
+
+		// 创建 数据库备份记录 表 开始
+		{
+			Version: "1.0",
+			Module:  ModuleDatabaseBackup,
+			Stage:   `创建表[` + TableDatabaseBackupRecord + `]`,
+			Sql: &install.StageSqlModel{
+				Mysql: []string{`
+CREATE TABLE ` + TableDatabaseBackupRecord + ` (
+	backupRecordId bigint(20) NOT NULL COMMENT '备份记录ID',
+	backupId bigint(20) NOT NULL COMMENT '备份任务ID',
+	toolboxId bigint(20) NOT NULL COMMENT '工具ID',
+	triggerType varchar(20) DEFAULT NULL COMMENT '触发方式',
+	place varchar(20) DEFAULT NULL COMMENT '保存位置',
+	placeId varchar(100) DEFAULT NULL COMMENT '保存位置ID',
+	filePath varchar(1000) DEFAULT NULL COMMENT '文件路径',
+	fileSize bigint(20) DEFAULT 0 COMMENT '文件大小',
+	status int(10) DEFAULT 1 COMMENT '状态',
+	error text DEFAULT NULL COMMENT '错误信息',
+	ownerCount int(10) DEFAULT 0 COMMENT '库数量',
+	tableCount int(10) DEFAULT 0 COMMENT '表数量',
+	dataCount int(10) DEFAULT 0 COMMENT '数据数量',
+	useTime bigint(20) DEFAULT 0 COMMENT '耗时',
+	startTime datetime DEFAULT NULL COMMENT '开始时间',
+	endTime datetime DEFAULT NULL COMMENT '结束时间',
+	createTime datetime NOT NULL COMMENT '创建时间',
+	PRIMARY KEY (backupRecordId),
+	KEY index_backupId (backupId),
+	KEY index_toolboxId (toolboxId),
+	KEY index_createTime (createTime)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='` + TableDatabaseBackupRecordComment + `';
+`},
+				Sqlite: []string{`
+CREATE TABLE ` + TableDatabaseBackupRecord + ` (
+	backupRecordId bigint(20) NOT NULL,
+	backupId bigint(20) NOT NULL,
+	toolboxId bigint(20) NOT NULL,
+	triggerType varchar(20) DEFAULT NULL,
+	place varchar(20) DEFAULT NULL,
+	placeId varchar(100) DEFAULT NULL,
+	filePath varchar(1000) DEFAULT NULL,
+	fileSize bigint(20) DEFAULT 0,
+	status int(10) DEFAULT 1,
+	error text DEFAULT NULL,
+	ownerCount int(10) DEFAULT 0,
+	tableCount int(10) DEFAULT 0,
+	dataCount int(10) DEFAULT 0,
+	useTime bigint(20) DEFAULT 0,
+	startTime datetime DEFAULT NULL,
+	endTime datetime DEFAULT NULL,
+	createTime datetime NOT NULL,
+	PRIMARY KEY (backupRecordId)
+);
+`,
+					`CREATE INDEX ` + TableDatabaseBackupRecord + `_index_backupId on ` + TableDatabaseBackupRecord + ` (backupId);`,
+					`CREATE INDEX ` + TableDatabaseBackupRecord + `_index_toolboxId on ` + TableDatabaseBackupRecord + ` (toolboxId);`,
+					`CREATE INDEX ` + TableDatabaseBackupRecord + `_index_createTime on ` + TableDatabaseBackupRecord + ` (createTime);`,
+				},
+			},
+		},
+		// 创建 数据库备份记录 表 结束
+	}
+}
@@ -0,0 +1,436 @@
+package module_database
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/team-ide/go-dialect/dialect"
+	"github.com/team-ide/go-tool/util"
+	"regexp"
+	"strconv"
+	"strings"
+	"teamide/internal/module/module_toolbox"
+)
+
+// MaskingRule 脱敏 规则
+// Column 支持 字段名、表名.字段名，可使用 * 通配，不区分 大小写
+type MaskingRule struct {
+	Column string `json:"column,omitempty"`
+	Method string `json:"method,omitempty"` // hash、partial、fixed、nullify、fake
+	Value  string `json:"value,omitempty"`  // partial：保留 前后 位数 如 3,4；fixed：替换值
+}
+
+type columnMaskingRule struct {
+	*MaskingRule
+	masker       *ColumnMasker
+	tableRegexp  *regexp.Regexp // 为空 匹配 所有 表
+	columnRegexp *regexp.Regexp
+	keepStart    int
+	keepEnd      int
+}
+
+// ColumnMasker 字段 脱敏，为 nil 时 不脱敏
+type ColumnMasker struct {
+	rules   []*columnMaskingRule
+	hashKey []byte // hash 方式 的 HMAC 密钥，每个 工具 不同
+}
+
+// NewColumnMasker 根据 规则 创建 脱敏器，没有 规则 返回 nil
+func NewColumnMasker(rules []*MaskingRule, hashKey []byte) (res *ColumnMasker, err error) {
+	masker := &ColumnMasker{
+		hashKey: hashKey,
+	}
+	for _, rule := range rules {
+		if rule == nil || strings.TrimSpace(rule.Column) == "" {
+			continue
+		}
+		one := &columnMaskingRule{
+			MaskingRule: rule,
+			masker:      masker,
+			keepStart:   -1,
+			keepEnd:     -1,
+		}
+		switch rule.Method {
+		case "hash":
+			if len(hashKey) == 0 {
+				err = errors.New("脱敏规则[" + rule.Column + "]哈希密钥不能为空")
+				return
+			}
+		case "fixed", "nullify", "fake":
+		case "", "partial":
+			rule.Method = "partial"
+			if rule.Value != "" {
+				ss := strings.Split(rule.Value, ",")
+				if len(ss) != 2 {
+					err = errors.New("脱敏规则[" + rule.Column + "]部分遮盖参数格式为：前保留位数,后保留位数")
+					return
+				}
+				one.keepStart, _ = strconv.Atoi(strings.TrimSpace(ss[0]))
+				one.keepEnd, _ = strconv.Atoi(strings.TrimSpace(ss[1]))
+			}
+		default:
+			err = errors.New("脱敏规则[" + rule.Column + "]方式[" + rule.Method + "]不支持")
+			return
+		}
+		column := strings.TrimSpace(rule.Column)
+		if index := strings.LastIndex(column, "."); index >= 0 {
+			one.tableRegexp = maskingPatternRegexp(column[:index])
+			column = column[index+1:]
+		}
+		one.columnRegexp = maskingPatternRegexp(column)
+		masker.rules = append(masker.rules, one)
+	}
+	if len(masker.rules) == 0 {
+		return
+	}
+	res = masker
+	return
+}
+
+// NewColumnMaskerByToolbox 根据 工具 配置 创建 脱敏器，hash 使用 工具 的 密钥
+func NewColumnMaskerByToolbox(toolboxService *module_toolbox.ToolboxService, toolbox *module_toolbox.ToolboxModel) (res *ColumnMasker, err error) {
+	if toolbox == nil {
+		return
+	}
+	databaseOption, err := ParseDatabaseOption(toolbox.Option)
+	if err != nil {
+		return
+	}
+	if len(databaseOption.MaskingRules) == 0 {
+		return
+	}
+	hashKey, err := toolboxService.GetToolboxSecret(toolbox.ToolboxId)
+	if err != nil {
+		return
+	}
+	res, err = NewColumnMasker(databaseOption.MaskingRules, hashKey)
+	return
+}
+
+func maskingPatternRegexp(pattern string) *regexp.Regexp {
+	pattern = regexp.QuoteMeta(strings.TrimSpace(pattern))
+	pattern = strings.ReplaceAll(pattern, `\*`, ".*")
+	return regexp.MustCompile("(?i)^" + pattern + "$")
+}
+
+// getRule 获取 字段 匹配 的 第一个 规则，表名 为空 表示 无法 确定 表，匹配 所有 表 的 规则
+func (this_ *ColumnMasker) getRule(tableName string, columnName string) *columnMaskingRule {
+	if this_ == nil {
+		return nil
+	}
+	for _, rule := range this_.rules {
+		if tableName != "" && rule.tableRegexp != nil && !rule.tableRegexp.MatchString(tableName) {
+			continue
+		}
+		if rule.columnRegexp.MatchString(columnName) {
+			return rule
+		}
+	}
+	return nil
+}
+
+// HasRule 表 中 是否 存在 需要 脱敏 的 字段，columnNames 为空 时 只要 存在 可能 匹配 该表 的 规则 即 返回 true
+func (this_ *ColumnMasker) HasRule(tableName string, columnNames []string) bool {
+	if this_ == nil {
+		return false
+	}
+	if len(columnNames) == 0 {
+		for _, rule := range this_.rules {
+			if tableName == "" || rule.tableRegexp == nil || rule.tableRegexp.MatchString(tableName) {
+				return true
+			}
+		}
+		return false
+	}
+	for _, columnName := range columnNames {
+		if this_.getRule(tableName, columnName) != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// MaskValue 对 单个 字段 值 脱敏，没有 匹配 的 规则 返回 原值
+func (this_ *ColumnMasker) MaskValue(tableName string, columnName string, value interface{}) interface{} {
+	rule := this_.getRule(tableName, columnName)
+	if rule == nil {
+		return value
+	}
+	return rule.mask(value)
+}
+
+// MaskDataList 对 数据 进行 脱敏，直接 修改 数据
+// columnSourceNames 字段 重命名 后 的 名称 对应 的 原 字段名，用于 匹配 规则
+func (this_ *ColumnMasker) MaskDataList(tableName string, dataList []map[string]interface{}, columnSourceNames map[string]string) {
+	if this_ == nil || len(dataList) == 0 {
+		return
+	}
+	var ruleCache = map[string]*columnMaskingRule{}
+	for _, data := range dataList {
+		for columnName, value := range data {
+			rule, find := ruleCache[columnName]
+			if !find {
+				sourceName := columnName
+				if columnSourceNames[columnName] != "" {
+					sourceName = columnSourceNames[columnName]
+				}
+				rule = this_.getRule(tableName, sourceName)
+				ruleCache[columnName] = rule
+			}
+			if rule == nil {
+				continue
+			}
+			data[columnName] = rule.mask(value)
+		}
+	}
+}
+
+// MaskExecuteList 对 SQL 执行 结果 中 的 查询 数据 脱敏，无法 确定 表，按 字段名 匹配 所有 表 的 规则
+// 别名 字段 按 SQL 中 的 原 字段 匹配，表达式、UNION 引用 脱敏 字段 时 无法 确定 来源，不 返回 数据
+func (this_ *ColumnMasker) MaskExecuteList(dialectType *dialect.Type, executeList []map[string]interface{}) {
+	if this_ == nil {
+		return
+	}
+	for _, executeData := range executeList {
+		dataList, ok := executeData["dataList"].([]map[string]interface{})
+		if !ok {
+			continue
+		}
+		sqlInfo, _ := executeData["sql"].(string)
+		aliasSourceNames, err := this_.selectAliasSourceNames(dialectType, sqlInfo)
+		if err != nil {
+			executeData["dataList"] = []map[string]interface{}{}
+			executeData["error"] = err.Error()
+			continue
+		}
+		var columnSourceNames = map[string]string{}
+		for _, data := range dataList {
+			for columnName := range data {
+				if sourceName, find := aliasSourceNames[strings.ToUpper(columnName)]; find {
+					columnSourceNames[columnName] = sourceName
+				}
+			}
+			break
+		}
+		this_.MaskDataList("", dataList, columnSourceNames)
+	}
+}
+
+// selectAliasSourceNames 分析 查询 SQL 中 字段 别名 对应 的 脱敏 字段，返回 大写 别名 对应 的 原 字段名
+// 表达式、UNION 等 引用 了 脱敏 字段 的 返回 错误
+func (this_ *ColumnMasker) selectAliasSourceNames(dialectType *dialect.Type, sqlInfo string) (res map[string]string, err error) {
+	tokens, err := sqlTokenize(dialectType, sqlInfo)
+	if err != nil {
+		err = errors.New("SQL解析失败，无法脱敏：" + err.Error())
+		return
+	}
+	var items [][]*sqlToken
+	var hasSetOperation bool
+	for i, token := range tokens {
+		if token.is("UNION", "INTERSECT", "EXCEPT", "MINUS") {
+			hasSetOperation = true
+		}
+		if token.is("SELECT") {
+			items = append(items, selectItems(tokens[i+1:], token.depth)...)
+		}
+	}
+
+	var aliases = map[string]string{}
+	var expressions [][]*sqlToken
+	for _, item := range items {
+		alias, expression := splitSelectAlias(item)
+		if len(expression) == 0 {
+			continue
+		}
+		last := expression[len(expression)-1]
+		isColumn := last.isName() && (len(expression) == 1 || expression[len(expression)-2].is("."))
+		if alias != "" && isColumn {
+			aliases[strings.ToUpper(alias)] = last.name()
+			continue
+		}
+		if !isColumn || hasSetOperation {
+			expressions = append(expressions, expression)
+		}
+	}
+	// sourceName 解析 别名，子查询 中 的 别名 可能 被 外层 再次 使用
+	var sourceName = func(name string) string {
+		for i := 0; i < len(aliases); i++ {
+			find, ok := aliases[strings.ToUpper(name)]
+			if !ok || strings.EqualFold(find, name) {
+				break
+			}
+			name = find
+		}
+		return name
+	}
+	for _, expression := range expressions {
+		for _, token := range expression {
+			if !token.isName() {
+				continue
+			}
+			name := sourceName(token.name())
+			if this_.getRule("", name) != nil {
+				err = errors.New("查询中的表达式或合并查询引用了脱敏字段[" + name + "]，无法脱敏，请直接查询该字段")
+				return
+			}
+		}
+	}
+	res = map[string]string{}
+	for alias, name := range aliases {
+		name = sourceName(name)
+		if this_.getRule("", name) != nil {
+			res[alias] = name
+		}
+	}
+	return
+}
+
+// selectItems 获取 SELECT 后 到 FROM 前 的 字段 列表，按 同 层级 的 逗号 分割
+func selectItems(tokens []*sqlToken, depth int) (items [][]*sqlToken) {
+	var item []*sqlToken
+	for _, token := range tokens {
+		if token.depth < depth || (token.depth == depth && (token.is("FROM", "INTO", "WHERE", "UNION", "INTERSECT", "EXCEPT", "MINUS", "ORDER", "LIMIT", "GROUP", "HAVING") || token.is(";"))) {
+			break
+		}
+		if token.kind == sqlTokenComment {
+			continue
+		}
+		if token.depth == depth && token.is(",") {
+			items = append(items, item)
+			item = nil
+			continue
+		}
+		if len(item) == 0 && token.depth == depth && token.is("DISTINCT", "ALL") {
+			continue
+		}
+		item = append(item, token)
+	}
+	if len(item) > 0 {
+		items = append(items, item)
+	}
+	return
+}
+
+// splitSelectAlias 拆分 字段 的 别名，如 "phone AS p"、"u.phone p"
+func splitSelectAlias(item []*sqlToken) (alias string, expression []*sqlToken) {
+	expression = item
+	size := len(item)
+	if size < 2 || !item[size-1].isName() {
+		return
+	}
+	prev := item[size-2]
+	if prev.is("AS") {
+		alias = item[size-1].name()
+		expression = item[:size-2]
+		return
+	}
+	if prev.is(")") || prev.isName() || prev.kind == sqlTokenString || prev.kind == sqlTokenNumber {
+		alias = item[size-1].name()
+		expression = item[:size-1]
+	}
+	return
+}
+
+func (this_ *columnMaskingRule) mask(value interface{}) interface{} {
+	if value == nil {
+		return nil
+	}
+	switch this_.Method {
+	case "nullify":
+		return nil
+	case "fixed":
+		return this_.Value
+	}
+	str := maskingStringValue(value)
+	switch this_.Method {
+	case "hash":
+		mac := hmac.New(sha256.New, this_.masker.hashKey)
+		mac.Write([]byte(str))
+		return hex.EncodeToString(mac.Sum(nil))
+	case "fake":
+		res := maskingFake(str)
+		// 数值 类型 伪造 后 仍 返回 数值
+		switch value.(type) {
+		case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+			if v, err := strconv.ParseInt(res, 10, 64); err == nil {
+				return v
+			}
+		case float32, float64:
+			if v, err := strconv.ParseFloat(res, 64); err == nil {
+				return v
+			}
+		}
+		return res
+	}
+	return maskingPartial(str, this_.keepStart, this_.keepEnd)
+}
+
+func maskingStringValue(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case []byte:
+		return string(v)
+	}
+	return util.GetStringValue(value)
+}
+
+// maskingPartial 部分 遮盖，如 13812341234 遮盖 为 138****1234
+func maskingPartial(str string, keepStart int, keepEnd int) string {
+	rs := []rune(str)
+	size := len(rs)
+	if keepStart < 0 || keepEnd < 0 {
+		if size >= 7 {
+			keepStart, keepEnd = 3, 4
+		} else {
+			keepStart, keepEnd = 1, 1
+		}
+	}
+	if keepStart+keepEnd >= size {
+		if size > 2 {
+			keepStart, keepEnd = 1, 1
+		} else {
+			keepStart, keepEnd = 0, 0
+		}
+	}
+	for i := keepStart; i < size-keepEnd; i++ {
+		rs[i] = '*'
+	}
+	return string(rs)
+}
+
+// maskingFake 保留 格式 伪造，数字 替换 为 数字，字母 替换 为 字母，其它 字符 不变
+// 相同 的 值 伪造 结果 相同，保证 脱敏 后 数据 关联 关系 不变
+func maskingFake(str string) string {
+	seed := sha256.Sum256([]byte(str))
+	var random []byte
+	var next = func(index int) byte {
+		for index >= len(random) {
+			bs := sha256.Sum256([]byte(fmt.Sprint(len(random), ":", string(seed[:]))))
+			random = append(random, bs[:]...)
+		}
+		return random[index]
+	}
+	rs := []rune(str)
+	var hasDigit bool
+	for i, r := range rs {
+		b := next(i)
+		switch {
+		case r >= '0' && r <= '9':
+			// 首个 数字 不为 0 时 伪造 结果 也 不为 0，避免 数值 位数 变化
+			if !hasDigit && r != '0' {
+				rs[i] = rune('1' + b%9)
+			} else {
+				rs[i] = rune('0' + b%10)
+			}
+			hasDigit = true
+		case r >= 'a' && r <= 'z':
+			rs[i] = rune('a' + b%26)
+		case r >= 'A' && r <= 'Z':
+			rs[i] = rune('A' + b%26)
+		}
+	}
+	return string(rs)
+}
@@ -0,0 +1,109 @@
+package module_database
+
+import "time"
+
+const (
+	// ModuleDatabaseSqlHistory 数据库SQL历史模块
+	ModuleDatabaseSqlHistory = "database_sql_history"
+	// TableDatabaseSqlHistory 数据库SQL历史表
+	TableDatabaseSqlHistory        = "TM_DATABASE_SQL_HISTORY"
+	TableDatabaseSqlHistoryComment = "数据库SQL历史"
+
+	// ModuleDatabaseDataEdit 数据库数据编辑记录模块
+	ModuleDatabaseDataEdit = "database_data_edit"
+	// TableDatabaseDataEdit 数据库数据编辑记录表
+	TableDatabaseDataEdit        = "TM_DATABASE_DATA_EDIT"
+	TableDatabaseDataEditComment = "数据库数据编辑记录"
+
+	// ModuleDatabaseBackup 数据库备份模块
+	ModuleDatabaseBackup = "database_backup"
+	// TableDatabaseBackup 数据库备份任务表
+	TableDatabaseBackup        = "TM_DATABASE_BACKUP"
+	TableDatabaseBackupComment = "数据库备份任务"
+	// TableDatabaseBackupRecord 数据库备份记录表
+	TableDatabaseBackupRecord        = "TM_DATABASE_BACKUP_RECORD"
+	TableDatabaseBackupRecordComment = "数据库备份记录"
+)
+
+// SqlHistoryModel 数据库SQL历史，收藏后 作为 保存的查询
+type SqlHistoryModel struct {
+	SqlHistoryId int64     `json:"sqlHistoryId,omitempty"`
+	ToolboxId    int64     `json:"toolboxId,omitempty"`
+	UserId       int64     `json:"userId,omitempty"`
+	UserName     string    `json:"userName,omitempty"`
+	UserAccount  string    `json:"userAccount,omitempty"`
+	OwnerName    string    `json:"ownerName,omitempty"`
+	ExecuteType  string    `json:"executeType,omitempty"`
+	ExecuteSql   string    `json:"executeSql,omitempty"`
+	UseTime      int64     `json:"useTime,omitempty"`
+	RowCount     int64     `json:"rowCount,omitempty"`
+	Error        string    `json:"error,omitempty"`
+	Saved        int8      `json:"saved,omitempty"`  // 1：已保存 2：未保存
+	Name         string    `json:"name,omitempty"`   // 保存的查询 名称
+	Tags         string    `json:"tags,omitempty"`   // 标签 多个 使用 英文逗号 隔开
+	Shared       int8      `json:"shared,omitempty"` // 1：共享给 可以 查看 该工具 的 用户 2：不共享
+	CreateTime   time.Time `json:"createTime,omitempty"`
+	UpdateTime   time.Time `json:"updateTime,omitempty"`
+}
+
+// DataEditModel 数据库数据编辑记录，保存 表格 编辑 前 的 数据，用于 撤销
+type DataEditModel struct {
+	DataEditId  int64     `json:"dataEditId,omitempty"`
+	ToolboxId   int64     `json:"toolboxId,omitempty"`
+	UserId      int64     `json:"userId,omitempty"`
+	UserName    string    `json:"userName,omitempty"`
+	UserAccount string    `json:"userAccount,omitempty"`
+	OwnerName   string    `json:"ownerName,omitempty"`
+	TableName   string    `json:"tableName,omitempty"`
+	InsertCount int       `json:"insertCount,omitempty"`
+	UpdateCount int       `json:"updateCount,omitempty"`
+	DeleteCount int       `json:"deleteCount,omitempty"`
+	ExecuteSql  string    `json:"executeSql,omitempty"`
+	UndoData    string    `json:"undoData,omitempty"` // 撤销 数据 JSON
+	Status      int8      `json:"status,omitempty"`   // 1：已执行 2：已撤销
+	UndoSql     string    `json:"undoSql,omitempty"`
+	UndoTime    time.Time `json:"undoTime,omitempty"`
+	CreateTime  time.Time `json:"createTime,omitempty"`
+}
+
+// BackupModel 数据库备份任务，按 定时 规则 导出 SQL 并 压缩 保存
+type BackupModel struct {
+	BackupId    int64     `json:"backupId,omitempty"`
+	ToolboxId   int64     `json:"toolboxId,omitempty"`
+	Name        string    `json:"name,omitempty"`
+	Spec        string    `json:"spec,omitempty"`        // 定时 规则，秒 分 时 日 月 周
+	Owners      string    `json:"owners,omitempty"`      // 备份 的 库 和 表 JSON
+	Place       string    `json:"place,omitempty"`       // 保存 位置，server：服务 数据 目录，local、ssh、node：文件 管理 位置
+	PlaceId     string    `json:"placeId,omitempty"`     // ssh 为 工具 ID，node 为 节点 ID
+	PlaceDir    string    `json:"placeDir,omitempty"`    // 文件 管理 位置 的 目录
+	RetainCount int       `json:"retainCount,omitempty"` // 保留 个数，0 不限制
+	RetainDays  int       `json:"retainDays,omitempty"`  // 保留 天数，0 不限制
+	Enabled     int8      `json:"enabled,omitempty"`     // 1：启用 2：停用
+	UserId      int64     `json:"userId,omitempty"`
+	UserName    string    `json:"userName,omitempty"`
+	UserAccount string    `json:"userAccount,omitempty"`
+	LastRunTime time.Time `json:"lastRunTime,omitempty"`
+	CreateTime  time.Time `json:"createTime,omitempty"`
+	UpdateTime  time.Time `json:"updateTime,omitempty"`
+}
+
+// BackupRecordModel 数据库备份记录，每次 备份 生成 一个 文件
+type BackupRecordModel struct {
+	BackupRecordId int64     `json:"backupRecordId,omitempty"`
+	BackupId       int64     `json:"backupId,omitempty"`
+	ToolboxId      int64     `json:"toolboxId,omitempty"`
+	TriggerType    string    `json:"triggerType,omitempty"` // schedule：定时 manual：手动
+	Place          string    `json:"place,omitempty"`
+	PlaceId        string    `json:"placeId,omitempty"`
+	FilePath       string    `json:"filePath,omitempty"`
+	FileSize       int64     `json:"fileSize,omitempty"`
+	Status         int8      `json:"status,omitempty"` // 1：备份中 2：成功 3：失败
+	Error          string    `json:"error,omitempty"`
+	OwnerCount     int       `json:"ownerCount,omitempty"`
+	TableCount     int       `json:"tableCount,omitempty"`
+	DataCount      int       `json:"dataCount,omitempty"`
+	UseTime        int64     `json:"useTime,omitempty"`
+	StartTime      time.Time `json:"startTime,omitempty"`
+	EndTime        time.Time `json:"endTime,omitempty"`
+	CreateTime     time.Time `json:"createTime,omitempty"`
+}
@@ -0,0 +1,99 @@
+package module_database
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+	"teamide/internal/module/module_toolbox"
+	"teamide/pkg/base"
+)
+
+// DatabaseOption 数据库 工具 配置 中 除 连接 信息 外 的 扩展 配置
+type DatabaseOption struct {
+	MaskingRules     []*MaskingRule `json:"maskingRules,omitempty"`
+	StatementTimeout optionInt      `json:"statementTimeout,omitempty"` // 默认 语句 超时 秒，0 不限制
+	Protected        optionBool     `json:"protected,omitempty"`        // 生产 保护，危险 操作 需要 确认
+	AnalyzeRules     []*AnalyzeRule `json:"analyzeRules,omitempty"`     // SQL 分析 规则 级别
+	LargeTableRows   optionInt      `json:"largeTableRows,omitempty"`   // SQL 分析 的 大表 行数，默认 100000
+}
+
+// optionInt 配置 中 的 数字，表单 可能 保存 为 字符串
+type optionInt int64
+
+func (this_ *optionInt) UnmarshalJSON(bs []byte) (err error) {
+	str := strings.Trim(strings.TrimSpace(string(bs)), `"`)
+	if str == "" || str == "null" {
+		*this_ = 0
+		return
+	}
+	v, err := strconv.ParseInt(str, 10, 64)
+	if err != nil {
+		return
+	}
+	*this_ = optionInt(v)
+	return
+}
+
+// optionBool 配置 中 的 开关，表单 可能 保存 为 字符串
+type optionBool bool
+
+func (this_ *optionBool) UnmarshalJSON(bs []byte) (err error) {
+	str := strings.ToLower(strings.Trim(strings.TrimSpace(string(bs)), `"`))
+	*this_ = optionBool(str == "true" || str == "1")
+	return
+}
+
+// ParseDatabaseOption 解析 工具 配置
+func ParseDatabaseOption(option string) (res *DatabaseOption, err error) {
+	res = &DatabaseOption{}
+	if option == "" {
+		return
+	}
+	err = json.Unmarshal([]byte(option), res)
+	if err != nil {
+		return
+	}
+	return
+}
+
+// getToolboxModel 获取 当前 请求 的 工具，测试 连接 时 工具 信息 来自 请求，有 工具 ID 的 以 保存 的 工具 为准
+func (this_ *api) getToolboxModel(requestBean *base.RequestBean) (res *module_toolbox.ToolboxModel, err error) {
+	if v := requestBean.GetExtend("toolboxModel"); v != nil {
+		res = v.(*module_toolbox.ToolboxModel)
+	}
+	if res == nil || res.ToolboxId == 0 {
+		return
+	}
+	find, err := this_.toolboxService.Get(res.ToolboxId)
+	if err != nil {
+		return
+	}
+	if find != nil {
+		res = find
+	}
+	return
+}
+
+// getDatabaseOption 获取 当前 请求 工具 的 扩展 配置，需要 在 getConfig 之后 调用
+func (this_ *api) getDatabaseOption(requestBean *base.RequestBean) (res *DatabaseOption, err error) {
+	toolbox, err := this_.getToolboxModel(requestBean)
+	if err != nil {
+		return
+	}
+	option := ""
+	if toolbox != nil {
+		option = toolbox.Option
+	}
+	res, err = ParseDatabaseOption(option)
+	return
+}
+
+// getColumnMasker 获取 当前 请求 工具 配置 的 脱敏器，未配置 脱敏 规则 返回 nil
+func (this_ *api) getColumnMasker(requestBean *base.RequestBean) (res *ColumnMasker, err error) {
+	toolbox, err := this_.getToolboxModel(requestBean)
+	if err != nil {
+		return
+	}
+	res, err = NewColumnMaskerByToolbox(this_.toolboxService, toolbox)
+	return
+}
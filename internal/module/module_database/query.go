@@ -0,0 +1,406 @@
+package module_database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/team-ide/go-dialect/dialect"
+	"github.com/team-ide/go-tool/db"
+	"github.com/team-ide/go-tool/util"
+	"go.uber.org/zap"
+	"regexp"
+	"strings"
+	"sync"
+	"teamide/pkg/base"
+	"time"
+)
+
+// QueryCancelRequest 取消 查询，QueryId 为空 时 取消 工作区 中 当前 用户 正在 执行 的 查询
+type QueryCancelRequest struct {
+	ToolboxId int64  `json:"toolboxId,omitempty"`
+	WorkerId  string `json:"workerId,omitempty"`
+	QueryId   string `json:"queryId,omitempty"`
+}
+
+// QueryInfo 正在 执行 的 查询
+type QueryInfo struct {
+	QueryId   string `json:"queryId"`
+	ToolboxId int64  `json:"toolboxId"`
+	WorkerId  string `json:"workerId"`
+	SessionId string `json:"sessionId,omitempty"` // 数据库 会话 ID，用于 在 服务端 取消 执行
+	Sql       string `json:"sql,omitempty"`       // 当前 执行 的 SQL
+	StartTime int64  `json:"startTime"`
+	IsCancel  bool   `json:"isCancel"`
+	IsTimeout bool   `json:"isTimeout"`
+}
+
+type runningQuery struct {
+	*QueryInfo
+	userId  int64
+	service db.IService
+	dia     dialect.Dialect
+	// 为空 时 只 在 服务端 取消，事务 中 的 查询 不能 取消 上下文，否则 连接 关闭 事务 丢失
+	cancel  context.CancelFunc
+	timeout time.Duration
+	locker  sync.Mutex
+}
+
+func newRunningQuery(requestBean *base.RequestBean, request *BaseRequest, service db.IService, timeout time.Duration) *runningQuery {
+	q := &runningQuery{
+		QueryInfo: &QueryInfo{
+			QueryId:   util.GetUUID(),
+			ToolboxId: request.ToolboxId,
+			WorkerId:  request.WorkerId,
+			StartTime: util.GetNowMilli(),
+		},
+		service: service,
+		dia:     service.GetDialect(),
+		timeout: timeout,
+	}
+	if requestBean.JWT != nil {
+		q.userId = requestBean.JWT.UserId
+	}
+	return q
+}
+
+func (this_ *runningQuery) info() (res *QueryInfo) {
+	this_.locker.Lock()
+	defer this_.locker.Unlock()
+	info := *this_.QueryInfo
+	res = &info
+	return
+}
+
+// stop 取消 执行，取消 上下文 并 在 服务端 取消 当前 语句
+func (this_ *runningQuery) stop(isTimeout bool) {
+	this_.locker.Lock()
+	if this_.IsCancel {
+		this_.locker.Unlock()
+		return
+	}
+	this_.IsCancel = true
+	this_.IsTimeout = isTimeout
+	sessionId := this_.SessionId
+	cancel := this_.cancel
+	this_.locker.Unlock()
+
+	if cancel != nil {
+		cancel()
+	}
+	cancelSql := queryCancelSql(this_.dia, sessionId)
+	if cancelSql == "" {
+		return
+	}
+	ctx, ctxCancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer ctxCancel()
+	_, err := this_.service.GetDb().ExecContext(ctx, cancelSql)
+	if err != nil {
+		util.Logger.Error("query cancel error", zap.Any("queryId", this_.QueryId), zap.Any("cancelSql", cancelSql), zap.Error(err))
+	}
+}
+
+func (this_ *runningQuery) isStop() bool {
+	this_.locker.Lock()
+	defer this_.locker.Unlock()
+	return this_.IsCancel
+}
+
+// execute 执行 单条 SQL，超过 超时 时间 自动 取消
+func (this_ *runningQuery) execute(ctx context.Context, executor sqlExecutor, executeSql string, selectDataMax int) (executeData map[string]interface{}, isChange bool, err error) {
+	this_.locker.Lock()
+	this_.Sql = executeSql
+	this_.locker.Unlock()
+
+	var timer *time.Timer
+	if this_.timeout > 0 {
+		timer = time.AfterFunc(this_.timeout, func() {
+			this_.stop(true)
+		})
+	}
+	executeData, isChange, err = executeStatement(ctx, executor, executeSql, selectDataMax)
+	if timer != nil {
+		timer.Stop()
+	}
+	if err != nil {
+		if info := this_.info(); info.IsTimeout {
+			err = errors.New(fmt.Sprint("执行超过", this_.timeout.Seconds(), "秒，已取消"))
+			executeData["error"] = err.Error()
+		} else if info.IsCancel {
+			err = errors.New("执行已取消")
+			executeData["error"] = err.Error()
+		}
+	}
+	return
+}
+
+// querySessionId 查询 连接 的 会话 ID，不支持 的 数据库 返回 空，只 通过 取消 上下文 取消
+func querySessionId(ctx context.Context, executor sqlExecutor, dia dialect.Dialect) (sessionId string) {
+	var querySql string
+	switch dia.DialectType() {
+	case dialect.TypeMysql:
+		querySql = "SELECT CONNECTION_ID()"
+	case dialect.TypePostgresql, dialect.TypeKingBase, dialect.TypeOpenGauss:
+		querySql = "SELECT pg_backend_pid()"
+	case dialect.TypeOracle:
+		// 需要 V$SESSION 查询 权限
+		querySql = "SELECT SID || ',' || SERIAL# FROM V$SESSION WHERE SID = SYS_CONTEXT('USERENV', 'SID')"
+	case dialect.TypeDM:
+		querySql = "SELECT SESSID()"
+	default:
+		return
+	}
+	rows, err := executor.QueryContext(ctx, querySql)
+	if err != nil {
+		util.Logger.Error("query session id error", zap.Any("querySql", querySql), zap.Error(err))
+		return
+	}
+	defer func() { _ = rows.Close() }()
+	if rows.Next() {
+		var id sql.NullString
+		if rows.Scan(&id) == nil && id.Valid && querySessionIdRegexp.MatchString(id.String) {
+			sessionId = id.String
+		}
+	}
+	return
+}
+
+// querySessionIdRegexp 会话 ID 拼接 到 取消 SQL 中，只 允许 数字，Oracle 为 SID,SERIAL#
+var querySessionIdRegexp = regexp.MustCompile(`^\d+(,\d+)?$`)
+
+// queryCancelSql 在 服务端 取消 会话 当前 执行 语句 的 SQL
+func queryCancelSql(dia dialect.Dialect, sessionId string) string {
+	if sessionId == "" {
+		return ""
+	}
+	switch dia.DialectType() {
+	case dialect.TypeMysql:
+		return "KILL QUERY " + sessionId
+	case dialect.TypePostgresql, dialect.TypeKingBase, dialect.TypeOpenGauss:
+		return "SELECT pg_cancel_backend(" + sessionId + ")"
+	case dialect.TypeOracle:
+		return "ALTER SYSTEM CANCEL SQL '" + sessionId + "'"
+	case dialect.TypeDM:
+		return "CALL SP_CANCEL_SESSION_OPERATION(" + sessionId + ")"
+	}
+	return ""
+}
+
+// executeQuery 可取消 的 执行 SQL，执行 结果 与 db.Service.ExecuteSQL 一致
+func executeQuery(q *runningQuery, param *db.Param, ownerName string, sqlContent string, options *db.ExecuteOptions) (executeList []map[string]interface{}, errStr string, err error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	q.locker.Lock()
+	q.cancel = cancel
+	q.locker.Unlock()
+
+	conn, err := newOwnerConn(ctx, q.service, param.ParamModel, ownerName)
+	if err != nil {
+		return
+	}
+	defer discardConn(conn)
+
+	sessionId := querySessionId(ctx, conn, q.dia)
+	q.locker.Lock()
+	q.SessionId = sessionId
+	q.locker.Unlock()
+
+	var executor sqlExecutor = conn
+	var hasError bool
+	if param.OpenTransaction {
+		var tx *sql.Tx
+		tx, err = conn.BeginTx(ctx, nil)
+		if err != nil {
+			return
+		}
+		defer func() {
+			if hasError {
+				_ = tx.Rollback()
+				return
+			}
+			if e := tx.Commit(); e != nil && !strings.Contains(e.Error(), "Not in transaction") {
+				err = e
+			}
+		}()
+		executor = tx
+	}
+
+	// 如果 是 mysql 开启 profiling
+	openProfiling := q.dia.DialectType() == dialect.TypeMysql && options.OpenProfiling
+	if openProfiling {
+		_, _ = executor.ExecContext(ctx, "SET profiling = 1")
+		defer func() {
+			_, _ = executor.ExecContext(ctx, "SET profiling = 0")
+		}()
+	}
+	sqlList := q.dia.SqlSplit(sqlContent)
+	var lastQueryId int
+	for _, executeSql := range sqlList {
+		if strings.TrimSpace(executeSql) == "" {
+			continue
+		}
+		if q.isStop() {
+			break
+		}
+		executeData, _, e := q.execute(ctx, executor, executeSql, options.SelectDataMax)
+		if openProfiling && !q.isStop() {
+			lastQueryId, executeData["profiling"], _ = queryProfiling(ctx, executor, lastQueryId)
+		}
+		executeList = append(executeList, executeData)
+		if e != nil {
+			util.Logger.Error("execute query error", zap.Any("queryId", q.QueryId), zap.Any("executeSql", executeSql), zap.Error(e))
+			errStr = e.Error()
+			hasError = true
+			// 取消 后 连接 已 不可用，不再 继续 执行
+			if !param.ErrorContinue || q.isStop() {
+				err = e
+				return
+			}
+		}
+	}
+	return
+}
+
+// queryProfiling 查询 mysql 最后 一条 语句 的 profiling
+func queryProfiling(ctx context.Context, executor sqlExecutor, lastQueryId int) (queryId int, profiling map[string]interface{}, err error) {
+	queryId = lastQueryId
+	rows, err := executor.QueryContext(ctx, "SHOW PROFILES")
+	if err != nil {
+		return
+	}
+	_, _, dataList, err := db.RowsToListMap(rows, 0)
+	_ = rows.Close()
+	if err != nil {
+		return
+	}
+	var data map[string]interface{}
+	for _, one := range dataList {
+		if one["Query_ID"] == nil {
+			continue
+		}
+		id := util.StringToInt(util.GetStringValue(one["Query_ID"]))
+		if lastQueryId < id {
+			queryId = id
+			data = one
+			break
+		}
+	}
+	if data == nil {
+		return
+	}
+	rows, err = executor.QueryContext(ctx, "SHOW PROFILE ALL FOR QUERY "+util.GetStringValue(data["Query_ID"]))
+	if err != nil {
+		return
+	}
+	_, columnList, dataList, err := db.RowsToListMap(rows, 0)
+	_ = rows.Close()
+	if err != nil {
+		return
+	}
+	data["columnList"] = columnList
+	data["profileDataList"] = dataList
+	profiling = data
+	return
+}
+
+var runningQueryCache = map[string]*runningQuery{}
+var runningQueryCacheLock = &sync.Mutex{}
+
+func addRunningQuery(q *runningQuery) {
+	runningQueryCacheLock.Lock()
+	defer runningQueryCacheLock.Unlock()
+
+	runningQueryCache[q.QueryId] = q
+}
+
+func getRunningQuery(queryId string) *runningQuery {
+	runningQueryCacheLock.Lock()
+	defer runningQueryCacheLock.Unlock()
+
+	return runningQueryCache[queryId]
+}
+
+func removeRunningQuery(queryId string) {
+	runningQueryCacheLock.Lock()
+	defer runningQueryCacheLock.Unlock()
+
+	delete(runningQueryCache, queryId)
+}
+
+// getUserRunningQueries 获取 用户 在 工作区 中 正在 执行 的 查询
+func getUserRunningQueries(userId int64, toolboxId int64, workerId string) (list []*runningQuery) {
+	if workerId == "" {
+		return
+	}
+	runningQueryCacheLock.Lock()
+	defer runningQueryCacheLock.Unlock()
+
+	for _, q := range runningQueryCache {
+		if q.userId == userId && q.ToolboxId == toolboxId && q.WorkerId == workerId {
+			list = append(list, q)
+		}
+	}
+	return
+}
+
+// cancelWorkerQuery 关闭 工作区 时 取消 正在 执行 的 查询
+func cancelWorkerQuery(workerId string) {
+	var list []*runningQuery
+	runningQueryCacheLock.Lock()
+	for _, q := range runningQueryCache {
+		if q.WorkerId == workerId {
+			list = append(list, q)
+		}
+	}
+	runningQueryCacheLock.Unlock()
+
+	for _, q := range list {
+		q.stop(false)
+	}
+}
+
+// getStatementTimeout 获取 工具 配置 的 默认 语句 超时 时间
+func (this_ *api) getStatementTimeout(requestBean *base.RequestBean) (timeout time.Duration, err error) {
+	databaseOption, err := this_.getDatabaseOption(requestBean)
+	if err != nil {
+		return
+	}
+	if databaseOption.StatementTimeout > 0 {
+		timeout = time.Duration(databaseOption.StatementTimeout) * time.Second
+	}
+	return
+}
+
+func (this_ *api) queryCancel(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	var request = &QueryCancelRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	var userId int64
+	if requestBean.JWT != nil {
+		userId = requestBean.JWT.UserId
+	}
+	if request.QueryId == "" {
+		list := getUserRunningQueries(userId, request.ToolboxId, request.WorkerId)
+		var infoList []*QueryInfo
+		for _, q := range list {
+			q.stop(false)
+			infoList = append(infoList, q.info())
+		}
+		res = infoList
+		return
+	}
+	q := getRunningQuery(request.QueryId)
+	if q == nil {
+		err = errors.New("查询不存在或已执行结束")
+		return
+	}
+	if q.ToolboxId != request.ToolboxId || q.userId != userId {
+		err = errors.New("查询不属于当前工具或用户，无法取消")
+		return
+	}
+	q.stop(false)
+	res = q.info()
+	return
+}
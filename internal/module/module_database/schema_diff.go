@@ -0,0 +1,445 @@
+package module_database
+
+import (
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/team-ide/go-dialect/dialect"
+	"github.com/team-ide/go-dialect/worker"
+	"github.com/team-ide/go-tool/db"
+	"sort"
+	"strings"
+	"teamide/pkg/base"
+)
+
+type SchemaDiffRequest struct {
+	SourceToolboxId int64    `json:"sourceToolboxId,omitempty"`
+	SourceOwnerName string   `json:"sourceOwnerName,omitempty"`
+	TargetToolboxId int64    `json:"targetToolboxId,omitempty"`
+	TargetOwnerName string   `json:"targetOwnerName,omitempty"`
+	TableNames      []string `json:"tableNames,omitempty"`
+	IgnoreComment   bool     `json:"ignoreComment,omitempty"`
+	IgnoreDefault   bool     `json:"ignoreDefault,omitempty"`
+	AllowDrop       bool     `json:"allowDrop,omitempty"` // 是否生成 删除 表、字段、索引 的语句
+}
+
+const (
+	diffTypeCreate = "create"
+	diffTypeDrop   = "drop"
+	diffTypeAlter  = "alter"
+)
+
+type SchemaDiffResult struct {
+	SourceDialect string       `json:"sourceDialect"`
+	TargetDialect string       `json:"targetDialect"`
+	TableList     []*TableDiff `json:"tableList"`
+	SqlList       []string     `json:"sqlList"`
+	ManualList    []string     `json:"manualList,omitempty"`
+}
+
+type TableDiff struct {
+	TableName         string        `json:"tableName"`
+	DiffType          string        `json:"diffType"`
+	TableComment      string        `json:"tableComment,omitempty"`
+	OldTableComment   string        `json:"oldTableComment,omitempty"`
+	ColumnList        []*ColumnDiff `json:"columnList,omitempty"`
+	IndexList         []*IndexDiff  `json:"indexList,omitempty"`
+	PrimaryKeyChanged bool          `json:"primaryKeyChanged,omitempty"`
+	PrimaryKeys       []string      `json:"primaryKeys,omitempty"`
+	OldPrimaryKeys    []string      `json:"oldPrimaryKeys,omitempty"`
+	SqlList           []string      `json:"sqlList,omitempty"`
+	ManualList        []string      `json:"manualList,omitempty"` // 未生成 语句 需 手动 处理 的 变更
+
+	source *dialect.TableModel
+	target *dialect.TableModel
+}
+
+type ColumnDiff struct {
+	ColumnName string               `json:"columnName"`
+	DiffType   string               `json:"diffType"`
+	Changes    []string             `json:"changes,omitempty"`
+	Column     *dialect.ColumnModel `json:"column,omitempty"`
+	OldColumn  *dialect.ColumnModel `json:"oldColumn,omitempty"`
+}
+
+type IndexDiff struct {
+	IndexName string              `json:"indexName"`
+	DiffType  string              `json:"diffType"`
+	Index     *dialect.IndexModel `json:"index,omitempty"`
+	OldIndex  *dialect.IndexModel `json:"oldIndex,omitempty"`
+}
+
+func (this_ *api) schemaDiff(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	var request = &SchemaDiffRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	if request.SourceOwnerName == "" || request.TargetOwnerName == "" {
+		err = errors.New("源库和目标库不能为空")
+		return
+	}
+	sourceService, err := this_.getServiceByToolboxId(requestBean, request.SourceToolboxId)
+	if err != nil {
+		return
+	}
+	targetService, err := this_.getServiceByToolboxId(requestBean, request.TargetToolboxId)
+	if err != nil {
+		return
+	}
+	param := this_.getParam(requestBean, c)
+
+	sourceTables, err := loadSchemaTables(sourceService, param, request.SourceOwnerName, request.TableNames)
+	if err != nil {
+		return
+	}
+	targetTables, err := loadSchemaTables(targetService, param, request.TargetOwnerName, request.TableNames)
+	if err != nil {
+		return
+	}
+
+	differ := &schemaDiffer{
+		SchemaDiffRequest: request,
+		param:             param.ParamModel,
+		sourceDialect:     sourceService.GetDialect(),
+		targetDialect:     targetService.GetDialect(),
+	}
+	res, err = differ.diff(sourceTables, targetTables)
+	if err != nil {
+		return
+	}
+	return
+}
+
+func loadSchemaTables(service db.IService, param *db.Param, ownerName string, tableNames []string) (tables []*dialect.TableModel, err error) {
+	if len(tableNames) == 0 {
+		tables, err = worker.TablesDetail(service.GetDb(), service.GetDialect(), param.ParamModel, ownerName, false)
+		return
+	}
+	for _, tableName := range tableNames {
+		var table *dialect.TableModel
+		table, err = worker.TableDetail(service.GetDb(), service.GetDialect(), param.ParamModel, ownerName, tableName, false)
+		if err != nil {
+			return
+		}
+		if table != nil {
+			tables = append(tables, table)
+		}
+	}
+	return
+}
+
+type schemaDiffer struct {
+	*SchemaDiffRequest
+	param         *dialect.ParamModel
+	sourceDialect dialect.Dialect // 为 空 时 不 返回 源 库 类型
+	targetDialect dialect.Dialect
+}
+
+// diff 对比 源 和 目标 表结构，生成 目标 库 的 变更 SQL
+// SQL 顺序：新建表 -> 修改表（删索引、删主键、改字段、加主键、加索引、删字段） -> 删除表
+func (this_ *schemaDiffer) diff(sourceTables []*dialect.TableModel, targetTables []*dialect.TableModel) (res *SchemaDiffResult, err error) {
+	res = &SchemaDiffResult{
+		TargetDialect: this_.targetDialect.DialectType().Name,
+	}
+	if this_.sourceDialect != nil {
+		res.SourceDialect = this_.sourceDialect.DialectType().Name
+	}
+
+	targetCache := map[string]*dialect.TableModel{}
+	for _, one := range targetTables {
+		targetCache[strings.ToLower(one.TableName)] = one
+	}
+	sourceCache := map[string]*dialect.TableModel{}
+
+	var createList, alterList, dropList []*TableDiff
+	for _, source := range sourceTables {
+		key := strings.ToLower(source.TableName)
+		sourceCache[key] = source
+		target := targetCache[key]
+		tableDiff := &TableDiff{
+			TableName: source.TableName,
+			source:    source,
+			target:    target,
+		}
+		if target == nil {
+			tableDiff.DiffType = diffTypeCreate
+			tableDiff.TableComment = source.TableComment
+			tableDiff.SqlList, err = this_.targetDialect.TableCreateSql(this_.param, this_.TargetOwnerName, source)
+			if err != nil {
+				err = errors.New("table [" + source.TableName + "] create sql error:" + err.Error())
+				return
+			}
+			createList = append(createList, tableDiff)
+			continue
+		}
+		tableDiff.TableName = target.TableName
+		err = this_.diffTable(tableDiff)
+		if err != nil {
+			return
+		}
+		if tableDiff.DiffType != "" {
+			alterList = append(alterList, tableDiff)
+		}
+	}
+	for _, target := range targetTables {
+		if sourceCache[strings.ToLower(target.TableName)] != nil {
+			continue
+		}
+		tableDiff := &TableDiff{
+			TableName:       target.TableName,
+			DiffType:        diffTypeDrop,
+			OldTableComment: target.TableComment,
+			target:          target,
+		}
+		if this_.AllowDrop {
+			tableDiff.SqlList, err = this_.targetDialect.TableDeleteSql(this_.param, this_.TargetOwnerName, target.TableName)
+			if err != nil {
+				err = errors.New("table [" + target.TableName + "] delete sql error:" + err.Error())
+				return
+			}
+		}
+		dropList = append(dropList, tableDiff)
+	}
+
+	for _, list := range [][]*TableDiff{createList, alterList, dropList} {
+		sort.Slice(list, func(i, j int) bool {
+			return strings.ToLower(list[i].TableName) < strings.ToLower(list[j].TableName)
+		})
+		for _, one := range list {
+			res.TableList = append(res.TableList, one)
+			res.SqlList = append(res.SqlList, one.SqlList...)
+			for _, manual := range one.ManualList {
+				res.ManualList = append(res.ManualList, "表["+one.TableName+"]"+manual)
+			}
+		}
+	}
+	return
+}
+
+func (this_ *schemaDiffer) diffTable(tableDiff *TableDiff) (err error) {
+	source := tableDiff.source
+	target := tableDiff.target
+	ownerName := this_.TargetOwnerName
+	tableName := target.TableName
+
+	var dropIndexSql, dropPrimaryKeySql, columnSql, addPrimaryKeySql, addIndexSql, dropColumnSql, commentSql []string
+	var sqlList_ []string
+
+	if !this_.IgnoreComment && source.TableComment != target.TableComment {
+		tableDiff.TableComment = source.TableComment
+		tableDiff.OldTableComment = target.TableComment
+		sqlList_, err = this_.targetDialect.TableCommentSql(this_.param, ownerName, tableName, source.TableComment)
+		if err != nil {
+			return
+		}
+		commentSql = append(commentSql, sqlList_...)
+	}
+
+	// 字段
+	targetColumnCache := map[string]*dialect.ColumnModel{}
+	for _, one := range target.ColumnList {
+		targetColumnCache[strings.ToLower(one.ColumnName)] = one
+	}
+	sourceColumnCache := map[string]*dialect.ColumnModel{}
+	var lastColumnName string
+	for _, sourceColumn := range source.ColumnList {
+		key := strings.ToLower(sourceColumn.ColumnName)
+		sourceColumnCache[key] = sourceColumn
+		targetColumn := targetColumnCache[key]
+
+		column := copyColumn(sourceColumn)
+		column.ColumnAfterColumn = lastColumnName
+		if targetColumn == nil {
+			tableDiff.ColumnList = append(tableDiff.ColumnList, &ColumnDiff{
+				ColumnName: column.ColumnName,
+				DiffType:   diffTypeCreate,
+				Column:     column,
+			})
+			sqlList_, err = this_.targetDialect.ColumnAddSql(this_.param, ownerName, tableName, column)
+			if err != nil {
+				err = errors.New("table [" + tableName + "] column [" + column.ColumnName + "] add sql error:" + err.Error())
+				return
+			}
+			columnSql = append(columnSql, sqlList_...)
+			lastColumnName = column.ColumnName
+			continue
+		}
+		lastColumnName = targetColumn.ColumnName
+		// 保持 目标库 字段名称 避免 大小写 不同 导致 重命名
+		column.ColumnName = targetColumn.ColumnName
+		changes := this_.columnChanges(column, targetColumn)
+		if len(changes) == 0 {
+			continue
+		}
+		oldColumn := copyColumn(targetColumn)
+		oldColumn.ColumnAfterColumn = column.ColumnAfterColumn
+		tableDiff.ColumnList = append(tableDiff.ColumnList, &ColumnDiff{
+			ColumnName: column.ColumnName,
+			DiffType:   diffTypeAlter,
+			Changes:    changes,
+			Column:     column,
+			OldColumn:  targetColumn,
+		})
+		if this_.IgnoreComment {
+			column.ColumnComment = oldColumn.ColumnComment
+		}
+		sqlList_, err = this_.targetDialect.ColumnUpdateSql(this_.param, ownerName, tableName, oldColumn, column)
+		if err != nil {
+			err = errors.New("table [" + tableName + "] column [" + column.ColumnName + "] update sql error:" + err.Error())
+			return
+		}
+		columnSql = append(columnSql, sqlList_...)
+	}
+	for _, targetColumn := range target.ColumnList {
+		if sourceColumnCache[strings.ToLower(targetColumn.ColumnName)] != nil {
+			continue
+		}
+		tableDiff.ColumnList = append(tableDiff.ColumnList, &ColumnDiff{
+			ColumnName: targetColumn.ColumnName,
+			DiffType:   diffTypeDrop,
+			OldColumn:  targetColumn,
+		})
+		if this_.AllowDrop {
+			sqlList_, err = this_.targetDialect.ColumnDeleteSql(this_.param, ownerName, tableName, targetColumn.ColumnName)
+			if err != nil {
+				return
+			}
+			dropColumnSql = append(dropColumnSql, sqlList_...)
+		}
+	}
+
+	// 主键
+	if !sameNames(source.PrimaryKeys, target.PrimaryKeys) {
+		tableDiff.PrimaryKeyChanged = true
+		tableDiff.PrimaryKeys = source.PrimaryKeys
+		tableDiff.OldPrimaryKeys = target.PrimaryKeys
+		// 不允许 删除 时 不生成 删除 原主键 的 语句，新主键 也 无法 添加，提示 手动 处理
+		if len(target.PrimaryKeys) > 0 && !this_.AllowDrop {
+			tableDiff.ManualList = append(tableDiff.ManualList, "主键变更需删除原主键["+strings.Join(target.PrimaryKeys, ",")+"]，请手动处理")
+		} else if len(target.PrimaryKeys) > 0 {
+			sqlList_, err = this_.targetDialect.PrimaryKeyDeleteSql(this_.param, ownerName, tableName)
+			if err != nil {
+				return
+			}
+			dropPrimaryKeySql = append(dropPrimaryKeySql, sqlList_...)
+		}
+		if len(source.PrimaryKeys) > 0 && (len(target.PrimaryKeys) == 0 || this_.AllowDrop) {
+			sqlList_, err = this_.targetDialect.PrimaryKeyAddSql(this_.param, ownerName, tableName, source.PrimaryKeys)
+			if err != nil {
+				return
+			}
+			addPrimaryKeySql = append(addPrimaryKeySql, sqlList_...)
+		}
+	}
+
+	// 索引 按 索引字段 匹配，索引名称 在不同库中 可能 不一致
+	targetIndexCache := map[string]*dialect.IndexModel{}
+	for _, one := range target.IndexList {
+		targetIndexCache[indexKey(one)] = one
+	}
+	sourceIndexCache := map[string]*dialect.IndexModel{}
+	for _, sourceIndex := range source.IndexList {
+		key := indexKey(sourceIndex)
+		sourceIndexCache[key] = sourceIndex
+		if targetIndexCache[key] != nil {
+			continue
+		}
+		tableDiff.IndexList = append(tableDiff.IndexList, &IndexDiff{
+			IndexName: sourceIndex.IndexName,
+			DiffType:  diffTypeCreate,
+			Index:     sourceIndex,
+		})
+		sqlList_, err = this_.targetDialect.IndexAddSql(this_.param, ownerName, tableName, sourceIndex)
+		if err != nil {
+			err = errors.New("table [" + tableName + "] index [" + sourceIndex.IndexName + "] add sql error:" + err.Error())
+			return
+		}
+		addIndexSql = append(addIndexSql, sqlList_...)
+	}
+	for _, targetIndex := range target.IndexList {
+		if sourceIndexCache[indexKey(targetIndex)] != nil {
+			continue
+		}
+		tableDiff.IndexList = append(tableDiff.IndexList, &IndexDiff{
+			IndexName: targetIndex.IndexName,
+			DiffType:  diffTypeDrop,
+			OldIndex:  targetIndex,
+		})
+		if this_.AllowDrop {
+			sqlList_, err = this_.targetDialect.IndexDeleteSql(this_.param, ownerName, tableName, targetIndex.IndexName)
+			if err != nil {
+				return
+			}
+			dropIndexSql = append(dropIndexSql, sqlList_...)
+		}
+	}
+
+	for _, one := range [][]string{dropIndexSql, dropPrimaryKeySql, columnSql, addPrimaryKeySql, addIndexSql, dropColumnSql, commentSql} {
+		tableDiff.SqlList = append(tableDiff.SqlList, one...)
+	}
+	if len(tableDiff.ColumnList) > 0 || len(tableDiff.IndexList) > 0 || tableDiff.PrimaryKeyChanged || len(commentSql) > 0 {
+		tableDiff.DiffType = diffTypeAlter
+	}
+	return
+}
+
+// columnChanges 对比 字段 属性，类型 使用 目标库 方言 格式化 后对比，兼容 不同 数据库 之间 对比
+func (this_ *schemaDiffer) columnChanges(column *dialect.ColumnModel, oldColumn *dialect.ColumnModel) (changes []string) {
+	columnType, e1 := this_.targetDialect.ColumnTypePack(column)
+	oldColumnType, e2 := this_.targetDialect.ColumnTypePack(oldColumn)
+	if e1 != nil || e2 != nil {
+		columnType = fmt.Sprintf("%s(%d,%d,%d)", column.ColumnDataType, column.ColumnLength, column.ColumnPrecision, column.ColumnScale)
+		oldColumnType = fmt.Sprintf("%s(%d,%d,%d)", oldColumn.ColumnDataType, oldColumn.ColumnLength, oldColumn.ColumnPrecision, oldColumn.ColumnScale)
+	}
+	if !strings.EqualFold(columnType, oldColumnType) {
+		changes = append(changes, "type")
+	}
+	if column.ColumnNotNull != oldColumn.ColumnNotNull {
+		changes = append(changes, "notNull")
+	}
+	if !this_.IgnoreDefault && formatColumnDefault(column.ColumnDefault) != formatColumnDefault(oldColumn.ColumnDefault) {
+		changes = append(changes, "default")
+	}
+	if !this_.IgnoreComment && column.ColumnComment != oldColumn.ColumnComment {
+		changes = append(changes, "comment")
+	}
+	return
+}
+
+func formatColumnDefault(columnDefault string) string {
+	columnDefault = strings.TrimSpace(columnDefault)
+	columnDefault = strings.Trim(columnDefault, "'")
+	if strings.EqualFold(columnDefault, "null") {
+		return ""
+	}
+	return columnDefault
+}
+
+func copyColumn(column *dialect.ColumnModel) *dialect.ColumnModel {
+	res := *column
+	return &res
+}
+
+func indexKey(index *dialect.IndexModel) string {
+	var names []string
+	for _, one := range index.ColumnNames {
+		names = append(names, strings.ToLower(one))
+	}
+	indexType := strings.ToLower(index.IndexType)
+	if indexType == "index" {
+		indexType = ""
+	}
+	return indexType + ":" + strings.Join(names, ",")
+}
+
+func sameNames(names []string, otherNames []string) bool {
+	if len(names) != len(otherNames) {
+		return false
+	}
+	for i := range names {
+		if !strings.EqualFold(names[i], otherNames[i]) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,433 @@
+package module_database
+
+import (
+	"errors"
+	"github.com/team-ide/go-dialect/dialect"
+	"strings"
+	"unicode"
+)
+
+// SQL 词 类型
+const (
+	sqlTokenWord    = iota + 1 // 关键字、名称
+	sqlTokenQuoted             // 引号 包含 的 名称
+	sqlTokenString             // 字符串
+	sqlTokenNumber             // 数字
+	sqlTokenSymbol             // 符号
+	sqlTokenComment            // 注释
+)
+
+type sqlToken struct {
+	kind  int
+	text  string
+	upper string // 关键字、名称 的 大写
+	depth int    // 所在 括号 层级
+	start int    // 在 SQL 中 的 开始 位置，按 字符 计算
+	end   int
+}
+
+func (this_ *sqlToken) is(words ...string) bool {
+	if this_ == nil || (this_.kind != sqlTokenWord && this_.kind != sqlTokenSymbol) {
+		return false
+	}
+	for _, word := range words {
+		if this_.upper == word {
+			return true
+		}
+	}
+	return false
+}
+
+// isName 是否 为 名称，关键字 不 作为 名称
+func (this_ *sqlToken) isName() bool {
+	if this_ == nil {
+		return false
+	}
+	if this_.kind == sqlTokenQuoted {
+		return true
+	}
+	return this_.kind == sqlTokenWord && !sqlKeywords[this_.upper]
+}
+
+// name 获取 名称，去掉 引号
+func (this_ *sqlToken) name() string {
+	if this_.kind == sqlTokenQuoted && len(this_.text) >= 2 {
+		return this_.text[1 : len(this_.text)-1]
+	}
+	return this_.text
+}
+
+// sqlKeywords 常用 关键字，格式化 时 转为 大写，分析 时 不 作为 名称
+var sqlKeywords = map[string]bool{}
+
+func init() {
+	for _, word := range strings.Fields(`SELECT FROM WHERE AND OR NOT IN IS NULL LIKE ILIKE BETWEEN EXISTS AS ON USING
+JOIN INNER LEFT RIGHT FULL OUTER CROSS NATURAL GROUP BY ORDER HAVING LIMIT OFFSET FETCH FIRST NEXT ROWS ROW ONLY TOP
+UNION ALL DISTINCT EXCEPT INTERSECT MINUS INSERT INTO VALUES UPDATE SET DELETE MERGE WITH RECURSIVE
+CASE WHEN THEN ELSE END ASC DESC CREATE ALTER DROP TRUNCATE TABLE VIEW INDEX PRIMARY KEY FOREIGN REFERENCES
+DEFAULT CONSTRAINT UNIQUE CHECK ADD COLUMN RENAME TO IF REPLACE DATABASE SCHEMA GRANT REVOKE CALL EXEC EXECUTE
+BEGIN COMMIT ROLLBACK TRUE FALSE INTERVAL OVER PARTITION WINDOW RETURNING FOR SHOW DESCRIBE EXPLAIN USE COMMENT`) {
+		sqlKeywords[word] = true
+	}
+}
+
+// sqlLexer 不同 数据库 字符串、注释 的 规则
+type sqlLexer struct {
+	backslashEscape   bool // 字符串 中 反斜杠 转义，MySQL
+	doubleQuoteString bool // 双引号 为 字符串，MySQL
+	hashComment       bool // # 开头 的 注释，MySQL
+	dashCommentSpace  bool // -- 后 需要 空白 才是 注释，MySQL
+	executableComment bool // /*! */ 中 的 内容 会 执行，MySQL
+	nestedComment     bool // 块 注释 可以 嵌套，PostgreSQL
+	escapeString      bool // E'' 字符串 中 反斜杠 转义，PostgreSQL
+	dollarQuote       bool // $tag$ $tag$ 字符串，PostgreSQL
+	qQuote            bool // q'[ ]' 字符串，Oracle
+	bracketQuote      bool // [] 包含 的 名称，SQLite
+	unknown           bool // 未知 数据库，字符串 中 有 反斜杠 时 无法 确定 是否 转义
+}
+
+func getSqlLexer(dialectType *dialect.Type) *sqlLexer {
+	switch dialectType {
+	case dialect.TypeMysql:
+		return &sqlLexer{backslashEscape: true, doubleQuoteString: true, hashComment: true, dashCommentSpace: true, executableComment: true}
+	case dialect.TypePostgresql, dialect.TypeKingBase, dialect.TypeOpenGauss:
+		return &sqlLexer{nestedComment: true, escapeString: true, dollarQuote: true}
+	case dialect.TypeOracle:
+		return &sqlLexer{qQuote: true}
+	case dialect.TypeSqlite:
+		return &sqlLexer{bracketQuote: true}
+	case dialect.TypeDM, dialect.TypeShenTong:
+		return &sqlLexer{}
+	}
+	return &sqlLexer{unknown: true}
+}
+
+// sqlTokenize 按 数据库 的 规则 将 SQL 拆分 为 词，记录 每个 词 所在 的 括号 层级，字符串、注释 未 结束 时 返回 错误
+func sqlTokenize(dialectType *dialect.Type, sqlInfo string) (tokens []*sqlToken, err error) {
+	lexer := getSqlLexer(dialectType)
+	rs := []rune(sqlInfo)
+	size := len(rs)
+	var depth int
+	var add = func(kind int, text string, start int, end int) {
+		token := &sqlToken{kind: kind, text: text, depth: depth, start: start, end: end}
+		if kind == sqlTokenWord || kind == sqlTokenSymbol {
+			token.upper = strings.ToUpper(text)
+		}
+		tokens = append(tokens, token)
+	}
+	// readQuoted 读取 引号 内 的 内容，返回 结束 引号 后 的 位置
+	var readQuoted = func(i int, quote rune, backslash bool) (int, error) {
+		for i++; i < size; i++ {
+			if backslash && rs[i] == '\\' {
+				i++
+				continue
+			}
+			if rs[i] == quote {
+				if i+1 < size && rs[i+1] == quote {
+					i++
+					continue
+				}
+				return i + 1, nil
+			}
+		}
+		return i, errors.New("SQL中字符串或名称没有结束")
+	}
+	for i := 0; i < size; {
+		r := rs[i]
+		start := i
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '-' && i+1 < size && rs[i+1] == '-' && (!lexer.dashCommentSpace || i+2 >= size || unicode.IsSpace(rs[i+2]) || unicode.IsControl(rs[i+2])),
+			r == '#' && lexer.hashComment:
+			for i < size && rs[i] != '\n' {
+				i++
+			}
+			add(sqlTokenComment, strings.TrimSpace(string(rs[start:i])), start, i)
+		case r == '/' && i+1 < size && rs[i+1] == '*':
+			if lexer.executableComment && i+2 < size && rs[i+2] == '!' {
+				err = errors.New("SQL中包含可执行注释/*! */，无法分析")
+				return
+			}
+			level := 1
+			for i += 2; i < size && level > 0; {
+				if rs[i] == '*' && i+1 < size && rs[i+1] == '/' {
+					level--
+					i += 2
+				} else if lexer.nestedComment && rs[i] == '/' && i+1 < size && rs[i+1] == '*' {
+					level++
+					i += 2
+				} else {
+					i++
+				}
+			}
+			if level > 0 {
+				err = errors.New("SQL中注释没有结束")
+				return
+			}
+			add(sqlTokenComment, string(rs[start:i]), start, i)
+		case r == '\'' || (r == '"' && lexer.doubleQuoteString):
+			if lexer.unknown && r == '\'' && hasBackslashInQuote(rs, i) {
+				err = errors.New("当前数据库类型无法确定字符串中的反斜杠是否转义")
+				return
+			}
+			if i, err = readQuoted(i, r, lexer.backslashEscape); err != nil {
+				return
+			}
+			add(sqlTokenString, string(rs[start:i]), start, i)
+		case r == '"' || r == '`':
+			if i, err = readQuoted(i, r, false); err != nil {
+				return
+			}
+			add(sqlTokenQuoted, string(rs[start:i]), start, i)
+		case r == '[' && lexer.bracketQuote:
+			for i++; i < size && rs[i] != ']'; i++ {
+			}
+			if i >= size {
+				err = errors.New("SQL中名称没有结束")
+				return
+			}
+			i++
+			add(sqlTokenQuoted, string(rs[start:i]), start, i)
+		case r == '$' && lexer.dollarQuote && dollarQuoteTag(rs, i) != "":
+			tag := []rune(dollarQuoteTag(rs, i))
+			for i += len(tag); i+len(tag) <= size && string(rs[i:i+len(tag)]) != string(tag); i++ {
+			}
+			if i+len(tag) > size {
+				err = errors.New("SQL中字符串" + string(tag) + "没有结束")
+				return
+			}
+			i += len(tag)
+			add(sqlTokenString, string(rs[start:i]), start, i)
+		case unicode.IsDigit(r) || (r == '.' && i+1 < size && unicode.IsDigit(rs[i+1]) && (len(tokens) == 0 || tokens[len(tokens)-1].kind == sqlTokenSymbol)):
+			for i < size && (unicode.IsDigit(rs[i]) || rs[i] == '.') {
+				i++
+			}
+			add(sqlTokenNumber, string(rs[start:i]), start, i)
+		case r == '_' || unicode.IsLetter(r):
+			for i < size && (rs[i] == '_' || rs[i] == '$' || unicode.IsLetter(rs[i]) || unicode.IsDigit(rs[i])) {
+				i++
+			}
+			word := strings.ToUpper(string(rs[start:i]))
+			switch {
+			case i < size && rs[i] == '\'' && lexer.escapeString && word == "E":
+				// E'' 字符串 反斜杠 转义
+				if i, err = readQuoted(i, '\'', true); err != nil {
+					return
+				}
+				add(sqlTokenString, string(rs[start:i]), start, i)
+			case i+1 < size && rs[i] == '\'' && lexer.qQuote && (word == "Q" || word == "NQ"):
+				// q'[ ]' 字符串，结束 符 为 对应 的 括号 或 相同 字符 加 单引号
+				closeRune := rs[i+1]
+				switch closeRune {
+				case '[':
+					closeRune = ']'
+				case '{':
+					closeRune = '}'
+				case '(':
+					closeRune = ')'
+				case '<':
+					closeRune = '>'
+				}
+				end := i + 2
+				for end+1 < size && !(rs[end] == closeRune && rs[end+1] == '\'') {
+					end++
+				}
+				if end+1 >= size {
+					err = errors.New("SQL中字符串没有结束")
+					return
+				}
+				i = end + 2
+				add(sqlTokenString, string(rs[start:i]), start, i)
+			default:
+				add(sqlTokenWord, string(rs[start:i]), start, i)
+			}
+		case r == '(':
+			i++
+			add(sqlTokenSymbol, "(", start, i)
+			depth++
+		case r == ')':
+			i++
+			if depth > 0 {
+				depth--
+			}
+			add(sqlTokenSymbol, ")", start, i)
+		default:
+			i++
+			if i < size {
+				switch string(rs[start : i+1]) {
+				case "<=", ">=", "<>", "!=", "||", "::", ":=", "=>":
+					i++
+				}
+			}
+			add(sqlTokenSymbol, string(rs[start:i]), start, i)
+		}
+	}
+	return
+}
+
+// hasBackslashInQuote 单引号 字符串 中 是否 有 反斜杠，从 开始 引号 查找 到 下一个 单引号
+func hasBackslashInQuote(rs []rune, start int) bool {
+	for i := start + 1; i < len(rs) && rs[i] != '\''; i++ {
+		if rs[i] == '\\' {
+			return true
+		}
+	}
+	return false
+}
+
+// dollarQuoteTag 获取 $tag$ 或 $$ 开始 标记，不是 时 返回 空
+func dollarQuoteTag(rs []rune, start int) string {
+	i := start + 1
+	for i < len(rs) && (rs[i] == '_' || unicode.IsLetter(rs[i]) || (i > start+1 && unicode.IsDigit(rs[i]))) {
+		i++
+	}
+	if i < len(rs) && rs[i] == '$' {
+		return string(rs[start : i+1])
+	}
+	return ""
+}
+
+// sqlCodeTokens 去掉 注释
+func sqlCodeTokens(tokens []*sqlToken) (res []*sqlToken) {
+	for _, token := range tokens {
+		if token.kind != sqlTokenComment {
+			res = append(res, token)
+		}
+	}
+	return
+}
+
+// 格式化 时 另起 一行 的 子句
+var sqlFormatClauses = map[string]bool{
+	"SELECT": true, "FROM": true, "WHERE": true, "GROUP": true, "ORDER": true, "HAVING": true, "LIMIT": true, "OFFSET": true,
+	"UNION": true, "EXCEPT": true, "INTERSECT": true, "MINUS": true, "SET": true, "VALUES": true, "UPDATE": true,
+	"DELETE": true, "INSERT": true, "WITH": true, "RETURNING": true, "FETCH": true, "WINDOW": true,
+}
+
+// 格式化 时 另起 一行 的 关联 关键字
+var sqlFormatJoins = map[string]bool{
+	"JOIN": true, "INNER": true, "LEFT": true, "RIGHT": true, "FULL": true, "CROSS": true, "NATURAL": true,
+}
+
+// formatSql 格式化 单条 SQL，子句 另起 一行，内容 缩进，子查询 按 层级 缩进，无法 解析 时 返回 原 SQL
+func formatSql(dialectType *dialect.Type, sqlInfo string) string {
+	tokens, err := sqlTokenize(dialectType, sqlInfo)
+	if err != nil {
+		return strings.TrimSpace(sqlInfo)
+	}
+	var buf strings.Builder
+	var indent int                 // 子查询 缩进 层级
+	var subqueryStack []bool       // 括号 是否 为 子查询
+	var clauses = map[int]string{} // 每个 括号 层级 当前 所在 子句
+	var inBetween bool             // BETWEEN 后 的 AND 不 换行
+	var newLineIndent = -1         // 写入 下一个 词 前 换行 的 缩进，-1 不 换行
+	var newLine = func(level int) {
+		newLineIndent = level
+	}
+	var prev *sqlToken
+	var write = func(token *sqlToken, text string, space bool) {
+		if newLineIndent >= 0 {
+			if buf.Len() > 0 {
+				buf.WriteString("\n")
+			}
+			buf.WriteString(strings.Repeat("  ", newLineIndent))
+			newLineIndent = -1
+		} else if space && buf.Len() > 0 {
+			buf.WriteString(" ")
+		}
+		buf.WriteString(text)
+		prev = token
+	}
+	for i, token := range tokens {
+		var next *sqlToken
+		if i+1 < len(tokens) {
+			next = tokens[i+1]
+		}
+		text := token.text
+		if token.kind == sqlTokenWord && sqlKeywords[token.upper] {
+			text = token.upper
+		}
+		// 只 在 最外层 和 子查询 中 按 子句 换行，函数、OVER 等 括号 内 不 换行
+		inQuery := len(subqueryStack) == 0 || subqueryStack[len(subqueryStack)-1]
+		switch {
+		case token.kind == sqlTokenComment:
+			write(token, text, true)
+			if strings.HasPrefix(text, "--") || strings.HasPrefix(text, "#") {
+				newLine(indent + 1)
+			}
+			continue
+		case inQuery && token.kind == sqlTokenWord && sqlFormatClauses[token.upper] && !prev.is("FOR", "ON") && !(token.upper == "SET" && !isUpdateClause(clauses[token.depth])):
+			// FOR UPDATE、ON DELETE 等 不 换行
+			newLine(indent)
+			write(token, text, true)
+			clauses[token.depth] = token.upper
+			inBetween = false
+			// GROUP BY、UNION ALL、LIMIT 10 等 不 换行
+			if !token.is("UNION", "EXCEPT", "INTERSECT", "MINUS", "LIMIT", "OFFSET", "FETCH") && !next.is("BY", "ALL", "DISTINCT", "INTO", "FROM", "(", ";") {
+				newLine(indent + 1)
+			}
+			continue
+		case inQuery && token.is("BY") && prev.is("GROUP", "ORDER"):
+			write(token, text, true)
+			newLine(indent + 1)
+			continue
+		case inQuery && token.kind == sqlTokenWord && sqlFormatJoins[token.upper] && !prev.is("LEFT", "RIGHT", "FULL", "CROSS", "NATURAL", "INNER", "OUTER"):
+			if token.upper == "JOIN" || next.is("JOIN", "OUTER", "INNER", "LEFT", "RIGHT") {
+				newLine(indent)
+				write(token, text, true)
+				clauses[token.depth] = "JOIN"
+				continue
+			}
+		case token.is("BETWEEN"):
+			inBetween = true
+		case token.is("AND", "OR"):
+			clause := clauses[token.depth]
+			if token.upper == "AND" && inBetween {
+				inBetween = false
+			} else if inQuery && (clause == "WHERE" || clause == "HAVING" || clause == "JOIN") {
+				newLine(indent + 1)
+				write(token, text, true)
+				continue
+			}
+		case token.is(","):
+			write(token, ",", false)
+			switch clauses[token.depth] {
+			case "SELECT", "GROUP", "ORDER", "SET":
+				if inQuery {
+					newLine(indent + 1)
+				}
+			}
+			continue
+		case token.is("("):
+			subquery := next.is("SELECT", "WITH")
+			subqueryStack = append(subqueryStack, subquery)
+			// 函数 调用 的 括号 前 不 加 空格，INSERT INTO t (a) 除外
+			space := !prev.is("(", ".") && !(prev.isName() && !(i >= 2 && tokens[i-2].is("INTO", "TABLE")))
+			write(token, "(", space)
+			if subquery {
+				indent += 2
+				newLine(indent)
+			}
+			continue
+		case token.is(")"):
+			if len(subqueryStack) > 0 {
+				subquery := subqueryStack[len(subqueryStack)-1]
+				subqueryStack = subqueryStack[:len(subqueryStack)-1]
+				delete(clauses, token.depth+1)
+				if subquery {
+					indent -= 2
+					newLine(indent + 1)
+				}
+			}
+			write(token, ")", false)
+			continue
+		}
+		write(token, text, !prev.is("(", ".", "::") && !token.is(".", ";", "::"))
+	}
+	return strings.TrimSpace(buf.String())
+}
+
+func isUpdateClause(clause string) bool {
+	return clause == "UPDATE" || clause == "JOIN" || clause == ""
+}
@@ -0,0 +1,88 @@
+package module_database
+
+import (
+	"github.com/team-ide/go-dialect/dialect"
+	"strings"
+	"testing"
+)
+
+// topLevelWords 测试 用，返回 顶层 关键字，空格 分隔
+func topLevelWords(tokens []*sqlToken) string {
+	var words []string
+	for _, token := range tokens {
+		if token.kind == sqlTokenWord && token.depth == 0 {
+			words = append(words, token.upper)
+		}
+	}
+	return strings.Join(words, " ")
+}
+
+func TestSqlTokenize(t *testing.T) {
+	tests := []struct {
+		name        string
+		dialectType *dialect.Type
+		sql         string
+		words       string // 顶层 关键字
+		hasError    bool
+	}{
+		{"mysql backslash escape", dialect.TypeMysql, `UPDATE t SET a='\' WHERE 1 '`, "UPDATE T SET A", false},
+		{"mysql double quote string", dialect.TypeMysql, `UPDATE t SET a="x\" WHERE 1 -- "`, "UPDATE T SET A", false},
+		{"mysql hash comment", dialect.TypeMysql, "DELETE FROM t # WHERE id = 1", "DELETE FROM T", false},
+		{"mysql dash without space", dialect.TypeMysql, "UPDATE t SET a=1--'\n WHERE 1 -- '", "UPDATE T SET A", false},
+		{"mysql executable comment", dialect.TypeMysql, "UPDATE t SET a=1 /*!99999 WHERE 1=1 */", "", true},
+		{"mysql unterminated string", dialect.TypeMysql, `UPDATE t SET a='\' WHERE 1`, "", true},
+		{"postgresql standard string", dialect.TypePostgresql, `UPDATE t SET a='\', b=' WHERE 1'`, "UPDATE T SET A B", false},
+		{"postgresql escape string", dialect.TypePostgresql, `UPDATE t SET a=E'\' WHERE 1 '`, "UPDATE T SET A", false},
+		{"postgresql dollar quote", dialect.TypePostgresql, "UPDATE t SET a=$x$ WHERE 1 $x$", "UPDATE T SET A", false},
+		{"postgresql positional param", dialect.TypePostgresql, "DELETE FROM t WHERE id = $1", "DELETE FROM T WHERE ID", false},
+		{"postgresql nested comment", dialect.TypePostgresql, "DELETE FROM t /* a /* b */ WHERE 1 */", "DELETE FROM T", false},
+		{"postgresql hash operator", dialect.TypePostgresql, "UPDATE t SET a = b # 1 WHERE id = 1", "UPDATE T SET A B WHERE ID", false},
+		{"postgresql unterminated dollar quote", dialect.TypePostgresql, "UPDATE t SET a=$$ WHERE 1", "", true},
+		{"oracle q quote", dialect.TypeOracle, "UPDATE t SET a=q'[ ' WHERE 1 ]'", "UPDATE T SET A", false},
+		{"oracle backslash", dialect.TypeOracle, `UPDATE t SET a='\', b=' WHERE 1'`, "UPDATE T SET A B", false},
+		{"sqlite bracket name", dialect.TypeSqlite, "UPDATE t SET a = [x WHERE y]", "UPDATE T SET A", false},
+		{"sqlite backslash", dialect.TypeSqlite, `UPDATE t SET a='\', b=' WHERE 1'`, "UPDATE T SET A B", false},
+		{"unterminated comment", dialect.TypeSqlite, "DELETE FROM t /* WHERE 1", "", true},
+		{"unterminated name", dialect.TypeSqlite, `SELECT "a FROM t`, "", true},
+		{"unknown dialect backslash", dialect.TypeGBase, `UPDATE t SET a='\' WHERE 1`, "", true},
+		{"subquery depth", dialect.TypeMysql, "DELETE FROM t WHERE id IN (SELECT id FROM b)", "DELETE FROM T WHERE ID IN", false},
+		{"doubled quote", dialect.TypeSqlite, "UPDATE t SET a='it''s' WHERE id = 1", "UPDATE T SET A WHERE ID", false},
+	}
+	for _, one := range tests {
+		t.Run(one.name, func(t *testing.T) {
+			tokens, err := sqlTokenize(one.dialectType, one.sql)
+			if one.hasError {
+				if err == nil {
+					t.Fatalf("sql [%s] should error, words [%s]", one.sql, topLevelWords(tokens))
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("sql [%s] error: %s", one.sql, err)
+			}
+			if words := topLevelWords(tokens); words != one.words {
+				t.Fatalf("sql [%s] words [%s], want [%s]", one.sql, words, one.words)
+			}
+		})
+	}
+}
+
+func TestSqlTokenizeKind(t *testing.T) {
+	tokens, err := sqlTokenize(dialect.TypeMysql, "SELECT `a`, \"b\", 'c', 1.5 FROM t -- x")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var kinds []int
+	for _, token := range tokens {
+		kinds = append(kinds, token.kind)
+	}
+	want := []int{sqlTokenWord, sqlTokenQuoted, sqlTokenSymbol, sqlTokenString, sqlTokenSymbol, sqlTokenString, sqlTokenSymbol, sqlTokenNumber, sqlTokenWord, sqlTokenWord, sqlTokenComment}
+	if len(kinds) != len(want) {
+		t.Fatalf("kinds %v, want %v", kinds, want)
+	}
+	for i := range want {
+		if kinds[i] != want[i] {
+			t.Fatalf("kinds %v, want %v", kinds, want)
+		}
+	}
+}
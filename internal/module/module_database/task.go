@@ -0,0 +1,271 @@
+package module_database
+
+import (
+	"database/sql"
+	"errors"
+	"github.com/team-ide/go-dialect/dialect"
+	"github.com/team-ide/go-dialect/worker"
+	"github.com/team-ide/go-tool/db"
+	"github.com/team-ide/go-tool/util"
+	"go.uber.org/zap"
+	goSSH "golang.org/x/crypto/ssh"
+	"os"
+	"strings"
+	"sync"
+	"teamide/pkg/ssh"
+	"time"
+)
+
+// maskingDialect 导出 时 生成 插入 SQL 前 对 数据 脱敏
+// 导出 的 SQL、Excel、CSV 等 文件 都 使用 同一份 数据，脱敏 后 所有 格式 都 生效
+type maskingDialect struct {
+	dialect.Dialect
+	masker *ColumnMasker
+	// 导出 后 的 表名 对应 的 原 表名
+	tableSourceNames map[string]string
+	// 导出 后 的 表名 对应 的 字段 重命名 信息
+	columnSourceNames map[string]map[string]string
+}
+
+func newMaskingDialect(dia dialect.Dialect, masker *ColumnMasker, exportParam *worker.TaskExportParam) *maskingDialect {
+	res := &maskingDialect{
+		Dialect:           dia,
+		masker:            masker,
+		tableSourceNames:  map[string]string{},
+		columnSourceNames: map[string]map[string]string{},
+	}
+	for _, owner := range exportParam.Owners {
+		for _, table := range owner.Tables {
+			targetName := table.TargetName
+			if targetName == "" {
+				targetName = table.SourceName
+			}
+			res.tableSourceNames[targetName] = table.SourceName
+			columnSourceNames := map[string]string{}
+			for _, column := range table.Columns {
+				if column.TargetName != "" && column.SourceName != "" {
+					columnSourceNames[column.TargetName] = column.SourceName
+				}
+			}
+			res.columnSourceNames[targetName] = columnSourceNames
+		}
+	}
+	return res
+}
+
+func (this_ *maskingDialect) DataListInsertSql(param *dialect.ParamModel, ownerName string, tableName string, columnList []*dialect.ColumnModel, dataList []map[string]interface{}) (sqlList []string, valuesList [][]interface{}, batchSqlList []string, batchValuesList [][]interface{}, err error) {
+	sourceTableName := tableName
+	if this_.tableSourceNames[tableName] != "" {
+		sourceTableName = this_.tableSourceNames[tableName]
+	}
+	this_.masker.MaskDataList(sourceTableName, dataList, this_.columnSourceNames[tableName])
+	return this_.Dialect.DataListInsertSql(param, ownerName, tableName, columnList, dataList)
+}
+
+// startExport 启动 导出 任务，与 db.Service.StartExport 一致，支持 扩展 文件 类型，配置 脱敏 时 目标 方言 替换 为 脱敏 方言
+func startExport(service db.IService, param *db.Param, exportParam *worker.TaskExportParam, masker *ColumnMasker) (task *worker.Task, err error) {
+	downloadPath := "export/" + util.GetUUID()
+	exportDir, err := util.GetTempDir()
+	if err != nil {
+		return
+	}
+	exportDir += downloadPath
+	exportParam.Dir = exportDir
+	exportParam.DataSourceType = getDataSourceType(param.ExportType)
+	exportParam.OnProgress = func(progress *worker.TaskProgress) {
+		util.Logger.Info("export task on progress", zap.Any("progress", progress))
+		progress.OnError = func(err error) {
+			util.Logger.Error("export task progress error", zap.Any("progress", progress), zap.Error(err))
+		}
+	}
+	sourceDialect := service.GetDialect()
+	exportParam.FormatIndexName = func(ownerName string, tableName string, index *dialect.IndexModel) (indexNameFormat string) {
+		if index.IndexName != "" && !param.FormatIndexName {
+			indexNameFormat = index.IndexName
+			return
+		}
+		if ownerName != "" {
+			indexNameFormat += ownerName + "_"
+		}
+		if tableName != "" {
+			indexNameFormat += tableName + "_"
+		}
+		if index.IndexType != "" && !strings.EqualFold(index.IndexType, "index") {
+			indexNameFormat += index.IndexType + "_"
+		}
+		indexNameFormat += strings.Join(index.ColumnNames, "_")
+		if sourceDialect.DialectType() == dialect.TypeOracle && len(indexNameFormat) > 30 {
+			indexNameFormat = indexNameFormat[0:21] + "_" + util.GetMD5(indexNameFormat)[0:8]
+		}
+		return
+	}
+
+	targetDialect := sourceDialect
+	if param.TargetDatabaseType != "" {
+		if t := db.GetDatabaseType(param.TargetDatabaseType); t != nil {
+			targetDialect = t.GetDialect()
+		}
+	}
+	if masker != nil {
+		targetDialect = newMaskingDialect(targetDialect, masker, exportParam)
+	}
+	task_ := worker.NewTaskExport(service.GetDb(), sourceDialect, targetDialect, exportParam)
+	task_.Param = param.ParamModel
+	task_.Extend = map[string]interface{}{
+		"downloadPath": "",
+	}
+	go func() {
+		var e error
+		defer func() {
+			if e != nil {
+				_ = os.RemoveAll(exportDir)
+				return
+			}
+			fs, _ := os.ReadDir(exportDir)
+			for _, f := range fs {
+				if f.IsDir() {
+					continue
+				}
+				if strings.HasPrefix(f.Name(), "数据列表导出") || strings.HasPrefix(f.Name(), "database.sql") {
+					task_.Extend["dirPath"] = exportDir
+					task_.Extend["downloadPath"] = downloadPath + "/" + f.Name()
+					return
+				}
+			}
+			e = util.Zip(exportDir, exportDir+".zip")
+			if e != nil {
+				util.Logger.Error("export file zip error", zap.Any("exportDir", exportDir), zap.Error(e))
+				return
+			}
+			task_.Extend["dirPath"] = exportDir
+			task_.Extend["zipPath"] = exportDir + ".zip"
+			task_.Extend["downloadPath"] = downloadPath + ".zip"
+		}()
+		e = task_.Start()
+	}()
+	time.Sleep(time.Millisecond * 100)
+	task = task_.Task
+	return
+}
+
+// startImport 启动 导入 任务，与 db.Service.StartImport 一致，支持 扩展 文件 类型，结束 后 执行 onEnd
+func startImport(service db.IService, config *db.Config, sshConfig *ssh.Config, param *db.Param, importParam *worker.TaskImportParam, onEnd func()) (task *worker.Task, err error) {
+	for _, owner := range importParam.Owners {
+		if owner.Path != "" {
+			owner.Path = db.FileUploadDir + owner.Path
+		}
+		for _, table := range owner.Tables {
+			if table.Path != "" {
+				table.Path = db.FileUploadDir + table.Path
+			}
+		}
+	}
+
+	importParam.DataSourceType = getDataSourceType(param.ImportType)
+	task, err = runImport(service, config, sshConfig, param, importParam, onEnd)
+	return
+}
+
+// runImport 使用 导入 参数 中 的 路径 启动 导入 任务，每个 库 使用 独立 的 连接，结束 后 执行 onEnd
+func runImport(service db.IService, config *db.Config, sshConfig *ssh.Config, param *db.Param, importParam *worker.TaskImportParam, onEnd func()) (task *worker.Task, err error) {
+	importParam.OnProgress = func(progress *worker.TaskProgress) {
+		util.Logger.Info("import task on progress", zap.Any("progress", progress))
+		progress.OnError = func(err error) {
+			util.Logger.Error("import task progress error", zap.Any("progress", progress), zap.Error(err))
+		}
+	}
+	databaseType := db.GetDatabaseType(config.Type)
+	if databaseType == nil {
+		err = errors.New("数据库类型[" + config.Type + "]不支持")
+		return
+	}
+	var workDbs []*sql.DB
+	var sshClients []*goSSH.Client
+	var locker sync.Mutex
+
+	task_ := worker.NewTaskImport(service.GetDb(), service.GetDialect(),
+		func(owner *worker.TaskImportOwner) (workDb *sql.DB, err error) {
+			workConfig := newOwnerConfig(service.GetDialect(), *config, owner.Username, owner.Password, owner.Name)
+			if sshConfig != nil {
+				workConfig.SSHClient, err = ssh.NewClient(*sshConfig)
+				if err != nil {
+					util.Logger.Error("import ssh NewClient error", zap.Error(err))
+					return
+				}
+			}
+			workDb, err = databaseType.NewDb(&workConfig)
+			locker.Lock()
+			defer locker.Unlock()
+			if workConfig.SSHClient != nil {
+				sshClients = append(sshClients, workConfig.SSHClient)
+			}
+			if err != nil {
+				util.Logger.Error("import new db pool error", zap.Error(err))
+				return
+			}
+			workDbs = append(workDbs, workDb)
+			return
+		},
+		importParam,
+	)
+	task_.Param = param.ParamModel
+
+	go func() {
+		defer func() {
+			locker.Lock()
+			defer locker.Unlock()
+			for _, workDb := range workDbs {
+				_ = workDb.Close()
+			}
+			for _, sshClient := range sshClients {
+				_ = sshClient.Close()
+			}
+			if onEnd != nil {
+				onEnd()
+			}
+		}()
+		_ = task_.Start()
+	}()
+	time.Sleep(time.Millisecond * 100)
+	task = task_.Task
+	return
+}
+
+// newOwnerConfig 导入 时 使用 指定 用户 和 库 的 连接 配置，与 go-tool 导入 一致
+func newOwnerConfig(dia dialect.Dialect, config db.Config, username string, password string, ownerName string) db.Config {
+	config.MaxIdleConn = 3
+	config.MaxOpenConn = 3
+	if username != "" {
+		config.Username = username
+	}
+	if password != "" {
+		config.Password = password
+	}
+	switch dia.DialectType() {
+	case dialect.TypeMysql:
+		config.Database = ownerName
+	case dialect.TypeGBase:
+		// GBase 库名 在 ODBC 连接串 中
+		if ownerName != "" {
+			lower := strings.ToLower(config.OdbcDsn)
+			key := "db="
+			index := strings.Index(lower, key)
+			if index < 0 {
+				key = "database="
+				index = strings.Index(lower, key)
+			}
+			if index >= 0 {
+				beforeStr := config.OdbcDsn[0 : index+len(key)]
+				afterStr := ""
+				str := config.OdbcDsn[index+len(key):]
+				if end := strings.Index(str, ";"); end >= 0 {
+					afterStr = str[end:]
+				}
+				config.OdbcDsn = beforeStr + ownerName + afterStr
+			}
+		}
+	default:
+		config.Schema = ownerName
+	}
+	return config
+}
@@ -0,0 +1,419 @@
+package module_database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/team-ide/go-dialect/dialect"
+	"github.com/team-ide/go-tool/util"
+	"go.uber.org/zap"
+	"strings"
+	"sync"
+	"teamide/pkg/base"
+	"time"
+)
+
+// 事务 默认 空闲 超时 时间，超时 后 自动 回滚
+const transactionDefaultIdleTimeout = 5 * 60
+
+// 事务 最大 空闲 超时 时间，需 小于 数据库 服务 缓存 回收 时间
+const transactionMaxIdleTimeout = 8 * 60
+
+type TransactionRequest struct {
+	ToolboxId   int64  `json:"toolboxId,omitempty"`
+	WorkerId    string `json:"workerId,omitempty"`
+	OwnerName   string `json:"ownerName,omitempty"`
+	IdleTimeout int64  `json:"idleTimeout,omitempty"` // 空闲 超时 秒
+}
+
+// TransactionInfo 事务 状态，返回 给 前端 显示 未提交 的 修改
+type TransactionInfo struct {
+	WorkerId     string `json:"workerId"`
+	ToolboxId    int64  `json:"toolboxId"`
+	OwnerName    string `json:"ownerName"`
+	StartTime    int64  `json:"startTime"`
+	LastUseTime  int64  `json:"lastUseTime"`
+	IdleTimeout  int64  `json:"idleTimeout"`
+	ExecuteCount int    `json:"executeCount"`
+	HasChange    bool   `json:"hasChange"`         // 存在 未提交 的 修改
+	Aborted      bool   `json:"aborted,omitempty"` // PostgreSQL 事务 中 语句 出错 后 事务 中止，只能 回滚
+	IsEnd        bool   `json:"isEnd"`
+	EndType      string `json:"endType,omitempty"` // commit、rollback、timeout、ddlCommit
+}
+
+type transaction struct {
+	*TransactionInfo
+	userId    int64
+	dia       dialect.Dialect
+	param     *dialect.ParamModel
+	ownerName string // 当前 会话 所在 库
+	sessionId string // 数据库 会话 ID，用于 取消 执行
+	conn      *sql.Conn
+	tx        *sql.Tx
+	locker    sync.Mutex
+}
+
+func (this_ *transaction) info() (res *TransactionInfo) {
+	this_.locker.Lock()
+	defer this_.locker.Unlock()
+	info := *this_.TransactionInfo
+	res = &info
+	return
+}
+
+// execute 在 事务 中 执行 SQL，取消 时 只 在 服务端 取消 当前 语句，不影响 事务 连接
+func (this_ *transaction) execute(q *runningQuery, ownerName string, sqlContent string, selectDataMax int, errorContinue bool) (executeList []map[string]interface{}, errStr string, err error) {
+	this_.locker.Lock()
+	defer this_.locker.Unlock()
+	if this_.IsEnd {
+		err = errTransactionEnd(this_.EndType)
+		return
+	}
+	if this_.Aborted {
+		err = errors.New("事务中语句执行失败，事务已中止，请回滚事务")
+		return
+	}
+	q.locker.Lock()
+	q.SessionId = this_.sessionId
+	q.locker.Unlock()
+	defer func() {
+		this_.LastUseTime = util.GetNowMilli()
+	}()
+	ctx := context.Background()
+	if ownerName != "" && ownerName != this_.ownerName {
+		if ownerUseConfig(this_.dia, ownerName) {
+			err = errors.New("当前数据库不支持在事务中切换库[" + ownerName + "]，请结束事务后执行")
+			return
+		}
+		if useSql := ownerUseSql(this_.dia, this_.param, ownerName); useSql != "" {
+			_, err = this_.tx.ExecContext(ctx, useSql)
+			if err != nil {
+				return
+			}
+		}
+		this_.ownerName = ownerName
+	}
+	sqlList := this_.dia.SqlSplit(sqlContent)
+	for index, executeSql := range sqlList {
+		if strings.TrimSpace(executeSql) == "" {
+			continue
+		}
+		if q.isStop() {
+			break
+		}
+		executeData, isChange, e := q.execute(ctx, this_.tx, executeSql, selectDataMax)
+		executeList = append(executeList, executeData)
+		this_.ExecuteCount++
+		if isChange {
+			this_.HasChange = true
+		}
+		// DDL 隐式 提交 事务，执行 失败 时 也 可能 已经 提交，结束 事务，后续 语句 不再 执行，避免 在 事务 外 自动 提交
+		if isImplicitCommit(this_.dia, executeSql) {
+			_ = this_.tx.Commit()
+			this_.HasChange = false
+			this_.IsEnd = true
+			this_.EndType = "ddlCommit"
+			discardConn(this_.conn)
+			if e != nil {
+				errStr = e.Error()
+			}
+			for _, one := range sqlList[index+1:] {
+				if strings.TrimSpace(one) != "" {
+					errStr = "DDL语句已隐式提交事务，后续语句未执行"
+					break
+				}
+			}
+			return
+		}
+		if e != nil {
+			util.Logger.Error("transaction execute error", zap.Any("executeSql", executeSql), zap.Error(e))
+			errStr = e.Error()
+			// PostgreSQL 语句 出错 后 事务 进入 中止 状态（25P02），后续 语句 都会 失败
+			if isAbortOnError(this_.dia) {
+				this_.Aborted = true
+				return
+			}
+			if !errorContinue || q.isStop() {
+				return
+			}
+		}
+	}
+	return
+}
+
+// isImplicitCommit 执行 DDL 时 数据库 是否 隐式 提交 事务
+func isImplicitCommit(dia dialect.Dialect, executeSql string) bool {
+	switch dia.DialectType() {
+	case dialect.TypeMysql, dialect.TypeOracle, dialect.TypeDM:
+	default:
+		return false
+	}
+	switch classifyStatement(dia.DialectType(), executeSql) {
+	case GuardKindDDL, GuardKindDrop, GuardKindTruncate:
+		return true
+	}
+	return false
+}
+
+// isAbortOnError 事务 中 语句 出错 后 是否 需要 回滚 才能 继续
+func isAbortOnError(dia dialect.Dialect) bool {
+	switch dia.DialectType() {
+	case dialect.TypePostgresql, dialect.TypeKingBase, dialect.TypeOpenGauss:
+		return true
+	}
+	return false
+}
+
+// end 结束 事务，提交 或 回滚 后 丢弃 连接
+func (this_ *transaction) end(endType string) (err error) {
+	this_.locker.Lock()
+	defer this_.locker.Unlock()
+	if this_.IsEnd {
+		return
+	}
+	if endType == "commit" && this_.Aborted {
+		// 中止 的 事务 不能 提交，回滚 后 提示
+		_ = this_.tx.Rollback()
+		endType = "rollback"
+		err = errors.New("事务已中止，已回滚")
+	} else if endType == "commit" {
+		err = this_.tx.Commit()
+	} else {
+		err = this_.tx.Rollback()
+	}
+	this_.IsEnd = true
+	this_.EndType = endType
+	discardConn(this_.conn)
+	return
+}
+
+func errTransactionEnd(endType string) error {
+	if endType == "timeout" {
+		return errors.New("事务空闲超时已自动回滚，请重新开启事务")
+	}
+	if endType == "ddlCommit" {
+		return errors.New("DDL语句已隐式提交事务，事务已结束")
+	}
+	return errors.New("事务已结束")
+}
+
+var transactionCache = map[string]*transaction{}
+var transactionCacheLock = &sync.Mutex{}
+var transactionCheckOnce = &sync.Once{}
+
+func getTransaction(workerId string) *transaction {
+	if workerId == "" {
+		return nil
+	}
+	transactionCacheLock.Lock()
+	defer transactionCacheLock.Unlock()
+
+	return transactionCache[workerId]
+}
+
+func addTransaction(t *transaction) {
+	transactionCacheLock.Lock()
+	defer transactionCacheLock.Unlock()
+
+	transactionCache[t.WorkerId] = t
+}
+
+func removeTransaction(workerId string) *transaction {
+	transactionCacheLock.Lock()
+	defer transactionCacheLock.Unlock()
+
+	t := transactionCache[workerId]
+	delete(transactionCache, workerId)
+	return t
+}
+
+// rollbackWorkerTransaction 关闭 工作区 时 回滚 未提交 的 事务
+func rollbackWorkerTransaction(workerId string) {
+	t := removeTransaction(workerId)
+	if t == nil {
+		return
+	}
+	if err := t.end("rollback"); err != nil {
+		util.Logger.Error("transaction rollback error", zap.Any("workerId", workerId), zap.Error(err))
+	}
+}
+
+// startTransactionCheck 定时 检测 空闲 超时 的 事务 并 回滚
+func startTransactionCheck() {
+	transactionCheckOnce.Do(func() {
+		go func() {
+			for {
+				time.Sleep(time.Second * 5)
+				checkTransactionIdle()
+			}
+		}()
+	})
+}
+
+func checkTransactionIdle() {
+	var timeoutList []*transaction
+	nowTime := util.GetNowMilli()
+	transactionCacheLock.Lock()
+	for _, t := range transactionCache {
+		// 正在 执行 的 事务 不检测
+		if !t.locker.TryLock() {
+			continue
+		}
+		if !t.IsEnd && nowTime-t.LastUseTime > t.IdleTimeout*1000 {
+			timeoutList = append(timeoutList, t)
+			delete(transactionCache, t.WorkerId)
+		}
+		t.locker.Unlock()
+	}
+	transactionCacheLock.Unlock()
+
+	for _, t := range timeoutList {
+		util.Logger.Info("transaction idle timeout rollback", zap.Any("workerId", t.WorkerId), zap.Any("toolboxId", t.ToolboxId))
+		if err := t.end("timeout"); err != nil {
+			util.Logger.Error("transaction timeout rollback error", zap.Any("workerId", t.WorkerId), zap.Error(err))
+		}
+	}
+}
+
+// getUserTransaction 获取 当前用户 在 工作区 开启 的 事务
+func getUserTransaction(requestBean *base.RequestBean, toolboxId int64, workerId string) (t *transaction, err error) {
+	t = getTransaction(workerId)
+	if t == nil {
+		return
+	}
+	var userId int64
+	if requestBean.JWT != nil {
+		userId = requestBean.JWT.UserId
+	}
+	if t.ToolboxId != toolboxId || t.userId != userId {
+		err = errors.New("事务不属于当前工具或用户，无法操作")
+		t = nil
+		return
+	}
+	return
+}
+
+func (this_ *api) transactionBegin(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	config, sshConfig, err := this_.getConfig(requestBean, c)
+	if err != nil {
+		return
+	}
+	service, err := getService(config, sshConfig)
+	if err != nil {
+		return
+	}
+
+	var request = &TransactionRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	if request.WorkerId == "" {
+		err = errors.New("workerId is empty")
+		return
+	}
+	if getTransaction(request.WorkerId) != nil {
+		err = errors.New("当前工作区已开启事务，请先提交或回滚")
+		return
+	}
+	param := this_.getParam(requestBean, c)
+	service, _, err = getExecService(service, config, sshConfig, param, request.OwnerName)
+	if err != nil {
+		return
+	}
+
+	ctx := context.Background()
+	conn, err := newOwnerConn(ctx, service, param.ParamModel, request.OwnerName)
+	if err != nil {
+		return
+	}
+	sessionId := querySessionId(ctx, conn, service.GetDialect())
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		discardConn(conn)
+		return
+	}
+	if request.IdleTimeout <= 0 {
+		request.IdleTimeout = transactionDefaultIdleTimeout
+	}
+	if request.IdleTimeout > transactionMaxIdleTimeout {
+		request.IdleTimeout = transactionMaxIdleTimeout
+	}
+	nowTime := util.GetNowMilli()
+	t := &transaction{
+		TransactionInfo: &TransactionInfo{
+			WorkerId:    request.WorkerId,
+			ToolboxId:   request.ToolboxId,
+			OwnerName:   request.OwnerName,
+			StartTime:   nowTime,
+			LastUseTime: nowTime,
+			IdleTimeout: request.IdleTimeout,
+		},
+		dia:       service.GetDialect(),
+		param:     param.ParamModel,
+		ownerName: request.OwnerName,
+		sessionId: sessionId,
+		conn:      conn,
+		tx:        tx,
+	}
+	if requestBean.JWT != nil {
+		t.userId = requestBean.JWT.UserId
+	}
+	addTransaction(t)
+	startTransactionCheck()
+
+	res = t.info()
+	return
+}
+
+func (this_ *api) transactionCommit(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	res, err = this_.transactionEnd(requestBean, c, "commit")
+	return
+}
+
+func (this_ *api) transactionRollback(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	res, err = this_.transactionEnd(requestBean, c, "rollback")
+	return
+}
+
+func (this_ *api) transactionEnd(requestBean *base.RequestBean, c *gin.Context, endType string) (res interface{}, err error) {
+	var request = &TransactionRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	t, err := getUserTransaction(requestBean, request.ToolboxId, request.WorkerId)
+	if err != nil {
+		return
+	}
+	if t == nil {
+		err = errors.New("当前工作区未开启事务或事务已超时回滚")
+		return
+	}
+	removeTransaction(request.WorkerId)
+	if info := t.info(); info.IsEnd {
+		err = errTransactionEnd(info.EndType)
+		return
+	}
+	err = t.end(endType)
+	if err != nil {
+		return
+	}
+	res = t.info()
+	return
+}
+
+func (this_ *api) transactionStatus(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	var request = &TransactionRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	t, err := getUserTransaction(requestBean, request.ToolboxId, request.WorkerId)
+	if err != nil {
+		return
+	}
+	if t == nil {
+		return
+	}
+	res = t.info()
+	return
+}
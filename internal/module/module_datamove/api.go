@@ -125,8 +125,17 @@ func (this_ *api) start(requestBean *base.RequestBean, c *gin.Context) (res inte
 	if err != nil {
 		return
 	}
-
 	options.Key = util.GetUUID()
+	err = this_.fullFromMasking(options.Key, request.FromToolboxId, options.From)
+	if err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			removeMaskingTask(options.Key)
+		}
+	}()
+
 	options.Dir = this_.getAnnexPath(requestBean, options.Key)
 	request.AnnexDir = options.Dir
 	if options.From.FilePath != "" {
@@ -162,6 +171,7 @@ func (this_ *api) start(requestBean *base.RequestBean, c *gin.Context) (res inte
 			options.To = nil
 			_ = this_.saveInfo(requestBean, options.Key, taskInfo)
 			removeTaskInfo(options.Key)
+			removeMaskingTask(options.Key)
 		}()
 		t.Run()
 
@@ -0,0 +1,119 @@
+package module_datamove
+
+import (
+	"errors"
+	"github.com/team-ide/go-tool/datamove"
+	"github.com/team-ide/go-tool/db"
+	"strings"
+	"sync"
+	"teamide/internal/module/module_database"
+)
+
+// maskingDatabaseTypePrefix 迁移 脱敏 注册 的 数据库 类型 前缀，每种 源 数据库 类型 只 注册 一次
+const maskingDatabaseTypePrefix = "teamide-masking-"
+
+// maskingDsnPrefix 脱敏 连接 的 DSN 前缀，后面 为 任务 key，创建 连接 时 根据 key 获取 任务 的 脱敏器
+const maskingDsnPrefix = "teamide-masking:"
+
+var (
+	maskingDatabaseTypeLocker sync.Mutex
+	maskingTaskCache          = map[string]*maskingTask{}
+	maskingTaskLocker         sync.Mutex
+)
+
+// maskingTask 迁移 任务 的 脱敏器 和 源 DSN
+type maskingTask struct {
+	masker *module_database.ColumnMasker
+	dsn    string
+}
+
+// fullFromMasking 源 为 数据库 工具 并 配置 了 脱敏 规则 时，对 迁移 数据 脱敏
+// 数据列表 直接 脱敏；数据库 数据 由 迁移 任务 内部 读取，将 源 数据库 类型 替换 为 脱敏 类型，读取 时 脱敏
+// 脱敏器 按 任务 缓存，任务 结束 后 调用 removeMaskingTask 删除
+func (this_ *api) fullFromMasking(taskKey string, fromToolboxId int64, from *datamove.DataSourceConfig) (err error) {
+	if fromToolboxId == 0 || from == nil {
+		return
+	}
+	find, err := this_.toolboxService.Get(fromToolboxId)
+	if err != nil {
+		return
+	}
+	if find == nil || find.ToolboxType != "database" {
+		return
+	}
+	masker, err := module_database.NewColumnMaskerByToolbox(this_.toolboxService, find)
+	if err != nil {
+		return
+	}
+	if masker == nil {
+		return
+	}
+	if from.IsData() {
+		masker.MaskDataList("", from.DataList, nil)
+		return
+	}
+	if !from.IsDb() || from.DbConfig == nil {
+		return
+	}
+	from.DbConfig.Type, err = maskingDatabaseType(from.DbConfig.Type)
+	if err != nil {
+		return
+	}
+	maskingTaskLocker.Lock()
+	defer maskingTaskLocker.Unlock()
+	maskingTaskCache[taskKey] = &maskingTask{
+		masker: masker,
+		dsn:    from.DbConfig.Dsn,
+	}
+	from.DbConfig.Dsn = maskingDsnPrefix + taskKey
+	return
+}
+
+// getMaskingTask 根据 脱敏 连接 的 DSN 获取 任务 的 脱敏 信息
+func getMaskingTask(dsn string) *maskingTask {
+	if !strings.HasPrefix(dsn, maskingDsnPrefix) {
+		return nil
+	}
+	maskingTaskLocker.Lock()
+	defer maskingTaskLocker.Unlock()
+	return maskingTaskCache[strings.TrimPrefix(dsn, maskingDsnPrefix)]
+}
+
+// removeMaskingTask 任务 结束 后 删除 脱敏器
+func removeMaskingTask(taskKey string) {
+	maskingTaskLocker.Lock()
+	defer maskingTaskLocker.Unlock()
+	delete(maskingTaskCache, taskKey)
+}
+
+// maskingDatabaseType 获取 源 数据库 类型 对应 的 脱敏 类型，不存在 时 注册
+func maskingDatabaseType(databaseType string) (res string, err error) {
+	maskingDatabaseTypeLocker.Lock()
+	defer maskingDatabaseTypeLocker.Unlock()
+
+	source := db.GetDatabaseType(databaseType)
+	if source == nil {
+		err = errors.New("数据库类型[" + databaseType + "]暂不支持")
+		return
+	}
+	res = maskingDatabaseTypePrefix + strings.ToLower(source.DialectName)
+	if db.GetDatabaseType(res) != nil {
+		return
+	}
+	err = db.AddDatabaseType(&db.DatabaseType{
+		DialectName: source.DialectName,
+		Matches:     []string{res},
+		NewDb:       newMaskingDb(source),
+	})
+	return
+}
+
+// IsMaskingDatabaseType 是否 为 迁移 脱敏 注册 的 数据库 类型，不在 数据库 类型 列表 中 展示
+func IsMaskingDatabaseType(databaseType *db.DatabaseType) bool {
+	for _, match := range databaseType.Matches {
+		if strings.HasPrefix(match, maskingDatabaseTypePrefix) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,312 @@
+package module_datamove
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"github.com/team-ide/go-tool/db"
+	"io"
+	"regexp"
+	"strings"
+	"teamide/internal/module/module_database"
+)
+
+// newMaskingDb 使用 源 类型 创建 连接池，并 包装 为 查询 结果 脱敏 的 连接池，脱敏器 根据 DSN 中 的 任务 key 获取
+func newMaskingDb(source *db.DatabaseType) func(config *db.Config) (*sql.DB, error) {
+	return func(config *db.Config) (res *sql.DB, err error) {
+		task := getMaskingTask(config.Dsn)
+		if task == nil {
+			err = errors.New("迁移任务脱敏配置不存在")
+			return
+		}
+		sourceConfig := *config
+		sourceConfig.Dsn = task.dsn
+		sourceDb, err := source.NewDb(&sourceConfig)
+		if err != nil {
+			return
+		}
+		res = sql.OpenDB(&maskingConnector{
+			db:     sourceDb,
+			masker: task.masker,
+		})
+		return
+	}
+}
+
+// maskingConnector 脱敏 连接器，连接 从 源 连接池 获取，关闭 时 关闭 源 连接池
+type maskingConnector struct {
+	db     *sql.DB
+	masker *module_database.ColumnMasker
+}
+
+func (this_ *maskingConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := this_.db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &maskingConn{
+		conn:   conn,
+		masker: this_.masker,
+	}, nil
+}
+
+func (this_ *maskingConnector) Driver() driver.Driver {
+	return maskingDriver{}
+}
+
+func (this_ *maskingConnector) Close() error {
+	return this_.db.Close()
+}
+
+type maskingDriver struct{}
+
+func (maskingDriver) Open(string) (driver.Conn, error) {
+	return nil, errors.New("脱敏连接不支持通过DSN打开")
+}
+
+// maskingConn 脱敏 连接，语句 交由 源 连接 执行，参数 不做 转换
+type maskingConn struct {
+	conn   *sql.Conn
+	masker *module_database.ColumnMasker
+}
+
+func (this_ *maskingConn) Prepare(query string) (driver.Stmt, error) {
+	return this_.PrepareContext(context.Background(), query)
+}
+
+func (this_ *maskingConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	stmt, err := this_.conn.PrepareContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	return &maskingStmt{
+		stmt:  stmt,
+		conn:  this_,
+		query: query,
+	}, nil
+}
+
+func (this_ *maskingConn) Close() error {
+	return this_.conn.Close()
+}
+
+func (this_ *maskingConn) Begin() (driver.Tx, error) {
+	return this_.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (this_ *maskingConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	return this_.conn.BeginTx(ctx, &sql.TxOptions{
+		Isolation: sql.IsolationLevel(opts.Isolation),
+		ReadOnly:  opts.ReadOnly,
+	})
+}
+
+func (this_ *maskingConn) CheckNamedValue(*driver.NamedValue) error {
+	return nil
+}
+
+func (this_ *maskingConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	return this_.conn.ExecContext(ctx, query, maskingArgs(args)...)
+}
+
+func (this_ *maskingConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	rows, err := this_.conn.QueryContext(ctx, query, maskingArgs(args)...)
+	if err != nil {
+		return nil, err
+	}
+	return this_.newRows(rows, query)
+}
+
+func (this_ *maskingConn) newRows(rows *sql.Rows, query string) (res driver.Rows, err error) {
+	columnTypes, err := rows.ColumnTypes()
+	if err != nil {
+		_ = rows.Close()
+		return
+	}
+	tableName := maskingTableName(query)
+	one := &maskingRows{
+		rows:        rows,
+		columnTypes: columnTypes,
+		masked:      make([]bool, len(columnTypes)),
+		values:      make([]interface{}, len(columnTypes)),
+		tableName:   tableName,
+		masker:      this_.masker,
+	}
+	if !maskingSkipTable(query) {
+		for i, columnType := range columnTypes {
+			one.masked[i] = this_.masker.HasRule(tableName, []string{columnType.Name()})
+		}
+	}
+	res = one
+	return
+}
+
+func maskingArgs(args []driver.NamedValue) (res []interface{}) {
+	for _, arg := range args {
+		if arg.Name != "" {
+			res = append(res, sql.Named(arg.Name, arg.Value))
+		} else {
+			res = append(res, arg.Value)
+		}
+	}
+	return
+}
+
+type maskingStmt struct {
+	stmt  *sql.Stmt
+	conn  *maskingConn
+	query string
+}
+
+func (this_ *maskingStmt) Close() error {
+	return this_.stmt.Close()
+}
+
+func (this_ *maskingStmt) NumInput() int {
+	return -1
+}
+
+func (this_ *maskingStmt) Exec(args []driver.Value) (driver.Result, error) {
+	return this_.stmt.Exec(maskingValues(args)...)
+}
+
+func (this_ *maskingStmt) Query(args []driver.Value) (driver.Rows, error) {
+	rows, err := this_.stmt.Query(maskingValues(args)...)
+	if err != nil {
+		return nil, err
+	}
+	return this_.conn.newRows(rows, this_.query)
+}
+
+func (this_ *maskingStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	return this_.stmt.ExecContext(ctx, maskingArgs(args)...)
+}
+
+func (this_ *maskingStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	rows, err := this_.stmt.QueryContext(ctx, maskingArgs(args)...)
+	if err != nil {
+		return nil, err
+	}
+	return this_.conn.newRows(rows, this_.query)
+}
+
+func maskingValues(args []driver.Value) (res []interface{}) {
+	for _, arg := range args {
+		res = append(res, arg)
+	}
+	return
+}
+
+// maskingRows 查询 结果，读取 时 对 匹配 规则 的 字段 脱敏
+type maskingRows struct {
+	rows        *sql.Rows
+	columnTypes []*sql.ColumnType
+	masked      []bool
+	values      []interface{}
+	tableName   string
+	masker      *module_database.ColumnMasker
+}
+
+func (this_ *maskingRows) Columns() (res []string) {
+	for _, columnType := range this_.columnTypes {
+		res = append(res, columnType.Name())
+	}
+	return
+}
+
+func (this_ *maskingRows) Close() error {
+	return this_.rows.Close()
+}
+
+func (this_ *maskingRows) Next(dest []driver.Value) (err error) {
+	if !this_.rows.Next() {
+		if err = this_.rows.Err(); err != nil {
+			return
+		}
+		return io.EOF
+	}
+	var scans = make([]interface{}, len(this_.values))
+	for i := range this_.values {
+		scans[i] = &this_.values[i]
+	}
+	if err = this_.rows.Scan(scans...); err != nil {
+		return
+	}
+	for i, value := range this_.values {
+		if i >= len(dest) {
+			break
+		}
+		if this_.masked[i] {
+			value = this_.masker.MaskValue(this_.tableName, this_.columnTypes[i].Name(), value)
+		}
+		dest[i] = value
+	}
+	return
+}
+
+func (this_ *maskingRows) ColumnTypeDatabaseTypeName(index int) string {
+	return this_.columnTypes[index].DatabaseTypeName()
+}
+
+func (this_ *maskingRows) ColumnTypeLength(index int) (length int64, ok bool) {
+	return this_.columnTypes[index].Length()
+}
+
+func (this_ *maskingRows) ColumnTypeNullable(index int) (nullable bool, ok bool) {
+	return this_.columnTypes[index].Nullable()
+}
+
+func (this_ *maskingRows) ColumnTypePrecisionScale(index int) (precision int64, scale int64, ok bool) {
+	return this_.columnTypes[index].DecimalSize()
+}
+
+var (
+	maskingFromRegexp = regexp.MustCompile(`(?i)\bfrom\s+([^\s,;()]+)`)
+	maskingJoinRegexp = regexp.MustCompile(`(?i)\bjoin\b`)
+	// maskingSkipOwners 系统 库，查询 的 是 结构 信息，不 脱敏
+	maskingSkipOwners = []string{"information_schema", "pg_catalog", "performance_schema", "mysql", "sys"}
+)
+
+// maskingTableName 获取 查询 的 表名，多表 或 无法 确定 时 返回 空，按 字段名 匹配 所有 表 的 规则
+func maskingTableName(query string) string {
+	matches := maskingFromRegexp.FindAllStringSubmatch(query, -1)
+	if len(matches) != 1 || maskingJoinRegexp.MatchString(query) {
+		return ""
+	}
+	if strings.Contains(query[strings.Index(query, matches[0][1])+len(matches[0][1]):], ",") {
+		return ""
+	}
+	_, tableName := maskingSplitName(matches[0][1])
+	return tableName
+}
+
+// maskingSkipTable 查询 系统 库 的 结构 信息 时 不 脱敏
+func maskingSkipTable(query string) bool {
+	matches := maskingFromRegexp.FindAllStringSubmatch(query, -1)
+	if len(matches) == 0 {
+		return false
+	}
+	for _, match := range matches {
+		ownerName, _ := maskingSplitName(match[1])
+		var isSkip bool
+		for _, skip := range maskingSkipOwners {
+			if strings.EqualFold(ownerName, skip) {
+				isSkip = true
+				break
+			}
+		}
+		if !isSkip {
+			return false
+		}
+	}
+	return true
+}
+
+func maskingSplitName(name string) (ownerName string, tableName string) {
+	name = strings.NewReplacer("`", "", `"`, "", "[", "", "]", "").Replace(name)
+	if index := strings.LastIndex(name, "."); index >= 0 {
+		return name[:index], name[index+1:]
+	}
+	return "", name
+}
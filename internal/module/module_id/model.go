@@ -63,4 +63,13 @@ const (
 	IDTypeTerminalLog = 8001
 	// IDTypeTerminalCommand 控制台命令
 	IDTypeTerminalCommand = 8002
+
+	// IDTypeDatabaseSqlHistory 数据库SQL历史
+	IDTypeDatabaseSqlHistory = 9001
+	// IDTypeDatabaseDataEdit 数据库数据编辑记录
+	IDTypeDatabaseDataEdit = 9002
+	// IDTypeDatabaseBackup 数据库备份任务
+	IDTypeDatabaseBackup = 9003
+	// IDTypeDatabaseBackupRecord 数据库备份记录
+	IDTypeDatabaseBackupRecord = 9004
 )
@@ -0,0 +1,586 @@
+package module_redis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	goRedis "github.com/go-redis/redis/v8"
+	"github.com/team-ide/go-tool/redis"
+	"github.com/team-ide/go-tool/util"
+	"go.uber.org/zap"
+	"sort"
+	"strings"
+	"sync"
+	"teamide/pkg/base"
+	"time"
+)
+
+var (
+	analyzeTaskCache     = map[string]*AnalyzeTask{}
+	analyzeTaskCacheLock = &sync.Mutex{}
+)
+
+// 前缀 统计 最多 记录 的 前缀 数，超出 后 计入 analyzeOtherPrefix
+// 结束 的 任务 保留 的 时间，超时 后 回收
+const analyzeTaskExpire = 30 * time.Minute
+
+const (
+	analyzeMaxPrefixCount = 10000
+	analyzeOtherPrefix    = "<other>"
+)
+
+// AnalyzeOptions 分析 配置
+type AnalyzeOptions struct {
+	Database         int    `json:"database"`
+	Pattern          string `json:"pattern"`          // 默认 *
+	ScanCount        int64  `json:"scanCount"`        // 每次 SCAN 的 COUNT，默认 200
+	MaxKeysPerSecond int64  `json:"maxKeysPerSecond"` // 每秒 最多 分析 的 key 数，默认 1000，避免 影响 线上
+	MaxKeys          int64  `json:"maxKeys"`          // 最多 分析 的 key 数，0 不限制
+	Samples          int    `json:"samples"`          // MEMORY USAGE 的 SAMPLES，默认 5
+	TopN             int    `json:"topN"`             // 默认 100
+	Delimiter        string `json:"delimiter"`        // key 前缀 分隔符，默认 ":"
+	PrefixDepth      int    `json:"prefixDepth"`      // 前缀 层级，默认 1
+}
+
+// AnalyzeTask 内存 分析 任务，SCAN 库 中 的 key，统计 内存、元素 数、过期 时间
+type AnalyzeTask struct {
+	*AnalyzeOptions
+	TaskKey      string         `json:"taskKey"`
+	TotalKeys    int64          `json:"totalKeys"` // 开始 时 DBSIZE
+	ScannedKeys  int64          `json:"scannedKeys"`
+	AnalyzedKeys int64          `json:"analyzedKeys"`
+	ErrorKeys    int64          `json:"errorKeys"`
+	Report       *AnalyzeReport `json:"report"`
+
+	IsEnd     bool      `json:"isEnd"`
+	IsStop    bool      `json:"isStop"`
+	StartTime time.Time `json:"startTime,omitempty"`
+	NowTime   time.Time `json:"nowTime,omitempty"`
+	EndTime   time.Time `json:"endTime,omitempty"`
+	UseTime   int64     `json:"useTime"`
+	Error     string    `json:"error,omitempty"`
+
+	userId    int64
+	toolboxId int64
+	service   redis.IService
+	keepAlive func() // 刷新 服务 使用 时间，避免 分析 期间 服务 空闲 被 关闭
+	locker    *sync.Mutex
+	topKeys   []*AnalyzeKey
+	prefixes  map[string]*AnalyzeGroup
+	types     map[string]*AnalyzeGroup
+	ttls      map[string]*AnalyzeGroup
+	memory    int64
+}
+
+type AnalyzeReport struct {
+	TotalMemory int64           `json:"totalMemory"`
+	TopKeys     []*AnalyzeKey   `json:"topKeys"`
+	PrefixCount int             `json:"prefixCount"`
+	PrefixList  []*AnalyzeGroup `json:"prefixList"` // 按 内存 倒序，最多 TopN 个
+	TypeList    []*AnalyzeGroup `json:"typeList"`
+	TtlList     []*AnalyzeGroup `json:"ttlList"`
+}
+
+type AnalyzeKey struct {
+	Key    string `json:"key"`
+	Type   string `json:"type"`
+	Memory int64  `json:"memory"`
+	Count  int64  `json:"count"` // 元素 数，string 为 长度
+	Ttl    int64  `json:"ttl"`   // 毫秒，-1 永不过期
+}
+
+type AnalyzeGroup struct {
+	Name     string `json:"name"`
+	KeyCount int64  `json:"keyCount"`
+	Memory   int64  `json:"memory"`
+	Count    int64  `json:"count"`
+}
+
+// 过期 时间 分布 区间
+var analyzeTtlBuckets = []struct {
+	name string
+	max  time.Duration
+}{
+	{"<1m", time.Minute},
+	{"1m-1h", time.Hour},
+	{"1h-1d", 24 * time.Hour},
+	{"1d-7d", 7 * 24 * time.Hour},
+	{"7d-30d", 30 * 24 * time.Hour},
+}
+
+func (this_ *api) analyze(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	config, sshConfig, err := this_.getConfig(requestBean, c)
+	if err != nil {
+		return
+	}
+	service, err := getService(config, sshConfig)
+	if err != nil {
+		return
+	}
+
+	options := &AnalyzeOptions{}
+	if !base.RequestJSON(options, c) {
+		return
+	}
+	task := &AnalyzeTask{
+		AnalyzeOptions: options,
+		service:        service,
+		keepAlive: func() {
+			_, _ = getService(config, sshConfig)
+		},
+	}
+	if requestBean.JWT != nil {
+		task.userId = requestBean.JWT.UserId
+	}
+	if toolbox, _ := getToolbox(requestBean); toolbox != nil {
+		task.toolboxId = toolbox.ToolboxId
+	}
+	startAnalyzeTask(task)
+	res = task.status()
+	return
+}
+
+// getUserAnalyzeTask 获取 当前 用户 在 当前 工具 下 的 分析 任务
+func (this_ *api) getUserAnalyzeTask(requestBean *base.RequestBean, c *gin.Context) (task *AnalyzeTask, err error) {
+	_, _, err = this_.getConfig(requestBean, c)
+	if err != nil {
+		return
+	}
+	request := &BaseRequest{}
+	if !base.RequestJSON(request, c) {
+		err = errors.New("请求参数错误")
+		return
+	}
+	var userId, toolboxId int64
+	if requestBean.JWT != nil {
+		userId = requestBean.JWT.UserId
+	}
+	if toolbox, _ := getToolbox(requestBean); toolbox != nil {
+		toolboxId = toolbox.ToolboxId
+	}
+	task = getAnalyzeTask(request.TaskKey)
+	if task == nil || task.userId != userId || task.toolboxId != toolboxId {
+		task = nil
+		err = errors.New("任务[" + request.TaskKey + "]不存在")
+		return
+	}
+	return
+}
+
+func (this_ *api) analyzeStatus(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	task, err := this_.getUserAnalyzeTask(requestBean, c)
+	if err != nil {
+		return
+	}
+	res = task.status()
+	return
+}
+
+func (this_ *api) analyzeStop(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	task, err := this_.getUserAnalyzeTask(requestBean, c)
+	if err != nil {
+		return
+	}
+	task.stop()
+	return
+}
+
+func (this_ *api) analyzeClean(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	task, err := this_.getUserAnalyzeTask(requestBean, c)
+	if err != nil {
+		return
+	}
+	task.stop()
+	analyzeTaskCacheLock.Lock()
+	defer analyzeTaskCacheLock.Unlock()
+	delete(analyzeTaskCache, task.TaskKey)
+	return
+}
+
+func startAnalyzeTask(task *AnalyzeTask) {
+	if task.Pattern == "" {
+		task.Pattern = "*"
+	}
+	if task.ScanCount <= 0 {
+		task.ScanCount = 200
+	}
+	if task.MaxKeysPerSecond <= 0 {
+		task.MaxKeysPerSecond = 1000
+	}
+	if task.Samples <= 0 {
+		task.Samples = 5
+	}
+	if task.TopN <= 0 {
+		task.TopN = 100
+	}
+	if task.Delimiter == "" {
+		task.Delimiter = ":"
+	}
+	if task.PrefixDepth <= 0 {
+		task.PrefixDepth = 1
+	}
+	task.TaskKey = util.GetUUID()
+	task.locker = &sync.Mutex{}
+	task.prefixes = map[string]*AnalyzeGroup{}
+	task.types = map[string]*AnalyzeGroup{}
+	task.ttls = map[string]*AnalyzeGroup{}
+	task.StartTime = time.Now()
+
+	analyzeTaskCacheLock.Lock()
+	cleanAnalyzeTask()
+	analyzeTaskCache[task.TaskKey] = task
+	analyzeTaskCacheLock.Unlock()
+
+	go task.start()
+}
+
+func getAnalyzeTask(taskKey string) *AnalyzeTask {
+	analyzeTaskCacheLock.Lock()
+	defer analyzeTaskCacheLock.Unlock()
+	cleanAnalyzeTask()
+	return analyzeTaskCache[taskKey]
+}
+
+// cleanAnalyzeTask 回收 结束 超过 analyzeTaskExpire 的 任务，调用 前 需 持有 analyzeTaskCacheLock
+func cleanAnalyzeTask() {
+	for taskKey, task := range analyzeTaskCache {
+		if task.isExpired() {
+			delete(analyzeTaskCache, taskKey)
+		}
+	}
+}
+
+func (this_ *AnalyzeTask) isExpired() bool {
+	this_.locker.Lock()
+	defer this_.locker.Unlock()
+	return this_.IsEnd && time.Since(this_.EndTime) > analyzeTaskExpire
+}
+
+func (this_ *AnalyzeTask) stop() {
+	this_.locker.Lock()
+	defer this_.locker.Unlock()
+	this_.IsStop = true
+}
+
+func (this_ *AnalyzeTask) needStop() bool {
+	this_.locker.Lock()
+	defer this_.locker.Unlock()
+	return this_.IsStop
+}
+
+// status 返回 当前 进度 和 报告 的 副本
+func (this_ *AnalyzeTask) status() *AnalyzeTask {
+	this_.locker.Lock()
+	defer this_.locker.Unlock()
+
+	if !this_.IsEnd {
+		this_.NowTime = time.Now()
+		this_.UseTime = util.GetMilliByTime(this_.NowTime) - util.GetMilliByTime(this_.StartTime)
+	}
+	res := *this_
+	res.Report = this_.report()
+	res.topKeys = nil
+	res.prefixes = nil
+	res.types = nil
+	res.ttls = nil
+	return &res
+}
+
+func (this_ *AnalyzeTask) start() {
+	var err error
+	defer func() {
+		if e := recover(); e != nil {
+			err = errors.New(fmt.Sprint(e))
+		}
+		this_.locker.Lock()
+		defer this_.locker.Unlock()
+		if err != nil {
+			this_.Error = err.Error()
+			util.Logger.Error("redis analyze task error", zap.Any("taskKey", this_.TaskKey), zap.Error(err))
+		}
+		this_.EndTime = time.Now()
+		this_.UseTime = util.GetMilliByTime(this_.EndTime) - util.GetMilliByTime(this_.StartTime)
+		this_.IsEnd = true
+	}()
+
+	client, param, err := getClient(this_.service, this_.Database)
+	if err != nil {
+		return
+	}
+	ctx := param.Ctx
+
+	// 单机 使用 独立 连接 固定 库，集群 依次 扫描 每个 主节点
+	var targets []goRedis.Cmdable
+	switch tV := client.(type) {
+	case *goRedis.Client:
+		conn := tV.Conn(ctx)
+		defer func() { _ = conn.Close() }()
+		err = conn.Select(ctx, this_.Database).Err()
+		if err != nil {
+			return
+		}
+		targets = append(targets, conn)
+	case *goRedis.ClusterClient:
+		var targetsLocker sync.Mutex
+		err = tV.ForEachMaster(ctx, func(ctx context.Context, master *goRedis.Client) error {
+			targetsLocker.Lock()
+			defer targetsLocker.Unlock()
+			targets = append(targets, master)
+			return nil
+		})
+		if err != nil {
+			return
+		}
+	default:
+		targets = append(targets, client)
+	}
+
+	for _, target := range targets {
+		size, e := target.DBSize(ctx).Result()
+		if e == nil {
+			this_.locker.Lock()
+			this_.TotalKeys += size
+			this_.locker.Unlock()
+		}
+	}
+
+	var analyzed int64
+	// 限速 计数，每次 SCAN 按 COUNT 计，返回 key 数 更多 时 按 返回 数 计，空 结果 的 SCAN 也 会 限速
+	var throttled int64
+	var startTime = time.Now()
+	var keepAliveTime = time.Now()
+	for _, target := range targets {
+		var cursor uint64
+		for {
+			if this_.needStop() {
+				return
+			}
+			var keys []string
+			keys, cursor, err = target.Scan(ctx, cursor, this_.Pattern, this_.ScanCount).Result()
+			if err != nil {
+				return
+			}
+			if int64(len(keys)) > this_.ScanCount {
+				throttled += int64(len(keys))
+			} else {
+				throttled += this_.ScanCount
+			}
+			if this_.MaxKeys > 0 && analyzed+int64(len(keys)) > this_.MaxKeys {
+				keys = keys[:this_.MaxKeys-analyzed]
+			}
+			if len(keys) > 0 {
+				err = this_.analyzeKeys(ctx, target, keys)
+				if err != nil {
+					return
+				}
+				analyzed += int64(len(keys))
+			}
+			if cursor == 0 || (this_.MaxKeys > 0 && analyzed >= this_.MaxKeys) {
+				break
+			}
+			if time.Since(keepAliveTime) > time.Minute {
+				keepAliveTime = time.Now()
+				this_.keepAlive()
+			}
+			// 限速，按 限速 计数 计算 应 耗时，不足 则 等待
+			shouldUse := time.Duration(throttled * int64(time.Second) / this_.MaxKeysPerSecond)
+			if wait := shouldUse - time.Since(startTime); wait > 0 {
+				time.Sleep(wait)
+			}
+		}
+		if this_.MaxKeys > 0 && analyzed >= this_.MaxKeys {
+			break
+		}
+	}
+}
+
+// analyzeKeys 使用 管道 批量 查询 类型、内存、过期 时间，再 按 类型 查询 元素 数
+func (this_ *AnalyzeTask) analyzeKeys(ctx context.Context, target goRedis.Cmdable, keys []string) (err error) {
+	pipe := target.Pipeline()
+	typeCmdList := make([]*goRedis.StatusCmd, len(keys))
+	memoryCmdList := make([]*goRedis.IntCmd, len(keys))
+	ttlCmdList := make([]*goRedis.DurationCmd, len(keys))
+	for i, key := range keys {
+		typeCmdList[i] = pipe.Type(ctx, key)
+		memoryCmdList[i] = pipe.MemoryUsage(ctx, key, this_.Samples)
+		ttlCmdList[i] = pipe.PTTL(ctx, key)
+	}
+	// 单个 命令 错误 不 影响 其它 key，按 key 统计 错误
+	_, _ = pipe.Exec(ctx)
+
+	var infoList []*AnalyzeKey
+	var countCmdList []*goRedis.IntCmd
+	pipe = target.Pipeline()
+	var errorCount int64
+	for i, key := range keys {
+		keyType, e := typeCmdList[i].Result()
+		if e != nil {
+			errorCount++
+			continue
+		}
+		// key 在 扫描 后 已 删除
+		if keyType == "none" {
+			continue
+		}
+		info := &AnalyzeKey{
+			Key:  key,
+			Type: keyType,
+		}
+		info.Memory, e = memoryCmdList[i].Result()
+		if e != nil {
+			errorCount++
+		}
+		ttl, _ := ttlCmdList[i].Result()
+		if ttl < 0 {
+			info.Ttl = -1
+		} else {
+			info.Ttl = ttl.Milliseconds()
+		}
+		var countCmd *goRedis.IntCmd
+		switch keyType {
+		case "string":
+			countCmd = pipe.StrLen(ctx, key)
+		case "list":
+			countCmd = pipe.LLen(ctx, key)
+		case "set":
+			countCmd = pipe.SCard(ctx, key)
+		case "zset":
+			countCmd = pipe.ZCard(ctx, key)
+		case "hash":
+			countCmd = pipe.HLen(ctx, key)
+		case "stream":
+			countCmd = pipe.XLen(ctx, key)
+		}
+		infoList = append(infoList, info)
+		countCmdList = append(countCmdList, countCmd)
+	}
+	if pipe.Len() > 0 {
+		_, _ = pipe.Exec(ctx)
+	}
+	for i, info := range infoList {
+		if countCmdList[i] != nil {
+			info.Count, _ = countCmdList[i].Result()
+		}
+	}
+
+	this_.locker.Lock()
+	defer this_.locker.Unlock()
+	this_.ScannedKeys += int64(len(keys))
+	this_.ErrorKeys += errorCount
+	for _, info := range infoList {
+		this_.add(info)
+	}
+	return
+}
+
+func (this_ *AnalyzeTask) add(info *AnalyzeKey) {
+	this_.AnalyzedKeys++
+	this_.memory += info.Memory
+
+	this_.topKeys = append(this_.topKeys, info)
+	if len(this_.topKeys) >= this_.TopN*2 {
+		this_.sortTopKeys()
+	}
+
+	prefix := this_.keyPrefix(info.Key)
+	if this_.prefixes[prefix] == nil && len(this_.prefixes) >= analyzeMaxPrefixCount {
+		prefix = analyzeOtherPrefix
+	}
+	addAnalyzeGroup(this_.prefixes, prefix, info)
+	addAnalyzeGroup(this_.types, info.Type, info)
+	addAnalyzeGroup(this_.ttls, analyzeTtlBucket(info.Ttl), info)
+}
+
+func (this_ *AnalyzeTask) sortTopKeys() {
+	sort.Slice(this_.topKeys, func(i, j int) bool {
+		return this_.topKeys[i].Memory > this_.topKeys[j].Memory
+	})
+	if len(this_.topKeys) > this_.TopN {
+		this_.topKeys = this_.topKeys[:this_.TopN]
+	}
+}
+
+// keyPrefix 取 前 PrefixDepth 段 作为 前缀，如 "user:1:name" 为 "user:*"，没有 分隔符 的 key 前缀 为 空
+func (this_ *AnalyzeTask) keyPrefix(key string) string {
+	ss := strings.Split(key, this_.Delimiter)
+	if len(ss) <= 1 {
+		return ""
+	}
+	depth := this_.PrefixDepth
+	if depth > len(ss)-1 {
+		depth = len(ss) - 1
+	}
+	return strings.Join(ss[:depth], this_.Delimiter) + this_.Delimiter + "*"
+}
+
+func analyzeTtlBucket(ttl int64) string {
+	if ttl < 0 {
+		return "never"
+	}
+	for _, bucket := range analyzeTtlBuckets {
+		if time.Duration(ttl)*time.Millisecond < bucket.max {
+			return bucket.name
+		}
+	}
+	return ">30d"
+}
+
+func addAnalyzeGroup(groups map[string]*AnalyzeGroup, name string, info *AnalyzeKey) {
+	group := groups[name]
+	if group == nil {
+		group = &AnalyzeGroup{Name: name}
+		groups[name] = group
+	}
+	group.KeyCount++
+	group.Memory += info.Memory
+	group.Count += info.Count
+}
+
+func sortedAnalyzeGroups(groups map[string]*AnalyzeGroup) (list []*AnalyzeGroup) {
+	for _, group := range groups {
+		one := *group
+		list = append(list, &one)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Memory != list[j].Memory {
+			return list[i].Memory > list[j].Memory
+		}
+		return list[i].Name < list[j].Name
+	})
+	return
+}
+
+// report 根据 当前 统计 生成 报告，调用 前 需 加锁
+func (this_ *AnalyzeTask) report() (report *AnalyzeReport) {
+	this_.sortTopKeys()
+	report = &AnalyzeReport{
+		TotalMemory: this_.memory,
+		PrefixCount: len(this_.prefixes),
+		TypeList:    sortedAnalyzeGroups(this_.types),
+	}
+	for _, one := range this_.topKeys {
+		key := *one
+		report.TopKeys = append(report.TopKeys, &key)
+	}
+	report.PrefixList = sortedAnalyzeGroups(this_.prefixes)
+	if len(report.PrefixList) > this_.TopN {
+		report.PrefixList = report.PrefixList[:this_.TopN]
+	}
+	// 过期 时间 按 区间 顺序
+	for _, name := range append([]string{"never"}, analyzeTtlBucketNames()...) {
+		if group := this_.ttls[name]; group != nil {
+			one := *group
+			report.TtlList = append(report.TtlList, &one)
+		}
+	}
+	return
+}
+
+func analyzeTtlBucketNames() (names []string) {
+	for _, bucket := range analyzeTtlBuckets {
+		names = append(names, bucket.name)
+	}
+	names = append(names, ">30d")
+	return
+}
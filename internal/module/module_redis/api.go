@@ -23,26 +23,69 @@ func NewApi(toolboxService *module_toolbox.ToolboxService) *api {
 }
 
 var (
-	Power              = base.AppendPower(&base.PowerAction{Action: "redis", Text: "Redis", ShouldLogin: true, StandAlone: true})
-	check              = base.AppendPower(&base.PowerAction{Action: "check", Text: "Redis测试", ShouldLogin: true, StandAlone: true, Parent: Power})
-	infoPower          = base.AppendPower(&base.PowerAction{Action: "info", Text: "Redis信息", ShouldLogin: true, StandAlone: true, Parent: Power})
-	getPower           = base.AppendPower(&base.PowerAction{Action: "get", Text: "Redis获取Key值", ShouldLogin: true, StandAlone: true, Parent: Power})
-	keysPower          = base.AppendPower(&base.PowerAction{Action: "keys", Text: "Redis查询Keys", ShouldLogin: true, StandAlone: true, Parent: Power})
-	setPower           = base.AppendPower(&base.PowerAction{Action: "set", Text: "Redis设置值", ShouldLogin: true, StandAlone: true, Parent: Power})
-	saddPower          = base.AppendPower(&base.PowerAction{Action: "sadd", Text: "Redis SAdd", ShouldLogin: true, StandAlone: true, Parent: Power})
-	sremPower          = base.AppendPower(&base.PowerAction{Action: "srem", Text: "Redis SRem", ShouldLogin: true, StandAlone: true, Parent: Power})
-	lpushPower         = base.AppendPower(&base.PowerAction{Action: "lpush", Text: "Redis LPush", ShouldLogin: true, StandAlone: true, Parent: Power})
-	rpushPower         = base.AppendPower(&base.PowerAction{Action: "rpush", Text: "Redis RPush", ShouldLogin: true, StandAlone: true, Parent: Power})
-	lsetPower          = base.AppendPower(&base.PowerAction{Action: "lset", Text: "Redis LSet", ShouldLogin: true, StandAlone: true, Parent: Power})
-	lremPower          = base.AppendPower(&base.PowerAction{Action: "lrem", Text: "Redis LRem", ShouldLogin: true, StandAlone: true, Parent: Power})
-	hsetPower          = base.AppendPower(&base.PowerAction{Action: "hset", Text: "Redis HSet", ShouldLogin: true, StandAlone: true, Parent: Power})
-	hdelPower          = base.AppendPower(&base.PowerAction{Action: "hdel", Text: "Redis HDel", ShouldLogin: true, StandAlone: true, Parent: Power})
-	deletePower        = base.AppendPower(&base.PowerAction{Action: "delete", Text: "Redis删除Key", ShouldLogin: true, StandAlone: true, Parent: Power})
-	deletePatternPower = base.AppendPower(&base.PowerAction{Action: "deletePattern", Text: "Redis删除匹配Key", ShouldLogin: true, StandAlone: true, Parent: Power})
-	expirePower        = base.AppendPower(&base.PowerAction{Action: "expire", Text: "Redis设置过期", ShouldLogin: true, StandAlone: true, Parent: Power})
-	ttlPower           = base.AppendPower(&base.PowerAction{Action: "ttl", Text: "Redis过期时间查询", ShouldLogin: true, StandAlone: true, Parent: Power})
-	persistPower       = base.AppendPower(&base.PowerAction{Action: "persist", Text: "Redis移除过期时间", ShouldLogin: true, StandAlone: true, Parent: Power})
-	closePower         = base.AppendPower(&base.PowerAction{Action: "close", Text: "Redis关闭", ShouldLogin: true, StandAlone: true, Parent: Power})
+	Power                 = base.AppendPower(&base.PowerAction{Action: "redis", Text: "Redis", ShouldLogin: true, StandAlone: true})
+	check                 = base.AppendPower(&base.PowerAction{Action: "check", Text: "Redis测试", ShouldLogin: true, StandAlone: true, Parent: Power})
+	infoPower             = base.AppendPower(&base.PowerAction{Action: "info", Text: "Redis信息", ShouldLogin: true, StandAlone: true, Parent: Power})
+	getPower              = base.AppendPower(&base.PowerAction{Action: "get", Text: "Redis获取Key值", ShouldLogin: true, StandAlone: true, Parent: Power})
+	keysPower             = base.AppendPower(&base.PowerAction{Action: "keys", Text: "Redis查询Keys", ShouldLogin: true, StandAlone: true, Parent: Power})
+	setPower              = base.AppendPower(&base.PowerAction{Action: "set", Text: "Redis设置值", ShouldLogin: true, StandAlone: true, Parent: Power})
+	saddPower             = base.AppendPower(&base.PowerAction{Action: "sadd", Text: "Redis SAdd", ShouldLogin: true, StandAlone: true, Parent: Power})
+	sremPower             = base.AppendPower(&base.PowerAction{Action: "srem", Text: "Redis SRem", ShouldLogin: true, StandAlone: true, Parent: Power})
+	lpushPower            = base.AppendPower(&base.PowerAction{Action: "lpush", Text: "Redis LPush", ShouldLogin: true, StandAlone: true, Parent: Power})
+	rpushPower            = base.AppendPower(&base.PowerAction{Action: "rpush", Text: "Redis RPush", ShouldLogin: true, StandAlone: true, Parent: Power})
+	lsetPower             = base.AppendPower(&base.PowerAction{Action: "lset", Text: "Redis LSet", ShouldLogin: true, StandAlone: true, Parent: Power})
+	lremPower             = base.AppendPower(&base.PowerAction{Action: "lrem", Text: "Redis LRem", ShouldLogin: true, StandAlone: true, Parent: Power})
+	hsetPower             = base.AppendPower(&base.PowerAction{Action: "hset", Text: "Redis HSet", ShouldLogin: true, StandAlone: true, Parent: Power})
+	hdelPower             = base.AppendPower(&base.PowerAction{Action: "hdel", Text: "Redis HDel", ShouldLogin: true, StandAlone: true, Parent: Power})
+	zaddPower             = base.AppendPower(&base.PowerAction{Action: "zadd", Text: "Redis ZAdd", ShouldLogin: true, StandAlone: true, Parent: Power})
+	zremPower             = base.AppendPower(&base.PowerAction{Action: "zrem", Text: "Redis ZRem", ShouldLogin: true, StandAlone: true, Parent: Power})
+	zincrbyPower          = base.AppendPower(&base.PowerAction{Action: "zincrby", Text: "Redis ZIncrBy", ShouldLogin: true, StandAlone: true, Parent: Power})
+	zrangePower           = base.AppendPower(&base.PowerAction{Action: "zrange", Text: "Redis ZRange", ShouldLogin: true, StandAlone: true, Parent: Power})
+	xaddPower             = base.AppendPower(&base.PowerAction{Action: "xadd", Text: "Redis XAdd", ShouldLogin: true, StandAlone: true, Parent: Power})
+	xrangePower           = base.AppendPower(&base.PowerAction{Action: "xrange", Text: "Redis XRange", ShouldLogin: true, StandAlone: true, Parent: Power})
+	xrevrangePower        = base.AppendPower(&base.PowerAction{Action: "xrevrange", Text: "Redis XRevRange", ShouldLogin: true, StandAlone: true, Parent: Power})
+	xdelPower             = base.AppendPower(&base.PowerAction{Action: "xdel", Text: "Redis XDel", ShouldLogin: true, StandAlone: true, Parent: Power})
+	xtrimPower            = base.AppendPower(&base.PowerAction{Action: "xtrim", Text: "Redis XTrim", ShouldLogin: true, StandAlone: true, Parent: Power})
+	xgroupsPower          = base.AppendPower(&base.PowerAction{Action: "xgroups", Text: "Redis Stream消费组查询", ShouldLogin: true, StandAlone: true, Parent: Power})
+	xgroupCreatePower     = base.AppendPower(&base.PowerAction{Action: "xgroupCreate", Text: "Redis Stream消费组创建", ShouldLogin: true, StandAlone: true, Parent: Power})
+	xpendingPower         = base.AppendPower(&base.PowerAction{Action: "xpending", Text: "Redis XPending", ShouldLogin: true, StandAlone: true, Parent: Power})
+	xclaimPower           = base.AppendPower(&base.PowerAction{Action: "xclaim", Text: "Redis XClaim", ShouldLogin: true, StandAlone: true, Parent: Power})
+	xackPower             = base.AppendPower(&base.PowerAction{Action: "xack", Text: "Redis XAck", ShouldLogin: true, StandAlone: true, Parent: Power})
+	pubsubKeyPower        = base.AppendPower(&base.PowerAction{Action: "pubsubKey", Text: "Redis订阅会话", ShouldLogin: true, StandAlone: true, Parent: Power})
+	pubsubWebsocketPower  = base.AppendPower(&base.PowerAction{Action: "pubsubWebsocket", Text: "Redis订阅WebSocket", ShouldLogin: true, StandAlone: true, Parent: Power})
+	publishPower          = base.AppendPower(&base.PowerAction{Action: "publish", Text: "Redis Publish", ShouldLogin: true, StandAlone: true, Parent: Power})
+	notifyConfigPower     = base.AppendPower(&base.PowerAction{Action: "notifyConfig", Text: "Redis键空间通知配置", ShouldLogin: true, StandAlone: true, Parent: Power})
+	analyzePower          = base.AppendPower(&base.PowerAction{Action: "analyze", Text: "Redis内存分析", ShouldLogin: true, StandAlone: true, Parent: Power})
+	analyzeStatusPower    = base.AppendPower(&base.PowerAction{Action: "analyzeStatus", Text: "Redis内存分析状态", ShouldLogin: true, StandAlone: true, Parent: Power})
+	analyzeStopPower      = base.AppendPower(&base.PowerAction{Action: "analyzeStop", Text: "Redis内存分析停止", ShouldLogin: true, StandAlone: true, Parent: Power})
+	analyzeCleanPower     = base.AppendPower(&base.PowerAction{Action: "analyzeClean", Text: "Redis内存分析清理", ShouldLogin: true, StandAlone: true, Parent: Power})
+	evalPower             = base.AppendPower(&base.PowerAction{Action: "eval", Text: "Redis执行脚本", ShouldLogin: true, StandAlone: true, Parent: Power})
+	evalshaPower          = base.AppendPower(&base.PowerAction{Action: "evalsha", Text: "Redis执行脚本SHA", ShouldLogin: true, StandAlone: true, Parent: Power})
+	scriptLoadPower       = base.AppendPower(&base.PowerAction{Action: "scriptLoad", Text: "Redis脚本加载", ShouldLogin: true, StandAlone: true, Parent: Power})
+	scriptExistsPower     = base.AppendPower(&base.PowerAction{Action: "scriptExists", Text: "Redis脚本检查", ShouldLogin: true, StandAlone: true, Parent: Power})
+	scriptFlushPower      = base.AppendPower(&base.PowerAction{Action: "scriptFlush", Text: "Redis脚本清空", ShouldLogin: true, StandAlone: true, Parent: Power})
+	scriptListPower       = base.AppendPower(&base.PowerAction{Action: "scriptList", Text: "Redis脚本列表", ShouldLogin: true, StandAlone: true, Parent: Power})
+	scriptSavePower       = base.AppendPower(&base.PowerAction{Action: "scriptSave", Text: "Redis脚本保存", ShouldLogin: true, StandAlone: true, Parent: Power})
+	scriptDeletePower     = base.AppendPower(&base.PowerAction{Action: "scriptDelete", Text: "Redis脚本删除", ShouldLogin: true, StandAlone: true, Parent: Power})
+	slowlogPower          = base.AppendPower(&base.PowerAction{Action: "slowlog", Text: "Redis慢日志", ShouldLogin: true, StandAlone: true, Parent: Power})
+	slowlogResetPower     = base.AppendPower(&base.PowerAction{Action: "slowlogReset", Text: "Redis慢日志清空", ShouldLogin: true, StandAlone: true, Parent: Power})
+	clientListPower       = base.AppendPower(&base.PowerAction{Action: "clientList", Text: "Redis客户端列表", ShouldLogin: true, StandAlone: true, Parent: Power})
+	clientKillPower       = base.AppendPower(&base.PowerAction{Action: "clientKill", Text: "Redis客户端关闭", ShouldLogin: true, StandAlone: true, Parent: Power})
+	configGetPower        = base.AppendPower(&base.PowerAction{Action: "configGet", Text: "Redis配置查询", ShouldLogin: true, StandAlone: true, Parent: Power})
+	configSetPower        = base.AppendPower(&base.PowerAction{Action: "configSet", Text: "Redis配置设置", ShouldLogin: true, StandAlone: true, Parent: Power})
+	latencyPower          = base.AppendPower(&base.PowerAction{Action: "latency", Text: "Redis延迟诊断", ShouldLogin: true, StandAlone: true, Parent: Power})
+	infoSamplerStartPower = base.AppendPower(&base.PowerAction{Action: "infoSamplerStart", Text: "Redis指标采集开始", ShouldLogin: true, StandAlone: true, Parent: Power})
+	infoSamplerDataPower  = base.AppendPower(&base.PowerAction{Action: "infoSamplerData", Text: "Redis指标采集数据", ShouldLogin: true, StandAlone: true, Parent: Power})
+	infoSamplerStopPower  = base.AppendPower(&base.PowerAction{Action: "infoSamplerStop", Text: "Redis指标采集停止", ShouldLogin: true, StandAlone: true, Parent: Power})
+	commandPower          = base.AppendPower(&base.PowerAction{Action: "command", Text: "Redis命令执行", ShouldLogin: true, StandAlone: true, Parent: Power})
+	commandDenyPower      = base.AppendPower(&base.PowerAction{Action: "commandDeny", Text: "Redis禁止命令查询", ShouldLogin: true, StandAlone: true, Parent: Power})
+	commandDenySavePower  = base.AppendPower(&base.PowerAction{Action: "commandDenySave", Text: "Redis禁止命令保存", ShouldLogin: true, StandAlone: true, Parent: Power})
+	deletePower           = base.AppendPower(&base.PowerAction{Action: "delete", Text: "Redis删除Key", ShouldLogin: true, StandAlone: true, Parent: Power})
+	deletePatternPower    = base.AppendPower(&base.PowerAction{Action: "deletePattern", Text: "Redis删除匹配Key", ShouldLogin: true, StandAlone: true, Parent: Power})
+	expirePower           = base.AppendPower(&base.PowerAction{Action: "expire", Text: "Redis设置过期", ShouldLogin: true, StandAlone: true, Parent: Power})
+	ttlPower              = base.AppendPower(&base.PowerAction{Action: "ttl", Text: "Redis过期时间查询", ShouldLogin: true, StandAlone: true, Parent: Power})
+	persistPower          = base.AppendPower(&base.PowerAction{Action: "persist", Text: "Redis移除过期时间", ShouldLogin: true, StandAlone: true, Parent: Power})
+	closePower            = base.AppendPower(&base.PowerAction{Action: "close", Text: "Redis关闭", ShouldLogin: true, StandAlone: true, Parent: Power})
 )
 
 func (this_ *api) GetApis() (apis []*base.ApiWorker) {
@@ -59,6 +102,50 @@ func (this_ *api) GetApis() (apis []*base.ApiWorker) {
 	apis = append(apis, &base.ApiWorker{Power: lremPower, Do: this_.lrem})
 	apis = append(apis, &base.ApiWorker{Power: hsetPower, Do: this_.hset})
 	apis = append(apis, &base.ApiWorker{Power: hdelPower, Do: this_.hdel})
+	apis = append(apis, &base.ApiWorker{Power: zaddPower, Do: this_.zadd})
+	apis = append(apis, &base.ApiWorker{Power: zremPower, Do: this_.zrem})
+	apis = append(apis, &base.ApiWorker{Power: zincrbyPower, Do: this_.zincrby})
+	apis = append(apis, &base.ApiWorker{Power: zrangePower, Do: this_.zrange})
+	apis = append(apis, &base.ApiWorker{Power: xaddPower, Do: this_.xadd})
+	apis = append(apis, &base.ApiWorker{Power: xrangePower, Do: this_.xrange})
+	apis = append(apis, &base.ApiWorker{Power: xrevrangePower, Do: this_.xrevrange})
+	apis = append(apis, &base.ApiWorker{Power: xdelPower, Do: this_.xdel})
+	apis = append(apis, &base.ApiWorker{Power: xtrimPower, Do: this_.xtrim})
+	apis = append(apis, &base.ApiWorker{Power: xgroupsPower, Do: this_.xgroups})
+	apis = append(apis, &base.ApiWorker{Power: xgroupCreatePower, Do: this_.xgroupCreate})
+	apis = append(apis, &base.ApiWorker{Power: xpendingPower, Do: this_.xpending})
+	apis = append(apis, &base.ApiWorker{Power: xclaimPower, Do: this_.xclaim})
+	apis = append(apis, &base.ApiWorker{Power: xackPower, Do: this_.xack})
+	apis = append(apis, &base.ApiWorker{Power: pubsubKeyPower, Do: this_.pubsubKey})
+	apis = append(apis, &base.ApiWorker{Power: pubsubWebsocketPower, Do: this_.pubsubWebsocket, IsWebSocket: true})
+	apis = append(apis, &base.ApiWorker{Power: publishPower, Do: this_.publish})
+	apis = append(apis, &base.ApiWorker{Power: notifyConfigPower, Do: this_.notifyConfig})
+	apis = append(apis, &base.ApiWorker{Power: analyzePower, Do: this_.analyze})
+	apis = append(apis, &base.ApiWorker{Power: analyzeStatusPower, Do: this_.analyzeStatus})
+	apis = append(apis, &base.ApiWorker{Power: analyzeStopPower, Do: this_.analyzeStop})
+	apis = append(apis, &base.ApiWorker{Power: analyzeCleanPower, Do: this_.analyzeClean})
+	// 脚本 可 修改、删除 任意 Key，需要 同时 有 设置 和 删除 权限
+	apis = append(apis, &base.ApiWorker{Power: evalPower, Do: this_.eval, AlsoPowers: []*base.PowerAction{setPower, deletePower}})
+	apis = append(apis, &base.ApiWorker{Power: evalshaPower, Do: this_.evalsha, AlsoPowers: []*base.PowerAction{setPower, deletePower}})
+	apis = append(apis, &base.ApiWorker{Power: scriptLoadPower, Do: this_.scriptLoad})
+	apis = append(apis, &base.ApiWorker{Power: scriptExistsPower, Do: this_.scriptExists})
+	apis = append(apis, &base.ApiWorker{Power: scriptFlushPower, Do: this_.scriptFlush})
+	apis = append(apis, &base.ApiWorker{Power: scriptListPower, Do: this_.scriptList})
+	apis = append(apis, &base.ApiWorker{Power: scriptSavePower, Do: this_.scriptSave})
+	apis = append(apis, &base.ApiWorker{Power: scriptDeletePower, Do: this_.scriptDelete})
+	apis = append(apis, &base.ApiWorker{Power: slowlogPower, Do: this_.slowlog})
+	apis = append(apis, &base.ApiWorker{Power: slowlogResetPower, Do: this_.slowlogReset})
+	apis = append(apis, &base.ApiWorker{Power: clientListPower, Do: this_.clientList})
+	apis = append(apis, &base.ApiWorker{Power: clientKillPower, Do: this_.clientKill})
+	apis = append(apis, &base.ApiWorker{Power: configGetPower, Do: this_.configGet})
+	apis = append(apis, &base.ApiWorker{Power: configSetPower, Do: this_.configSet})
+	apis = append(apis, &base.ApiWorker{Power: latencyPower, Do: this_.latency})
+	apis = append(apis, &base.ApiWorker{Power: infoSamplerStartPower, Do: this_.infoSamplerStart})
+	apis = append(apis, &base.ApiWorker{Power: infoSamplerDataPower, Do: this_.infoSamplerData})
+	apis = append(apis, &base.ApiWorker{Power: infoSamplerStopPower, Do: this_.infoSamplerStop})
+	apis = append(apis, &base.ApiWorker{Power: commandPower, Do: this_.command})
+	apis = append(apis, &base.ApiWorker{Power: commandDenyPower, Do: this_.commandDeny})
+	apis = append(apis, &base.ApiWorker{Power: commandDenySavePower, Do: this_.commandDenySave})
 	apis = append(apis, &base.ApiWorker{Power: deletePower, Do: this_.delete})
 	apis = append(apis, &base.ApiWorker{Power: deletePatternPower, Do: this_.deletePattern})
 	apis = append(apis, &base.ApiWorker{Power: expirePower, Do: this_.expire})
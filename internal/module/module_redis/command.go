@@ -0,0 +1,510 @@
+package module_redis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	goRedis "github.com/go-redis/redis/v8"
+	"sort"
+	"strconv"
+	"strings"
+	"teamide/internal/module/module_toolbox"
+	"teamide/pkg/base"
+	"time"
+)
+
+// commandDenyExtendType 保存 在 工具箱 扩展 中 的 命令 禁止 列表 类型
+const commandDenyExtendType = "redisCommandDeny"
+
+// defaultCommandDenyList 未 配置 时 默认 禁止 的 命令
+var defaultCommandDenyList = []string{
+	"FLUSHALL",
+	"KEYS *",
+	"SHUTDOWN",
+	"DEBUG",
+}
+
+func init() {
+	// 禁止 命令 只能 由 工具 创建者 通过 commandDenySave 修改
+	module_toolbox.AddProtectedExtendType(commandDenyExtendType)
+}
+
+// commandScript 执行 脚本 的 命令，脚本 内 可 调用 任意 命令，开启 禁止 脚本 时 禁止
+var commandScript = map[string]bool{
+	"EVAL":       true,
+	"EVAL_RO":    true,
+	"EVALSHA":    true,
+	"EVALSHA_RO": true,
+	"FCALL":      true,
+	"FCALL_RO":   true,
+}
+
+// commandUnsupported 会 改变 连接 状态 或 持续 占用 连接 的 命令，控制台 不 支持
+var commandUnsupported = map[string]string{
+	"SELECT":       "请使用库选择",
+	"SWAPDB":       "",
+	"SUBSCRIBE":    "请使用订阅功能",
+	"PSUBSCRIBE":   "请使用订阅功能",
+	"SSUBSCRIBE":   "请使用订阅功能",
+	"UNSUBSCRIBE":  "请使用订阅功能",
+	"PUNSUBSCRIBE": "请使用订阅功能",
+	"MONITOR":      "",
+	"SYNC":         "",
+	"PSYNC":        "",
+	"MULTI":        "",
+	"EXEC":         "",
+	"DISCARD":      "",
+	"WATCH":        "",
+	"UNWATCH":      "",
+	"HELLO":        "",
+	"RESET":        "",
+	"QUIT":         "",
+}
+
+type CommandRequest struct {
+	Database   int      `json:"database"`
+	Command    string   `json:"command"` // 多行 时 每行 一个 命令，依次 执行
+	DenyList   []string `json:"denyList"`
+	DenyScript bool     `json:"denyScript"`
+}
+
+// CommandDeny 工具 的 禁止 命令 配置
+type CommandDeny struct {
+	Commands   []string `json:"commands"`
+	DenyScript bool     `json:"denyScript"` // 禁止 执行 脚本，脚本 内 的 命令 无法 校验
+}
+
+type CommandResult struct {
+	Command string   `json:"command"`
+	Args    []string `json:"args"`
+	Reply   *Reply   `json:"reply,omitempty"`
+	Error   string   `json:"error,omitempty"`
+	UseTime int64    `json:"useTime"`
+}
+
+// Reply 命令 返回 值，按 RESP 类型 区分，数组、集合 的 元素 在 List 中，map 的 键值 在 Entries 中
+type Reply struct {
+	Type    string        `json:"type"` // nil、integer、string、double、boolean、array、map、error
+	Value   interface{}   `json:"value,omitempty"`
+	List    []*Reply      `json:"list,omitempty"`
+	Entries []*ReplyEntry `json:"entries,omitempty"`
+}
+
+type ReplyEntry struct {
+	Key   *Reply `json:"key"`
+	Value *Reply `json:"value"`
+}
+
+// toReply 将 执行 结果 转为 回复，Redis 返回 的 错误 作为 error 类型 回复，连接 等 错误 直接 返回
+func toReply(value interface{}, err error) (reply *Reply, resErr error) {
+	var redisErr goRedis.Error
+	switch {
+	case err == goRedis.Nil:
+		reply = formatReply(nil)
+	case errors.As(err, &redisErr):
+		reply = formatReply(redisErr)
+	case err != nil:
+		resErr = err
+	default:
+		reply = formatReply(value)
+	}
+	return
+}
+
+func formatReply(value interface{}) (reply *Reply) {
+	reply = &Reply{}
+	switch v := value.(type) {
+	case nil:
+		reply.Type = "nil"
+	case int64:
+		reply.Type = "integer"
+		reply.Value = v
+	case string:
+		reply.Type = "string"
+		reply.Value = v
+	case float64:
+		reply.Type = "double"
+		reply.Value = v
+	case bool:
+		reply.Type = "boolean"
+		reply.Value = v
+	case []interface{}:
+		reply.Type = "array"
+		for _, one := range v {
+			reply.List = append(reply.List, formatReply(one))
+		}
+	case map[interface{}]interface{}:
+		reply.Type = "map"
+		for key, one := range v {
+			reply.Entries = append(reply.Entries, &ReplyEntry{Key: formatReply(key), Value: formatReply(one)})
+		}
+		sortReplyEntries(reply.Entries)
+	case map[string]interface{}:
+		reply.Type = "map"
+		for key, one := range v {
+			reply.Entries = append(reply.Entries, &ReplyEntry{Key: formatReply(key), Value: formatReply(one)})
+		}
+		sortReplyEntries(reply.Entries)
+	case error:
+		reply.Type = "error"
+		reply.Value = v.Error()
+	default:
+		reply.Type = fmt.Sprintf("%T", v)
+		reply.Value = v
+	}
+	return
+}
+
+// sortReplyEntries map 无序，按 键 排序 保证 展示 稳定
+func sortReplyEntries(entries []*ReplyEntry) {
+	sort.Slice(entries, func(i, j int) bool {
+		return fmt.Sprint(entries[i].Key.Value) < fmt.Sprint(entries[j].Key.Value)
+	})
+}
+
+// splitCommandArgs 按 redis-cli 的 规则 拆分 命令 参数，支持 双引号 转义 和 单引号
+func splitCommandArgs(line string) (args []string, err error) {
+	runes := []byte(line)
+	length := len(runes)
+	i := 0
+	for {
+		for i < length && isCommandSpace(runes[i]) {
+			i++
+		}
+		if i >= length {
+			return
+		}
+		var current []byte
+		inDouble := false
+		inSingle := false
+		done := false
+		for !done {
+			if inDouble {
+				if i >= length {
+					err = errors.New("双引号未闭合")
+					return
+				}
+				r := runes[i]
+				if r == '\\' && i+3 < length && runes[i+1] == 'x' && isHexRune(runes[i+2]) && isHexRune(runes[i+3]) {
+					b, _ := strconv.ParseUint(string(runes[i+2:i+4]), 16, 8)
+					current = append(current, byte(b))
+					i += 3
+				} else if r == '\\' && i+1 < length {
+					i++
+					switch runes[i] {
+					case 'n':
+						current = append(current, '\n')
+					case 'r':
+						current = append(current, '\r')
+					case 't':
+						current = append(current, '\t')
+					case 'b':
+						current = append(current, '\b')
+					case 'a':
+						current = append(current, '\a')
+					default:
+						current = append(current, runes[i])
+					}
+				} else if r == '"' {
+					// 闭合 引号 后 必须 是 空白 或 结束
+					if i+1 < length && !isCommandSpace(runes[i+1]) {
+						err = errors.New("引号后必须是空格")
+						return
+					}
+					done = true
+				} else {
+					current = append(current, r)
+				}
+			} else if inSingle {
+				if i >= length {
+					err = errors.New("单引号未闭合")
+					return
+				}
+				r := runes[i]
+				if r == '\\' && i+1 < length && runes[i+1] == '\'' {
+					i++
+					current = append(current, '\'')
+				} else if r == '\'' {
+					if i+1 < length && !isCommandSpace(runes[i+1]) {
+						err = errors.New("引号后必须是空格")
+						return
+					}
+					done = true
+				} else {
+					current = append(current, r)
+				}
+			} else {
+				if i >= length {
+					done = true
+					break
+				}
+				switch r := runes[i]; {
+				case isCommandSpace(r):
+					done = true
+				case r == '"':
+					inDouble = true
+				case r == '\'':
+					inSingle = true
+				default:
+					current = append(current, r)
+				}
+			}
+			if i < length {
+				i++
+			}
+		}
+		args = append(args, string(current))
+	}
+}
+
+func isCommandSpace(r byte) bool {
+	return r == ' ' || r == '\t' || r == '\n' || r == '\r' || r == 0
+}
+
+func isHexRune(r byte) bool {
+	return (r >= '0' && r <= '9') || (r >= 'a' && r <= 'f') || (r >= 'A' && r <= 'F')
+}
+
+// matchCommandDeny 判断 命令 是否 被 禁止，禁止 项 为 命令 加 可选 的 参数 前缀，如 "KEYS *"、"CONFIG SET"，不 区分 大小写
+func matchCommandDeny(args []string, denyList []string) (deny string, err error) {
+	for _, one := range denyList {
+		var denyArgs []string
+		denyArgs, err = splitCommandArgs(one)
+		if err != nil {
+			err = errors.New("禁止命令[" + one + "]格式错误:" + err.Error())
+			return
+		}
+		if len(denyArgs) == 0 || len(denyArgs) > len(args) {
+			continue
+		}
+		match := true
+		for i, denyArg := range denyArgs {
+			if !strings.EqualFold(denyArg, args[i]) {
+				match = false
+				break
+			}
+		}
+		if match {
+			deny = one
+			return
+		}
+	}
+	return
+}
+
+// checkCommandDeny 校验 命令 是否 被 禁止，开启 禁止 脚本 时 脚本 命令 也 被 禁止
+func checkCommandDeny(args []string, commandDeny *CommandDeny) (err error) {
+	deny, err := matchCommandDeny(args, commandDeny.Commands)
+	if err != nil {
+		return
+	}
+	line := strings.Join(args, " ")
+	if deny != "" {
+		err = errors.New("命令[" + line + "]已被禁止[" + deny + "]")
+		return
+	}
+	if commandDeny.DenyScript && len(args) > 0 && commandScript[strings.ToUpper(args[0])] {
+		err = errors.New("已禁止执行脚本命令[" + strings.ToUpper(args[0]) + "]，可由工具创建者关闭禁止脚本后执行")
+		return
+	}
+	return
+}
+
+// command 执行 命令 行，所有 命令 校验 通过 后 才 开始 执行
+func (this_ *api) command(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	config, sshConfig, err := this_.getConfig(requestBean, c)
+	if err != nil {
+		return
+	}
+	toolbox, err := this_.getStoredToolbox(requestBean)
+	if err != nil {
+		return
+	}
+	service, err := getService(config, sshConfig)
+	if err != nil {
+		return
+	}
+
+	request := &CommandRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	_, commandDeny, err := this_.getCommandDeny(toolbox)
+	if err != nil {
+		return
+	}
+
+	var results []*CommandResult
+	for _, line := range strings.Split(request.Command, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		result := &CommandResult{
+			Command: line,
+		}
+		result.Args, err = splitCommandArgs(line)
+		if err != nil {
+			err = errors.New("命令[" + line + "]解析失败:" + err.Error())
+			return
+		}
+		name := strings.ToUpper(result.Args[0])
+		if tip, find := commandUnsupported[name]; find {
+			err = errors.New("不支持命令[" + name + "]" + tip)
+			return
+		}
+		err = checkCommandDeny(result.Args, commandDeny)
+		if err != nil {
+			return
+		}
+		results = append(results, result)
+	}
+	if len(results) == 0 {
+		err = errors.New("命令不能为空")
+		return
+	}
+
+	client, param, err := getClient(service, request.Database)
+	if err != nil {
+		return
+	}
+	doer, ok := client.(interface {
+		Do(ctx context.Context, args ...interface{}) *goRedis.Cmd
+	})
+	if !ok {
+		err = errors.New("不支持的客户端类型:" + fmt.Sprintf("%T", client))
+		return
+	}
+	for _, result := range results {
+		var args []interface{}
+		for _, arg := range result.Args {
+			args = append(args, arg)
+		}
+		startTime := time.Now()
+		value, e := doer.Do(param.Ctx, args...).Result()
+		result.UseTime = time.Since(startTime).Milliseconds()
+		result.Reply, e = toReply(value, e)
+		if e != nil {
+			result.Error = e.Error()
+		}
+	}
+	res = results
+	return
+}
+
+// getCommandDeny 查询 工具 的 禁止 命令 配置，只 使用 工具 创建者 保存 的 配置，未 配置 时 返回 默认 列表
+func (this_ *api) getCommandDeny(toolbox *module_toolbox.ToolboxModel) (find *module_toolbox.ToolboxExtendModel, commandDeny *CommandDeny, err error) {
+	list, err := this_.toolboxService.QueryExtends(&module_toolbox.ToolboxExtendModel{
+		ToolboxId:  toolbox.ToolboxId,
+		ExtendType: commandDenyExtendType,
+	})
+	if err != nil {
+		return
+	}
+	for _, one := range list {
+		if one.UserId == toolbox.UserId {
+			find = one
+			break
+		}
+	}
+	commandDeny = &CommandDeny{}
+	if find == nil {
+		commandDeny.Commands = defaultCommandDenyList
+		return
+	}
+	commandDeny.Commands = []string{}
+	if commands, ok := find.Extend["commands"].([]interface{}); ok {
+		for _, one := range commands {
+			commandDeny.Commands = append(commandDeny.Commands, fmt.Sprint(one))
+		}
+	}
+	commandDeny.DenyScript, _ = find.Extend["denyScript"].(bool)
+	return
+}
+
+// checkRequestDeny 校验 接口 将要 执行 的 命令 是否 被 禁止
+func (this_ *api) checkRequestDeny(requestBean *base.RequestBean, args ...string) (err error) {
+	toolbox, err := this_.getStoredToolbox(requestBean)
+	if err != nil {
+		return
+	}
+	_, commandDeny, err := this_.getCommandDeny(toolbox)
+	if err != nil {
+		return
+	}
+	return checkCommandDeny(args, commandDeny)
+}
+
+func (this_ *api) commandDeny(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	_, _, err = this_.getConfig(requestBean, c)
+	if err != nil {
+		return
+	}
+	toolbox, err := this_.getStoredToolbox(requestBean)
+	if err != nil {
+		return
+	}
+
+	_, res, err = this_.getCommandDeny(toolbox)
+	return
+}
+
+// commandDenySave 保存 工具 的 禁止 命令 和 是否 禁止 脚本，命令 为 空 时 不 禁止 任何 命令，只有 工具 创建者 可以 保存
+func (this_ *api) commandDenySave(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	_, _, err = this_.getConfig(requestBean, c)
+	if err != nil {
+		return
+	}
+	toolbox, err := this_.getStoredToolbox(requestBean)
+	if err != nil {
+		return
+	}
+	if toolbox.UserId != requestBean.JWT.UserId {
+		err = errors.New("只有工具创建者可以修改禁止命令")
+		return
+	}
+
+	request := &CommandRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	var denyList = []string{}
+	for _, one := range request.DenyList {
+		one = strings.TrimSpace(one)
+		if one == "" {
+			continue
+		}
+		if _, e := splitCommandArgs(one); e != nil {
+			err = errors.New("禁止命令[" + one + "]格式错误:" + e.Error())
+			return
+		}
+		denyList = append(denyList, one)
+	}
+
+	data, _, err := this_.getCommandDeny(toolbox)
+	if err != nil {
+		return
+	}
+	if data == nil {
+		data = &module_toolbox.ToolboxExtendModel{
+			ToolboxId:  toolbox.ToolboxId,
+			ExtendType: commandDenyExtendType,
+			Name:       "Redis禁止命令",
+			UserId:     requestBean.JWT.UserId,
+		}
+	}
+	data.Extend = map[string]interface{}{
+		"commands":   denyList,
+		"denyScript": request.DenyScript,
+	}
+	err = this_.toolboxService.SaveExtend(data)
+	if err != nil {
+		return
+	}
+	res = &CommandDeny{
+		Commands:   denyList,
+		DenyScript: request.DenyScript,
+	}
+	return
+}
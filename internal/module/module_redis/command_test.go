@@ -0,0 +1,92 @@
+package module_redis
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSplitCommandArgs(t *testing.T) {
+	tests := []struct {
+		name     string
+		line     string
+		args     []string
+		hasError bool
+	}{
+		{"plain", "SET a 1", []string{"SET", "a", "1"}, false},
+		{"spaces", "  GET \t a  ", []string{"GET", "a"}, false},
+		{"empty", "   ", nil, false},
+		{"double quote", `SET a "b c"`, []string{"SET", "a", "b c"}, false},
+		{"double quote escape", `SET a "x\n\"y\""`, []string{"SET", "a", "x\n\"y\""}, false},
+		{"double quote hex", `SET a "\x41\x62"`, []string{"SET", "a", "Ab"}, false},
+		{"single quote", `SET a 'b "c"'`, []string{"SET", "a", `b "c"`}, false},
+		{"single quote escape", `SET a 'it\'s'`, []string{"SET", "a", "it's"}, false},
+		{"single quote backslash", `SET a 'x\n'`, []string{"SET", "a", `x\n`}, false},
+		{"empty quote", `SET a ""`, []string{"SET", "a", ""}, false},
+		{"unterminated double quote", `SET a "b`, nil, true},
+		{"unterminated single quote", `SET a 'b`, nil, true},
+		{"text after quote", `SET a "b"c`, nil, true},
+	}
+	for _, one := range tests {
+		t.Run(one.name, func(t *testing.T) {
+			args, err := splitCommandArgs(one.line)
+			if one.hasError {
+				if err == nil {
+					t.Fatalf("line [%s] should error, args %q", one.line, args)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("line [%s] error: %s", one.line, err)
+			}
+			if strings.Join(args, "|") != strings.Join(one.args, "|") || len(args) != len(one.args) {
+				t.Fatalf("line [%s] args %q, want %q", one.line, args, one.args)
+			}
+		})
+	}
+}
+
+func TestMatchCommandDeny(t *testing.T) {
+	denyList := []string{"FLUSHALL", "KEYS *", "CONFIG SET", `CONFIG "GET" dir`}
+	tests := []struct {
+		name string
+		args []string
+		deny string
+	}{
+		{"command", []string{"FLUSHALL"}, "FLUSHALL"},
+		{"ignore case", []string{"flushall", "ASYNC"}, "FLUSHALL"},
+		{"arg prefix", []string{"config", "set", "dir", "/tmp"}, "CONFIG SET"},
+		{"arg match", []string{"KEYS", "*"}, "KEYS *"},
+		{"arg not match", []string{"KEYS", "user:*"}, ""},
+		{"quoted deny", []string{"CONFIG", "GET", "DIR"}, `CONFIG "GET" dir`},
+		{"shorter args", []string{"CONFIG"}, ""},
+		{"other command", []string{"GET", "a"}, ""},
+	}
+	for _, one := range tests {
+		t.Run(one.name, func(t *testing.T) {
+			deny, err := matchCommandDeny(one.args, denyList)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if deny != one.deny {
+				t.Fatalf("args %q deny [%s], want [%s]", one.args, deny, one.deny)
+			}
+		})
+	}
+	if _, err := matchCommandDeny([]string{"GET"}, []string{`GET "a`}); err == nil {
+		t.Fatal("bad deny should error")
+	}
+}
+
+func TestCheckCommandDeny(t *testing.T) {
+	commandDeny := &CommandDeny{Commands: defaultCommandDenyList}
+	if err := checkCommandDeny([]string{"EVAL", "return 1", "0"}, commandDeny); err != nil {
+		t.Fatalf("script should be allowed: %s", err)
+	}
+	commandDeny.DenyScript = true
+	if err := checkCommandDeny([]string{"evalsha", "abc", "0"}, commandDeny); err == nil {
+		t.Fatal("script should be denied")
+	}
+	if err := checkCommandDeny([]string{"GET", "a"}, commandDeny); err != nil {
+		t.Fatalf("GET should be allowed: %s", err)
+	}
+}
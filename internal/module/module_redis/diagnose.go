@@ -0,0 +1,723 @@
+package module_redis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	goRedis "github.com/go-redis/redis/v8"
+	"github.com/team-ide/go-tool/redis"
+	"github.com/team-ide/go-tool/util"
+	"go.uber.org/zap"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"teamide/pkg/base"
+	"teamide/pkg/ssh"
+	"teamide/pkg/task"
+	"time"
+)
+
+type DiagnoseRequest struct {
+	Address     string `json:"address"` // 集群 时 指定 节点，为 空 时 所有 节点
+	Count       int64  `json:"count"`
+	ClientId    int64  `json:"clientId"`
+	ClientAddr  string `json:"clientAddr"`
+	Pattern     string `json:"pattern"`
+	Name        string `json:"name"`
+	Value       string `json:"value"`
+	OnlyChanged bool   `json:"onlyChanged"` // 只 返回 与 默认 值 不同 的 配置
+}
+
+// NodeResult 单个 节点 的 执行 结果，某个 节点 失败 不 影响 其它 节点
+type NodeResult struct {
+	Address string      `json:"address"`
+	Data    interface{} `json:"data,omitempty"`
+	Error   string      `json:"error,omitempty"`
+}
+
+type SlowLogEntry struct {
+	Id         int64    `json:"id"`
+	Time       int64    `json:"time"`
+	Duration   int64    `json:"duration"` // 微秒
+	Args       []string `json:"args"`
+	ClientAddr string   `json:"clientAddr"`
+	ClientName string   `json:"clientName"`
+}
+
+type ConfigItem struct {
+	Name       string `json:"name"`
+	Value      string `json:"value"`
+	Default    string `json:"default,omitempty"`
+	HasDefault bool   `json:"hasDefault"`
+	Changed    bool   `json:"changed"`
+}
+
+type LatencyEvent struct {
+	Event  string `json:"event"`
+	Time   int64  `json:"time"`
+	Latest int64  `json:"latest"` // 毫秒
+	Max    int64  `json:"max"`
+}
+
+// configDefaults 常用 配置 的 默认 值，以 Redis 6.2 为 准，不同 版本 可能 不同
+var configDefaults = map[string]string{
+	"maxmemory":                     "0",
+	"maxmemory-policy":              "noeviction",
+	"maxmemory-samples":             "5",
+	"maxclients":                    "10000",
+	"timeout":                       "0",
+	"tcp-keepalive":                 "300",
+	"tcp-backlog":                   "511",
+	"databases":                     "16",
+	"save":                          "3600 1 300 100 60 10000",
+	"appendonly":                    "no",
+	"appendfsync":                   "everysec",
+	"no-appendfsync-on-rewrite":     "no",
+	"auto-aof-rewrite-percentage":   "100",
+	"auto-aof-rewrite-min-size":     "67108864",
+	"rdbcompression":                "yes",
+	"rdbchecksum":                   "yes",
+	"stop-writes-on-bgsave-error":   "yes",
+	"slowlog-log-slower-than":       "10000",
+	"slowlog-max-len":               "128",
+	"latency-monitor-threshold":     "0",
+	"notify-keyspace-events":        "",
+	"hz":                            "10",
+	"dynamic-hz":                    "yes",
+	"lazyfree-lazy-eviction":        "no",
+	"lazyfree-lazy-expire":          "no",
+	"lazyfree-lazy-server-del":      "no",
+	"replica-lazy-flush":            "no",
+	"replica-read-only":             "yes",
+	"replica-serve-stale-data":      "yes",
+	"repl-backlog-size":             "1048576",
+	"repl-timeout":                  "60",
+	"min-replicas-to-write":         "0",
+	"min-replicas-max-lag":          "10",
+	"hash-max-ziplist-entries":      "512",
+	"hash-max-ziplist-value":        "64",
+	"list-max-ziplist-size":         "-2",
+	"list-compress-depth":           "0",
+	"set-max-intset-entries":        "512",
+	"zset-max-ziplist-entries":      "128",
+	"zset-max-ziplist-value":        "64",
+	"stream-node-max-bytes":         "4096",
+	"stream-node-max-entries":       "100",
+	"activerehashing":               "yes",
+	"activedefrag":                  "no",
+	"lua-time-limit":                "5000",
+	"cluster-node-timeout":          "15000",
+	"cluster-require-full-coverage": "yes",
+	"protected-mode":                "yes",
+	"io-threads":                    "1",
+	"loglevel":                      "notice",
+}
+
+// getNodes 获取 节点 客户端，集群 时 onlyMaster 只 返回 主节点，address 不为 空 时 只 返回 该 节点
+func getNodes(service redis.IService, address string, onlyMaster bool) (nodes []*goRedis.Client, ctx context.Context, err error) {
+	client, param, err := getClient(service, 0)
+	if err != nil {
+		return
+	}
+	ctx = param.Ctx
+
+	switch tV := client.(type) {
+	case *goRedis.Client:
+		nodes = append(nodes, tV)
+	case *goRedis.ClusterClient:
+		locker := &sync.Mutex{}
+		collect := func(ctx context.Context, node *goRedis.Client) error {
+			locker.Lock()
+			defer locker.Unlock()
+			nodes = append(nodes, node)
+			return nil
+		}
+		if onlyMaster {
+			err = tV.ForEachMaster(ctx, collect)
+		} else {
+			err = tV.ForEachShard(ctx, collect)
+		}
+		if err != nil {
+			return
+		}
+		sort.Slice(nodes, func(i, j int) bool {
+			return nodes[i].Options().Addr < nodes[j].Options().Addr
+		})
+	default:
+		err = errors.New("不支持的客户端类型:" + fmt.Sprintf("%T", client))
+		return
+	}
+
+	if address != "" {
+		var find []*goRedis.Client
+		for _, node := range nodes {
+			if node.Options().Addr == address {
+				find = append(find, node)
+			}
+		}
+		if len(find) == 0 {
+			err = errors.New("节点[" + address + "]不存在")
+			return
+		}
+		nodes = find
+	}
+	return
+}
+
+// forEachNode 依次 在 节点 上 执行，返回 每个 节点 的 结果
+func forEachNode(service redis.IService, address string, onlyMaster bool, do func(ctx context.Context, node *goRedis.Client) (interface{}, error)) (res []*NodeResult, err error) {
+	nodes, ctx, err := getNodes(service, address, onlyMaster)
+	if err != nil {
+		return
+	}
+	for _, node := range nodes {
+		one := &NodeResult{
+			Address: node.Options().Addr,
+		}
+		data, e := do(ctx, node)
+		if e != nil {
+			one.Error = e.Error()
+		} else {
+			one.Data = data
+		}
+		res = append(res, one)
+	}
+	return
+}
+
+func (this_ *api) slowlog(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	config, sshConfig, err := this_.getConfig(requestBean, c)
+	if err != nil {
+		return
+	}
+	service, err := getService(config, sshConfig)
+	if err != nil {
+		return
+	}
+
+	request := &DiagnoseRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	if request.Count <= 0 {
+		request.Count = 128
+	}
+	res, err = forEachNode(service, request.Address, false, func(ctx context.Context, node *goRedis.Client) (interface{}, error) {
+		logs, e := node.SlowLogGet(ctx, request.Count).Result()
+		if e != nil {
+			return nil, e
+		}
+		var list = []*SlowLogEntry{}
+		for _, one := range logs {
+			list = append(list, &SlowLogEntry{
+				Id:         one.ID,
+				Time:       util.GetMilliByTime(one.Time),
+				Duration:   one.Duration.Microseconds(),
+				Args:       one.Args,
+				ClientAddr: one.ClientAddr,
+				ClientName: one.ClientName,
+			})
+		}
+		return list, nil
+	})
+	return
+}
+
+func (this_ *api) slowlogReset(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	config, sshConfig, err := this_.getConfig(requestBean, c)
+	if err != nil {
+		return
+	}
+	service, err := getService(config, sshConfig)
+	if err != nil {
+		return
+	}
+
+	request := &DiagnoseRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	res, err = forEachNode(service, request.Address, false, func(ctx context.Context, node *goRedis.Client) (interface{}, error) {
+		return node.Do(ctx, "slowlog", "reset").Result()
+	})
+	return
+}
+
+func (this_ *api) clientList(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	config, sshConfig, err := this_.getConfig(requestBean, c)
+	if err != nil {
+		return
+	}
+	service, err := getService(config, sshConfig)
+	if err != nil {
+		return
+	}
+
+	request := &DiagnoseRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	res, err = forEachNode(service, request.Address, false, func(ctx context.Context, node *goRedis.Client) (interface{}, error) {
+		text, e := node.ClientList(ctx).Result()
+		if e != nil {
+			return nil, e
+		}
+		return parseClientList(text), nil
+	})
+	return
+}
+
+// parseClientList 解析 CLIENT LIST，每行 一个 客户端，格式 为 空格 分隔 的 key=value
+func parseClientList(text string) (list []map[string]string) {
+	list = []map[string]string{}
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		one := map[string]string{}
+		for _, field := range strings.Split(line, " ") {
+			index := strings.Index(field, "=")
+			if index < 0 {
+				continue
+			}
+			one[field[:index]] = field[index+1:]
+		}
+		list = append(list, one)
+	}
+	return
+}
+
+// clientKill 按 ClientId 或 ClientAddr 关闭 客户端 连接，返回 关闭 的 数量
+// 集群 各 节点 的 ClientId 独立 分配，按 ClientId 关闭 时 需要 使用 Address 指定 节点
+func (this_ *api) clientKill(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	config, sshConfig, err := this_.getConfig(requestBean, c)
+	if err != nil {
+		return
+	}
+	service, err := getService(config, sshConfig)
+	if err != nil {
+		return
+	}
+
+	request := &DiagnoseRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	var filter []string
+	if request.ClientId > 0 {
+		filter = append(filter, "ID", strconv.FormatInt(request.ClientId, 10))
+	}
+	if request.ClientAddr != "" {
+		filter = append(filter, "ADDR", request.ClientAddr)
+	}
+	if len(filter) == 0 {
+		err = errors.New("客户端ID或地址不能为空")
+		return
+	}
+	if request.ClientId > 0 && request.Address == "" {
+		client, _, e := getClient(service, 0)
+		if e != nil {
+			err = e
+			return
+		}
+		if _, ok := client.(*goRedis.ClusterClient); ok {
+			err = errors.New("集群按客户端ID关闭时节点地址不能为空")
+			return
+		}
+	}
+	res, err = forEachNode(service, request.Address, false, func(ctx context.Context, node *goRedis.Client) (interface{}, error) {
+		return node.ClientKillByFilter(ctx, filter...).Result()
+	})
+	return
+}
+
+// configGet 查询 配置，并 与 默认 值 比较，集群 时 使用 Address 指定 节点，为 空 使用 第一个 节点
+func (this_ *api) configGet(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	config, sshConfig, err := this_.getConfig(requestBean, c)
+	if err != nil {
+		return
+	}
+	service, err := getService(config, sshConfig)
+	if err != nil {
+		return
+	}
+
+	request := &DiagnoseRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	if request.Pattern == "" {
+		request.Pattern = "*"
+	}
+	nodes, ctx, err := getNodes(service, request.Address, false)
+	if err != nil {
+		return
+	}
+	values, err := nodes[0].ConfigGet(ctx, request.Pattern).Result()
+	if err != nil {
+		return
+	}
+	var list = []*ConfigItem{}
+	for i := 0; i+1 < len(values); i += 2 {
+		item := &ConfigItem{
+			Name:  fmt.Sprint(values[i]),
+			Value: fmt.Sprint(values[i+1]),
+		}
+		item.Default, item.HasDefault = configDefaults[item.Name]
+		item.Changed = item.HasDefault && item.Default != item.Value
+		if request.OnlyChanged && !item.Changed {
+			continue
+		}
+		list = append(list, item)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Name < list[j].Name
+	})
+	res = list
+	return
+}
+
+// configSet 设置 配置，集群 时 Address 为 空 则 设置 所有 节点
+func (this_ *api) configSet(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	config, sshConfig, err := this_.getConfig(requestBean, c)
+	if err != nil {
+		return
+	}
+	service, err := getService(config, sshConfig)
+	if err != nil {
+		return
+	}
+
+	request := &DiagnoseRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	if request.Name == "" {
+		err = errors.New("配置名称不能为空")
+		return
+	}
+	err = this_.checkRequestDeny(requestBean, "CONFIG", "SET", request.Name, request.Value)
+	if err != nil {
+		return
+	}
+	res, err = forEachNode(service, request.Address, false, func(ctx context.Context, node *goRedis.Client) (interface{}, error) {
+		return node.ConfigSet(ctx, request.Name, request.Value).Result()
+	})
+	return
+}
+
+// latency 返回 LATENCY DOCTOR 报告 和 LATENCY LATEST 事件，需要 设置 latency-monitor-threshold
+func (this_ *api) latency(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	config, sshConfig, err := this_.getConfig(requestBean, c)
+	if err != nil {
+		return
+	}
+	service, err := getService(config, sshConfig)
+	if err != nil {
+		return
+	}
+
+	request := &DiagnoseRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	res, err = forEachNode(service, request.Address, false, func(ctx context.Context, node *goRedis.Client) (interface{}, error) {
+		doctor, e := node.Do(ctx, "latency", "doctor").Text()
+		if e != nil {
+			return nil, e
+		}
+		latest, e := node.Do(ctx, "latency", "latest").Slice()
+		if e != nil {
+			return nil, e
+		}
+		var events = []*LatencyEvent{}
+		for _, one := range latest {
+			values, ok := one.([]interface{})
+			if !ok || len(values) < 4 {
+				continue
+			}
+			event := &LatencyEvent{
+				Event: fmt.Sprint(values[0]),
+			}
+			event.Time, _ = values[1].(int64)
+			event.Latest, _ = values[2].(int64)
+			event.Max, _ = values[3].(int64)
+			events = append(events, event)
+		}
+		return map[string]interface{}{
+			"doctor": doctor,
+			"latest": events,
+		}, nil
+	})
+	return
+}
+
+var (
+	infoSamplerCache     = map[string]*InfoSampler{}
+	infoSamplerCacheLock = &sync.Mutex{}
+)
+
+const (
+	// infoSamplerMaxSize 最多 保留 的 采样 数
+	infoSamplerMaxSize = 3600
+	// infoSamplerIdleTime 超过 该 时间 未 查询 则 停止 采样
+	infoSamplerIdleTime = 30 * time.Minute
+	// infoSamplerMinInterval 最小 采样 间隔 秒，避免 频繁 执行 INFO
+	infoSamplerMinInterval = 5
+)
+
+// infoSamplerDefaultFields 默认 采样 的 INFO 字段
+var infoSamplerDefaultFields = []string{
+	"instantaneous_ops_per_sec",
+	"used_memory",
+	"connected_clients",
+	"keyspace_hits",
+	"keyspace_misses",
+}
+
+type InfoSamplerRequest struct {
+	Interval  int      `json:"interval"` // 采样 间隔 秒，默认 10
+	Fields    []string `json:"fields"`
+	Timestamp int64    `json:"timestamp"` // 查询 该 时间 之后 的 采样
+	Size      int      `json:"size"`
+}
+
+type InfoSample struct {
+	Timestamp int64              `json:"timestamp"`
+	Values    map[string]float64 `json:"values"` // 集群 为 所有 主节点 之和
+	Error     string             `json:"error,omitempty"`
+}
+
+type InfoSamplerResponse struct {
+	Interval      int           `json:"interval"`
+	Fields        []string      `json:"fields"`
+	IsStop        bool          `json:"isStop"`
+	LastTimestamp int64         `json:"lastTimestamp,omitempty"`
+	SampleList    []*InfoSample `json:"sampleList,omitempty"`
+}
+
+// InfoSampler 定时 采集 INFO 字段，保留 最近 infoSamplerMaxSize 个 采样，用于 图表 展示
+type InfoSampler struct {
+	key           string
+	interval      int
+	fields        []string
+	service       redis.IService
+	keepAlive     func()
+	cronTask      *task.CronTask
+	locker        *sync.Mutex
+	sampleList    []*InfoSample
+	lastQueryTime time.Time
+}
+
+func (this_ *api) infoSamplerStart(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	config, sshConfig, err := this_.getConfig(requestBean, c)
+	if err != nil {
+		return
+	}
+	service, err := getService(config, sshConfig)
+	if err != nil {
+		return
+	}
+
+	request := &InfoSamplerRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	if request.Interval <= 0 {
+		request.Interval = 10
+	}
+	if request.Interval < infoSamplerMinInterval {
+		request.Interval = infoSamplerMinInterval
+	}
+	if len(request.Fields) == 0 {
+		request.Fields = infoSamplerDefaultFields
+	}
+
+	key := getInfoSamplerKey(requestBean, config, sshConfig)
+	infoSamplerCacheLock.Lock()
+	defer infoSamplerCacheLock.Unlock()
+
+	// 已存在 时 停止 后 按 新 的 配置 重新 开始，保留 已 采集 的 数据
+	sampler := infoSamplerCache[key]
+	var sampleList []*InfoSample
+	if sampler != nil {
+		sampler.cronTask.Stop()
+		sampleList = sampler.sampleList
+	}
+	sampler = &InfoSampler{
+		key:      key,
+		interval: request.Interval,
+		fields:   request.Fields,
+		service:  service,
+		keepAlive: func() {
+			_, _ = getService(config, sshConfig)
+		},
+		locker:        &sync.Mutex{},
+		sampleList:    sampleList,
+		lastQueryTime: time.Now(),
+	}
+	sampler.cronTask = &task.CronTask{
+		Spec: fmt.Sprintf("@every %ds", request.Interval),
+		Task: &task.Task{
+			Key: "redis-info-sampler-" + key,
+			Do:  sampler.collect,
+		},
+	}
+	err = task.AddCronTask(sampler.cronTask)
+	if err != nil {
+		delete(infoSamplerCache, key)
+		return
+	}
+	infoSamplerCache[key] = sampler
+	res = sampler.query(&InfoSamplerRequest{Timestamp: util.GetNowMilli()})
+	return
+}
+
+func (this_ *api) infoSamplerData(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	config, sshConfig, err := this_.getConfig(requestBean, c)
+	if err != nil {
+		return
+	}
+
+	request := &InfoSamplerRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	sampler := getInfoSampler(getInfoSamplerKey(requestBean, config, sshConfig))
+	if sampler == nil {
+		res = &InfoSamplerResponse{IsStop: true}
+		return
+	}
+	res = sampler.query(request)
+	return
+}
+
+func (this_ *api) infoSamplerStop(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	config, sshConfig, err := this_.getConfig(requestBean, c)
+	if err != nil {
+		return
+	}
+	removeInfoSampler(getInfoSamplerKey(requestBean, config, sshConfig))
+	return
+}
+
+// getInfoSamplerKey 采样 按 服务 和 用户 区分，不同 用户 互不 影响
+func getInfoSamplerKey(requestBean *base.RequestBean, config *redis.Config, sshConfig *ssh.Config) string {
+	var userId int64
+	if requestBean.JWT != nil {
+		userId = requestBean.JWT.UserId
+	}
+	return getServiceKey(config, sshConfig) + "-user-" + util.GetStringValue(userId)
+}
+
+func getInfoSampler(key string) *InfoSampler {
+	infoSamplerCacheLock.Lock()
+	defer infoSamplerCacheLock.Unlock()
+	return infoSamplerCache[key]
+}
+
+func removeInfoSampler(key string) {
+	infoSamplerCacheLock.Lock()
+	defer infoSamplerCacheLock.Unlock()
+	sampler := infoSamplerCache[key]
+	if sampler != nil {
+		sampler.cronTask.Stop()
+		delete(infoSamplerCache, key)
+	}
+}
+
+func (this_ *InfoSampler) query(request *InfoSamplerRequest) (response *InfoSamplerResponse) {
+	this_.locker.Lock()
+	defer this_.locker.Unlock()
+
+	this_.lastQueryTime = time.Now()
+	response = &InfoSamplerResponse{
+		Interval: this_.interval,
+		Fields:   this_.fields,
+		IsStop:   this_.cronTask.IsStopped(),
+	}
+	size := request.Size
+	if size <= 0 {
+		size = 100
+	}
+	for _, one := range this_.sampleList {
+		if len(response.SampleList) >= size {
+			break
+		}
+		if one.Timestamp <= request.Timestamp {
+			continue
+		}
+		response.SampleList = append(response.SampleList, one)
+		response.LastTimestamp = one.Timestamp
+	}
+	return
+}
+
+func (this_ *InfoSampler) collect() {
+	this_.locker.Lock()
+	idle := time.Since(this_.lastQueryTime) > infoSamplerIdleTime
+	this_.locker.Unlock()
+	if idle {
+		util.Logger.Info("redis info sampler idle stop", zap.Any("key", this_.key))
+		removeInfoSampler(this_.key)
+		return
+	}
+	this_.keepAlive()
+
+	sample := &InfoSample{
+		Timestamp: util.GetNowMilli(),
+		Values:    map[string]float64{},
+	}
+	nodes, ctx, err := getNodes(this_.service, "", true)
+	if err == nil {
+		for _, node := range nodes {
+			var text string
+			text, err = node.Info(ctx).Result()
+			if err != nil {
+				break
+			}
+			for name, value := range parseInfoFields(text, this_.fields) {
+				sample.Values[name] += value
+			}
+		}
+	}
+	if err != nil {
+		sample.Error = err.Error()
+		util.Logger.Error("redis info sampler error", zap.Any("key", this_.key), zap.Error(err))
+	}
+
+	this_.locker.Lock()
+	defer this_.locker.Unlock()
+	if len(this_.sampleList) >= infoSamplerMaxSize {
+		this_.sampleList = this_.sampleList[len(this_.sampleList)-infoSamplerMaxSize+1:]
+	}
+	this_.sampleList = append(this_.sampleList, sample)
+}
+
+// parseInfoFields 解析 INFO 中 指定 的 数值 字段
+func parseInfoFields(text string, fields []string) (res map[string]float64) {
+	res = map[string]float64{}
+	want := map[string]bool{}
+	for _, field := range fields {
+		want[field] = true
+	}
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		index := strings.Index(line, ":")
+		if index < 0 || !want[line[:index]] {
+			continue
+		}
+		value, e := strconv.ParseFloat(line[index+1:], 64)
+		if e != nil {
+			continue
+		}
+		res[line[:index]] = value
+	}
+	return
+}
@@ -0,0 +1,514 @@
+package module_redis
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	goRedis "github.com/go-redis/redis/v8"
+	"github.com/gorilla/websocket"
+	"github.com/team-ide/go-tool/redis"
+	"github.com/team-ide/go-tool/util"
+	"go.uber.org/zap"
+	"net/http"
+	"strings"
+	"sync"
+	"teamide/pkg/base"
+	"teamide/pkg/ssh"
+	"time"
+)
+
+var upGrader = websocket.Upgrader{
+	ReadBufferSize:  32 * 1024,
+	WriteBufferSize: 32 * 1024,
+	CheckOrigin: func(r *http.Request) bool {
+		return true
+	},
+}
+
+var (
+	pubSubSessionCache     = map[string]*pubSubSession{}
+	pubSubSessionCacheLock = &sync.Mutex{}
+)
+
+// pubSubConnectTimeout 创建 会话 后 需要 在 该 时间 内 连接，否则 删除 会话
+const pubSubConnectTimeout = time.Minute
+
+func getPubSubSession(key string) (session *pubSubSession) {
+	pubSubSessionCacheLock.Lock()
+	defer pubSubSessionCacheLock.Unlock()
+	session = pubSubSessionCache[key]
+	return
+}
+
+func setPubSubSession(key string, session *pubSubSession) {
+	pubSubSessionCacheLock.Lock()
+	defer pubSubSessionCacheLock.Unlock()
+	pubSubSessionCache[key] = session
+}
+
+func removePubSubSession(key string) {
+	pubSubSessionCacheLock.Lock()
+	defer pubSubSessionCacheLock.Unlock()
+	delete(pubSubSessionCache, key)
+}
+
+// subscriber 单机、集群 客户端 都 实现 了 订阅
+type subscriber interface {
+	Subscribe(ctx context.Context, channels ...string) *goRedis.PubSub
+}
+
+// PubSubCommand 浏览器 通过 WebSocket 发送 的 指令
+type PubSubCommand struct {
+	Action   string   `json:"action"` // subscribe、psubscribe、unsubscribe、punsubscribe、keyevent、keyspace、pause、resume、filter
+	Channels []string `json:"channels"`
+	Database *int     `json:"database"` // keyevent、keyspace 监听 的 库，为空 监听 所有 库
+	Filter   string   `json:"filter"`
+}
+
+// PubSubEvent 推送 给 浏览器 的 消息
+type PubSubEvent struct {
+	Type    string `json:"type"` // message、subscription、status、error
+	Kind    string `json:"kind,omitempty"`
+	Channel string `json:"channel,omitempty"`
+	Pattern string `json:"pattern,omitempty"`
+	Payload string `json:"payload,omitempty"`
+	Count   int    `json:"count,omitempty"`
+	Time    int64  `json:"time"`
+}
+
+type pubSubSession struct {
+	key       string
+	userId    int64
+	config    *redis.Config
+	sshConfig *ssh.Config
+	service   redis.IService
+	ws        *websocket.Conn
+	pubSub    *goRedis.PubSub
+	ctx       context.Context
+	writeLock sync.Mutex
+
+	locker    sync.Mutex
+	paused    bool
+	dropped   int64 // 暂停 期间 丢弃 的 消息 数
+	filter    string
+	isStopped bool
+}
+
+func (this_ *api) pubsubKey(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	config, sshConfig, err := this_.getConfig(requestBean, c)
+	if err != nil {
+		return
+	}
+	service, err := getService(config, sshConfig)
+	if err != nil {
+		return
+	}
+
+	session := &pubSubSession{
+		key:       util.GetUUID(),
+		config:    config,
+		sshConfig: sshConfig,
+		service:   service,
+	}
+	if requestBean.JWT != nil {
+		session.userId = requestBean.JWT.UserId
+	}
+	setPubSubSession(session.key, session)
+	// 未 连接 的 会话 超时 删除
+	time.AfterFunc(pubSubConnectTimeout, func() {
+		if !session.isConnected() {
+			session.stop()
+		}
+	})
+	data := make(map[string]interface{})
+	data["key"] = session.key
+	res = data
+	return
+}
+
+func (this_ *api) pubsubWebsocket(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	if requestBean.JWT == nil || requestBean.JWT.UserId == 0 {
+		err = errors.New("登录用户获取失败")
+		return
+	}
+	key := c.Query("key")
+	if key == "" {
+		err = errors.New("key获取失败")
+		return
+	}
+	//升级get请求为webSocket协议
+	ws, err := upGrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		return
+	}
+
+	session := getPubSubSession(key)
+	if session == nil || session.userId != requestBean.JWT.UserId {
+		err = errors.New("会话[" + key + "]不存在")
+		_ = ws.WriteMessage(websocket.TextMessage, []byte("session not found:"+err.Error()))
+		util.Logger.Error("redis pubsub websocket start error", zap.Error(err))
+		_ = ws.Close()
+		return
+	}
+	if !session.bindWs(ws) {
+		err = errors.New("会话[" + key + "]已连接")
+		_ = ws.WriteMessage(websocket.TextMessage, []byte("start error:"+err.Error()))
+		util.Logger.Error("redis pubsub websocket start error", zap.Error(err))
+		_ = ws.Close()
+		return
+	}
+	err = session.start()
+	if err != nil {
+		_ = ws.WriteMessage(websocket.TextMessage, []byte("start error:"+err.Error()))
+		util.Logger.Error("redis pubsub websocket start error", zap.Error(err))
+		session.stop()
+		_ = ws.Close()
+		return
+	}
+
+	res = base.HttpNotResponse
+	return
+}
+
+// bindWs 绑定 连接，已 连接 或 已 停止 的 会话 返回 false
+func (this_ *pubSubSession) bindWs(ws *websocket.Conn) bool {
+	this_.locker.Lock()
+	defer this_.locker.Unlock()
+	if this_.ws != nil || this_.isStopped {
+		return false
+	}
+	this_.ws = ws
+	return true
+}
+
+func (this_ *pubSubSession) isConnected() bool {
+	this_.locker.Lock()
+	defer this_.locker.Unlock()
+	return this_.ws != nil
+}
+
+func (this_ *pubSubSession) isStop() bool {
+	this_.locker.Lock()
+	defer this_.locker.Unlock()
+	return this_.isStopped
+}
+
+func (this_ *pubSubSession) start() (err error) {
+	client, err := this_.service.GetClient()
+	if err != nil {
+		return
+	}
+	sub, ok := client.(subscriber)
+	if !ok {
+		err = errors.New("当前客户端不支持订阅")
+		return
+	}
+	this_.ctx = context.Background()
+	// 不 带 频道 创建，后续 根据 浏览器 指令 订阅，SSH 隧道 使用 客户端 的 Dialer
+	this_.pubSub = sub.Subscribe(this_.ctx)
+
+	go this_.startReadWS()
+	go this_.startReadPubSub()
+
+	this_.sendStatus("connected to " + this_.config.Address)
+	return
+}
+
+func (this_ *pubSubSession) stop() {
+	this_.locker.Lock()
+	if this_.isStopped {
+		this_.locker.Unlock()
+		return
+	}
+	this_.isStopped = true
+	ws := this_.ws
+	this_.locker.Unlock()
+
+	removePubSubSession(this_.key)
+	if this_.pubSub != nil {
+		_ = this_.pubSub.Close()
+	}
+	if ws != nil {
+		_ = ws.Close()
+	}
+}
+
+func (this_ *pubSubSession) send(event *PubSubEvent) (err error) {
+	if event.Time == 0 {
+		event.Time = util.GetNowMilli()
+	}
+	bs, err := json.Marshal(event)
+	if err != nil {
+		return
+	}
+	this_.writeLock.Lock()
+	defer this_.writeLock.Unlock()
+	err = this_.ws.WriteMessage(websocket.TextMessage, bs)
+	return
+}
+
+func (this_ *pubSubSession) sendStatus(payload string) {
+	_ = this_.send(&PubSubEvent{Type: "status", Payload: payload})
+}
+
+func (this_ *pubSubSession) sendError(err error) {
+	_ = this_.send(&PubSubEvent{Type: "error", Payload: err.Error()})
+}
+
+func (this_ *pubSubSession) startReadWS() {
+	defer func() {
+		if e := recover(); e != nil {
+			err := errors.New(fmt.Sprint(e))
+			util.Logger.Error("redis pubsub startReadWS panic error", zap.Error(err))
+		}
+	}()
+	defer func() { this_.stop() }()
+
+	for {
+		_, buf, err := this_.ws.ReadMessage()
+		if err != nil {
+			if !this_.isStop() && !websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+				util.Logger.Error("redis pubsub ws read error", zap.Error(err))
+			}
+			return
+		}
+		command := &PubSubCommand{}
+		err = json.Unmarshal(buf, command)
+		if err != nil {
+			this_.sendError(errors.New("指令解析失败:" + err.Error()))
+			continue
+		}
+		err = this_.doCommand(command)
+		if err != nil {
+			this_.sendError(err)
+		}
+	}
+}
+
+func (this_ *pubSubSession) doCommand(command *PubSubCommand) (err error) {
+	switch command.Action {
+	case "subscribe":
+		err = this_.pubSub.Subscribe(this_.ctx, command.Channels...)
+	case "psubscribe":
+		err = this_.pubSub.PSubscribe(this_.ctx, command.Channels...)
+	case "unsubscribe":
+		err = this_.pubSub.Unsubscribe(this_.ctx, command.Channels...)
+	case "punsubscribe":
+		err = this_.pubSub.PUnsubscribe(this_.ctx, command.Channels...)
+	case "keyevent", "keyspace":
+		// keyevent 的 Channels 为 事件，如 expired、del，为空 监听 expired
+		// keyspace 的 Channels 为 Key 匹配，为空 监听 所有 Key
+		err = this_.subscribeNotify(command)
+	case "pause":
+		this_.locker.Lock()
+		this_.paused = true
+		this_.locker.Unlock()
+		this_.sendStatus("paused")
+	case "resume":
+		this_.locker.Lock()
+		this_.paused = false
+		dropped := this_.dropped
+		this_.dropped = 0
+		this_.locker.Unlock()
+		this_.sendStatus(fmt.Sprintf("resumed, %d messages dropped while paused", dropped))
+	case "filter":
+		this_.locker.Lock()
+		this_.filter = strings.ToLower(command.Filter)
+		this_.locker.Unlock()
+		this_.sendStatus("filter [" + command.Filter + "]")
+	default:
+		err = errors.New("不支持的指令[" + command.Action + "]")
+	}
+	return
+}
+
+// subscribeNotify 订阅 键 空间 通知，需要 服务 开启 notify-keyspace-events，集群 只能 收到 当前 连接 节点 的 通知
+func (this_ *pubSubSession) subscribeNotify(command *PubSubCommand) (err error) {
+	database := "*"
+	if command.Database != nil {
+		database = fmt.Sprint(*command.Database)
+	}
+	var patterns []string
+	if command.Action == "keyevent" {
+		events := command.Channels
+		if len(events) == 0 {
+			events = []string{"expired"}
+		}
+		for _, event := range events {
+			patterns = append(patterns, "__keyevent@"+database+"__:"+event)
+		}
+	} else {
+		keys := command.Channels
+		if len(keys) == 0 {
+			keys = []string{"*"}
+		}
+		for _, key := range keys {
+			patterns = append(patterns, "__keyspace@"+database+"__:"+key)
+		}
+	}
+	err = this_.pubSub.PSubscribe(this_.ctx, patterns...)
+	if err != nil {
+		return
+	}
+
+	notify, e := getNotifyConfig(this_.service)
+	if e != nil {
+		this_.sendStatus("notify-keyspace-events 查询失败:" + e.Error())
+		return
+	}
+	flag := "E"
+	if command.Action == "keyspace" {
+		flag = "K"
+	}
+	if !strings.Contains(notify, flag) {
+		this_.sendStatus("notify-keyspace-events [" + notify + "] 未开启 " + flag + "，收不到 通知")
+	}
+	return
+}
+
+func (this_ *pubSubSession) startReadPubSub() {
+	defer func() {
+		if e := recover(); e != nil {
+			err := errors.New(fmt.Sprint(e))
+			util.Logger.Error("redis pubsub startReadPubSub panic error", zap.Error(err))
+		}
+	}()
+	defer func() { this_.stop() }()
+
+	// 订阅 期间 定时 刷新 服务 使用 时间，避免 服务 空闲 被 关闭
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	ch := this_.pubSub.ChannelWithSubscriptions(this_.ctx, 1000)
+	for {
+		select {
+		case <-ticker.C:
+			_, _ = getService(this_.config, this_.sshConfig)
+		case one, ok := <-ch:
+			if !ok {
+				return
+			}
+			var err error
+			switch msg := one.(type) {
+			case *goRedis.Subscription:
+				err = this_.send(&PubSubEvent{Type: "subscription", Kind: msg.Kind, Channel: msg.Channel, Count: msg.Count})
+			case *goRedis.Message:
+				if !this_.accept(msg) {
+					continue
+				}
+				err = this_.send(&PubSubEvent{Type: "message", Channel: msg.Channel, Pattern: msg.Pattern, Payload: msg.Payload})
+			}
+			if err != nil {
+				if !this_.isStop() {
+					util.Logger.Error("redis pubsub ws write error", zap.Error(err))
+				}
+				return
+			}
+		}
+	}
+}
+
+// accept 暂停 时 丢弃 消息，过滤 按 频道、内容 包含 匹配，忽略 大小写
+func (this_ *pubSubSession) accept(msg *goRedis.Message) bool {
+	this_.locker.Lock()
+	defer this_.locker.Unlock()
+	if this_.paused {
+		this_.dropped++
+		return false
+	}
+	if this_.filter == "" {
+		return true
+	}
+	return strings.Contains(strings.ToLower(msg.Channel), this_.filter) || strings.Contains(strings.ToLower(msg.Payload), this_.filter)
+}
+
+type PublishRequest struct {
+	Database int    `json:"database"`
+	Channel  string `json:"channel"`
+	Value    string `json:"value"`
+}
+
+func (this_ *api) publish(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	config, sshConfig, err := this_.getConfig(requestBean, c)
+	if err != nil {
+		return
+	}
+	service, err := getService(config, sshConfig)
+	if err != nil {
+		return
+	}
+
+	request := &PublishRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	if request.Channel == "" {
+		err = errors.New("频道不能为空")
+		return
+	}
+	client, param, err := getClient(service, request.Database)
+	if err != nil {
+		return
+	}
+	// 返回 收到 消息 的 订阅 者 数量
+	res, err = client.Publish(param.Ctx, request.Channel, request.Value).Result()
+	return
+}
+
+// notifyConfig 查询 notify-keyspace-events，DoType 为 set 时 设置 为 Value，如 "Ex" 开启 过期 事件
+func (this_ *api) notifyConfig(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	config, sshConfig, err := this_.getConfig(requestBean, c)
+	if err != nil {
+		return
+	}
+	service, err := getService(config, sshConfig)
+	if err != nil {
+		return
+	}
+
+	request := &BaseRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	if request.DoType == "set" {
+		err = this_.checkRequestDeny(requestBean, "CONFIG", "SET", "notify-keyspace-events", request.Value)
+		if err != nil {
+			return
+		}
+		client, param, e := getClient(service, request.Database)
+		if e != nil {
+			err = e
+			return
+		}
+		// 集群 需要 每个 节点 都 设置
+		if cluster, ok := client.(*goRedis.ClusterClient); ok {
+			err = cluster.ForEachShard(param.Ctx, func(ctx context.Context, shard *goRedis.Client) error {
+				return shard.ConfigSet(ctx, "notify-keyspace-events", request.Value).Err()
+			})
+		} else {
+			err = client.ConfigSet(param.Ctx, "notify-keyspace-events", request.Value).Err()
+		}
+		if err != nil {
+			return
+		}
+	}
+	res, err = getNotifyConfig(service)
+	return
+}
+
+func getNotifyConfig(service redis.IService) (res string, err error) {
+	client, param, err := getClient(service, 0)
+	if err != nil {
+		return
+	}
+	values, err := client.ConfigGet(param.Ctx, "notify-keyspace-events").Result()
+	if err != nil {
+		return
+	}
+	if len(values) >= 2 {
+		res = fmt.Sprint(values[1])
+	}
+	return
+}
@@ -0,0 +1,362 @@
+package module_redis
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"teamide/internal/module/module_toolbox"
+	"teamide/pkg/base"
+	"time"
+)
+
+// scriptExtendType 保存 在 工具箱 扩展 中 的 Lua 脚本 类型
+const scriptExtendType = "redisScript"
+
+type ScriptRequest struct {
+	Database int      `json:"database"`
+	Script   string   `json:"script"`
+	Sha      string   `json:"sha"`
+	ShaList  []string `json:"shaList"`
+	Keys     []string `json:"keys"`
+	Args     []string `json:"args"`
+
+	ExtendId int64  `json:"extendId"`
+	Name     string `json:"name"`
+	Comment  string `json:"comment"`
+}
+
+type ScriptResult struct {
+	Reply   *Reply `json:"reply"`
+	UseTime int64  `json:"useTime"`
+}
+
+// scriptResult 将 执行 结果 转为 回复，连接 等 错误 直接 返回
+func scriptResult(value interface{}, err error, startTime time.Time) (res *ScriptResult, resErr error) {
+	reply, resErr := toReply(value, err)
+	if resErr != nil {
+		return
+	}
+	res = &ScriptResult{
+		Reply:   reply,
+		UseTime: time.Since(startTime).Milliseconds(),
+	}
+	return
+}
+
+func (this_ *api) eval(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	config, sshConfig, err := this_.getConfig(requestBean, c)
+	if err != nil {
+		return
+	}
+	service, err := getService(config, sshConfig)
+	if err != nil {
+		return
+	}
+
+	request := &ScriptRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	if request.Script == "" {
+		err = errors.New("脚本不能为空")
+		return
+	}
+	err = this_.checkRequestDeny(requestBean, "EVAL", request.Script)
+	if err != nil {
+		return
+	}
+	client, param, err := getClient(service, request.Database)
+	if err != nil {
+		return
+	}
+
+	startTime := time.Now()
+	value, e := client.Eval(param.Ctx, request.Script, request.Keys, toScriptArgs(request.Args)...).Result()
+	res, err = scriptResult(value, e, startTime)
+	return
+}
+
+func (this_ *api) evalsha(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	config, sshConfig, err := this_.getConfig(requestBean, c)
+	if err != nil {
+		return
+	}
+	service, err := getService(config, sshConfig)
+	if err != nil {
+		return
+	}
+
+	request := &ScriptRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	if request.Sha == "" {
+		err = errors.New("脚本SHA不能为空")
+		return
+	}
+	err = this_.checkRequestDeny(requestBean, "EVALSHA", request.Sha)
+	if err != nil {
+		return
+	}
+	client, param, err := getClient(service, request.Database)
+	if err != nil {
+		return
+	}
+
+	startTime := time.Now()
+	value, e := client.EvalSha(param.Ctx, request.Sha, request.Keys, toScriptArgs(request.Args)...).Result()
+	res, err = scriptResult(value, e, startTime)
+	return
+}
+
+// scriptSha 计算 脚本 的 SHA1，与 SCRIPT LOAD 返回 一致
+func scriptSha(script string) string {
+	sum := sha1.Sum([]byte(script))
+	return hex.EncodeToString(sum[:])
+}
+
+func toScriptArgs(args []string) (res []interface{}) {
+	for _, one := range args {
+		res = append(res, one)
+	}
+	return
+}
+
+func (this_ *api) scriptLoad(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	config, sshConfig, err := this_.getConfig(requestBean, c)
+	if err != nil {
+		return
+	}
+	service, err := getService(config, sshConfig)
+	if err != nil {
+		return
+	}
+
+	request := &ScriptRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	if request.Script == "" {
+		err = errors.New("脚本不能为空")
+		return
+	}
+	client, param, err := getClient(service, request.Database)
+	if err != nil {
+		return
+	}
+
+	res, err = client.ScriptLoad(param.Ctx, request.Script).Result()
+	return
+}
+
+func (this_ *api) scriptExists(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	config, sshConfig, err := this_.getConfig(requestBean, c)
+	if err != nil {
+		return
+	}
+	service, err := getService(config, sshConfig)
+	if err != nil {
+		return
+	}
+
+	request := &ScriptRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	shaList := request.ShaList
+	if len(shaList) == 0 && request.Sha != "" {
+		shaList = []string{request.Sha}
+	}
+	if len(shaList) == 0 {
+		err = errors.New("脚本SHA不能为空")
+		return
+	}
+	client, param, err := getClient(service, request.Database)
+	if err != nil {
+		return
+	}
+
+	exists, err := client.ScriptExists(param.Ctx, shaList...).Result()
+	if err != nil {
+		return
+	}
+	data := map[string]bool{}
+	for i, sha := range shaList {
+		if i < len(exists) {
+			data[sha] = exists[i]
+		}
+	}
+	res = data
+	return
+}
+
+func (this_ *api) scriptFlush(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	config, sshConfig, err := this_.getConfig(requestBean, c)
+	if err != nil {
+		return
+	}
+	service, err := getService(config, sshConfig)
+	if err != nil {
+		return
+	}
+
+	request := &ScriptRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	client, param, err := getClient(service, request.Database)
+	if err != nil {
+		return
+	}
+
+	err = client.ScriptFlush(param.Ctx).Err()
+	return
+}
+
+// getToolboxId 获取 当前 请求 的 工具 ID，getConfig 后 调用
+func getToolboxId(requestBean *base.RequestBean) (toolboxId int64, err error) {
+	toolbox, err := getToolbox(requestBean)
+	if err != nil {
+		return
+	}
+	toolboxId = toolbox.ToolboxId
+	return
+}
+
+// getToolbox 获取 当前 请求 的 工具，getConfig 后 调用
+func getToolbox(requestBean *base.RequestBean) (toolbox *module_toolbox.ToolboxModel, err error) {
+	if v := requestBean.GetExtend("toolboxModel"); v != nil {
+		toolbox = v.(*module_toolbox.ToolboxModel)
+	}
+	if toolbox == nil || toolbox.ToolboxId == 0 {
+		err = errors.New("工具获取失败")
+	}
+	return
+}
+
+// getStoredToolbox 获取 当前 请求 工具 保存 的 信息，测试 连接 时 请求 中 的 工具 信息 不可信，校验 创建者 时 使用
+func (this_ *api) getStoredToolbox(requestBean *base.RequestBean) (toolbox *module_toolbox.ToolboxModel, err error) {
+	toolboxId, err := getToolboxId(requestBean)
+	if err != nil {
+		return
+	}
+	toolbox, err = this_.toolboxService.Get(toolboxId)
+	if err != nil {
+		return
+	}
+	if toolbox == nil {
+		err = errors.New("工具[" + fmt.Sprint(toolboxId) + "]不存在")
+	}
+	return
+}
+
+// getScript 查询 当前 工具 下 保存 的 脚本，只有 脚本 作者 和 工具 创建者 可以 修改、删除
+func (this_ *api) getScript(requestBean *base.RequestBean, extendId int64) (find *module_toolbox.ToolboxExtendModel, err error) {
+	toolbox, err := this_.getStoredToolbox(requestBean)
+	if err != nil {
+		return
+	}
+	find, err = this_.toolboxService.GetExtend(extendId)
+	if err != nil {
+		return
+	}
+	if find == nil || find.ToolboxId != toolbox.ToolboxId || find.ExtendType != scriptExtendType {
+		find = nil
+		err = errors.New("脚本[" + fmt.Sprint(extendId) + "]不存在")
+		return
+	}
+	if find.UserId != requestBean.JWT.UserId && toolbox.UserId != requestBean.JWT.UserId {
+		find = nil
+		err = errors.New("只有脚本作者或工具创建者可以修改脚本")
+	}
+	return
+}
+
+// scriptList 查询 工具 下 所有 用户 保存 的 脚本
+func (this_ *api) scriptList(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	_, _, err = this_.getConfig(requestBean, c)
+	if err != nil {
+		return
+	}
+	toolboxId, err := getToolboxId(requestBean)
+	if err != nil {
+		return
+	}
+
+	res, err = this_.toolboxService.QueryExtends(&module_toolbox.ToolboxExtendModel{
+		ToolboxId:  toolboxId,
+		ExtendType: scriptExtendType,
+	})
+	return
+}
+
+func (this_ *api) scriptSave(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	_, _, err = this_.getConfig(requestBean, c)
+	if err != nil {
+		return
+	}
+	toolboxId, err := getToolboxId(requestBean)
+	if err != nil {
+		return
+	}
+
+	request := &ScriptRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	if request.Name == "" {
+		err = errors.New("脚本名称不能为空")
+		return
+	}
+	if request.Script == "" {
+		err = errors.New("脚本不能为空")
+		return
+	}
+
+	data := &module_toolbox.ToolboxExtendModel{
+		ToolboxId:  toolboxId,
+		ExtendType: scriptExtendType,
+	}
+	if request.ExtendId != 0 {
+		data, err = this_.getScript(requestBean, request.ExtendId)
+		if err != nil {
+			return
+		}
+	} else {
+		data.UserId = requestBean.JWT.UserId
+	}
+	data.Name = request.Name
+	data.Extend = map[string]interface{}{
+		"script":  request.Script,
+		"keys":    request.Keys,
+		"args":    request.Args,
+		"comment": request.Comment,
+		"sha":     scriptSha(request.Script),
+	}
+	err = this_.toolboxService.SaveExtend(data)
+	if err != nil {
+		return
+	}
+	res = data
+	return
+}
+
+func (this_ *api) scriptDelete(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	_, _, err = this_.getConfig(requestBean, c)
+	if err != nil {
+		return
+	}
+
+	request := &ScriptRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	_, err = this_.getScript(requestBean, request.ExtendId)
+	if err != nil {
+		return
+	}
+	res, err = this_.toolboxService.DeleteExtend(request.ExtendId)
+	return
+}
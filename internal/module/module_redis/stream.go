@@ -0,0 +1,480 @@
+package module_redis
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	goRedis "github.com/go-redis/redis/v8"
+	"teamide/pkg/base"
+	"time"
+)
+
+type StreamRequest struct {
+	BaseRequest
+	Id        string         `json:"id"`     // XADD 的 消息 ID，为空 使用 "*"
+	IdList    []string       `json:"idList"` // XDEL、XCLAIM、XACK 的 消息 ID
+	FieldList []*StreamField `json:"fieldList"`
+	Start     string         `json:"start"` // 范围 查询 开始，分页 时 可 使用 "(上一页最后ID" 排除 该 消息
+	End       string         `json:"end"`
+	MaxLen    int64          `json:"maxLen"`
+	MinId     string         `json:"minId"`
+	Approx    bool           `json:"approx"` // 使用 "~" 近似 裁剪
+	Group     string         `json:"group"`
+	Consumer  string         `json:"consumer"`
+	MkStream  bool           `json:"mkStream"` // 创建 消费组 时 Stream 不存在 则 创建
+	MinIdle   int64          `json:"minIdle"`  // 毫秒
+}
+
+type StreamField struct {
+	Field string `json:"field"`
+	Value string `json:"value"`
+}
+
+type StreamMessage struct {
+	Id     string                 `json:"id"`
+	Values map[string]interface{} `json:"values"`
+}
+
+type StreamRangeResult struct {
+	Length      int64            `json:"length"`
+	MessageList []*StreamMessage `json:"messageList"`
+}
+
+type StreamGroup struct {
+	Name            string            `json:"name"`
+	Consumers       int64             `json:"consumers"`
+	Pending         int64             `json:"pending"`
+	LastDeliveredId string            `json:"lastDeliveredId"`
+	ConsumerList    []*StreamConsumer `json:"consumerList"`
+}
+
+type StreamConsumer struct {
+	Name    string `json:"name"`
+	Pending int64  `json:"pending"`
+	Idle    int64  `json:"idle"` // 毫秒
+}
+
+type StreamPendingResult struct {
+	Count       int64               `json:"count"`
+	Lower       string              `json:"lower"`
+	Higher      string              `json:"higher"`
+	Consumers   map[string]int64    `json:"consumers"`
+	PendingList []*StreamPendingOne `json:"pendingList"`
+}
+
+type StreamPendingOne struct {
+	Id         string `json:"id"`
+	Consumer   string `json:"consumer"`
+	Idle       int64  `json:"idle"` // 毫秒
+	RetryCount int64  `json:"retryCount"`
+}
+
+func toStreamMessages(list []goRedis.XMessage) (res []*StreamMessage) {
+	for _, one := range list {
+		res = append(res, &StreamMessage{
+			Id:     one.ID,
+			Values: one.Values,
+		})
+	}
+	return
+}
+
+func (this_ *StreamRequest) getCount() int64 {
+	if this_.Count <= 0 {
+		return 100
+	}
+	return this_.Count
+}
+
+func (this_ *api) xadd(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	config, sshConfig, err := this_.getConfig(requestBean, c)
+	if err != nil {
+		return
+	}
+	service, err := getService(config, sshConfig)
+	if err != nil {
+		return
+	}
+
+	request := &StreamRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	if len(request.FieldList) == 0 {
+		err = errors.New("消息字段不能为空")
+		return
+	}
+	client, param, err := getClient(service, request.Database)
+	if err != nil {
+		return
+	}
+
+	// 按 顺序 传入 字段，保持 字段 顺序
+	var values []interface{}
+	for _, one := range request.FieldList {
+		values = append(values, one.Field, one.Value)
+	}
+	args := &goRedis.XAddArgs{
+		Stream: request.getKey(),
+		ID:     request.Id,
+		MaxLen: request.MaxLen,
+		MinID:  request.MinId,
+		Approx: request.Approx,
+		Values: values,
+	}
+	if args.ID == "" {
+		args.ID = "*"
+	}
+	res, err = client.XAdd(param.Ctx, args).Result()
+	return
+}
+
+func (this_ *api) xrange(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	return this_.streamRange(requestBean, c, false)
+}
+
+func (this_ *api) xrevrange(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	return this_.streamRange(requestBean, c, true)
+}
+
+// streamRange 查询 消息，每次 查询 Count 条，下一页 使用 最后 一条 的 ID 作为 开始
+func (this_ *api) streamRange(requestBean *base.RequestBean, c *gin.Context, reverse bool) (res interface{}, err error) {
+	config, sshConfig, err := this_.getConfig(requestBean, c)
+	if err != nil {
+		return
+	}
+	service, err := getService(config, sshConfig)
+	if err != nil {
+		return
+	}
+
+	request := &StreamRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	client, param, err := getClient(service, request.Database)
+	if err != nil {
+		return
+	}
+	key := request.getKey()
+
+	result := &StreamRangeResult{}
+	result.Length, err = client.XLen(param.Ctx, key).Result()
+	if err != nil {
+		return
+	}
+	var list []goRedis.XMessage
+	if reverse {
+		start, end := request.Start, request.End
+		if start == "" {
+			start = "+"
+		}
+		if end == "" {
+			end = "-"
+		}
+		list, err = client.XRevRangeN(param.Ctx, key, start, end, request.getCount()).Result()
+	} else {
+		start, end := request.Start, request.End
+		if start == "" {
+			start = "-"
+		}
+		if end == "" {
+			end = "+"
+		}
+		list, err = client.XRangeN(param.Ctx, key, start, end, request.getCount()).Result()
+	}
+	if err != nil {
+		return
+	}
+	result.MessageList = toStreamMessages(list)
+	res = result
+	return
+}
+
+func (this_ *api) xdel(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	config, sshConfig, err := this_.getConfig(requestBean, c)
+	if err != nil {
+		return
+	}
+	service, err := getService(config, sshConfig)
+	if err != nil {
+		return
+	}
+
+	request := &StreamRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	if len(request.IdList) == 0 {
+		err = errors.New("消息ID不能为空")
+		return
+	}
+	client, param, err := getClient(service, request.Database)
+	if err != nil {
+		return
+	}
+
+	res, err = client.XDel(param.Ctx, request.getKey(), request.IdList...).Result()
+	return
+}
+
+// xtrim 按 MaxLen 或 MinId 裁剪，MinId 优先
+func (this_ *api) xtrim(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	config, sshConfig, err := this_.getConfig(requestBean, c)
+	if err != nil {
+		return
+	}
+	service, err := getService(config, sshConfig)
+	if err != nil {
+		return
+	}
+
+	request := &StreamRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	client, param, err := getClient(service, request.Database)
+	if err != nil {
+		return
+	}
+	key := request.getKey()
+
+	switch {
+	case request.MinId != "" && request.Approx:
+		res, err = client.XTrimMinIDApprox(param.Ctx, key, request.MinId, 0).Result()
+	case request.MinId != "":
+		res, err = client.XTrimMinID(param.Ctx, key, request.MinId).Result()
+	case request.MaxLen <= 0:
+		err = errors.New("MaxLen需大于0或指定MinId")
+	case request.Approx:
+		res, err = client.XTrimMaxLenApprox(param.Ctx, key, request.MaxLen, 0).Result()
+	default:
+		res, err = client.XTrimMaxLen(param.Ctx, key, request.MaxLen).Result()
+	}
+	return
+}
+
+// xgroups 查询 消费组 及 组 内 消费者
+func (this_ *api) xgroups(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	config, sshConfig, err := this_.getConfig(requestBean, c)
+	if err != nil {
+		return
+	}
+	service, err := getService(config, sshConfig)
+	if err != nil {
+		return
+	}
+
+	request := &StreamRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	client, param, err := getClient(service, request.Database)
+	if err != nil {
+		return
+	}
+	key := request.getKey()
+
+	groups, err := client.XInfoGroups(param.Ctx, key).Result()
+	if err != nil {
+		return
+	}
+	var groupList []*StreamGroup
+	for _, one := range groups {
+		group := &StreamGroup{
+			Name:            one.Name,
+			Consumers:       one.Consumers,
+			Pending:         one.Pending,
+			LastDeliveredId: one.LastDeliveredID,
+		}
+		var consumers []goRedis.XInfoConsumer
+		consumers, err = client.XInfoConsumers(param.Ctx, key, one.Name).Result()
+		if err != nil {
+			return
+		}
+		for _, consumer := range consumers {
+			group.ConsumerList = append(group.ConsumerList, &StreamConsumer{
+				Name:    consumer.Name,
+				Pending: consumer.Pending,
+				Idle:    consumer.Idle,
+			})
+		}
+		groupList = append(groupList, group)
+	}
+	res = groupList
+	return
+}
+
+func (this_ *api) xgroupCreate(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	config, sshConfig, err := this_.getConfig(requestBean, c)
+	if err != nil {
+		return
+	}
+	service, err := getService(config, sshConfig)
+	if err != nil {
+		return
+	}
+
+	request := &StreamRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	if request.Group == "" {
+		err = errors.New("消费组不能为空")
+		return
+	}
+	client, param, err := getClient(service, request.Database)
+	if err != nil {
+		return
+	}
+
+	// 默认 从 最新 消息 开始 消费，"0" 从 头 开始
+	start := request.Start
+	if start == "" {
+		start = "$"
+	}
+	if request.MkStream {
+		res, err = client.XGroupCreateMkStream(param.Ctx, request.getKey(), request.Group, start).Result()
+	} else {
+		res, err = client.XGroupCreate(param.Ctx, request.getKey(), request.Group, start).Result()
+	}
+	return
+}
+
+// xpending 查询 消费组 待确认 消息 汇总 及 明细，可 按 消费者、空闲 时间 过滤
+func (this_ *api) xpending(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	config, sshConfig, err := this_.getConfig(requestBean, c)
+	if err != nil {
+		return
+	}
+	service, err := getService(config, sshConfig)
+	if err != nil {
+		return
+	}
+
+	request := &StreamRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	if request.Group == "" {
+		err = errors.New("消费组不能为空")
+		return
+	}
+	client, param, err := getClient(service, request.Database)
+	if err != nil {
+		return
+	}
+	key := request.getKey()
+
+	summary, err := client.XPending(param.Ctx, key, request.Group).Result()
+	if err != nil {
+		return
+	}
+	result := &StreamPendingResult{
+		Count:     summary.Count,
+		Lower:     summary.Lower,
+		Higher:    summary.Higher,
+		Consumers: summary.Consumers,
+	}
+	args := &goRedis.XPendingExtArgs{
+		Stream:   key,
+		Group:    request.Group,
+		Idle:     time.Duration(request.MinIdle) * time.Millisecond,
+		Start:    request.Start,
+		End:      request.End,
+		Count:    request.getCount(),
+		Consumer: request.Consumer,
+	}
+	if args.Start == "" {
+		args.Start = "-"
+	}
+	if args.End == "" {
+		args.End = "+"
+	}
+	list, err := client.XPendingExt(param.Ctx, args).Result()
+	if err != nil {
+		return
+	}
+	for _, one := range list {
+		result.PendingList = append(result.PendingList, &StreamPendingOne{
+			Id:         one.ID,
+			Consumer:   one.Consumer,
+			Idle:       one.Idle.Milliseconds(),
+			RetryCount: one.RetryCount,
+		})
+	}
+	res = result
+	return
+}
+
+// xclaim 将 空闲 超过 MinIdle 的 待确认 消息 转移 给 Consumer
+func (this_ *api) xclaim(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	config, sshConfig, err := this_.getConfig(requestBean, c)
+	if err != nil {
+		return
+	}
+	service, err := getService(config, sshConfig)
+	if err != nil {
+		return
+	}
+
+	request := &StreamRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	if request.Group == "" || request.Consumer == "" {
+		err = errors.New("消费组和消费者不能为空")
+		return
+	}
+	if len(request.IdList) == 0 {
+		err = errors.New("消息ID不能为空")
+		return
+	}
+	client, param, err := getClient(service, request.Database)
+	if err != nil {
+		return
+	}
+
+	list, err := client.XClaim(param.Ctx, &goRedis.XClaimArgs{
+		Stream:   request.getKey(),
+		Group:    request.Group,
+		Consumer: request.Consumer,
+		MinIdle:  time.Duration(request.MinIdle) * time.Millisecond,
+		Messages: request.IdList,
+	}).Result()
+	if err != nil {
+		return
+	}
+	res = toStreamMessages(list)
+	return
+}
+
+func (this_ *api) xack(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	config, sshConfig, err := this_.getConfig(requestBean, c)
+	if err != nil {
+		return
+	}
+	service, err := getService(config, sshConfig)
+	if err != nil {
+		return
+	}
+
+	request := &StreamRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	if request.Group == "" {
+		err = errors.New("消费组不能为空")
+		return
+	}
+	if len(request.IdList) == 0 {
+		err = errors.New("消息ID不能为空")
+		return
+	}
+	client, param, err := getClient(service, request.Database)
+	if err != nil {
+		return
+	}
+
+	res, err = client.XAck(param.Ctx, request.getKey(), request.Group, request.IdList...).Result()
+	return
+}
@@ -0,0 +1,216 @@
+package module_redis
+
+import (
+	"github.com/gin-gonic/gin"
+	goRedis "github.com/go-redis/redis/v8"
+	"github.com/team-ide/go-tool/redis"
+	"teamide/pkg/base"
+)
+
+type ZSetRequest struct {
+	BaseRequest
+	Score      float64       `json:"score"`
+	Increment  float64       `json:"increment"`
+	MemberList []*ZSetMember `json:"memberList"` // 批量 添加、删除，为空 使用 Value
+	Min        string        `json:"min"`        // 按 分数 范围 查询，如 "-inf"、"(1.5"
+	Max        string        `json:"max"`
+	Reverse    bool          `json:"reverse"` // 按 分数 倒序
+	PageNo     int64         `json:"pageNo"`
+	PageSize   int64         `json:"pageSize"`
+}
+
+type ZSetMember struct {
+	Member string  `json:"member"`
+	Score  float64 `json:"score"`
+	Rank   int64   `json:"rank,omitempty"`
+}
+
+type ZSetRangeResult struct {
+	Total      int64         `json:"total"`
+	PageNo     int64         `json:"pageNo"`
+	PageSize   int64         `json:"pageSize"`
+	MemberList []*ZSetMember `json:"memberList"`
+}
+
+// getClient 获取 指定 库 的 客户端，param 中 带有 执行 使用 的 Ctx
+func getClient(service redis.IService, database int) (client goRedis.Cmdable, param *redis.Param, err error) {
+	param = &redis.Param{Database: database}
+	client, err = service.GetClient(param)
+	return
+}
+
+func (this_ *api) zadd(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	config, sshConfig, err := this_.getConfig(requestBean, c)
+	if err != nil {
+		return
+	}
+	service, err := getService(config, sshConfig)
+	if err != nil {
+		return
+	}
+
+	request := &ZSetRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	client, param, err := getClient(service, request.Database)
+	if err != nil {
+		return
+	}
+
+	var members []*goRedis.Z
+	for _, one := range request.MemberList {
+		members = append(members, &goRedis.Z{Score: one.Score, Member: one.Member})
+	}
+	if len(members) == 0 {
+		members = append(members, &goRedis.Z{Score: request.Score, Member: request.Value})
+	}
+	res, err = client.ZAdd(param.Ctx, request.getKey(), members...).Result()
+	if err != nil {
+		return
+	}
+	if request.Expire > 0 {
+		_, err = service.Expire(request.getKey(), request.Expire, &redis.Param{Database: request.Database})
+	}
+	return
+}
+
+func (this_ *api) zrem(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	config, sshConfig, err := this_.getConfig(requestBean, c)
+	if err != nil {
+		return
+	}
+	service, err := getService(config, sshConfig)
+	if err != nil {
+		return
+	}
+
+	request := &ZSetRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	client, param, err := getClient(service, request.Database)
+	if err != nil {
+		return
+	}
+
+	var members []interface{}
+	for _, one := range request.MemberList {
+		members = append(members, one.Member)
+	}
+	if len(members) == 0 {
+		members = append(members, request.Value)
+	}
+	res, err = client.ZRem(param.Ctx, request.getKey(), members...).Result()
+	return
+}
+
+func (this_ *api) zincrby(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	config, sshConfig, err := this_.getConfig(requestBean, c)
+	if err != nil {
+		return
+	}
+	service, err := getService(config, sshConfig)
+	if err != nil {
+		return
+	}
+
+	request := &ZSetRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	client, param, err := getClient(service, request.Database)
+	if err != nil {
+		return
+	}
+
+	res, err = client.ZIncrBy(param.Ctx, request.getKey(), request.Increment, request.Value).Result()
+	return
+}
+
+// zrange 分页 查询 成员 和 分数，设置 Min 或 Max 时 按 分数 范围 查询，否则 按 排名 查询
+func (this_ *api) zrange(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	config, sshConfig, err := this_.getConfig(requestBean, c)
+	if err != nil {
+		return
+	}
+	service, err := getService(config, sshConfig)
+	if err != nil {
+		return
+	}
+
+	request := &ZSetRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	client, param, err := getClient(service, request.Database)
+	if err != nil {
+		return
+	}
+	key := request.getKey()
+
+	result := &ZSetRangeResult{
+		PageNo:   request.PageNo,
+		PageSize: request.PageSize,
+	}
+	if result.PageNo <= 0 {
+		result.PageNo = 1
+	}
+	if result.PageSize <= 0 {
+		result.PageSize = 100
+	}
+	offset := (result.PageNo - 1) * result.PageSize
+
+	var list []goRedis.Z
+	byScore := request.Min != "" || request.Max != ""
+	if byScore {
+		rangeBy := &goRedis.ZRangeBy{
+			Min:    request.Min,
+			Max:    request.Max,
+			Offset: offset,
+			Count:  result.PageSize,
+		}
+		if rangeBy.Min == "" {
+			rangeBy.Min = "-inf"
+		}
+		if rangeBy.Max == "" {
+			rangeBy.Max = "+inf"
+		}
+		result.Total, err = client.ZCount(param.Ctx, key, rangeBy.Min, rangeBy.Max).Result()
+		if err != nil {
+			return
+		}
+		if request.Reverse {
+			list, err = client.ZRevRangeByScoreWithScores(param.Ctx, key, rangeBy).Result()
+		} else {
+			list, err = client.ZRangeByScoreWithScores(param.Ctx, key, rangeBy).Result()
+		}
+	} else {
+		result.Total, err = client.ZCard(param.Ctx, key).Result()
+		if err != nil {
+			return
+		}
+		stop := offset + result.PageSize - 1
+		if request.Reverse {
+			list, err = client.ZRevRangeWithScores(param.Ctx, key, offset, stop).Result()
+		} else {
+			list, err = client.ZRangeWithScores(param.Ctx, key, offset, stop).Result()
+		}
+	}
+	if err != nil {
+		return
+	}
+	for i, one := range list {
+		member := &ZSetMember{
+			Score: one.Score,
+		}
+		member.Member, _ = one.Member.(string)
+		// 按 排名 查询 时 返回 排名，从 0 开始
+		if !byScore {
+			member.Rank = offset + int64(i)
+		}
+		result.MemberList = append(result.MemberList, member)
+	}
+	res = result
+	return
+}
@@ -9,6 +9,34 @@ import (
 	"teamide/pkg/base"
 )
 
+var (
+	// protectedExtendTypes 由 对应 功能 接口 维护 的 扩展 类型，通用 扩展 接口 不能 修改 和 删除
+	protectedExtendTypes = map[string]bool{}
+)
+
+// AddProtectedExtendType 添加 受 保护 的 扩展 类型，在 init 中 调用
+func AddProtectedExtendType(extendType string) {
+	protectedExtendTypes[extendType] = true
+}
+
+// checkExtendProtected 校验 扩展 类型 及 已 保存 扩展 的 类型 是否 受 保护
+func (this_ *ToolboxApi) checkExtendProtected(extendType string, extendId int64) (err error) {
+	if extendId != 0 {
+		find, e := this_.ToolboxService.GetExtend(extendId)
+		if e != nil {
+			err = e
+			return
+		}
+		if find != nil {
+			extendType = find.ExtendType
+		}
+	}
+	if protectedExtendTypes[extendType] {
+		err = errors.New("扩展类型[" + extendType + "]不能通过该接口修改")
+	}
+	return
+}
+
 func (this_ *ToolboxApi) extendGet(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
 
 	request := &ToolboxExtendModel{}
@@ -52,6 +80,11 @@ func (this_ *ToolboxApi) extendSave(requestBean *base.RequestBean, c *gin.Contex
 		return
 	}
 
+	err = this_.checkExtendProtected(request.ExtendType, request.ExtendId)
+	if err != nil {
+		return
+	}
+
 	request.UserId = requestBean.JWT.UserId
 	err = this_.ToolboxService.SaveExtend(request)
 	if err != nil {
@@ -68,6 +101,11 @@ func (this_ *ToolboxApi) extendDelete(requestBean *base.RequestBean, c *gin.Cont
 		return
 	}
 
+	err = this_.checkExtendProtected("", request.ExtendId)
+	if err != nil {
+		return
+	}
+
 	res, err = this_.ToolboxService.DeleteExtend(request.ExtendId)
 	if err != nil {
 		return
@@ -0,0 +1,76 @@
+package module_toolbox
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// toolboxSecretFile 服务端 密钥 文件，位于 数据 目录，不通过 接口 暴露
+const toolboxSecretFile = "toolbox-secret.key"
+
+var (
+	toolboxSecretLocker sync.Mutex
+	toolboxSecretKey    []byte
+)
+
+// GetToolboxSecret 获取 工具 的 密钥，由 服务端 密钥 与 工具 ID 派生，每个 工具 不同
+func (this_ *ToolboxService) GetToolboxSecret(toolboxId int64) (res []byte, err error) {
+	if toolboxId == 0 {
+		err = errors.New("工具ID不能为空")
+		return
+	}
+	key, err := this_.getServerSecret()
+	if err != nil {
+		return
+	}
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(fmt.Sprint("toolbox:", toolboxId)))
+	res = mac.Sum(nil)
+	return
+}
+
+// getServerSecret 读取 服务端 密钥，不存在 时 随机 生成 并 保存
+func (this_ *ToolboxService) getServerSecret() (res []byte, err error) {
+	toolboxSecretLocker.Lock()
+	defer toolboxSecretLocker.Unlock()
+
+	if toolboxSecretKey != nil {
+		res = toolboxSecretKey
+		return
+	}
+	path := this_.ServerConfig.Server.Data + toolboxSecretFile
+	bs, err := os.ReadFile(path)
+	if err == nil {
+		res, err = hex.DecodeString(strings.TrimSpace(string(bs)))
+		if err != nil || len(res) < 32 {
+			err = errors.New("服务端密钥文件[" + path + "]格式错误")
+			return
+		}
+		toolboxSecretKey = res
+		return
+	}
+	if !os.IsNotExist(err) {
+		return
+	}
+	res = make([]byte, 32)
+	if _, err = rand.Read(res); err != nil {
+		return
+	}
+	if err = os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return
+	}
+	err = os.WriteFile(path, []byte(hex.EncodeToString(res)), 0600)
+	if err != nil {
+		return
+	}
+	toolboxSecretKey = res
+	return
+}
@@ -522,6 +522,47 @@ func databaseWorker() *ToolboxType {
 				{Label: "TLS RootCert", Name: "tlsRootCert", Type: "file", VIf: `type == 'mysql' && tlsConfig == 'custom'`},
 				{Label: "TLS Client Cert", Name: "tlsClientCert", Type: "file", VIf: `type == 'mysql' && tlsConfig == 'custom'`},
 				{Label: "TLS Client Key", Name: "tlsClientKey", Type: "file", VIf: `type == 'mysql' && tlsConfig == 'custom'`},
+				{Label: "默认语句超时（秒，0 不限制）", Name: "statementTimeout", IsNumber: true, DefaultValue: 0},
+				{Label: "生产保护（删除、清空、结构变更、无条件修改等操作需输入工具名称确认）", Name: "protected", Type: "switch", DefaultValue: false},
+				{
+					Label: "脱敏规则（字段支持 字段名、表名.字段名，可使用 * 通配）", Name: "maskingRules", Type: "list",
+					Fields: []*form.Field{
+						{Label: "字段", Name: "column"},
+						{Label: "方式", Name: "method", DefaultValue: "partial", Type: "select",
+							Options: []*form.Option{
+								{Text: "部分遮盖（如 138****1234）", Value: "partial"},
+								{Text: "哈希", Value: "hash"},
+								{Text: "固定值", Value: "fixed"},
+								{Text: "置空", Value: "nullify"},
+								{Text: "保留格式伪造", Value: "fake"},
+							},
+						},
+						{Label: "参数（部分遮盖：保留前后位数 如 3,4；固定值：替换值）", Name: "value"},
+					},
+				},
+				{Label: "SQL分析大表行数（超过该行数的表为大表，默认 100000）", Name: "largeTableRows", IsNumber: true, DefaultValue: 100000},
+				{
+					Label: "SQL分析规则（未配置的规则为警告）", Name: "analyzeRules", Type: "list",
+					Fields: []*form.Field{
+						{Label: "规则", Name: "rule", Type: "select",
+							Options: []*form.Option{
+								{Text: "没有WHERE条件的修改或删除", Value: "noWhere"},
+								{Text: "大表查询全部字段", Value: "selectStar"},
+								{Text: "隐式类型转换", Value: "implicitConversion"},
+								{Text: "查询没有限制行数", Value: "noLimit"},
+								{Text: "LIKE以通配符开头", Value: "leadingLike"},
+								{Text: "笛卡尔积关联", Value: "cartesianJoin"},
+							},
+						},
+						{Label: "级别", Name: "level", DefaultValue: "warn", Type: "select",
+							Options: []*form.Option{
+								{Text: "关闭", Value: "off"},
+								{Text: "警告", Value: "warn"},
+								{Text: "阻止执行", Value: "block"},
+							},
+						},
+					},
+				},
 			},
 		},
 	}
@@ -17,17 +17,21 @@ func (this_ *Api) checkPower(api *base.ApiWorker, JWT *base.JWTBean, c *gin.Cont
 	if !this_.IsServer && api.Power.StandAlone {
 		return true
 	}
-	if !api.Power.ShouldPower {
+	shouldPower := api.Power.ShouldPower
+	for _, power := range api.AlsoPowers {
+		shouldPower = shouldPower || power.ShouldPower
+	}
+	if !shouldPower {
 		return true
 	}
 	ps := this_.getPowersByJWT(JWT)
 
-	find := false
-	for _, power := range ps {
-		if power == api.Power {
-			find = true
+	find := hasPower(ps, api.Power)
+	for _, power := range api.AlsoPowers {
+		if !find {
 			break
 		}
+		find = hasPower(ps, power)
 	}
 	if find {
 		return find
@@ -37,6 +41,18 @@ func (this_ *Api) checkPower(api *base.ApiWorker, JWT *base.JWTBean, c *gin.Cont
 	return find
 }
 
+func hasPower(ps []*base.PowerAction, power *base.PowerAction) bool {
+	if !power.ShouldPower {
+		return true
+	}
+	for _, one := range ps {
+		if one == power {
+			return true
+		}
+	}
+	return false
+}
+
 func (this_ *Api) getPowersByJWT(JWT *base.JWTBean) (powers []*base.PowerAction) {
 	var userId int64 = 0
 	if JWT != nil {
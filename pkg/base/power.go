@@ -8,7 +8,8 @@ type ApiWorker struct {
 	IsGet        bool
 	IsWebSocket  bool
 	IsUpload     bool
-	NotRecodeLog bool `json:"notRecodeLog"`
+	NotRecodeLog bool           `json:"notRecodeLog"`
+	AlsoPowers   []*PowerAction // 同时 需要 的 其它 权限
 }
 
 type PowerAction struct {